@@ -0,0 +1,95 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// Admin is a read-only JSON endpoint describing the runtime state of a Mux, for
+// use by operational tooling. A GET request returns an object containing the
+// route table (under "routes"), the names of the top-level middleware (under
+// "middleware"), and the value of each section added with Section.
+//
+// Other components with runtime state, such as a FaultInjector, rate limiter,
+// ban list, maintenance-mode switch or request statistics, can be exposed by
+// adding them as sections:
+//
+//	admin := flow.NewAdmin(mux, requireAdmin)
+//	admin.Section("faults", func() any { return faults.Config() })
+//	mux.Handle("/admin/state", admin, "GET")
+type Admin struct {
+	mux     *Mux
+	handler http.Handler
+
+	mu       sync.RWMutex
+	names    []string
+	sections map[string]func() any
+}
+
+// NewAdmin returns an Admin endpoint for m. Every request is passed through the
+// auth middleware, which should reject requests that aren't authorized to see
+// the state. NewAdmin panics if auth is nil, so that the endpoint can't be
+// exposed without protection by mistake.
+func NewAdmin(m *Mux, auth func(http.Handler) http.Handler) *Admin {
+	if auth == nil {
+		panic("flow: NewAdmin requires an auth middleware")
+	}
+
+	a := &Admin{mux: m, sections: map[string]func() any{}}
+	a.handler = auth(http.HandlerFunc(a.serve))
+
+	return a
+}
+
+// Section adds a named section to the endpoint's output. The function is called
+// for each request, and its result is encoded as JSON. Adding a section with
+// the same name as an existing one replaces it.
+func (a *Admin) Section(name string, fn func() any) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, exists := a.sections[name]; !exists {
+		a.names = append(a.names, name)
+	}
+	a.sections[name] = fn
+}
+
+func (a *Admin) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.handler.ServeHTTP(w, r)
+}
+
+func (a *Admin) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := map[string]any{
+		"routes":     a.mux.Routes(),
+		"middleware": middlewareNames(a.mux.middlewares),
+	}
+
+	a.mu.RLock()
+	for _, name := range a.names {
+		state[name] = a.sections[name]()
+	}
+	a.mu.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(state)
+}
+
+// middlewareNames returns the names of the functions for the given middleware,
+// without the module path (for example "flow.Recover.func1").
+func middlewareNames(mw []func(http.Handler) http.Handler) []string {
+	names := make([]string, len(mw))
+
+	for i, fn := range mw {
+		names[i] = funcName(fn)
+	}
+
+	return names
+}
@@ -0,0 +1,79 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdmin(t *testing.T) {
+	requireToken := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	m := New()
+	m.Use(Recover(nil))
+
+	admin := NewAdmin(m, requireToken)
+	admin.Section("maintenance", func() any { return false })
+	admin.Section("faults", func() any { return FaultConfig{Percent: 5} })
+
+	m.Handle("/admin/state", admin, "GET")
+	m.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {}, "GET").Tag("public")
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/admin/state", nil))
+
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected status 401 without credentials but was %d", rr.Code)
+	}
+
+	r := httptest.NewRequest("GET", "/admin/state", nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	m.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but was %d", rr.Code)
+	}
+
+	var state struct {
+		Routes      []RouteInfo    `json:"routes"`
+		Middleware  []string       `json:"middleware"`
+		Maintenance bool           `json:"maintenance"`
+		Faults      map[string]any `json:"faults"`
+	}
+
+	if err := json.NewDecoder(rr.Body).Decode(&state); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(state.Routes) != 2 || state.Routes[1].Pattern != "/users/:id" || state.Routes[1].Tags[0] != "public" {
+		t.Errorf("unexpected routes %+v", state.Routes)
+	}
+
+	if len(state.Middleware) != 1 || state.Middleware[0] != "flow.Recover.func1" {
+		t.Errorf("unexpected middleware %v", state.Middleware)
+	}
+
+	if state.Faults["percent"] != 5.0 {
+		t.Errorf("unexpected faults section %v", state.Faults)
+	}
+}
+
+func TestNewAdminRequiresAuth(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for nil auth middleware")
+		}
+	}()
+
+	NewAdmin(New(), nil)
+}
@@ -0,0 +1,102 @@
+package flow
+
+import (
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Affinity routes each client of a Proxy consistently to the same upstream
+// (sometimes called sticky sessions), which is useful when upstreams keep
+// per-client state in memory.
+//
+// If Cookie is set, the proxy sets a cookie with that name identifying the
+// upstream used for the first request, and later requests carrying the cookie
+// go to the same upstream. Otherwise, if Header is set, requests are assigned
+// to an upstream based on a hash of the header's value (such as an API key or
+// user ID), so that no state is needed on the client.
+//
+// Affinity is best-effort: if a client's upstream has been ejected by the
+// passive health check, the request is sent to a different upstream (and the
+// cookie, if any, is updated).
+type Affinity struct {
+	Cookie string `json:"cookie,omitempty"`
+	Header string `json:"header,omitempty"`
+
+	// CookieMaxAge sets the Max-Age of the cookie. If it is zero the cookie
+	// lasts until the browser is closed.
+	CookieMaxAge time.Duration `json:"cookie_max_age,omitempty"`
+}
+
+func (a *Affinity) pick(r *http.Request, healthy []*Upstream) *Upstream {
+	if a.Cookie != "" {
+		if c, err := r.Cookie(a.Cookie); err == nil {
+			for _, u := range healthy {
+				if u.id == c.Value {
+					return u
+				}
+			}
+		}
+	}
+
+	if a.Header != "" {
+		if key := r.Header.Get(a.Header); key != "" {
+			return rendezvous(key, healthy)
+		}
+	}
+
+	return nil
+}
+
+// setCookie adds the affinity cookie to the response, unless the request
+// already carried the right one.
+func (a *Affinity) setCookie(resp *http.Response, u *Upstream) {
+	if a.Cookie == "" {
+		return
+	}
+
+	if c, err := resp.Request.Cookie(a.Cookie); err == nil && c.Value == u.id {
+		return
+	}
+
+	cookie := &http.Cookie{
+		Name:     a.Cookie,
+		Value:    u.id,
+		Path:     "/",
+		MaxAge:   int(a.CookieMaxAge.Seconds()),
+		Secure:   resp.Request.Header.Get("X-Forwarded-Proto") == "https",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	resp.Header.Add("Set-Cookie", cookie.String())
+}
+
+// rendezvous chooses an upstream for the key using rendezvous (highest random
+// weight) hashing, so that when an upstream is ejected only the keys assigned
+// to it move elsewhere.
+func rendezvous(key string, upstreams []*Upstream) *Upstream {
+	var best *Upstream
+	var bestScore uint64
+
+	for _, u := range upstreams {
+		h := fnv.New64a()
+		h.Write([]byte(u.id))
+		h.Write([]byte(key))
+
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = u, score
+		}
+	}
+
+	return best
+}
+
+// upstreamID returns an opaque identifier for an upstream, which is stable
+// across restarts and doesn't reveal the upstream's address to clients.
+func upstreamID(u *url.URL) string {
+	h := fnv.New64a()
+	h.Write([]byte(u.String()))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
@@ -0,0 +1,102 @@
+package flow
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAffinityCookie(t *testing.T) {
+	upstreams := newTestUpstreams(t, 3)
+
+	m := New()
+	m.Proxy("/...", &Proxy{Upstreams: upstreams, Affinity: &Affinity{Cookie: "backend", CookieMaxAge: time.Hour}})
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	first := rr.Body.String()
+	setCookie := rr.Header().Get("Set-Cookie")
+	if !strings.HasPrefix(setCookie, "backend=") || !strings.Contains(setCookie, "Max-Age=3600") || !strings.Contains(setCookie, "HttpOnly") {
+		t.Fatalf("unexpected Set-Cookie header %q", setCookie)
+	}
+	cookie := strings.Split(setCookie, ";")[0]
+
+	for i := 0; i < 5; i++ {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Cookie", cookie)
+		m.ServeHTTP(rr, r)
+
+		if body := rr.Body.String(); body != first {
+			t.Errorf("expected request to go to upstream %s but it went to %s", first, body)
+		}
+		if setCookie := rr.Header().Get("Set-Cookie"); setCookie != "" {
+			t.Errorf("expected no Set-Cookie header but got %q", setCookie)
+		}
+	}
+
+	// When the upstream is ejected, the client is moved to another one.
+	upstreams[first[0]-'0'].ejectedUntil.Store(time.Now().Add(time.Hour).UnixNano())
+
+	rr = httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Cookie", cookie)
+	m.ServeHTTP(rr, r)
+
+	if rr.Body.String() == first {
+		t.Errorf("expected request not to go to ejected upstream %s", first)
+	}
+	if setCookie := rr.Header().Get("Set-Cookie"); setCookie == "" || strings.HasPrefix(setCookie, cookie+";") {
+		t.Errorf("expected the cookie to be replaced but got %q", setCookie)
+	}
+}
+
+func TestAffinityHeader(t *testing.T) {
+	m := New()
+	m.Proxy("/...", &Proxy{Upstreams: newTestUpstreams(t, 3), Affinity: &Affinity{Header: "X-User"}})
+
+	get := func(user string) string {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-User", user)
+		m.ServeHTTP(rr, r)
+		return rr.Body.String()
+	}
+
+	seen := map[string]bool{}
+	for _, user := range []string{"alice", "bob", "carol", "dave", "erin", "frank", "grace", "heidi"} {
+		first := get(user)
+		seen[first] = true
+
+		for i := 0; i < 3; i++ {
+			if got := get(user); got != first {
+				t.Errorf("%s: expected upstream %s but got %s", user, first, got)
+			}
+		}
+	}
+
+	if len(seen) < 2 {
+		t.Errorf("expected users to be spread over upstreams but all went to %v", seen)
+	}
+}
+
+func TestRendezvous(t *testing.T) {
+	upstreams := []*Upstream{{id: "a"}, {id: "b"}, {id: "c"}}
+
+	moved := 0
+	for i := 0; i < 100; i++ {
+		key := strings.Repeat("k", i)
+		before := rendezvous(key, upstreams)
+		after := rendezvous(key, []*Upstream{upstreams[0], upstreams[1]})
+
+		if before != upstreams[2] && before != after {
+			moved++
+		}
+	}
+
+	if moved != 0 {
+		t.Errorf("expected only keys on the removed upstream to move, but %d others moved", moved)
+	}
+}
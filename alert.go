@@ -0,0 +1,195 @@
+package flow
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// errorBuckets is the number of buckets each ErrorWatch window is divided
+// into. The window slides forward a bucket at a time.
+const errorBuckets = 10
+
+// ErrorRateAlert describes a route whose rate of error responses has crossed
+// one of the thresholds of an ErrorWatch.
+type ErrorRateAlert struct {
+	// Route is the pattern of the route (including its host pattern, if it
+	// has one).
+	Route string
+
+	// Class is 4 for client errors (4xx responses) or 5 for server errors
+	// (5xx responses).
+	Class int
+
+	// Requests and Errors are the numbers of requests to the route, and of
+	// those which got an error response of the class, within the window.
+	Requests int
+	Errors   int
+
+	// Rate is Errors divided by Requests.
+	Rate float64
+
+	// Resolved is false when the rate has risen above the threshold, and
+	// true when it has fallen back below it.
+	Resolved bool
+}
+
+// ErrorWatch is middleware which tracks the rate of 4xx and 5xx responses
+// for each route over a sliding window, and calls the functions registered
+// with OnAlert when the rate for a route rises above a threshold, and again
+// when it falls back below it. It can be used to send alerts, or to open a
+// circuit breaker:
+//
+//	watch := &flow.ErrorWatch{ServerErrors: 0.05}
+//	watch.OnAlert(func(a flow.ErrorRateAlert) {
+//		if !a.Resolved {
+//			pager.Send("%s is failing: %.0f%% server errors", a.Route, a.Rate*100)
+//		}
+//	})
+//	mux.Use(watch.Middleware)
+//
+// A panic in a later handler counts as a server error. Requests which don't
+// match a route aren't tracked. The rates are only checked when requests
+// arrive, so an alert isn't resolved until the route gets more requests.
+type ErrorWatch struct {
+	// Window is the length of the sliding window. If it is zero, one minute
+	// is used.
+	Window time.Duration
+
+	// ClientErrors and ServerErrors are the thresholds for the proportion of
+	// requests (between 0 and 1) which get 4xx and 5xx responses
+	// respectively. A threshold of zero isn't checked.
+	ClientErrors float64
+	ServerErrors float64
+
+	// MinRequests is the number of requests a route must get within the
+	// window before its rates are checked, so that a single failure on a
+	// quiet route doesn't cause an alert. If it is zero, 10 is used.
+	MinRequests int
+
+	mu        sync.Mutex
+	routes    map[string]*errorWindow
+	callbacks []func(ErrorRateAlert)
+}
+
+// errorWindow holds the counts for a route.
+type errorWindow struct {
+	buckets  [errorBuckets]errorBucket
+	alerting [2]bool
+}
+
+type errorBucket struct {
+	index    int64
+	requests int
+	errors   [2]int
+}
+
+// OnAlert registers a function to be called when an error rate crosses a
+// threshold. The functions are called in the order they were registered, by
+// the goroutine serving the request which crossed the threshold, so they
+// should return quickly.
+func (e *ErrorWatch) OnAlert(fn func(ErrorRateAlert)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.callbacks = append(e.callbacks, fn)
+}
+
+// Middleware tracks the responses of the next handler.
+func (e *ErrorWatch) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, _ := r.Context().Value(routeContextKey{}).(*Route)
+		if route == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+
+		defer func() {
+			if v := recover(); v != nil {
+				e.record(route.hostPattern+route.pattern, http.StatusInternalServerError)
+				panic(v)
+			}
+		}()
+
+		next.ServeHTTP(sw, r)
+
+		e.record(route.hostPattern+route.pattern, sw.status())
+	})
+}
+
+// record counts a response for the route, and calls the callbacks for any
+// threshold which has been crossed.
+func (e *ErrorWatch) record(route string, status int) {
+	window := e.Window
+	if window <= 0 {
+		window = time.Minute
+	}
+	minRequests := e.MinRequests
+	if minRequests <= 0 {
+		minRequests = 10
+	}
+	thresholds := [2]float64{e.ClientErrors, e.ServerErrors}
+
+	index := time.Now().UnixNano() / max(int64(window/errorBuckets), 1)
+
+	e.mu.Lock()
+
+	if e.routes == nil {
+		e.routes = map[string]*errorWindow{}
+	}
+	ew := e.routes[route]
+	if ew == nil {
+		ew = &errorWindow{}
+		e.routes[route] = ew
+	}
+
+	b := &ew.buckets[index%errorBuckets]
+	if b.index != index {
+		*b = errorBucket{index: index}
+	}
+	b.requests++
+	if class := status / 100; class == 4 || class == 5 {
+		b.errors[class-4]++
+	}
+
+	var requests int
+	var errors [2]int
+	for _, b := range ew.buckets {
+		if b.index > index-errorBuckets {
+			requests += b.requests
+			errors[0] += b.errors[0]
+			errors[1] += b.errors[1]
+		}
+	}
+
+	var alerts []ErrorRateAlert
+	for i, threshold := range thresholds {
+		if threshold <= 0 || requests < minRequests {
+			continue
+		}
+
+		rate := float64(errors[i]) / float64(requests)
+		if exceeded := rate > threshold; exceeded != ew.alerting[i] {
+			ew.alerting[i] = exceeded
+			alerts = append(alerts, ErrorRateAlert{
+				Route:    route,
+				Class:    i + 4,
+				Requests: requests,
+				Errors:   errors[i],
+				Rate:     rate,
+				Resolved: !exceeded,
+			})
+		}
+	}
+	callbacks := e.callbacks
+
+	e.mu.Unlock()
+
+	for _, alert := range alerts {
+		for _, fn := range callbacks {
+			fn(alert)
+		}
+	}
+}
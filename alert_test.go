@@ -0,0 +1,68 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestErrorWatch(t *testing.T) {
+	var alerts []ErrorRateAlert
+
+	watch := &ErrorWatch{Window: 300 * time.Millisecond, ClientErrors: 0.5, ServerErrors: 0.1}
+	watch.OnAlert(func(a ErrorRateAlert) { alerts = append(alerts, a) })
+
+	m := New()
+	m.Use(Recover(nil))
+	m.Use(watch.Middleware)
+	m.HandleFunc("/status/:code", func(w http.ResponseWriter, r *http.Request) {
+		switch Param(r.Context(), "code") {
+		case "404":
+			http.NotFound(w, r)
+		case "500":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "panic":
+			panic("boom")
+		}
+	}, "GET")
+
+	send := func(code string, n int) {
+		for i := 0; i < n; i++ {
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/status/"+code, nil))
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/unmatched", nil))
+		}
+	}
+
+	// Rates aren't checked until there are MinRequests requests, and unmatched
+	// requests aren't counted.
+	send("500", 9)
+	if len(alerts) != 0 {
+		t.Fatalf("expected no alerts but got %v", alerts)
+	}
+
+	send("panic", 1)
+	expected := []ErrorRateAlert{{Route: "/status/:code", Class: 5, Requests: 10, Errors: 10, Rate: 1}}
+	if !reflect.DeepEqual(alerts, expected) {
+		t.Fatalf("expected %v but got %v", expected, alerts)
+	}
+
+	// An alert isn't repeated while the rate stays above the threshold, but
+	// is resolved when it falls below it.
+	alerts = nil
+	send("200", 90)
+	expected = []ErrorRateAlert{{Route: "/status/:code", Class: 5, Requests: 100, Errors: 10, Rate: 0.1, Resolved: true}}
+	if !reflect.DeepEqual(alerts, expected) {
+		t.Fatalf("expected %v but got %v", expected, alerts)
+	}
+
+	// Old requests slide out of the window.
+	time.Sleep(350 * time.Millisecond)
+	alerts = nil
+	send("404", 10)
+	expected = []ErrorRateAlert{{Route: "/status/:code", Class: 4, Requests: 10, Errors: 10, Rate: 1}}
+	if !reflect.DeepEqual(alerts, expected) {
+		t.Fatalf("expected %v but got %v", expected, alerts)
+	}
+}
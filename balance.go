@@ -0,0 +1,187 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalanceStrategy is the way a Proxy with more than one upstream chooses which
+// upstream to send each request to.
+type BalanceStrategy int
+
+const (
+	// RoundRobin sends requests to each upstream in turn.
+	RoundRobin BalanceStrategy = iota
+	// LeastConnections sends each request to the upstream with the fewest
+	// requests in progress.
+	LeastConnections
+	// Weighted sends requests to each upstream in proportion to its Weight,
+	// spreading them out evenly rather than in bursts.
+	Weighted
+)
+
+// DefaultEjectDuration is how long an upstream is ejected for when a Proxy has
+// MaxFails set but no EjectDuration.
+const DefaultEjectDuration = 30 * time.Second
+
+// Upstream is a server which a Proxy can forward requests to.
+type Upstream struct {
+	URL *url.URL
+
+	// Weight is the relative share of requests the upstream receives when
+	// using the Weighted strategy. Values less than 1 are treated as 1.
+	Weight int
+
+	id           string
+	active       atomic.Int64
+	requests     atomic.Uint64
+	failures     atomic.Uint64
+	latency      atomic.Int64
+	ejectedUntil atomic.Int64
+
+	mu          sync.Mutex
+	consecutive int
+	current     int
+}
+
+// UpstreamStats is a snapshot of the metrics for an upstream, as returned by
+// Proxy.Stats.
+type UpstreamStats struct {
+	URL            string        `json:"url"`
+	Requests       uint64        `json:"requests"`
+	Failures       uint64        `json:"failures"`
+	Active         int64         `json:"active"`
+	AverageLatency time.Duration `json:"average_latency"`
+	Ejected        bool          `json:"ejected"`
+}
+
+// Stats returns the metrics for each of the proxy's upstreams, in the order
+// they were given. It can be exposed through an Admin section:
+//
+//	admin.Section("upstreams", func() any { return proxy.Stats() })
+func (p *Proxy) Stats() []UpstreamStats {
+	p.once.Do(p.init)
+
+	now := time.Now().UnixNano()
+	stats := make([]UpstreamStats, len(p.upstreams))
+
+	for i, u := range p.upstreams {
+		stats[i] = UpstreamStats{
+			URL:      u.URL.String(),
+			Requests: u.requests.Load(),
+			Failures: u.failures.Load(),
+			Active:   u.active.Load(),
+			Ejected:  u.ejectedUntil.Load() > now,
+		}
+		if stats[i].Requests > 0 {
+			stats[i].AverageLatency = time.Duration(u.latency.Load() / int64(stats[i].Requests))
+		}
+	}
+
+	return stats
+}
+
+type attemptContextKey struct{}
+
+func attemptFromContext(ctx context.Context) *proxyAttempt {
+	a, _ := ctx.Value(attemptContextKey{}).(*proxyAttempt)
+	return a
+}
+
+// pick chooses the upstream for a request using the proxy's strategy. Ejected
+// upstreams are skipped, unless every upstream has been ejected, in which case
+// they are all used rather than failing every request. If the proxy has an
+// Affinity which identifies an upstream for the request, that's used instead.
+func (p *Proxy) pick(r *http.Request) *Upstream {
+	if len(p.upstreams) == 1 {
+		return p.upstreams[0]
+	}
+
+	now := time.Now().UnixNano()
+	healthy := make([]*Upstream, 0, len(p.upstreams))
+	for _, u := range p.upstreams {
+		if u.ejectedUntil.Load() <= now {
+			healthy = append(healthy, u)
+		}
+	}
+	if len(healthy) == 0 {
+		healthy = p.upstreams
+	}
+
+	if p.Affinity != nil {
+		if u := p.Affinity.pick(r, healthy); u != nil {
+			return u
+		}
+	}
+
+	switch p.Strategy {
+	case LeastConnections:
+		best := healthy[0]
+		for _, u := range healthy[1:] {
+			if u.active.Load() < best.active.Load() {
+				best = u
+			}
+		}
+		return best
+	case Weighted:
+		return p.pickWeighted(healthy)
+	default:
+		n := p.next.Add(1) - 1
+		return healthy[n%uint64(len(healthy))]
+	}
+}
+
+// pickWeighted uses the smooth weighted round-robin algorithm from nginx.
+func (p *Proxy) pickWeighted(healthy []*Upstream) *Upstream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var best *Upstream
+	total := 0
+
+	for _, u := range healthy {
+		weight := max(u.Weight, 1)
+		total += weight
+		u.current += weight
+		if best == nil || u.current > best.current {
+			best = u
+		}
+	}
+	best.current -= total
+
+	return best
+}
+
+// report records the outcome of a request to an upstream for passive health
+// checking, ejecting the upstream after MaxFails consecutive failures.
+func (p *Proxy) report(u *Upstream, ok bool) {
+	if !ok {
+		u.failures.Add(1)
+	}
+
+	if p.MaxFails <= 0 {
+		return
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if ok {
+		u.consecutive = 0
+		return
+	}
+
+	u.consecutive++
+	if u.consecutive >= p.MaxFails {
+		u.consecutive = 0
+		eject := p.EjectDuration
+		if eject <= 0 {
+			eject = DefaultEjectDuration
+		}
+		u.ejectedUntil.Store(time.Now().Add(eject).UnixNano())
+	}
+}
@@ -0,0 +1,142 @@
+package flow
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestUpstreams(t *testing.T, n int) []*Upstream {
+	upstreams := make([]*Upstream, n)
+
+	for i := range upstreams {
+		i := i
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, i)
+		}))
+		t.Cleanup(srv.Close)
+
+		u, err := url.Parse(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		upstreams[i] = &Upstream{URL: u}
+	}
+
+	return upstreams
+}
+
+func proxyCounts(t *testing.T, p *Proxy, n int) map[string]int {
+	m := New()
+	m.Proxy("/...", p)
+
+	counts := map[string]int{}
+	for i := 0; i < n; i++ {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		counts[rr.Body.String()]++
+	}
+
+	return counts
+}
+
+func TestBalanceStrategies(t *testing.T) {
+	t.Run("round robin", func(t *testing.T) {
+		counts := proxyCounts(t, &Proxy{Upstreams: newTestUpstreams(t, 3)}, 9)
+
+		for _, key := range []string{"0", "1", "2"} {
+			if counts[key] != 3 {
+				t.Errorf("expected 3 requests to upstream %s but got %d", key, counts[key])
+			}
+		}
+	})
+
+	t.Run("weighted", func(t *testing.T) {
+		upstreams := newTestUpstreams(t, 2)
+		upstreams[0].Weight = 3
+
+		counts := proxyCounts(t, &Proxy{Upstreams: upstreams, Strategy: Weighted}, 8)
+
+		if counts["0"] != 6 || counts["1"] != 2 {
+			t.Errorf("expected a 6/2 split but got %v", counts)
+		}
+	})
+
+	t.Run("least connections", func(t *testing.T) {
+		upstreams := newTestUpstreams(t, 3)
+		upstreams[0].active.Add(2)
+		upstreams[2].active.Add(1)
+
+		p := &Proxy{Upstreams: upstreams, Strategy: LeastConnections}
+		p.once.Do(p.init)
+
+		if u := p.pick(httptest.NewRequest("GET", "/", nil)); u != upstreams[1] {
+			t.Errorf("expected upstream 1 but got %s", u.URL)
+		}
+	})
+}
+
+func TestPassiveHealthCheck(t *testing.T) {
+	upstreams := newTestUpstreams(t, 2)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	upstreams[1].URL, _ = url.Parse(failing.URL)
+
+	p := &Proxy{Upstreams: upstreams, MaxFails: 2, EjectDuration: time.Hour}
+	counts := proxyCounts(t, p, 10)
+
+	if counts["0"] != 8 || counts[""] != 2 {
+		t.Errorf("expected the failing upstream to be ejected after 2 requests but got %v", counts)
+	}
+
+	stats := p.Stats()
+	if stats[0].Requests != 8 || stats[0].Failures != 0 || stats[0].Ejected {
+		t.Errorf("unexpected stats for healthy upstream: %+v", stats[0])
+	}
+	if stats[1].Requests != 2 || stats[1].Failures != 2 || !stats[1].Ejected {
+		t.Errorf("unexpected stats for failing upstream: %+v", stats[1])
+	}
+	if stats[0].Active != 0 {
+		t.Errorf("expected no active requests but got %d", stats[0].Active)
+	}
+}
+
+func TestAllUpstreamsEjected(t *testing.T) {
+	upstreams := newTestUpstreams(t, 2)
+	for _, u := range upstreams {
+		u.ejectedUntil.Store(time.Now().Add(time.Hour).UnixNano())
+	}
+
+	counts := proxyCounts(t, &Proxy{Upstreams: upstreams}, 4)
+
+	if counts["0"] != 2 || counts["1"] != 2 {
+		t.Errorf("expected requests to be spread over all upstreams but got %v", counts)
+	}
+}
+
+func TestProxyConnectionError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	target, _ := url.Parse(srv.URL)
+	srv.Close()
+
+	p := &Proxy{Target: target, MaxFails: 1}
+	m := New()
+	m.Proxy("/...", p)
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("expected status %d but got %d", http.StatusBadGateway, rr.Code)
+	}
+	if stats := p.Stats(); !stats[0].Ejected {
+		t.Errorf("expected upstream to be ejected: %+v", stats[0])
+	}
+}
@@ -0,0 +1,146 @@
+package flow
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// RouteBuilder builds a route one condition at a time, for routes which are
+// awkward to register with Handle because they depend on the host, request
+// headers or scheme as well as the path. Create one with Mux.NewRoute, and
+// finish it with Handler or HandlerFunc:
+//
+//	mux.NewRoute().
+//		Methods("GET", "POST").
+//		Host("api.example.com").
+//		Path("/items/:id").
+//		Headers("Accept", "application/json").
+//		Handler(itemsAPI)
+//
+// A request which doesn't meet the header or scheme conditions is treated as
+// if the route didn't match its path, so it's tried against the later routes
+// (and isn't counted when building the Allow header for a 405 Method Not
+// Allowed response). Match and MatchRequest check the conditions in the same
+// way.
+type RouteBuilder struct {
+	mux     *Mux
+	methods []string
+	path    string
+	pathSet bool
+	host    string
+	headers []string
+	schemes []string
+}
+
+// NewRoute returns a RouteBuilder for a route which is registered with m (with
+// its middleware and settings) when the builder's Handler method is called.
+func (m *Mux) NewRoute() *RouteBuilder {
+	return &RouteBuilder{mux: m}
+}
+
+// Methods sets the methods of the route. If it isn't called, the default
+// methods are used (see Handle).
+func (b *RouteBuilder) Methods(methods ...string) *RouteBuilder {
+	b.methods = append(b.methods, methods...)
+	return b
+}
+
+// Path sets the pattern for the request path, which has the same syntax as the
+// patterns given to Handle.
+func (b *RouteBuilder) Path(pattern string) *RouteBuilder {
+	b.path, b.pathSet = pattern, true
+	return b
+}
+
+// Host restricts the route to requests for a host, using a pattern with the
+// same syntax as Mux.Host.
+func (b *RouteBuilder) Host(pattern string) *RouteBuilder {
+	b.host = pattern
+	return b
+}
+
+// Headers restricts the route to requests with the given headers, which are
+// listed as name and value pairs. An empty value matches any request which has
+// the header. Values are compared exactly, and a header matches if any of its
+// values is the one given. Headers panics if it's given an odd number of
+// arguments.
+func (b *RouteBuilder) Headers(pairs ...string) *RouteBuilder {
+	if len(pairs)%2 != 0 {
+		panic("flow: route builder headers must be given as name and value pairs")
+	}
+
+	b.headers = append(b.headers, pairs...)
+	return b
+}
+
+// Schemes restricts the route to requests using one of the given URL schemes,
+// "http" or "https". A request's scheme is taken from its URL if it has one,
+// and is otherwise "https" if the request was received over TLS. Behind a
+// proxy which terminates TLS, use Headers("X-Forwarded-Proto", "https")
+// instead.
+func (b *RouteBuilder) Schemes(schemes ...string) *RouteBuilder {
+	for _, scheme := range schemes {
+		b.schemes = append(b.schemes, strings.ToLower(scheme))
+	}
+	return b
+}
+
+// Handler registers the route with h as its handler, and returns it. It panics
+// if Path hasn't been called, or if the route is invalid (as Handle does).
+func (b *RouteBuilder) Handler(h http.Handler) *Route {
+	if !b.pathSet {
+		panic("flow: route builder requires a path")
+	}
+
+	// The route is created first and only added to the table once its
+	// conditions are attached, so that it's never served without them.
+	var route *Route
+	register := func(m *Mux) {
+		var err error
+		if route, err = m.newRoute(b.path, h, b.methods); err != nil {
+			panic(err.Error())
+		}
+	}
+
+	if b.host != "" {
+		b.mux.Host(b.host, register)
+	} else {
+		register(b.mux)
+	}
+
+	for i := 0; i < len(b.headers); i += 2 {
+		name, value := http.CanonicalHeaderKey(b.headers[i]), b.headers[i+1]
+		route.conditions = append(route.conditions, func(r *http.Request) bool {
+			values, ok := r.Header[name]
+			return ok && (value == "" || slices.Contains(values, value))
+		})
+	}
+
+	if len(b.schemes) > 0 {
+		schemes := b.schemes
+		route.conditions = append(route.conditions, func(r *http.Request) bool {
+			return slices.Contains(schemes, requestScheme(r))
+		})
+	}
+
+	b.mux.routes.add(route)
+
+	return route
+}
+
+// HandlerFunc registers the route with fn as its handler, and returns it.
+func (b *RouteBuilder) HandlerFunc(fn http.HandlerFunc) *Route {
+	return b.Handler(fn)
+}
+
+// requestScheme returns the URL scheme of a request.
+func requestScheme(r *http.Request) string {
+	if r.URL.Scheme != "" {
+		return strings.ToLower(r.URL.Scheme)
+	}
+	if r.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
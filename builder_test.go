@@ -0,0 +1,128 @@
+package flow
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRouteBuilder(t *testing.T) {
+	hf := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body + " " + Param(r.Context(), "id")))
+		}
+	}
+
+	m := New()
+	m.NewRoute().
+		Methods("GET", "POST").
+		Host("api.example.com").
+		Path("/items/:id").
+		Headers("Accept", "application/json").
+		HandlerFunc(hf("api json"))
+	m.NewRoute().Host("api.example.com").Path("/items/:id").Methods("GET").HandlerFunc(hf("api"))
+	m.NewRoute().Path("/secure").Schemes("HTTPS").HandlerFunc(hf("secure"))
+	m.NewRoute().Path("/beta").Headers("X-Beta", "").Methods("GET").HandlerFunc(hf("beta"))
+	m.NewRoute().Path("/beta").Methods("PUT").HandlerFunc(hf("beta put"))
+
+	var tests = []struct {
+		RequestMethod string
+		RequestURL    string
+		Header        http.Header
+		TLS           bool
+
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{"GET", "http://api.example.com/items/1", http.Header{"Accept": {"application/json"}}, false, http.StatusOK, "api json 1"},
+		{"POST", "http://api.example.com/items/1", http.Header{"Accept": {"application/json"}}, false, http.StatusOK, "api json 1"},
+		{"GET", "http://api.example.com/items/1", http.Header{"Accept": {"text/html"}}, false, http.StatusOK, "api 1"},
+		{"POST", "http://api.example.com/items/1", nil, false, http.StatusMethodNotAllowed, ""},
+		{"GET", "http://www.example.com/items/1", http.Header{"Accept": {"application/json"}}, false, http.StatusNotFound, ""},
+		{"GET", "/secure", nil, true, http.StatusOK, "secure "},
+		{"GET", "/secure", nil, false, http.StatusNotFound, ""},
+		{"GET", "/beta", http.Header{"X-Beta": {"1"}}, false, http.StatusOK, "beta "},
+		{"GET", "/beta", nil, false, http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.RequestMethod, test.RequestURL, nil)
+		for name, values := range test.Header {
+			r.Header[name] = values
+		}
+		if test.TLS {
+			r.TLS = &tls.ConnectionState{}
+		}
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.RequestMethod, test.RequestURL, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedStatus == http.StatusOK && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s %s: expected body %q but was %q", test.RequestMethod, test.RequestURL, test.ExpectedBody, rr.Body.String())
+		}
+	}
+
+	// Only the PUT route counts towards the Allow header when the header
+	// condition isn't met.
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/beta", nil))
+	if allow := rr.Header().Get("Allow"); allow != "PUT, OPTIONS" {
+		t.Errorf("expected Allow header %q but was %q", "PUT, OPTIONS", allow)
+	}
+}
+
+func TestRouteBuilderWhileServing(t *testing.T) {
+	m := New()
+
+	var unconditional atomic.Int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+		go func() {
+			defer wg.Done()
+			m.NewRoute().Path("/").Methods("GET").Headers("X-Version", "2").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("X-Version") != "2" {
+					unconditional.Add(1)
+				}
+			})
+		}()
+	}
+	wg.Wait()
+
+	if n := unconditional.Load(); n != 0 {
+		t.Errorf("expected no requests to be served without the header condition but got %d", n)
+	}
+}
+
+func TestRouteBuilderPanics(t *testing.T) {
+	var tests = []struct {
+		Name  string
+		Build func(*RouteBuilder)
+	}{
+		{"no path", func(b *RouteBuilder) { b.Handler(http.NotFoundHandler()) }},
+		{"odd headers", func(b *RouteBuilder) { b.Headers("Accept") }},
+		{"invalid pattern", func(b *RouteBuilder) { b.Path("/.../...").Handler(http.NotFoundHandler()) }},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", test.Name)
+				}
+			}()
+			test.Build(New().NewRoute())
+		}()
+	}
+}
@@ -0,0 +1,48 @@
+package flow
+
+import "net/http"
+
+// RouteSpec describes a route for HandleAll.
+type RouteSpec struct {
+	Pattern string
+	Handler http.Handler
+	Methods []string
+}
+
+// HandleAll registers a route for each spec, as if Handle had been called for
+// each one in turn, and returns the routes in the same order. It's intended for
+// applications such as gateways which register many thousands of routes
+// generated from configuration: the routes and their parsed patterns are
+// allocated in a few large blocks, and they are added to the route table in a
+// single step, so registration makes far fewer allocations than calling Handle
+// repeatedly.
+//
+// The routes are matched in the order of specs, like routes registered with
+// Handle. HandleAll panics if any of the specs is invalid, in which case none
+// of the routes are registered. Because the routes share memory, the memory
+// used by a route removed with Remove is only freed once all of the routes
+// registered in the same call have been removed.
+func (m *Mux) HandleAll(specs []RouteSpec) []*Route {
+	size := 0
+	for _, spec := range specs {
+		size += countSegments(m.fullPattern(spec.Pattern))
+	}
+
+	arena := make([]Route, len(specs))
+	segments := make([]segment, size)
+	routes := make([]*Route, len(specs))
+
+	for i, spec := range specs {
+		n := countSegments(m.fullPattern(spec.Pattern))
+
+		if err := m.initRoute(&arena[i], segments[:n], spec.Pattern, spec.Handler, spec.Methods); err != nil {
+			panic(err.Error())
+		}
+		segments = segments[n:]
+		routes[i] = &arena[i]
+	}
+
+	m.routes.add(routes...)
+
+	return routes
+}
@@ -0,0 +1,113 @@
+package flow
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleAll(t *testing.T) {
+	reply := func(s string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(s + " " + Param(r.Context(), "id")))
+		})
+	}
+
+	m := New()
+	var routes []*Route
+	m.Route("/api", func(m *Mux) {
+		routes = m.HandleAll([]RouteSpec{
+			{Pattern: "/users/new", Handler: reply("new"), Methods: []string{"GET"}},
+			{Pattern: "/users/:id", Handler: reply("user"), Methods: []string{"GET"}},
+			{Pattern: "/users/:id", Handler: reply("update"), Methods: []string{"PUT"}},
+			{Pattern: "", Handler: reply("root")},
+		})
+	})
+
+	if len(routes) != 4 || routes[1].pattern != "/api/users/:id" {
+		t.Fatalf("unexpected routes %v", routes)
+	}
+
+	var tests = []struct {
+		Method      string
+		RequestPath string
+
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{"GET", "/api/users/new", http.StatusOK, "new "},
+		{"GET", "/api/users/42", http.StatusOK, "user 42"},
+		{"HEAD", "/api/users/42", http.StatusOK, "user 42"},
+		{"PUT", "/api/users/42", http.StatusOK, "update 42"},
+		{"DELETE", "/api/users/42", http.StatusMethodNotAllowed, ""},
+		{"POST", "/api", http.StatusOK, "root "},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.Method, test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.Method, test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedStatus == http.StatusOK && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s %s: expected body %q but was %q", test.Method, test.RequestPath, test.ExpectedBody, rr.Body.String())
+		}
+	}
+}
+
+func TestHandleAllInvalid(t *testing.T) {
+	m := New()
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected panic for an invalid method")
+			}
+		}()
+
+		m.HandleAll([]RouteSpec{
+			{Pattern: "/a", Handler: http.NotFoundHandler()},
+			{Pattern: "/b", Handler: http.NotFoundHandler(), Methods: []string{"GTE"}},
+		})
+	}()
+
+	if n := len(m.routes.load()); n != 0 {
+		t.Errorf("expected no routes to be registered but got %d", n)
+	}
+}
+
+func benchmarkSpecs() []RouteSpec {
+	specs := make([]RouteSpec, 10000)
+	for i := range specs {
+		specs[i] = RouteSpec{
+			Pattern: fmt.Sprintf("/gateway/service%d/v1/:resource/:id", i),
+			Handler: http.NotFoundHandler(),
+			Methods: []string{"GET", "POST"},
+		}
+	}
+
+	return specs
+}
+
+func BenchmarkHandle(b *testing.B) {
+	specs := benchmarkSpecs()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		m := New()
+		for _, spec := range specs {
+			m.Handle(spec.Pattern, spec.Handler, spec.Methods...)
+		}
+	}
+}
+
+func BenchmarkHandleAll(b *testing.B) {
+	specs := benchmarkSpecs()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		New().HandleAll(specs)
+	}
+}
@@ -0,0 +1,444 @@
+package flow
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseCache is an in-memory shared cache for the responses to GET and HEAD
+// requests sent through a Proxy (see Proxy.Cache). It follows the caching
+// headers sent by the upstream:
+//
+//   - A response is stored only if it has a 200, 203, 301, 404 or 410 status,
+//     no Set-Cookie header, and either an explicit lifetime (from the s-maxage
+//     or max-age Cache-Control directives, or the Expires header) or a
+//     validator (an ETag or Last-Modified header). Responses marked no-store
+//     or private, or with "Vary: *", are never stored.
+//   - Fresh entries are served without contacting the upstream. Stale entries,
+//     and entries marked no-cache, are revalidated with a conditional request
+//     to the upstream, and served again if it responds 304 Not Modified.
+//   - Requests with an Authorization header bypass the cache, and requests with
+//     "Cache-Control: no-cache" or "max-age=0" always revalidate.
+//
+// Responses from the cache have an Age header, and an X-Cache header which is
+// HIT, REVALIDATED or MISS.
+//
+// Entries can be removed with Purge and PurgeRoute, or over HTTP by mounting the
+// ResponseCache itself as an admin endpoint: a POST request with a url or route
+// form value purges the matching entries, and responds with the number purged
+// as JSON. The admin endpoint should be protected by authentication
+// middleware.
+type ResponseCache struct {
+	maxEntries   int
+	maxEntrySize int64
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// NewResponseCache returns a ResponseCache holding at most maxEntries
+// responses, each with a body of at most maxEntrySize bytes. When the cache is
+// full, the least recently used entry is evicted.
+func NewResponseCache(maxEntries int, maxEntrySize int64) *ResponseCache {
+	return &ResponseCache{
+		maxEntries:   maxEntries,
+		maxEntrySize: maxEntrySize,
+		entries:      map[string]*list.Element{},
+		lru:          list.New(),
+	}
+}
+
+type cacheEntry struct {
+	key    string
+	host   string
+	uri    string
+	route  string
+	status int
+	header http.Header
+	body   []byte
+	vary   map[string]string
+
+	stored     time.Time
+	initialAge time.Duration
+	lifetime   time.Duration
+	noCache    bool
+}
+
+func (e *cacheEntry) age(now time.Time) time.Duration {
+	return e.initialAge + now.Sub(e.stored)
+}
+
+func (e *cacheEntry) fresh(now time.Time) bool {
+	return !e.noCache && e.age(now) < e.lifetime
+}
+
+func (e *cacheEntry) varyMatches(r *http.Request) bool {
+	for name, value := range e.vary {
+		if r.Header.Get(name) != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cacheLookup is the result of looking up a request in the cache, which is
+// carried through each proxy attempt.
+type cacheLookup struct {
+	cache        *ResponseCache
+	key          string
+	host         string
+	uri          string
+	stale        *cacheEntry
+	revalidating bool
+}
+
+// serve sends a fresh response from the cache if there is one, and returns
+// true. Otherwise it returns the lookup to use when forwarding the request.
+func (c *ResponseCache) serve(w http.ResponseWriter, r *http.Request) (cacheLookup, bool) {
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.Header.Get("Authorization") != "" {
+		return cacheLookup{}, false
+	}
+
+	lookup := cacheLookup{cache: c, host: r.Host, uri: r.URL.RequestURI()}
+	lookup.key = lookup.host + lookup.uri
+
+	c.mu.Lock()
+	var e *cacheEntry
+	if el, ok := c.entries[lookup.key]; ok {
+		e = el.Value.(*cacheEntry)
+		c.lru.MoveToFront(el)
+	}
+	c.mu.Unlock()
+
+	if e == nil || !e.varyMatches(r) {
+		return lookup, false
+	}
+
+	now := time.Now()
+	reqDirectives := parseCacheControl(r.Header.Values("Cache-Control"))
+	_, noCache := reqDirectives["no-cache"]
+	if !e.fresh(now) || noCache || reqDirectives["max-age"] == "0" {
+		if e.header.Get("ETag") != "" || e.header.Get("Last-Modified") != "" {
+			lookup.stale = e
+		}
+		return lookup, false
+	}
+
+	e.write(w, r, now, "HIT")
+	return lookup, true
+}
+
+func (e *cacheEntry) write(w http.ResponseWriter, r *http.Request, now time.Time, result string) {
+	h := w.Header()
+	for name, values := range e.header {
+		h[name] = append([]string(nil), values...)
+	}
+	h.Set("Age", strconv.Itoa(int(e.age(now).Seconds())))
+	h.Set("X-Cache", result)
+
+	if etag := e.header.Get("ETag"); etag != "" && e.status == http.StatusOK && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(e.status)
+	if r.Method != http.MethodHead {
+		w.Write(e.body)
+	}
+}
+
+// prepare adds validators from a stale entry to the request to the upstream,
+// unless the client has sent its own conditional headers.
+func (l *cacheLookup) prepare(out *http.Request) {
+	l.revalidating = false
+
+	if l.stale == nil || out.Header.Get("If-None-Match") != "" || out.Header.Get("If-Modified-Since") != "" {
+		return
+	}
+
+	if etag := l.stale.header.Get("ETag"); etag != "" {
+		out.Header.Set("If-None-Match", etag)
+	}
+	if modified := l.stale.header.Get("Last-Modified"); modified != "" {
+		out.Header.Set("If-Modified-Since", modified)
+	}
+	l.revalidating = true
+}
+
+// handle processes a response from the upstream: a 304 Not Modified response
+// to a revalidation is replaced with the refreshed cached response, and
+// cacheable responses are stored as their body is read.
+func (l *cacheLookup) handle(resp *http.Response) {
+	if l.cache == nil {
+		return
+	}
+
+	now := time.Now()
+	resp.Header.Set("X-Cache", "MISS")
+
+	if l.revalidating && resp.StatusCode == http.StatusNotModified {
+		e := l.cache.refresh(l.stale, resp.Header, now)
+
+		resp.StatusCode = e.status
+		resp.Header = e.header.Clone()
+		resp.Header.Set("Age", strconv.Itoa(int(e.age(now).Seconds())))
+		resp.Header.Set("X-Cache", "REVALIDATED")
+		resp.Body = io.NopCloser(bytes.NewReader(e.body))
+		resp.ContentLength = int64(len(e.body))
+		return
+	}
+
+	in := resp.Request
+	if in.Method != http.MethodGet {
+		return
+	}
+
+	e := newCacheEntry(resp, now)
+	if e == nil || resp.ContentLength > l.cache.maxEntrySize {
+		// The response replaces any stale entry, even though it can't be
+		// stored itself.
+		if l.stale != nil {
+			l.cache.purge(func(e *cacheEntry) bool { return e.key == l.key })
+		}
+		return
+	}
+	e.key, e.host, e.uri = l.key, l.host, l.uri
+	if route, _ := in.Context().Value(routeContextKey{}).(*Route); route != nil {
+		e.route = route.pattern
+	}
+
+	resp.Body = &cachingBody{ReadCloser: resp.Body, entry: e, cache: l.cache}
+}
+
+func newCacheEntry(resp *http.Response, now time.Time) *cacheEntry {
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+	default:
+		return nil
+	}
+
+	if resp.Header.Get("Set-Cookie") != "" {
+		return nil
+	}
+
+	directives := parseCacheControl(resp.Header.Values("Cache-Control"))
+	if _, ok := directives["no-store"]; ok {
+		return nil
+	}
+	if _, ok := directives["private"]; ok {
+		return nil
+	}
+
+	e := &cacheEntry{status: resp.StatusCode, header: resp.Header.Clone(), stored: now, vary: map[string]string{}}
+	e.header.Del("X-Cache")
+	e.update(directives, resp.Header, now)
+
+	if e.lifetime <= 0 && e.header.Get("ETag") == "" && e.header.Get("Last-Modified") == "" {
+		return nil
+	}
+
+	for _, value := range resp.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "*" {
+				return nil
+			}
+			if name != "" {
+				e.vary[name] = resp.Request.Header.Get(name)
+			}
+		}
+	}
+
+	return e
+}
+
+// update sets the freshness of the entry from the response headers.
+func (e *cacheEntry) update(directives map[string]string, h http.Header, now time.Time) {
+	_, e.noCache = directives["no-cache"]
+
+	e.initialAge = 0
+	if age, err := strconv.Atoi(h.Get("Age")); err == nil && age > 0 {
+		e.initialAge = time.Duration(age) * time.Second
+	}
+
+	e.lifetime = 0
+	if v, ok := directives["s-maxage"]; ok {
+		e.lifetime = parseSeconds(v)
+	} else if v, ok := directives["max-age"]; ok {
+		e.lifetime = parseSeconds(v)
+	} else if expires := h.Get("Expires"); expires != "" {
+		if t, err := http.ParseTime(expires); err == nil {
+			date := now
+			if d, err := http.ParseTime(h.Get("Date")); err == nil {
+				date = d
+			}
+			e.lifetime = t.Sub(date)
+		}
+	}
+}
+
+// refresh updates a stale entry with the headers from a 304 Not Modified
+// response, and returns the updated entry. The entry is replaced rather than
+// modified, as it may be being served to other requests.
+func (c *ResponseCache) refresh(stale *cacheEntry, h http.Header, now time.Time) *cacheEntry {
+	e := *stale
+	e.header = stale.header.Clone()
+	for _, name := range []string{"Cache-Control", "Expires", "ETag", "Last-Modified", "Date"} {
+		if values := h.Values(name); len(values) > 0 {
+			e.header[name] = values
+		}
+	}
+	e.stored = now
+	e.update(parseCacheControl(e.header.Values("Cache-Control")), h, now)
+
+	c.store(&e)
+	return &e
+}
+
+func (c *ResponseCache) store(e *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[e.key]; ok {
+		el.Value = e
+		c.lru.MoveToFront(el)
+		return
+	}
+
+	c.entries[e.key] = c.lru.PushFront(e)
+
+	for c.maxEntries > 0 && c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// cachingBody copies a response body as it is read, and stores the entry once
+// the whole body has been read without exceeding the size limit.
+type cachingBody struct {
+	io.ReadCloser
+	entry    *cacheEntry
+	cache    *ResponseCache
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (b *cachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+
+	if !b.overflow {
+		if int64(b.buf.Len()+n) > b.cache.maxEntrySize {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+
+	if err == io.EOF && !b.overflow {
+		b.entry.body = b.buf.Bytes()
+		b.cache.store(b.entry)
+		b.overflow = true
+	}
+
+	return n, err
+}
+
+// Purge removes the cached responses for a URL, and returns the number
+// removed. If rawURL has a host, only responses for requests to that host are
+// removed; otherwise the responses for the path and query on any host are
+// removed.
+func (c *ResponseCache) Purge(rawURL string) int {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0
+	}
+
+	return c.purge(func(e *cacheEntry) bool {
+		return e.uri == u.RequestURI() && (u.Host == "" || e.host == u.Host)
+	})
+}
+
+// PurgeRoute removes the cached responses for all requests matched by the
+// route with the given pattern, and returns the number removed.
+func (c *ResponseCache) PurgeRoute(pattern string) int {
+	return c.purge(func(e *cacheEntry) bool {
+		return e.route == pattern
+	})
+}
+
+func (c *ResponseCache) purge(match func(*cacheEntry) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for key, el := range c.entries {
+		if match(el.Value.(*cacheEntry)) {
+			c.lru.Remove(el)
+			delete(c.entries, key)
+			n++
+		}
+	}
+
+	return n
+}
+
+// ServeHTTP implements the admin endpoint for purging entries.
+func (c *ResponseCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	n := 0
+	switch {
+	case r.FormValue("url") != "":
+		n = c.Purge(r.FormValue("url"))
+	case r.FormValue("route") != "":
+		n = c.PurgeRoute(r.FormValue("route"))
+	default:
+		http.Error(w, "A url or route value is required.", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"purged": n})
+}
+
+// parseCacheControl parses Cache-Control directives into a map of lowercase
+// names to (unquoted) values.
+func parseCacheControl(values []string) map[string]string {
+	directives := map[string]string{}
+
+	for _, value := range values {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name != "" {
+				directives[strings.ToLower(name)] = strings.Trim(arg, `"`)
+			}
+		}
+	}
+
+	return directives
+}
+
+func parseSeconds(s string) time.Duration {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,209 @@
+package flow
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func newCacheTestProxy(t *testing.T, cache *ResponseCache, h http.HandlerFunc) *Mux {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New()
+	m.Proxy("/items/...", &Proxy{Target: target, Cache: cache})
+	m.Proxy("/other/...", &Proxy{Target: target, Cache: cache})
+
+	return m
+}
+
+func TestResponseCache(t *testing.T) {
+	var requests atomic.Int64
+
+	m := newCacheTestProxy(t, NewResponseCache(100, 1<<20), func(w http.ResponseWriter, r *http.Request) {
+		n := requests.Add(1)
+
+		switch r.URL.Path {
+		case "/items/fresh", "/other/fresh":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+			w.Header().Set("ETag", `"v1"`)
+		case "/items/revalidate":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/items/expires":
+			w.Header().Set("Expires", "Thu, 01 Jan 1970 00:00:00 GMT")
+		case "/items/no-store":
+			w.Header().Set("Cache-Control", "no-store")
+		case "/items/private":
+			w.Header().Set("Cache-Control", "private, max-age=60")
+		case "/items/cookie":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Set-Cookie", "session=abc")
+		case "/items/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		case "/items/error":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		fmt.Fprintf(w, "response %d", n)
+	})
+
+	var tests = []struct {
+		Name    string
+		Path    string
+		Headers map[string]string
+
+		ExpectedStatus   int
+		ExpectedBody     string
+		ExpectedXCache   string
+		ExpectedUpstream bool
+	}{
+		{"first request", "/items/fresh", nil, 200, "response 1", "MISS", true},
+		{"fresh hit", "/items/fresh", nil, 200, "response 1", "HIT", false},
+		{"different query", "/items/fresh?page=2", nil, 200, "response 2", "MISS", true},
+		{"client conditional", "/items/fresh", map[string]string{"If-None-Match": `"v1"`}, 304, "", "HIT", false},
+		{"client no-cache without match", "/items/fresh", map[string]string{"Cache-Control": "no-cache"}, 200, "response 3", "MISS", true},
+		{"authorization", "/items/fresh", map[string]string{"Authorization": "Bearer x"}, 200, "response 4", "", true},
+		{"revalidate first", "/items/revalidate", nil, 200, "response 5", "MISS", true},
+		{"revalidate second", "/items/revalidate", nil, 200, "response 5", "REVALIDATED", true},
+		{"expired", "/items/expires", nil, 200, "response 7", "MISS", true},
+		{"expired again", "/items/expires", nil, 200, "response 8", "MISS", true},
+		{"no-store", "/items/no-store", nil, 200, "response 9", "MISS", true},
+		{"no-store again", "/items/no-store", nil, 200, "response 10", "MISS", true},
+		{"private", "/items/private", nil, 200, "response 11", "MISS", true},
+		{"private again", "/items/private", nil, 200, "response 12", "MISS", true},
+		{"cookie", "/items/cookie", nil, 200, "response 13", "MISS", true},
+		{"cookie again", "/items/cookie", nil, 200, "response 14", "MISS", true},
+		{"vary en", "/items/vary", map[string]string{"Accept-Language": "en"}, 200, "response 15", "MISS", true},
+		{"vary en again", "/items/vary", map[string]string{"Accept-Language": "en"}, 200, "response 15", "HIT", false},
+		{"vary fr", "/items/vary", map[string]string{"Accept-Language": "fr"}, 200, "response 16", "MISS", true},
+		{"error", "/items/error", nil, 500, "response 17", "MISS", true},
+		{"error again", "/items/error", nil, 500, "response 18", "MISS", true},
+	}
+
+	for _, test := range tests {
+		before := requests.Load()
+
+		r := httptest.NewRequest("GET", "http://example.com"+test.Path, nil)
+		for name, value := range test.Headers {
+			r.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but got %d", test.Name, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedBody != "" && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s: expected body %q but got %q", test.Name, test.ExpectedBody, rr.Body.String())
+		}
+		if xcache := rr.Header().Get("X-Cache"); xcache != test.ExpectedXCache {
+			t.Errorf("%s: expected X-Cache %q but got %q", test.Name, test.ExpectedXCache, xcache)
+		}
+		if upstream := requests.Load() != before; upstream != test.ExpectedUpstream {
+			t.Errorf("%s: expected upstream request to be %t but was %t", test.Name, test.ExpectedUpstream, upstream)
+		}
+		if test.ExpectedXCache == "HIT" && rr.Header().Get("Age") == "" {
+			t.Errorf("%s: expected an Age header", test.Name)
+		}
+	}
+}
+
+func TestResponseCachePurge(t *testing.T) {
+	var requests atomic.Int64
+
+	cache := NewResponseCache(100, 1<<20)
+	m := newCacheTestProxy(t, cache, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+	})
+
+	get := func(target string) {
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	for _, target := range []string{"http://a.com/items/1", "http://b.com/items/1", "http://a.com/items/2", "http://a.com/other/1"} {
+		get(target)
+	}
+
+	if n := cache.Purge("http://a.com/items/1"); n != 1 {
+		t.Errorf("expected to purge 1 entry but purged %d", n)
+	}
+	if n := cache.Purge("/items/1"); n != 1 {
+		t.Errorf("expected to purge 1 entry but purged %d", n)
+	}
+
+	rr := httptest.NewRecorder()
+	cache.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader("route=/items/...")))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d without form content type but got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("route=/items/..."))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rr = httptest.NewRecorder()
+	cache.ServeHTTP(rr, r)
+	if body := strings.TrimSpace(rr.Body.String()); body != `{"purged":1}` {
+		t.Errorf("unexpected purge response %q", body)
+	}
+
+	before := requests.Load()
+	get("http://a.com/other/1")
+	if requests.Load() != before {
+		t.Error("expected /other/1 to still be cached")
+	}
+	get("http://a.com/items/2")
+	if requests.Load() == before {
+		t.Error("expected /items/2 to have been purged")
+	}
+}
+
+func TestResponseCacheLimits(t *testing.T) {
+	var requests atomic.Int64
+
+	m := newCacheTestProxy(t, NewResponseCache(2, 10), func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		if r.URL.Path == "/items/large" {
+			w.Write([]byte("this body is too large"))
+		}
+	})
+
+	get := func(path string) bool {
+		before := requests.Load()
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+		return requests.Load() != before
+	}
+
+	get("/items/large")
+	if !get("/items/large") {
+		t.Error("expected large response not to be cached")
+	}
+
+	get("/items/1")
+	get("/items/2")
+	get("/items/1")
+	get("/items/3")
+
+	if get("/items/1") {
+		t.Error("expected recently used entry to be kept")
+	}
+	if !get("/items/2") {
+		t.Error("expected least recently used entry to be evicted")
+	}
+}
@@ -0,0 +1,212 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// DefaultPropagatedHeaders are the request headers which clients returned by
+// Client copy from the incoming request to outgoing requests, unless a
+// ClientPolicy lists different ones: the request ID and the W3C trace context
+// headers.
+var DefaultPropagatedHeaders = []string{"X-Request-Id", "Traceparent", "Tracestate"}
+
+var errClientTryTimeout = errors.New("flow: client attempt timed out")
+
+type requestHeaderContextKey struct{}
+
+// ClientPolicy configures the HTTP clients returned by Client while handling
+// requests to a route. Set it for a route with Route.ClientPolicy, or for all
+// the routes registered afterwards in a group with Mux.ClientPolicy.
+type ClientPolicy struct {
+	// Transport is used to make the requests. If it is nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Retry, if it is set, makes clients retry failed requests. Each route
+	// which uses the policy has its own retry budget. Only requests without
+	// a body, or whose body can be recreated with Request.GetBody (as for the
+	// common body types given to http.NewRequest), are retried.
+	Retry *RetryPolicy
+
+	// PropagateHeaders lists the headers which are copied from the incoming
+	// request to outgoing requests that don't already have them. If it is
+	// nil, DefaultPropagatedHeaders is used.
+	PropagateHeaders []string
+}
+
+// ClientPolicy sets the policy for the clients returned by Client while
+// handling requests to the route.
+func (r *Route) ClientPolicy(p *ClientPolicy) *Route {
+	r.client, r.clientBudget = p, &retryBudget{}
+	return r
+}
+
+// ClientPolicy sets the policy for the clients returned by Client for routes
+// registered afterwards. Like middleware, it is scoped to the current group.
+// Each route gets its own retry budget.
+func (m *Mux) ClientPolicy(p *ClientPolicy) {
+	m.client = p
+}
+
+// Client returns an HTTP client for making requests to other services while
+// handling a request, so that downstream calls inherit the request's limits.
+// ctx should be the request context:
+//
+//	resp, err := flow.Client(r.Context()).Get("http://inventory/items/" + id)
+//
+// The client's timeout is the time remaining before the context's deadline
+// (if it has one), and its requests are canceled when ctx is, even if they
+// were made with a context of their own. The request ID and trace headers (see
+// DefaultPropagatedHeaders) are copied from the incoming request, and failed
+// requests are retried if the route's ClientPolicy has a RetryPolicy.
+//
+// Outside of a request handled by a Mux, the client just applies ctx.
+func Client(ctx context.Context) *http.Client {
+	t := &clientTransport{ctx: ctx}
+
+	if route, ok := ctx.Value(routeContextKey{}).(*Route); ok && route.client != nil {
+		t.policy, t.budget = route.client, route.clientBudget
+	} else {
+		t.policy = &ClientPolicy{}
+	}
+	t.header, _ = ctx.Value(requestHeaderContextKey{}).(http.Header)
+
+	c := &http.Client{Transport: t}
+	if deadline, ok := ctx.Deadline(); ok {
+		// A zero Timeout means no limit, so use the smallest one if the
+		// deadline has already passed.
+		c.Timeout = max(time.Until(deadline), time.Nanosecond)
+	}
+
+	return c
+}
+
+type clientTransport struct {
+	ctx    context.Context
+	policy *ClientPolicy
+	budget *retryBudget
+	header http.Header
+}
+
+// RoundTrip makes the request with a context which is also canceled when the
+// context given to Client is, until the response body is closed.
+func (t *clientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancelCause(req.Context())
+	stop := context.AfterFunc(t.ctx, func() { cancel(context.Cause(t.ctx)) })
+	release := func() {
+		stop()
+		cancel(nil)
+	}
+
+	resp, err := t.roundTrip(req.Clone(ctx))
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: release}
+	return resp, nil
+}
+
+func (t *clientTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	propagate := t.policy.PropagateHeaders
+	if propagate == nil {
+		propagate = DefaultPropagatedHeaders
+	}
+	for _, name := range propagate {
+		if value := t.header.Get(name); value != "" && req.Header.Get(name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+
+	base := t.policy.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	retry := t.policy.Retry
+	if retry == nil || retry.MaxRetries <= 0 || t.budget == nil || !t.retryable(req) {
+		return base.RoundTrip(req)
+	}
+
+	t.budget.deposit(retry.Budget)
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+
+		resp, err := t.try(base, req, retry.TryTimeout)
+
+		failed := err != nil && ctx.Err() == nil ||
+			resp != nil && (resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusServiceUnavailable)
+		if !failed || attempt >= retry.MaxRetries || !t.budget.withdraw() {
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+
+		if !retry.wait(ctx, attempt+1) {
+			return nil, context.Cause(ctx)
+		}
+	}
+}
+
+// retryable reports whether req may be retried under the policy.
+func (t *clientTransport) retryable(req *http.Request) bool {
+	methods := t.policy.Retry.Methods
+	if len(methods) == 0 {
+		methods = idempotentMethods
+	}
+
+	return slices.Contains(methods, req.Method) && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+}
+
+// try makes a single attempt at req. If timeout is positive, the attempt is
+// canceled if the response headers haven't arrived in time.
+func (t *clientTransport) try(base http.RoundTripper, req *http.Request, timeout time.Duration) (*http.Response, error) {
+	if timeout <= 0 {
+		return base.RoundTrip(req)
+	}
+
+	ctx, cancel := context.WithCancelCause(req.Context())
+	timer := time.AfterFunc(timeout, func() { cancel(errClientTryTimeout) })
+
+	resp, err := base.RoundTrip(req.WithContext(ctx))
+	timer.Stop()
+	if err != nil {
+		cancel(nil)
+		return nil, err
+	}
+
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: func() { cancel(nil) }}
+	return resp, nil
+}
+
+// cancelBody releases the context for a request when the response body is
+// closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel func()
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
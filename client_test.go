@@ -0,0 +1,176 @@
+package flow
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientPropagation(t *testing.T) {
+	var received http.Header
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+	}))
+	defer upstream.Close()
+
+	m := New()
+	m.HandleFunc("/default", func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequest("GET", upstream.URL, nil)
+		req.Header.Set("Tracestate", "explicit")
+		resp, err := Client(r.Context()).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}, "GET")
+	m.HandleFunc("/custom", func(w http.ResponseWriter, r *http.Request) {
+		resp, err := Client(r.Context()).Get(upstream.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}, "GET").ClientPolicy(&ClientPolicy{PropagateHeaders: []string{"X-Tenant"}})
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedHeaders map[string]string
+	}{
+		{"/default", map[string]string{"X-Request-Id": "abc", "Traceparent": "00-trace", "Tracestate": "explicit", "X-Tenant": ""}},
+		{"/custom", map[string]string{"X-Request-Id": "", "Traceparent": "", "X-Tenant": "acme"}},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.RequestPath, nil)
+		r.Header.Set("X-Request-Id", "abc")
+		r.Header.Set("Traceparent", "00-trace")
+		r.Header.Set("Tracestate", "inbound")
+		r.Header.Set("X-Tenant", "acme")
+		m.ServeHTTP(httptest.NewRecorder(), r)
+
+		for name, expected := range test.ExpectedHeaders {
+			if actual := received.Get(name); actual != expected {
+				t.Errorf("%s: expected %s header %q but was %q", test.RequestPath, name, expected, actual)
+			}
+		}
+	}
+}
+
+func TestClientContext(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if timeout := Client(ctx).Timeout; timeout <= 0 || timeout > time.Minute {
+		t.Errorf("expected a timeout of up to a minute but got %v", timeout)
+	}
+	if timeout := Client(context.Background()).Timeout; timeout != 0 {
+		t.Errorf("expected no timeout without a deadline but got %v", timeout)
+	}
+
+	cancel()
+	if _, err := Client(ctx).Get(upstream.URL); err == nil {
+		t.Errorf("expected an error after the context was canceled")
+	}
+}
+
+func TestClientRetry(t *testing.T) {
+	var calls atomic.Int64
+	var mu sync.Mutex
+	var bodies []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, string(body))
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/flaky":
+			if calls.Add(1)%3 != 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		case "/slow":
+			if calls.Add(1) == 1 {
+				time.Sleep(100 * time.Millisecond)
+			}
+		case "/down":
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer upstream.Close()
+
+	var status int
+	do := func(method, path, body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			req, _ := http.NewRequest(method, upstream.URL+path, strings.NewReader(body))
+			resp, err := Client(r.Context()).Do(req)
+			if err != nil {
+				status = 0
+				return
+			}
+			resp.Body.Close()
+			status = resp.StatusCode
+		}
+	}
+
+	m := New()
+	m.ClientPolicy(&ClientPolicy{Retry: &RetryPolicy{MaxRetries: 100, Methods: []string{"GET", "POST"}, TryTimeout: 50 * time.Millisecond}})
+	m.HandleFunc("/flaky", do("POST", "/flaky", "payload"), "GET")
+	m.HandleFunc("/slow", do("GET", "/slow", ""), "GET")
+	m.HandleFunc("/down/1", do("GET", "/down", ""), "GET")
+	m.HandleFunc("/down/2", do("GET", "/down", ""), "GET")
+	m.HandleFunc("/put", do("PUT", "/flaky", ""), "GET")
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedStatus int
+		ExpectedCalls  int64
+	}{
+		{"/flaky", http.StatusOK, 3},
+		{"/slow", http.StatusOK, 2},
+		// Each route has its own budget, which allows 10 retries when full.
+		{"/down/1", http.StatusBadGateway, 11},
+		{"/down/1", http.StatusBadGateway, 1},
+		{"/down/2", http.StatusBadGateway, 11},
+		// PUT isn't in the policy's methods.
+		{"/put", http.StatusServiceUnavailable, 1},
+	}
+
+	for _, test := range tests {
+		calls.Store(0)
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if status != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but was %d", test.RequestPath, test.ExpectedStatus, status)
+		}
+		if n := calls.Load(); n != test.ExpectedCalls {
+			t.Errorf("%s: expected %d calls but got %d", test.RequestPath, test.ExpectedCalls, n)
+		}
+	}
+
+	mu.Lock()
+	bodies = nil
+	mu.Unlock()
+
+	calls.Store(0)
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/flaky", nil))
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, body := range bodies {
+		if body != "payload" {
+			t.Errorf("expected every attempt to send the body but got %q", bodies)
+			break
+		}
+	}
+}
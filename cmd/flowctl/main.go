@@ -0,0 +1,273 @@
+// Command flowctl inspects the routes of an application built with flow.
+//
+// It works with a route dump, which is the JSON encoding of the slice returned
+// by Mux.Routes(). The dump can be read from a file, from stdin (using "-"), or
+// fetched from an http:// or https:// URL where the application exposes it.
+// For example, an application might expose its routes like this:
+//
+//	mux.HandleFunc("/debug/routes", func(w http.ResponseWriter, r *http.Request) {
+//		json.NewEncoder(w).Encode(mux.Routes())
+//	}, "GET")
+//
+// Usage:
+//
+//	flowctl list [-method METHOD] [-grep TEXT] SOURCE
+//	flowctl match SOURCE METHOD PATH
+//	flowctl diff OLD NEW
+//	flowctl openapi [-package NAME] [-o FILE] SPEC
+//
+// The match command matches a request against the patterns and methods in
+// the dump, in the same order as the application. It can't check routes
+// registered with Host, or patterns which use constraints registered by the
+// application with RegisterConstraint, so it reports an error if the dump has
+// any. The header and scheme conditions of routes registered with a
+// RouteBuilder aren't recorded in the dump, and are ignored.
+//
+// The openapi command generates Go code from an OpenAPI 3 spec (in JSON, so
+// YAML specs need to be converted first). For each operation it writes a
+// struct holding the typed path and query parameters, and a method on a
+// Handler interface which the application implements, and it writes a
+// RegisterRoutes function which registers the routes with a Mux. It's intended
+// to be run with go generate, so the routes stay in sync with the spec:
+//
+//	//go:generate go run github.com/alexedwards/flow/cmd/flowctl openapi -package api -o routes.go openapi.json
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/alexedwards/flow"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+
+	switch os.Args[1] {
+	case "list":
+		err = list(os.Stdout, os.Args[2:])
+	case "match":
+		err = match(os.Stdout, os.Args[2:])
+	case "diff":
+		err = diff(os.Stdout, os.Args[2:])
+	case "openapi":
+		err = openAPI(os.Stdout, os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "flowctl: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	fmt.Fprintln(os.Stderr, "  flowctl list [-method METHOD] [-grep TEXT] SOURCE")
+	fmt.Fprintln(os.Stderr, "  flowctl match SOURCE METHOD PATH   (host patterns and custom constraints aren't supported)")
+	fmt.Fprintln(os.Stderr, "  flowctl diff OLD NEW")
+	fmt.Fprintln(os.Stderr, "  flowctl openapi [-package NAME] [-o FILE] SPEC")
+}
+
+func list(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	method := fs.String("method", "", "only list routes which accept this HTTP method")
+	grep := fs.String("grep", "", "only list routes whose pattern contains this text")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("list requires exactly one source")
+	}
+
+	routes, err := load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	for _, route := range routes {
+		if *method != "" && !slices.Contains(route.Methods, strings.ToUpper(*method)) {
+			continue
+		}
+		if *grep != "" && !strings.Contains(route.Pattern, *grep) {
+			continue
+		}
+		if route.Handler != "" {
+			fmt.Fprintf(w, "%-30s %-40s %s\n", strings.Join(route.Methods, ","), route.Pattern, route.Handler)
+		} else {
+			fmt.Fprintf(w, "%-30s %s\n", strings.Join(route.Methods, ","), route.Pattern)
+		}
+	}
+
+	return nil
+}
+
+func match(w io.Writer, args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("match requires a source, a method and a path")
+	}
+
+	routes, err := load(args[0])
+	if err != nil {
+		return err
+	}
+
+	info, params, err := matchRoutes(routes, strings.ToUpper(args[1]), args[2])
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%s %s\n", strings.Join(info.Methods, ","), info.Pattern)
+
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "  %s = %s\n", key, params[key])
+	}
+
+	return nil
+}
+
+// matchRoutes finds the route in a route dump which matches the method and
+// path. A dump only records the patterns and methods of the routes, so it
+// returns an error for routes which can't be matched in the same way as the
+// application would: routes registered with Host, and routes whose patterns
+// use constraints registered by the application with RegisterConstraint.
+// The header and scheme conditions of routes registered with a RouteBuilder
+// aren't in the dump either, so they're ignored.
+func matchRoutes(routes []flow.RouteInfo, method, path string) (flow.RouteInfo, flow.Params, error) {
+	mux := flow.New()
+
+	for _, route := range routes {
+		if route.Host != "" {
+			return flow.RouteInfo{}, nil, fmt.Errorf("route %s%s is registered with a host pattern, which match doesn't support", route.Host, route.Pattern)
+		}
+		if err := flow.ValidatePattern(route.Pattern); err != nil {
+			return flow.RouteInfo{}, nil, fmt.Errorf("route %s can't be matched (constraints registered by the application aren't available to match): %w", route.Pattern, err)
+		}
+
+		for _, method := range route.Methods {
+			if !slices.Contains(flow.AllMethods, method) && !slices.Contains(mux.CustomMethods, method) {
+				mux.CustomMethods = append(mux.CustomMethods, method)
+			}
+		}
+		mux.HandleFunc(route.Pattern, func(w http.ResponseWriter, r *http.Request) {}, route.Methods...)
+	}
+
+	info, params, ok := mux.Match(method, path)
+	if !ok {
+		return flow.RouteInfo{}, nil, fmt.Errorf("no route matches %s %s", method, path)
+	}
+
+	return info, params, nil
+}
+
+func diff(w io.Writer, args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("diff requires two sources")
+	}
+
+	before, err := load(args[0])
+	if err != nil {
+		return err
+	}
+
+	after, err := load(args[1])
+	if err != nil {
+		return err
+	}
+
+	for _, line := range diffRoutes(before, after) {
+		fmt.Fprintln(w, line)
+	}
+
+	return nil
+}
+
+// diffRoutes compares two route dumps, and returns a line for each method and
+// pattern combination which has been removed (prefixed with "-") or added
+// (prefixed with "+").
+func diffRoutes(before, after []flow.RouteInfo) []string {
+	expand := func(routes []flow.RouteInfo) []string {
+		var lines []string
+		for _, route := range routes {
+			for _, method := range route.Methods {
+				line := method + " " + route.Pattern
+				if !slices.Contains(lines, line) {
+					lines = append(lines, line)
+				}
+			}
+		}
+		return lines
+	}
+
+	beforeLines, afterLines := expand(before), expand(after)
+
+	var lines []string
+	for _, line := range beforeLines {
+		if !slices.Contains(afterLines, line) {
+			lines = append(lines, "- "+line)
+		}
+	}
+	for _, line := range afterLines {
+		if !slices.Contains(beforeLines, line) {
+			lines = append(lines, "+ "+line)
+		}
+	}
+
+	return lines
+}
+
+func load(source string) ([]flow.RouteInfo, error) {
+	var r io.Reader
+
+	switch {
+	case source == "-":
+		r = os.Stdin
+	case strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://"):
+		resp, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("fetching %s: unexpected status %s", source, resp.Status)
+		}
+		r = resp.Body
+	default:
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var routes []flow.RouteInfo
+
+	err := json.NewDecoder(r).Decode(&routes)
+	if err != nil {
+		return nil, fmt.Errorf("decoding route dump from %s: %w", source, err)
+	}
+
+	return routes, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/flow"
+)
+
+func TestDiffRoutes(t *testing.T) {
+	before := []flow.RouteInfo{
+		{Pattern: "/users/:id", Methods: []string{"GET", "HEAD"}},
+		{Pattern: "/admin", Methods: []string{"POST"}},
+	}
+
+	after := []flow.RouteInfo{
+		{Pattern: "/users/:id", Methods: []string{"GET", "HEAD", "PUT"}},
+		{Pattern: "/health", Methods: []string{"GET"}},
+	}
+
+	expected := []string{
+		"- POST /admin",
+		"+ PUT /users/:id",
+		"+ GET /health",
+	}
+
+	actual := diffRoutes(before, after)
+	if !slices.Equal(actual, expected) {
+		t.Errorf("expected %q but got %q", expected, actual)
+	}
+}
+
+func TestMatchRoutes(t *testing.T) {
+	routes := []flow.RouteInfo{
+		{Pattern: "/users/:id|int", Methods: []string{"GET", "HEAD"}},
+		{Pattern: "/users/:name", Methods: []string{"GET", "HEAD", "PURGE"}},
+	}
+
+	info, params, err := matchRoutes(routes, "PURGE", "/users/alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Pattern != "/users/:name" || !maps.Equal(params, flow.Params{"name": "alice"}) {
+		t.Errorf("unexpected match %q %v", info.Pattern, params)
+	}
+
+	if _, _, err := matchRoutes(routes, "DELETE", "/users/42"); err == nil || !strings.Contains(err.Error(), "no route matches") {
+		t.Errorf("expected no match but got %v", err)
+	}
+
+	var tests = []struct {
+		Route flow.RouteInfo
+
+		ExpectedError string
+	}{
+		{flow.RouteInfo{Host: "api.example.com", Pattern: "/users/:id", Methods: []string{"GET"}}, "host pattern"},
+		{flow.RouteInfo{Pattern: "/posts/:slug|slug", Methods: []string{"GET"}}, "unknown constraint"},
+	}
+
+	for _, test := range tests {
+		_, _, err := matchRoutes(append(slices.Clone(routes), test.Route), "GET", "/users/42")
+		if err == nil || !strings.Contains(err.Error(), test.ExpectedError) {
+			t.Errorf("%s%s: expected error containing %q but got %v", test.Route.Host, test.Route.Pattern, test.ExpectedError, err)
+		}
+	}
+}
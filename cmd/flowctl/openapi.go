@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"github.com/alexedwards/flow"
+)
+
+// openAPISpec holds the parts of an OpenAPI 3 document which are used to
+// generate routes.
+type openAPISpec struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Parameters map[string]openAPIParameter `json:"parameters"`
+	} `json:"components"`
+}
+
+type openAPIOperation struct {
+	OperationID string             `json:"operationId"`
+	Summary     string             `json:"summary"`
+	Parameters  []openAPIParameter `json:"parameters"`
+}
+
+type openAPIParameter struct {
+	Ref      string `json:"$ref"`
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required"`
+	Schema   struct {
+		Type  string `json:"type"`
+		Items struct {
+			Type string `json:"type"`
+		} `json:"items"`
+	} `json:"schema"`
+}
+
+// genOperation and genParam describe the code generated for an operation and
+// each of its parameters.
+type genOperation struct {
+	Name    string
+	Method  string
+	Path    string
+	Pattern string
+	Summary string
+	Params  []genParam
+}
+
+type genParam struct {
+	Name      string
+	Field     string
+	In        string
+	Type      string
+	ParamType string // The flow.ParamType for a typed path parameter.
+	Parse     string // The expression which parses a query value v.
+	Required  bool
+}
+
+var openAPITemplate = template.Must(template.New("").Parse(`// Code generated by flowctl openapi; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- if .Query}}
+	"fmt"
+{{- end}}
+	"net/http"
+{{- if .Strconv}}
+	"strconv"
+{{- end}}
+
+	"github.com/alexedwards/flow"
+)
+{{range .Operations}}
+// {{.Name}}Params holds the parameters of the {{.Name}} operation.
+type {{.Name}}Params struct {
+{{- range .Params}}
+	{{.Field}} {{.Type}} // {{.In}} parameter "{{.Name}}"
+{{- end}}
+}
+{{end}}
+// Handler is implemented by the handlers for the operations in the API.
+type Handler interface {
+{{- range .Operations}}
+	// {{.Name}} handles {{.Method}} {{.Path}}.{{if .Summary}} {{.Summary}}{{end}}
+	{{.Name}}(w http.ResponseWriter, r *http.Request, params {{.Name}}Params)
+{{- end}}
+}
+
+// RegisterRoutes registers a route for each operation with mux. Requests with
+// missing or invalid query parameters are passed to errorHandler (or
+// flow.DefaultErrorHandler, if it is nil) with a 400 Bad Request status.
+func RegisterRoutes(mux *flow.Mux, h Handler, errorHandler flow.ErrorHandler) {
+	if errorHandler == nil {
+		errorHandler = flow.DefaultErrorHandler
+	}
+{{range .Operations}}
+	mux.HandleFunc({{printf "%q" .Pattern}}, func(w http.ResponseWriter, r *http.Request) {
+		var params {{.Name}}Params
+{{- range .Params}}
+{{- if eq .In "path"}}
+{{- if .ParamType}}
+		params.{{.Field}}, _ = flow.TypedParam[{{.Type}}](r.Context(), {{printf "%q" .Name}})
+{{- else}}
+		params.{{.Field}} = flow.Param(r.Context(), {{printf "%q" .Name}})
+{{- end}}
+{{- else if eq .Type "[]string"}}
+		params.{{.Field}} = r.URL.Query()[{{printf "%q" .Name}}]
+{{- if .Required}}
+		if len(params.{{.Field}}) == 0 {
+			errorHandler(w, r, flow.HTTPError{Status: http.StatusBadRequest, Err: fmt.Errorf("missing query parameter %q", {{printf "%q" .Name}})})
+			return
+		}
+{{- end}}
+{{- else}}
+		if v := r.URL.Query().Get({{printf "%q" .Name}}); v != "" {
+{{- if eq .Type "string"}}
+			params.{{.Field}} = v
+{{- else}}
+			value, err := {{.Parse}}
+			if err != nil {
+				errorHandler(w, r, flow.HTTPError{Status: http.StatusBadRequest, Err: fmt.Errorf("invalid query parameter %q: %w", {{printf "%q" .Name}}, err)})
+				return
+			}
+			params.{{.Field}} = value
+{{- end}}
+		}{{if .Required}} else {
+			errorHandler(w, r, flow.HTTPError{Status: http.StatusBadRequest, Err: fmt.Errorf("missing query parameter %q", {{printf "%q" .Name}})})
+			return
+		}{{end}}
+{{- end}}
+{{- end}}
+		h.{{.Name}}(w, r, params)
+	}, {{printf "%q" .Method}}){{range .Params}}{{if .ParamType}}.Param({{printf "%q" .Name}}, {{.ParamType}}){{end}}{{end}}
+{{end -}}
+}
+`))
+
+func openAPI(w io.Writer, args []string) error {
+	fs := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	pkg := fs.String("package", "api", "the package name for the generated code")
+	output := fs.String("o", "", "the file to write the generated code to (default stdout)")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("openapi requires exactly one spec")
+	}
+
+	var r io.Reader = os.Stdin
+	if source := fs.Arg(0); source != "-" {
+		f, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var spec openAPISpec
+	if err := json.NewDecoder(r).Decode(&spec); err != nil {
+		return fmt.Errorf("decoding OpenAPI spec (only JSON is supported): %w", err)
+	}
+
+	src, err := generateRoutes(*pkg, &spec)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = w.Write(src)
+		return err
+	}
+	return os.WriteFile(*output, src, 0o644)
+}
+
+// generateRoutes returns the Go source for the routes in the spec.
+func generateRoutes(pkg string, spec *openAPISpec) ([]byte, error) {
+	paths := make([]string, 0, len(spec.Paths))
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+
+	var ops []genOperation
+	names := map[string]bool{}
+
+	for _, path := range paths {
+		item := spec.Paths[path]
+
+		var shared []openAPIParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return nil, fmt.Errorf("path %s: %w", path, err)
+			}
+		}
+
+		for _, method := range flow.AllMethods {
+			raw, ok := item[strings.ToLower(method)]
+			if !ok {
+				continue
+			}
+
+			var op openAPIOperation
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+
+			gen, err := newGenOperation(spec, method, path, op, shared)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", method, path, err)
+			}
+			if names[gen.Name] {
+				return nil, fmt.Errorf("%s %s: duplicate operation name %s", method, path, gen.Name)
+			}
+			names[gen.Name] = true
+
+			ops = append(ops, gen)
+		}
+	}
+
+	data := struct {
+		Package    string
+		Operations []genOperation
+		Query      bool
+		Strconv    bool
+	}{Package: pkg, Operations: ops}
+
+	for _, op := range ops {
+		for _, p := range op.Params {
+			if p.In == "query" && (p.Required || p.Parse != "") {
+				data.Query = true
+			}
+			if p.In == "query" && p.Parse != "" {
+				data.Strconv = true
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := openAPITemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+func newGenOperation(spec *openAPISpec, method, path string, op openAPIOperation, shared []openAPIParameter) (genOperation, error) {
+	gen := genOperation{
+		Method:  method,
+		Path:    path,
+		Summary: strings.TrimSpace(strings.ReplaceAll(op.Summary, "\n", " ")),
+	}
+
+	gen.Name = goName(op.OperationID)
+	if gen.Name == "" {
+		gen.Name = goName(strings.ToLower(method) + " " + path)
+	}
+
+	// Parameters given for the operation override those shared by the path.
+	var params []openAPIParameter
+	for _, p := range append(slices.Clone(shared), op.Parameters...) {
+		if p.Ref != "" {
+			name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/")
+			resolved, found := spec.Components.Parameters[name]
+			if !ok || !found {
+				return gen, fmt.Errorf("can't resolve parameter %s", p.Ref)
+			}
+			p = resolved
+		}
+
+		params = slices.DeleteFunc(params, func(q openAPIParameter) bool { return q.Name == p.Name && q.In == p.In })
+		params = append(params, p)
+	}
+
+	fields := map[string]bool{}
+	pattern := path
+
+	for _, p := range params {
+		if p.In != "path" && p.In != "query" {
+			continue
+		}
+
+		param := genParam{Name: p.Name, Field: goName(p.Name), In: p.In, Required: p.Required, Type: "string"}
+		if param.Field == "" || fields[param.Field] {
+			return gen, fmt.Errorf("can't name a field for parameter %q", p.Name)
+		}
+		fields[param.Field] = true
+
+		switch p.Schema.Type {
+		case "integer":
+			param.Type, param.ParamType, param.Parse = "int64", "flow.Int64", "strconv.ParseInt(v, 10, 64)"
+		case "number":
+			param.Type, param.ParamType, param.Parse = "float64", "flow.Float64", "strconv.ParseFloat(v, 64)"
+		case "boolean":
+			param.Type, param.ParamType, param.Parse = "bool", "flow.Bool", "strconv.ParseBool(v)"
+		case "array":
+			if p.In == "query" {
+				param.Type = "[]string"
+			}
+		}
+
+		if p.In == "path" {
+			if !strings.Contains(pattern, "{"+p.Name+"}") {
+				return gen, fmt.Errorf("path parameter %q isn't in the path", p.Name)
+			}
+			pattern = strings.ReplaceAll(pattern, "{"+p.Name+"}", ":"+p.Name)
+			param.Parse = ""
+		} else {
+			param.ParamType = ""
+		}
+
+		gen.Params = append(gen.Params, param)
+	}
+
+	if strings.ContainsAny(pattern, "{}") {
+		return gen, fmt.Errorf("path has parameters which aren't described")
+	}
+	gen.Pattern = pattern
+
+	return gen, nil
+}
+
+// goName converts a name like "get_user-by id" or "/users/{id}" into an
+// exported Go identifier like "GetUserByID".
+func goName(s string) string {
+	words := strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var b strings.Builder
+	for _, word := range words {
+		switch upper := strings.ToUpper(word); upper {
+		case "ID", "URL", "URI", "HTTP", "API", "JSON", "UUID":
+			b.WriteString(upper)
+		default:
+			runes := []rune(word)
+			runes[0] = unicode.ToUpper(runes[0])
+			b.WriteString(string(runes))
+		}
+	}
+
+	name := b.String()
+	if name != "" && unicode.IsDigit([]rune(name)[0]) {
+		name = "Op" + name
+	}
+	return name
+}
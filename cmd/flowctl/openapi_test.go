@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestGenerateRoutes(t *testing.T) {
+	spec := `{
+		"paths": {
+			"/users/{id}": {
+				"parameters": [{"name": "id", "in": "path", "required": true, "schema": {"type": "integer"}}],
+				"get": {
+					"operationId": "getUser",
+					"parameters": [{"name": "verbose", "in": "query", "schema": {"type": "boolean"}}]
+				},
+				"delete": {
+					"parameters": [{"$ref": "#/components/parameters/Reason"}]
+				}
+			}
+		},
+		"components": {
+			"parameters": {
+				"Reason": {"name": "reason", "in": "query", "required": true, "schema": {"type": "string"}}
+			}
+		}
+	}`
+
+	var s openAPISpec
+	if err := json.Unmarshal([]byte(spec), &s); err != nil {
+		t.Fatal(err)
+	}
+
+	src, err := generateRoutes("api", &s)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{
+		"package api",
+		"ID      int64 // path parameter \"id\"",
+		"Verbose bool  // query parameter \"verbose\"",
+		"GetUser(w http.ResponseWriter, r *http.Request, params GetUserParams)",
+		"DeleteUsersID(w http.ResponseWriter, r *http.Request, params DeleteUsersIDParams)",
+		"mux.HandleFunc(\"/users/:id\", func(",
+		"}, \"GET\").Param(\"id\", flow.Int64)",
+		"}, \"DELETE\").Param(\"id\", flow.Int64)",
+		"strconv.ParseBool(v)",
+		"fmt.Errorf(\"missing query parameter %q\", \"reason\")",
+	} {
+		if !strings.Contains(string(src), expected) {
+			t.Errorf("expected generated code to contain %q:\n%s", expected, src)
+		}
+	}
+}
+
+func TestGenerateRoutesErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{
+			spec: `{"paths": {"/users/{id}": {"get": {}}}}`,
+			err:  "GET /users/{id}: path has parameters which aren't described",
+		},
+		{
+			spec: `{"paths": {"/users": {"get": {"parameters": [{"name": "id", "in": "path"}]}}}}`,
+			err:  `GET /users: path parameter "id" isn't in the path`,
+		},
+		{
+			spec: `{"paths": {"/users": {"get": {"parameters": [{"$ref": "#/components/parameters/Missing"}]}}}}`,
+			err:  "GET /users: can't resolve parameter #/components/parameters/Missing",
+		},
+		{
+			spec: `{"paths": {"/a": {"get": {"operationId": "fetch"}}, "/b": {"get": {"operationId": "fetch"}}}}`,
+			err:  "GET /b: duplicate operation name Fetch",
+		},
+	}
+
+	for _, test := range tests {
+		var s openAPISpec
+		if err := json.Unmarshal([]byte(test.spec), &s); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := generateRoutes("api", &s)
+		if err == nil || err.Error() != test.err {
+			t.Errorf("spec %s: expected error %q but got %v", test.spec, test.err, err)
+		}
+	}
+}
+
+func TestGoName(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"getUser", "GetUser"},
+		{"list_users", "ListUsers"},
+		{"get /users/{id}", "GetUsersID"},
+		{"X-Request-Id", "XRequestID"},
+		{"2fa", "Op2fa"},
+		{"", ""},
+	}
+
+	for _, test := range tests {
+		if actual := goName(test.name); actual != test.expected {
+			t.Errorf("goName(%q): expected %q but got %q", test.name, test.expected, actual)
+		}
+	}
+}
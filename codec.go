@@ -0,0 +1,202 @@
+package flow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// A Codec encodes and decodes values in a particular wire format. Codecs are
+// registered against a media type with RegisterCodec, and are used by Bind and
+// Render.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the Codec for application/json, which uses encoding/json.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v any) ([]byte, error)      { return json.Marshal(v) }
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+type codecEntry struct {
+	mediaType string
+	codec     Codec
+}
+
+var codecs = struct {
+	sync.RWMutex
+	entries []codecEntry
+}{
+	entries: []codecEntry{
+		{"application/json", JSONCodec{}},
+		{"application/xml", XMLCodec{}},
+		{"text/xml", XMLCodec{}},
+	},
+}
+
+// RegisterCodec makes a Codec available to Bind and Render for the given media
+// type, replacing any codec already registered for it. JSON (application/json)
+// and XML (application/xml and text/xml) codecs are registered by default.
+// Other formats, such as protobuf, msgpack or CBOR, can be supported by
+// registering a codec which wraps the relevant package:
+//
+//	flow.RegisterCodec("application/msgpack", msgpackCodec{})
+//
+// When several registered codecs are equally acceptable to a client, Render
+// uses the one which was registered first.
+func RegisterCodec(mediaType string, c Codec) {
+	mediaType = strings.ToLower(mediaType)
+
+	codecs.Lock()
+	defer codecs.Unlock()
+
+	for i, e := range codecs.entries {
+		if e.mediaType == mediaType {
+			codecs.entries[i].codec = c
+			return
+		}
+	}
+
+	codecs.entries = append(codecs.entries, codecEntry{mediaType, c})
+}
+
+// lookupCodec returns the codec registered for mediaType. Structured syntax
+// suffixes are supported, so application/problem+json uses the JSON codec
+// unless a codec is registered for it specifically.
+func lookupCodec(mediaType string) (Codec, bool) {
+	codecs.RLock()
+	defer codecs.RUnlock()
+
+	find := func(mediaType string) (Codec, bool) {
+		for _, e := range codecs.entries {
+			if e.mediaType == mediaType {
+				return e.codec, true
+			}
+		}
+		return nil, false
+	}
+
+	if c, ok := find(mediaType); ok {
+		return c, true
+	}
+
+	if i := strings.LastIndexByte(mediaType, '+'); i != -1 {
+		return find("application/" + mediaType[i+1:])
+	}
+
+	return nil, false
+}
+
+// Bind decodes the request body into v, using the codec registered for the
+// request's Content-Type. Requests without a Content-Type are decoded as JSON.
+// A charset parameter other than UTF-8 is only supported by the XML codec.
+//
+// The returned error is an HTTPError with the status 415 Unsupported Media
+// Type if there is no codec for the Content-Type, 413 Content Too Large if the
+// body is larger than the Mux's MaxBodyBytes, or 400 Bad Request if the body
+// can't be decoded.
+func Bind(r *http.Request, v any) error {
+	mediaType, charset := "application/json", ""
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		mt, params, err := mime.ParseMediaType(ct)
+		if err != nil {
+			return HTTPError{Status: http.StatusUnsupportedMediaType, Err: fmt.Errorf("flow: invalid Content-Type %q", ct)}
+		}
+		mediaType, charset = mt, params["charset"]
+	}
+
+	codec, ok := lookupCodec(mediaType)
+	if !ok {
+		return HTTPError{Status: http.StatusUnsupportedMediaType, Err: fmt.Errorf("flow: unsupported Content-Type %q", mediaType)}
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return HTTPError{Status: http.StatusRequestEntityTooLarge, Err: err}
+		}
+		return HTTPError{Status: http.StatusBadRequest, Err: err}
+	}
+
+	if cu, ok := codec.(charsetUnmarshaler); ok {
+		err = cu.unmarshalCharset(body, charset, v)
+	} else if isUTF8Charset(charset) {
+		err = codec.Unmarshal(body, v)
+	} else {
+		return HTTPError{Status: http.StatusUnsupportedMediaType, Err: fmt.Errorf("flow: unsupported charset %q", charset)}
+	}
+
+	if err != nil {
+		if errors.Is(err, errUnsupportedCharset) {
+			return HTTPError{Status: http.StatusUnsupportedMediaType, Err: err}
+		}
+		return HTTPError{Status: http.StatusBadRequest, Err: fmt.Errorf("flow: decoding %s request body: %w", mediaType, err)}
+	}
+
+	return nil
+}
+
+// Render encodes v using the registered codec which best matches the
+// request's Accept header, and sends it with the given status code. Requests
+// without an Accept header get the first registered codec (JSON, unless the
+// defaults have been changed).
+//
+// If none of the registered codecs are acceptable, Render sends nothing and
+// returns an HTTPError with the status 406 Not Acceptable, which can be passed
+// to an ErrorHandler.
+func Render(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	mediaType, codec, ok := negotiateCodec(r.Header.Get("Accept"))
+	if !ok {
+		return HTTPError{Status: http.StatusNotAcceptable}
+	}
+
+	body, err := codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Add("Vary", "Accept")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+func negotiateCodec(accept string) (string, Codec, bool) {
+	if accept == "" {
+		accept = "*/*"
+	}
+
+	for _, accepted := range parseWeighted(accept) {
+		mediaType := strings.ToLower(accepted.value)
+
+		if !strings.HasSuffix(mediaType, "/*") {
+			if c, ok := lookupCodec(mediaType); ok {
+				return mediaType, c, true
+			}
+			continue
+		}
+
+		prefix := strings.TrimSuffix(mediaType, "*")
+
+		codecs.RLock()
+		for _, e := range codecs.entries {
+			if mediaType == "*/*" || strings.HasPrefix(e.mediaType, prefix) {
+				codecs.RUnlock()
+				return e.mediaType, e.codec, true
+			}
+		}
+		codecs.RUnlock()
+	}
+
+	return "", nil, false
+}
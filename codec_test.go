@@ -0,0 +1,128 @@
+package flow
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testCodec struct{}
+
+func (testCodec) Marshal(v any) ([]byte, error) {
+	p, ok := v.(*testPayload)
+	if !ok {
+		return nil, errors.New("unsupported value")
+	}
+	return []byte("name=" + p.Name), nil
+}
+
+func (testCodec) Unmarshal(data []byte, v any) error {
+	p, ok := v.(*testPayload)
+	if !ok {
+		return errors.New("unsupported value")
+	}
+	name, found := strings.CutPrefix(string(data), "name=")
+	if !found {
+		return errors.New("missing name")
+	}
+	p.Name = name
+	return nil
+}
+
+type testPayload struct {
+	Name string `json:"name" xml:"name"`
+}
+
+func TestBind(t *testing.T) {
+	RegisterCodec("application/x-test", testCodec{})
+
+	var tests = []struct {
+		ContentType string
+		Body        string
+
+		ExpectedStatus int
+		ExpectedName   string
+	}{
+		{"", `{"name":"alice"}`, 0, "alice"},
+		{"application/json; charset=utf-8", `{"name":"alice"}`, 0, "alice"},
+		{"application/merge-patch+json", `{"name":"alice"}`, 0, "alice"},
+		{"application/xml", `<payload><name>bob</name></payload>`, 0, "bob"},
+		{"application/x-test", `name=carol`, 0, "carol"},
+		{"application/x-test", `nope`, http.StatusBadRequest, ""},
+		{"application/json", `{"name":`, http.StatusBadRequest, ""},
+		{"text/csv", `name`, http.StatusUnsupportedMediaType, ""},
+		{"not a media type", `name`, http.StatusUnsupportedMediaType, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(test.Body))
+		if test.ContentType != "" {
+			r.Header.Set("Content-Type", test.ContentType)
+		}
+
+		var p testPayload
+		err := Bind(r, &p)
+
+		if test.ExpectedStatus == 0 {
+			if err != nil {
+				t.Errorf("%q: unexpected error %v", test.ContentType, err)
+			}
+		} else if err == nil || StatusCode(err) != test.ExpectedStatus {
+			t.Errorf("%q: expected status %d but got error %v", test.ContentType, test.ExpectedStatus, err)
+		}
+
+		if p.Name != test.ExpectedName {
+			t.Errorf("%q: expected name %q but was %q", test.ContentType, test.ExpectedName, p.Name)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	RegisterCodec("application/x-test", testCodec{})
+
+	var tests = []struct {
+		Accept string
+
+		ExpectedStatus      int
+		ExpectedContentType string
+		ExpectedBody        string
+	}{
+		{"", http.StatusCreated, "application/json", `{"name":"alice"}`},
+		{"*/*", http.StatusCreated, "application/json", `{"name":"alice"}`},
+		{"application/xml", http.StatusCreated, "application/xml", xml.Header + `<testPayload><name>alice</name></testPayload>`},
+		{"text/html, text/*;q=0.5", http.StatusCreated, "text/xml", xml.Header + `<testPayload><name>alice</name></testPayload>`},
+		{"application/json;q=0.5, application/x-test", http.StatusCreated, "application/x-test", `name=alice`},
+		{"image/png", http.StatusNotAcceptable, "", ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.Accept != "" {
+			r.Header.Set("Accept", test.Accept)
+		}
+		rr := httptest.NewRecorder()
+
+		err := Render(rr, r, http.StatusCreated, &testPayload{Name: "alice"})
+		if err != nil {
+			if StatusCode(err) != test.ExpectedStatus {
+				t.Errorf("%q: expected status %d but got error %v", test.Accept, test.ExpectedStatus, err)
+			}
+			continue
+		}
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%q: expected status %d but was %d", test.Accept, test.ExpectedStatus, rr.Code)
+		}
+
+		if ct := rr.Header().Get("Content-Type"); ct != test.ExpectedContentType {
+			t.Errorf("%q: expected Content-Type %q but was %q", test.Accept, test.ExpectedContentType, ct)
+		}
+
+		if body := rr.Body.String(); body != test.ExpectedBody {
+			t.Errorf("%q: expected body %s but was %s", test.Accept, test.ExpectedBody, body)
+		}
+	}
+}
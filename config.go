@@ -0,0 +1,208 @@
+package flow
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Config holds the settings for a Mux, for use with NewWithConfig. The zero
+// value gives the same Mux as New. Fields correspond to the Mux fields of the
+// same name. The handler fields can't be loaded from JSON or the environment;
+// a nil handler means the default from New is used.
+//
+// Config only covers the Mux's own settings. Paths are always matched
+// case-sensitively, and the Mux doesn't interpret X-Forwarded headers, so
+// there are no settings for case sensitivity or trusted proxies; handle those
+// in middleware.
+type Config struct {
+	MaxURLLength       int                 `json:"max_url_length"`
+	MaxBodyBytes       int64               `json:"max_body_bytes"`
+	AllowTrace         bool                `json:"allow_trace"`
+	AllowConnect       bool                `json:"allow_connect"`
+	DefaultMethods     []string            `json:"default_methods"`
+	CustomMethods      []string            `json:"custom_methods"`
+	WildcardNotFound   bool                `json:"wildcard_not_found"`
+	TrailingSlash      TrailingSlashPolicy `json:"trailing_slash"`
+	DisableAutoHead    bool                `json:"disable_auto_head"`
+	DisableAutoOptions bool                `json:"disable_auto_options"`
+
+	NotFound         http.Handler `json:"-"`
+	MethodNotAllowed http.Handler `json:"-"`
+	Options          http.Handler `json:"-"`
+	BadRequest       http.Handler `json:"-"`
+	URITooLong       http.Handler `json:"-"`
+}
+
+// Validate checks the config for invalid values, returning an error which
+// describes all of the problems found.
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.MaxURLLength < 0 {
+		errs = append(errs, fmt.Errorf("flow: MaxURLLength must not be negative (got %d)", c.MaxURLLength))
+	}
+
+	if c.MaxBodyBytes < 0 {
+		errs = append(errs, fmt.Errorf("flow: MaxBodyBytes must not be negative (got %d)", c.MaxBodyBytes))
+	}
+
+	if _, err := c.TrailingSlash.MarshalText(); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, method := range c.CustomMethods {
+		if !isToken(method) {
+			errs = append(errs, fmt.Errorf("flow: custom method %q is not a valid HTTP method name", method))
+		}
+	}
+
+	for _, method := range c.DefaultMethods {
+		method = strings.ToUpper(method)
+		if !slices.Contains(AllMethods, method) && !slices.ContainsFunc(c.CustomMethods, func(s string) bool { return strings.EqualFold(s, method) }) {
+			errs = append(errs, fmt.Errorf("flow: default method %q is not a standard method or a custom method", method))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// NewWithConfig returns a new Mux using the settings in cfg, after checking
+// them with Validate.
+func NewWithConfig(cfg Config) (*Mux, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	m := New()
+	m.MaxURLLength = cfg.MaxURLLength
+	m.MaxBodyBytes = cfg.MaxBodyBytes
+	m.AllowTrace = cfg.AllowTrace
+	m.AllowConnect = cfg.AllowConnect
+	m.DefaultMethods = slices.Clone(cfg.DefaultMethods)
+	m.CustomMethods = slices.Clone(cfg.CustomMethods)
+	m.WildcardNotFound = cfg.WildcardNotFound
+	m.TrailingSlash = cfg.TrailingSlash
+	m.DisableAutoHead = cfg.DisableAutoHead
+	m.DisableAutoOptions = cfg.DisableAutoOptions
+
+	for _, h := range []struct {
+		dst *http.Handler
+		src http.Handler
+	}{
+		{&m.NotFound, cfg.NotFound},
+		{&m.MethodNotAllowed, cfg.MethodNotAllowed},
+		{&m.Options, cfg.Options},
+		{&m.BadRequest, cfg.BadRequest},
+		{&m.URITooLong, cfg.URITooLong},
+	} {
+		if h.src != nil {
+			*h.dst = h.src
+		}
+	}
+
+	return m, nil
+}
+
+// ConfigFromJSON reads a Config from a JSON object, such as:
+//
+//	{"max_url_length": 8192, "custom_methods": ["PURGE"]}
+//
+// Unknown keys are an error, so that misspelled settings aren't silently
+// ignored.
+func ConfigFromJSON(r io.Reader) (Config, error) {
+	var cfg Config
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(&cfg); err != nil {
+		return Config{}, fmt.Errorf("flow: decoding config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// ConfigFromEnv reads a Config from environment variables with the given
+// prefix. For example, with the prefix "FLOW_" the variables are
+// FLOW_MAX_URL_LENGTH, FLOW_MAX_BODY_BYTES, FLOW_ALLOW_TRACE, FLOW_ALLOW_CONNECT,
+// FLOW_DEFAULT_METHODS, FLOW_CUSTOM_METHODS, FLOW_WILDCARD_NOT_FOUND,
+// FLOW_TRAILING_SLASH ("strict", "redirect" or "ignore"),
+// FLOW_DISABLE_AUTO_HEAD and FLOW_DISABLE_AUTO_OPTIONS. Method lists are
+// comma-separated. Variables which aren't set leave the setting at its default.
+func ConfigFromEnv(prefix string) (Config, error) {
+	var cfg Config
+	var errs []error
+
+	parseInt := func(name string, dst *int64) {
+		if v, ok := os.LookupEnv(prefix + name); ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("flow: %s%s: %w", prefix, name, err))
+			}
+			*dst = n
+		}
+	}
+
+	parseBool := func(name string, dst *bool) {
+		if v, ok := os.LookupEnv(prefix + name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("flow: %s%s: %w", prefix, name, err))
+			}
+			*dst = b
+		}
+	}
+
+	parseList := func(name string, dst *[]string) {
+		if v, ok := os.LookupEnv(prefix + name); ok {
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*dst = append(*dst, item)
+				}
+			}
+		}
+	}
+
+	var maxURLLength int64
+	parseInt("MAX_URL_LENGTH", &maxURLLength)
+	cfg.MaxURLLength = int(maxURLLength)
+	parseInt("MAX_BODY_BYTES", &cfg.MaxBodyBytes)
+	parseBool("ALLOW_TRACE", &cfg.AllowTrace)
+	parseBool("ALLOW_CONNECT", &cfg.AllowConnect)
+	parseList("DEFAULT_METHODS", &cfg.DefaultMethods)
+	parseList("CUSTOM_METHODS", &cfg.CustomMethods)
+	parseBool("WILDCARD_NOT_FOUND", &cfg.WildcardNotFound)
+	parseBool("DISABLE_AUTO_HEAD", &cfg.DisableAutoHead)
+	parseBool("DISABLE_AUTO_OPTIONS", &cfg.DisableAutoOptions)
+
+	if v, ok := os.LookupEnv(prefix + "TRAILING_SLASH"); ok {
+		if err := cfg.TrailingSlash.UnmarshalText([]byte(v)); err != nil {
+			errs = append(errs, fmt.Errorf("flow: %sTRAILING_SLASH: %w", prefix, err))
+		}
+	}
+
+	return cfg, errors.Join(errs...)
+}
+
+// isToken reports whether s is a valid HTTP token (RFC 9110 section 5.6.2),
+// which is the syntax required for method names.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range []byte(s) {
+		if c >= 0x80 || c <= ' ' || strings.IndexByte("\"(),/:;<=>?@[\\]{}", c) != -1 || c == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,131 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestNewWithConfig(t *testing.T) {
+	m, err := NewWithConfig(Config{
+		MaxURLLength:   20,
+		CustomMethods:  []string{"PURGE"},
+		DefaultMethods: []string{"GET", "purge"},
+		NotFound: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.HandleFunc("/cache", func(w http.ResponseWriter, r *http.Request) {})
+
+	var tests = []struct {
+		Method string
+		Path   string
+
+		ExpectedStatus int
+	}{
+		{"PURGE", "/cache", http.StatusOK},
+		{"HEAD", "/cache", http.StatusOK},
+		{"POST", "/cache", http.StatusMethodNotAllowed},
+		{"GET", "/missing", http.StatusTeapot},
+		{"GET", "/cache?" + strings.Repeat("x", 20), http.StatusRequestURITooLong},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.Method, test.Path, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.Method, test.Path, test.ExpectedStatus, rr.Code)
+		}
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	var tests = []struct {
+		Config Config
+
+		ExpectedErrors []string
+	}{
+		{Config{}, nil},
+		{Config{MaxURLLength: -1}, []string{"must not be negative"}},
+		{Config{CustomMethods: []string{"BAD METHOD"}}, []string{`"BAD METHOD" is not a valid`}},
+		{Config{DefaultMethods: []string{"PURGE"}}, []string{`default method "PURGE"`}},
+		{Config{MaxURLLength: -5, DefaultMethods: []string{"X"}}, []string{"must not be negative", `default method "X"`}},
+		{Config{TrailingSlash: 7}, []string{"invalid trailing slash policy"}},
+	}
+
+	for _, test := range tests {
+		err := test.Config.Validate()
+
+		if len(test.ExpectedErrors) == 0 {
+			if err != nil {
+				t.Errorf("%+v: expected no error but got %q", test.Config, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%+v: expected an error but got nil", test.Config)
+			continue
+		}
+
+		for _, expected := range test.ExpectedErrors {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("%+v: expected error to contain %q but was %q", test.Config, expected, err)
+			}
+		}
+	}
+
+	if _, err := NewWithConfig(Config{MaxURLLength: -1}); err == nil {
+		t.Errorf("expected NewWithConfig to return the validation error")
+	}
+}
+
+func TestConfigFromJSON(t *testing.T) {
+	cfg, err := ConfigFromJSON(strings.NewReader(`{"max_url_length": 8192, "allow_trace": true, "custom_methods": ["PURGE"], "trailing_slash": "redirect"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.MaxURLLength != 8192 || !cfg.AllowTrace || !slices.Equal(cfg.CustomMethods, []string{"PURGE"}) || cfg.TrailingSlash != RedirectTrailingSlash {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	if _, err := ConfigFromJSON(strings.NewReader(`{"max_url_lenght": 8192}`)); err == nil {
+		t.Errorf("expected an error for an unknown key")
+	}
+
+	if _, err := ConfigFromJSON(strings.NewReader(`{"trailing_slash": "sometimes"}`)); err == nil {
+		t.Errorf("expected an error for an unknown trailing slash policy")
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("TEST_MAX_URL_LENGTH", "4096")
+	t.Setenv("TEST_WILDCARD_NOT_FOUND", "true")
+	t.Setenv("TEST_CUSTOM_METHODS", "PURGE, PROPFIND")
+	t.Setenv("TEST_TRAILING_SLASH", "ignore")
+	t.Setenv("TEST_DISABLE_AUTO_HEAD", "1")
+	t.Setenv("TEST_DISABLE_AUTO_OPTIONS", "true")
+
+	cfg, err := ConfigFromEnv("TEST_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.MaxURLLength != 4096 || !cfg.WildcardNotFound || cfg.AllowTrace || !slices.Equal(cfg.CustomMethods, []string{"PURGE", "PROPFIND"}) || cfg.TrailingSlash != IgnoreTrailingSlash || !cfg.DisableAutoHead || !cfg.DisableAutoOptions {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+
+	t.Setenv("TEST_ALLOW_TRACE", "sometimes")
+
+	if _, err := ConfigFromEnv("TEST_"); err == nil || !strings.Contains(err.Error(), "TEST_ALLOW_TRACE") {
+		t.Errorf("expected an error naming TEST_ALLOW_TRACE but got %v", err)
+	}
+}
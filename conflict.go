@@ -0,0 +1,137 @@
+package flow
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// TryHandle is like Handle, but returns an error instead of registering the
+// route if the pattern or methods are invalid, or if the route conflicts with
+// one which is already registered. A route conflicts with an earlier one when
+// the earlier route would match every request that the new route matches for
+// at least one of its methods, so that those requests could never reach the
+// new handler. That includes registering the same pattern and method twice,
+// and overlaps such as /posts/new after /posts/:id, or /users/:name after
+// /users/:id.
+//
+// The check is conservative: it doesn't report overlaps which depend on a
+// regular expression constraint matching everything another one does, on a
+// wildcard in the middle of the earlier pattern, on parameter types set with
+// Route.Param, or on the header and scheme conditions of a RouteBuilder. A GET
+// route's automatic HEAD method isn't counted as a conflict, so Head can be
+// used before Get for the same pattern as usual.
+func (m *Mux) TryHandle(pattern string, handler http.Handler, methods ...string) (*Route, error) {
+	route, err := m.newRoute(pattern, handler, methods)
+	if err != nil {
+		return nil, err
+	}
+
+	given := methods
+	if len(given) == 0 {
+		given = m.defaultMethods()
+	}
+	check := route.methods
+	if !slices.ContainsFunc(given, func(s string) bool { return strings.EqualFold(s, http.MethodHead) }) {
+		check &^= methodBit(http.MethodHead)
+	}
+
+	// The check and the insert are made under the table's lock, so that two
+	// conflicting routes registered concurrently can't both be added.
+	m.routes.update(func(routes []*Route) []*Route {
+		if err = conflict(routes, route, check); err != nil {
+			return routes
+		}
+		return append(routes, route)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return route, nil
+}
+
+// conflict returns an error if one of routes would match every request that
+// route matches for at least one of the methods in check.
+func conflict(routes []*Route, route *Route, check methodSet) error {
+	for _, earlier := range routes {
+		conflicting := (earlier.methods & check).methods()
+		for _, method := range route.customMethods {
+			if slices.Contains(earlier.customMethods, method) {
+				conflicting = append(conflicting, method)
+			}
+		}
+
+		if len(conflicting) == 0 || !earlier.covers(route) {
+			continue
+		}
+
+		if earlier.pattern == route.pattern && earlier.hostPattern == route.hostPattern {
+			return fmt.Errorf("flow: route %q is already registered for %s", route.pattern, strings.Join(conflicting, ", "))
+		}
+		return fmt.Errorf("flow: route %q would never match %s requests, because the earlier route %q matches all of them", route.pattern, strings.Join(conflicting, ", "), earlier.pattern)
+	}
+
+	return nil
+}
+
+// covers reports whether r matches every request path (and host) that other
+// does. It returns false when it can't tell.
+func (r *Route) covers(other *Route) bool {
+	if len(r.paramTypes) > 0 || len(r.conditions) > 0 {
+		return false
+	}
+
+	if r.host != nil && r.hostPattern != other.hostPattern {
+		return false
+	}
+
+	if r.wildcard {
+		// Only a trailing wildcard is handled. It matches at least one
+		// segment, so other must have a segment for it.
+		last := len(r.segments) - 1
+		if !r.segments[last].wildcard || len(other.segments) < len(r.segments) {
+			return false
+		}
+
+		for i := 0; i < last; i++ {
+			if !r.segments[i].covers(other.segments[i]) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	if other.wildcard || len(other.segments) != len(r.segments) {
+		return false
+	}
+
+	for i := range r.segments {
+		if !r.segments[i].covers(other.segments[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// covers reports whether s matches every value that other does.
+func (s segment) covers(other segment) bool {
+	switch {
+	case s.wildcard || other.wildcard:
+		return false
+	case !s.param:
+		return !other.param && s.value == other.value
+	case s.rx == nil && s.check == nil:
+		// An unconstrained parameter matches anything except an empty
+		// segment.
+		return other.param || other.value != ""
+	case !other.param:
+		value := unescape(other.value)
+		return (s.rx == nil || s.rx.MatchString(value)) && (s.check == nil || s.check(value))
+	default:
+		return s.rx == other.rx && sameFunc(s.check, other.check)
+	}
+}
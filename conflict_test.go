@@ -0,0 +1,107 @@
+package flow
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTryHandle(t *testing.T) {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	var tests = []struct {
+		Name     string
+		Register func(m *Mux)
+		Pattern  string
+		Methods  []string
+
+		ExpectedError string
+	}{
+		{"no conflict", func(m *Mux) { m.Handle("/users/:id", hf, "GET") }, "/users/:id/posts", []string{"GET"}, ""},
+		{"different method", func(m *Mux) { m.Handle("/users/:id", hf, "GET") }, "/users/:id", []string{"POST"}, ""},
+		{"duplicate", func(m *Mux) { m.Handle("/users/:id", hf, "GET", "PUT") }, "/users/:id", []string{"PUT", "DELETE"}, `route "/users/:id" is already registered for PUT`},
+		{"renamed parameter", func(m *Mux) { m.Handle("/users/:id", hf, "GET") }, "/users/:name", []string{"GET"}, `earlier route "/users/:id" matches all of them`},
+		{"literal after parameter", func(m *Mux) { m.Handle("/posts/:id", hf, "GET") }, "/posts/new", []string{"GET"}, `"/posts/new" would never match GET requests`},
+		{"literal before parameter", func(m *Mux) { m.Handle("/posts/new", hf, "GET") }, "/posts/:id", []string{"GET"}, ""},
+		{"constraint after parameter", func(m *Mux) { m.Handle("/posts/:id", hf, "GET") }, "/posts/:id|int", []string{"GET"}, "would never match"},
+		{"parameter after constraint", func(m *Mux) { m.Handle("/posts/:id|int", hf, "GET") }, "/posts/:slug", []string{"GET"}, ""},
+		{"literal matching constraint", func(m *Mux) { m.Handle("/posts/:id|^[0-9]+$", hf, "GET") }, "/posts/42", []string{"GET"}, "would never match"},
+		{"literal not matching constraint", func(m *Mux) { m.Handle("/posts/:id|int", hf, "GET") }, "/posts/latest", []string{"GET"}, ""},
+		{"same constraint", func(m *Mux) { m.Handle("/posts/:id|uuid", hf, "GET") }, "/posts/:key|uuid", []string{"GET"}, "would never match"},
+		{"trailing wildcard", func(m *Mux) { m.Handle("/files/...", hf, "GET") }, "/files/:dir/readme", []string{"GET"}, `earlier route "/files/..."`},
+		{"wildcard needs a segment", func(m *Mux) { m.Handle("/files/...", hf, "GET") }, "/files", []string{"GET"}, ""},
+		{"wildcard after wildcard", func(m *Mux) { m.Handle("/files/...", hf, "GET") }, "/files/:path...", []string{"GET"}, "would never match"},
+		{"middle wildcard", func(m *Mux) { m.Handle("/files/.../raw", hf, "GET") }, "/files/a/raw", []string{"GET"}, ""},
+		{"head before get", func(m *Mux) { m.Handle("/items", hf, "HEAD") }, "/items", []string{"GET"}, ""},
+		{"explicit head after get", func(m *Mux) { m.Handle("/items", hf, "GET") }, "/items", []string{"HEAD"}, "already registered for HEAD"},
+		{"custom method", func(m *Mux) { m.Handle("/cache", hf, "PURGE") }, "/cache", []string{"PURGE"}, "already registered for PURGE"},
+		{"typed parameter", func(m *Mux) { m.Handle("/orders/:id", hf, "GET").Param("id", Int) }, "/orders/:ref", []string{"GET"}, ""},
+		{"host route after any host", func(m *Mux) { m.Handle("/status", hf, "GET") }, "/status", []string{"GET"}, "would never match"},
+		{"any host after host route", func(m *Mux) {
+			m.Host("api.example.com", func(m *Mux) { m.Handle("/status", hf, "GET") })
+		}, "/status", []string{"GET"}, ""},
+		{"invalid method", func(m *Mux) {}, "/status", []string{"GTE"}, `invalid HTTP method "GTE"`},
+		{"invalid pattern", func(m *Mux) {}, "/a/.../b/...", nil, "more than one wildcard"},
+	}
+
+	for _, test := range tests {
+		m := New()
+		m.CustomMethods = []string{"PURGE"}
+		test.Register(m)
+
+		register := func(m *Mux) (*Route, error) { return m.TryHandle(test.Pattern, hf, test.Methods...) }
+
+		var route *Route
+		var err error
+		if test.Name == "host route after any host" {
+			m.Host("api.example.com", func(m *Mux) { route, err = register(m) })
+		} else {
+			route, err = register(m)
+		}
+
+		if test.ExpectedError == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error but got %q", test.Name, err)
+			} else if routes := m.routes.load(); routes[len(routes)-1] != route {
+				t.Errorf("%s: expected the route to be registered", test.Name)
+			}
+			continue
+		}
+
+		if err == nil || !strings.Contains(err.Error(), test.ExpectedError) {
+			t.Errorf("%s: expected error containing %q but got %v", test.Name, test.ExpectedError, err)
+		}
+		if route != nil {
+			t.Errorf("%s: expected no route to be returned", test.Name)
+		}
+	}
+}
+
+func TestTryHandleConcurrent(t *testing.T) {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	m := New()
+
+	var registered atomic.Int32
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := m.TryHandle("/posts/:id", hf, "GET"); err == nil {
+				registered.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := registered.Load(); n != 1 {
+		t.Errorf("expected 1 route to be registered but got %d", n)
+	}
+	if n := len(m.routes.load()); n != 1 {
+		t.Errorf("expected 1 route in the table but got %d", n)
+	}
+}
@@ -0,0 +1,153 @@
+package flow
+
+import (
+	"fmt"
+	"sync"
+)
+
+// constraints holds the named parameter constraints which can be used in
+// route patterns in place of a regular expression, like /users/:id|int. They
+// are shared by all Mux instances.
+var constraints = struct {
+	sync.RWMutex
+	fns map[string]func(string) bool
+}{
+	fns: map[string]func(string) bool{
+		"int":   isInt,
+		"uint":  isUint,
+		"alpha": isAlpha,
+		"alnum": isAlnum,
+		"hex":   isHex,
+		"uuid":  isUUID,
+	},
+}
+
+// RegisterConstraint makes a named constraint available for use in route
+// patterns. After RegisterConstraint("slug", fn), the pattern /posts/:slug|slug
+// only matches requests where fn returns true for the percent-decoded value of
+// the parameter. Named constraints are checked without a regular expression, so
+// they're faster than the equivalent pattern.
+//
+// The built-in constraints are int (an optionally signed decimal integer), uint
+// (an unsigned decimal integer), alpha and alnum (ASCII letters, and ASCII
+// letters and digits), hex (hexadecimal digits) and uuid (a UUID in the
+// canonical 8-4-4-4-12 form).
+//
+// Names are resolved when a route is registered, so RegisterConstraint must
+// be called before any routes which use the name are registered. A constraint
+// which consists only of ASCII letters, digits and underscores is always
+// treated as a name, and registering a route which uses a name that isn't
+// registered (such as a misspelling like |slgu) causes a panic, rather than
+// the name being used as a regular expression. A regular expression which is
+// a bare word must be written differently, such as (abc) instead of abc.
+// RegisterConstraint panics if the name is empty, contains characters other
+// than ASCII letters, digits and underscores, or is already registered, or if
+// fn is nil.
+func RegisterConstraint(name string, fn func(string) bool) {
+	if name == "" || !isConstraintName(name) {
+		panic(fmt.Sprintf("flow: invalid constraint name %q", name))
+	}
+	if fn == nil {
+		panic(fmt.Sprintf("flow: nil function for constraint %q", name))
+	}
+
+	constraints.Lock()
+	defer constraints.Unlock()
+
+	if _, exists := constraints.fns[name]; exists {
+		panic(fmt.Sprintf("flow: constraint %q is already registered", name))
+	}
+
+	constraints.fns[name] = fn
+}
+
+// lookupConstraint returns the registered constraint with the given name.
+func lookupConstraint(name string) (func(string) bool, bool) {
+	constraints.RLock()
+	defer constraints.RUnlock()
+
+	fn, ok := constraints.fns[name]
+	return fn, ok
+}
+
+func isConstraintName(s string) bool {
+	for _, c := range []byte(s) {
+		if !isASCIILetter(c) && !isASCIIDigit(c) && c != '_' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isInt(s string) bool {
+	if len(s) > 1 && (s[0] == '-' || s[0] == '+') {
+		s = s[1:]
+	}
+
+	return isUint(s)
+}
+
+func isUint(s string) bool {
+	return allBytes(s, isASCIIDigit)
+}
+
+func isAlpha(s string) bool {
+	return allBytes(s, isASCIILetter)
+}
+
+func isAlnum(s string) bool {
+	return allBytes(s, func(c byte) bool { return isASCIILetter(c) || isASCIIDigit(c) })
+}
+
+func isHex(s string) bool {
+	return allBytes(s, isHexDigit)
+}
+
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch i {
+		case 8, 13, 18, 23:
+			if s[i] != '-' {
+				return false
+			}
+		default:
+			if !isHexDigit(s[i]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// allBytes reports whether s is non-empty and every byte in it satisfies fn.
+func allBytes(s string, fn func(byte) bool) bool {
+	if s == "" {
+		return false
+	}
+
+	for i := 0; i < len(s); i++ {
+		if !fn(s[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isASCIIDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isHexDigit(c byte) bool {
+	return isASCIIDigit(c) || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
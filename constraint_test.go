@@ -0,0 +1,141 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestNamedConstraints(t *testing.T) {
+	RegisterConstraint("even", func(s string) bool {
+		return isUint(s) && (s[len(s)-1]-'0')%2 == 0
+	})
+
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}
+
+	m := New()
+	m.HandleFunc("/users/:id|int", hf, "GET")
+	m.HandleFunc("/pages/:n|uint", hf, "GET")
+	m.HandleFunc("/tags/:tag|alpha", hf, "GET")
+	m.HandleFunc("/codes/:code|alnum", hf, "GET")
+	m.HandleFunc("/colors/:rgb|hex", hf, "GET")
+	m.HandleFunc("/keys/:key|uuid", hf, "GET")
+	m.HandleFunc("/seats/:seat|even", hf, "GET")
+	m.HandleFunc("/words/:word|^[a-z]+$", hf, "GET")
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedStatus int
+	}{
+		{"/users/42", http.StatusOK},
+		{"/users/-42", http.StatusOK},
+		{"/users/-", http.StatusNotFound},
+		{"/users/4a", http.StatusNotFound},
+		{"/pages/7", http.StatusOK},
+		{"/pages/-7", http.StatusNotFound},
+		{"/tags/Go", http.StatusOK},
+		{"/tags/go1", http.StatusNotFound},
+		{"/tags/%C3%A9", http.StatusNotFound},
+		{"/codes/go1", http.StatusOK},
+		{"/codes/go-1", http.StatusNotFound},
+		{"/colors/00FFaa", http.StatusOK},
+		{"/colors/00FFag", http.StatusNotFound},
+		{"/keys/123e4567-e89b-12d3-a456-426614174000", http.StatusOK},
+		{"/keys/123e4567e89b12d3a456426614174000", http.StatusNotFound},
+		{"/keys/123e4567-e89b-12d3-a456-42661417400z", http.StatusNotFound},
+		{"/seats/12", http.StatusOK},
+		{"/seats/13", http.StatusNotFound},
+		{"/words/abc", http.StatusOK},
+		{"/words/ABC", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("GET %s: expected status %d but was %d", test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+	}
+
+	if err := ValidatePattern("/users/:id|int/:key|uuid"); err != nil {
+		t.Errorf("unexpected error for named constraints: %v", err)
+	}
+}
+
+func TestUnknownConstraint(t *testing.T) {
+	var tests = []struct {
+		Pattern string
+
+		ExpectedError bool
+	}{
+		{"/posts/:slug|slgu", true},
+		{"/posts/:slug|not_registered_1", true},
+		{"/posts/:slug|int", false},
+		{"/posts/:slug|^slug$", false},
+		{"/posts/:slug|[a-z]+", false},
+		{"/posts/:slug|(abc)", false},
+		{"/posts/:slug|", false},
+	}
+
+	for _, test := range tests {
+		err := ValidatePattern(test.Pattern)
+		if (err != nil) != test.ExpectedError {
+			t.Errorf("%s: expected error %t but got %v", test.Pattern, test.ExpectedError, err)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(string), `unknown constraint "slgu"`) {
+			t.Errorf("expected panic for an unknown constraint but got %v", r)
+		}
+	}()
+
+	New().HandleFunc("/posts/:slug|slgu", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+}
+
+func TestRegisterConstraintPanics(t *testing.T) {
+	var tests = []struct {
+		Name string
+		Fn   func(string) bool
+
+		ExpectedPanic string
+	}{
+		{"", isInt, "invalid constraint name"},
+		{"not-valid", isInt, "invalid constraint name"},
+		{"nilfn", nil, "nil function"},
+		{"int", isInt, "already registered"},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(r.(string), test.ExpectedPanic) {
+					t.Errorf("%q: expected panic containing %q but got %v", test.Name, test.ExpectedPanic, r)
+				}
+			}()
+
+			RegisterConstraint(test.Name, test.Fn)
+		}()
+	}
+}
+
+func BenchmarkNamedConstraint(b *testing.B) {
+	m := New()
+	m.HandleFunc("/keys/:key|uuid", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+
+	r := httptest.NewRequest("GET", "/keys/123e4567-e89b-12d3-a456-426614174000", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,229 @@
+package flow
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type envelopeContextKey struct{}
+
+// EnvelopeBody is the standard response envelope written by the Envelope
+// middleware.
+type EnvelopeBody struct {
+	Data  any            `json:"data,omitempty"`
+	Error any            `json:"error,omitempty"`
+	Meta  map[string]any `json:"meta,omitempty"`
+}
+
+// Envelope is middleware which buffers the response from the handler and wraps
+// it in an EnvelopeBody. Successful JSON responses are placed in the data
+// member, and error responses (with a status code of 400 or above) are placed
+// in the error member. Plain-text error responses, like the ones written by
+// http.Error, are converted to an object with status and message members.
+// Successful responses which aren't JSON are passed through unchanged.
+//
+// Because the response is buffered, Envelope should only be used on routes
+// which opt in to it, typically by registering it with Use in a Group
+// containing those routes. Handlers can add members to the meta object using
+// SetMeta.
+func Envelope(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		meta := map[string]any{}
+		ctx := context.WithValue(r.Context(), envelopeContextKey{}, meta)
+
+		bw := &bufferedWriter{ResponseWriter: w}
+		next.ServeHTTP(bw, r.WithContext(ctx))
+
+		if bw.Hijacked() {
+			return
+		}
+
+		var env EnvelopeBody
+		if len(meta) > 0 {
+			env.Meta = meta
+		}
+
+		isJSON := isJSONContentType(w.Header().Get("Content-Type"))
+
+		switch {
+		case bw.status() >= 400 && isJSON:
+			env.Error = json.RawMessage(bw.body.Bytes())
+		case bw.status() >= 400:
+			env.Error = map[string]any{
+				"status":  bw.status(),
+				"message": strings.TrimSpace(bw.body.String()),
+			}
+		case isJSON && bw.body.Len() > 0:
+			env.Data = json.RawMessage(bw.body.Bytes())
+		default:
+			bw.flush()
+			return
+		}
+
+		body, err := json.Marshal(env)
+		if err != nil {
+			bw.flush()
+			return
+		}
+
+		bw.body.Reset()
+		bw.body.Write(body)
+		bw.body.WriteByte('\n')
+
+		w.Header().Set("Content-Type", "application/json")
+		bw.flush()
+	})
+}
+
+// SetMeta adds a member to the meta object of the response envelope. It does
+// nothing if the Envelope middleware isn't being used for the request.
+func SetMeta(ctx context.Context, key string, value any) {
+	if meta, ok := ctx.Value(envelopeContextKey{}).(map[string]any); ok {
+		meta[key] = value
+	}
+}
+
+// Fields returns middleware which filters the members of JSON responses
+// according to a comma-separated list in the named query string parameter. For
+// example, with Fields("fields") a request for /users?fields=id,name will only
+// include the id and name members of the returned object (or of each object, if
+// the response is an array). Requests which don't include the parameter, and
+// responses which aren't JSON objects or arrays of objects, are passed through
+// unchanged.
+//
+// When used together with Envelope, Fields should be registered after it, so
+// that the filtering is applied to the data rather than to the envelope.
+func Fields(param string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			list := r.URL.Query().Get(param)
+			if list == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			keep := map[string]bool{}
+			for _, field := range strings.Split(list, ",") {
+				keep[strings.TrimSpace(field)] = true
+			}
+
+			bw := &bufferedWriter{ResponseWriter: w}
+			next.ServeHTTP(bw, r)
+
+			if bw.Hijacked() {
+				return
+			}
+
+			if bw.status() < 400 && isJSONContentType(w.Header().Get("Content-Type")) {
+				if body, ok := filterFields(bw.body.Bytes(), keep); ok {
+					bw.body.Reset()
+					bw.body.Write(body)
+					bw.body.WriteByte('\n')
+				}
+			}
+
+			bw.flush()
+		})
+	}
+}
+
+func filterFields(body []byte, keep map[string]bool) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+
+	filter := func(v any) bool {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return false
+		}
+		for key := range obj {
+			if !keep[key] {
+				delete(obj, key)
+			}
+		}
+		return true
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		filter(v)
+	case []any:
+		for _, item := range v {
+			if !filter(item) {
+				return nil, false
+			}
+		}
+	default:
+		return nil, false
+	}
+
+	filtered, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+
+	return filtered, true
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// bufferedWriter holds back the status code and body written by a handler, so
+// that middleware can transform the response before sending it. Headers are
+// written directly to the underlying ResponseWriter's header map.
+type bufferedWriter struct {
+	http.ResponseWriter
+	hijackTracker
+	code int
+	body bytes.Buffer
+}
+
+func (bw *bufferedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return bw.hijack(bw.ResponseWriter)
+}
+
+func (bw *bufferedWriter) WriteHeader(code int) {
+	if bw.code == 0 {
+		bw.code = code
+	}
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.code == 0 {
+		bw.code = http.StatusOK
+	}
+	return bw.body.Write(b)
+}
+
+func (bw *bufferedWriter) status() int {
+	if bw.code == 0 {
+		return http.StatusOK
+	}
+	return bw.code
+}
+
+func (bw *bufferedWriter) flush() {
+	if bw.ResponseWriter.Header().Get("Content-Length") != "" {
+		bw.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(bw.body.Len()))
+	}
+	bw.ResponseWriter.WriteHeader(bw.status())
+	bw.ResponseWriter.Write(bw.body.Bytes())
+}
@@ -0,0 +1,104 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	m := New()
+
+	m.Group(func(m *Mux) {
+		m.Use(Envelope, Fields("fields"))
+
+		m.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+			SetMeta(r.Context(), "total", 2)
+			w.Header().Set("X-Content-Type-Options", "nosniff")
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[{"id":1,"name":"alice","email":"a@example.com"},{"id":2,"name":"bob","email":"b@example.com"}]`))
+		}, "GET")
+
+		m.HandleFunc("/missing", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "no such thing", http.StatusNotFound)
+		}, "GET")
+
+		m.HandleFunc("/problem", func(w http.ResponseWriter, r *http.Request) {
+			WriteProblem(w, Problem{Status: http.StatusConflict})
+		}, "GET")
+
+		m.HandleFunc("/text", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("plain"))
+		}, "GET")
+	})
+
+	m.HandleFunc("/raw", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":1}`))
+	}, "GET")
+
+	var tests = []struct {
+		Path string
+
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{"/users", http.StatusOK, `{"data":[{"id":1,"name":"alice","email":"a@example.com"},{"id":2,"name":"bob","email":"b@example.com"}],"meta":{"total":2}}`},
+		{"/users?fields=id,name", http.StatusOK, `{"data":[{"id":1,"name":"alice"},{"id":2,"name":"bob"}],"meta":{"total":2}}`},
+		{"/missing", http.StatusNotFound, `{"error":{"message":"no such thing","status":404}}`},
+		{"/problem", http.StatusConflict, `{"error":{"title":"Conflict","status":409}}`},
+		{"/text", http.StatusOK, `plain`},
+		{"/raw", http.StatusOK, `{"id":1}`},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.Path, nil)
+		rr := httptest.NewRecorder()
+
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but was %d", test.Path, test.ExpectedStatus, rr.Code)
+		}
+
+		if body := strings.TrimSpace(rr.Body.String()); body != test.ExpectedBody {
+			t.Errorf("%s: expected body %s but was %s", test.Path, test.ExpectedBody, body)
+		}
+
+		if strings.HasPrefix(test.Path, "/users") && rr.Header().Get("X-Content-Type-Options") != "nosniff" {
+			t.Errorf("%s: expected the X-Content-Type-Options header to be kept", test.Path)
+		}
+	}
+}
+
+func TestFields(t *testing.T) {
+	m := New()
+	m.Use(Fields("fields"))
+	m.HandleFunc("/user", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"id": 1, "name": "alice", "email": "a@example.com"})
+	}, "GET")
+
+	var tests = []struct {
+		Path string
+
+		ExpectedBody string
+	}{
+		{"/user", `{"email":"a@example.com","id":1,"name":"alice"}`},
+		{"/user?fields=name", `{"name":"alice"}`},
+		{"/user?fields=name,+id,unknown", `{"id":1,"name":"alice"}`},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.Path, nil)
+		rr := httptest.NewRecorder()
+
+		m.ServeHTTP(rr, r)
+
+		if body := strings.TrimSpace(rr.Body.String()); body != test.ExpectedBody {
+			t.Errorf("%s: expected body %s but was %s", test.Path, test.ExpectedBody, body)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package flow
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+)
+
+// HTTPError is an error with an associated HTTP status code. When an HTTPError
+// (or an error wrapping one) reaches an ErrorHandler, the status code is used
+// for the response.
+type HTTPError struct {
+	Status int
+	Err    error
+}
+
+// Abort returns an HTTPError with the given status code. Combined with the
+// Recover middleware, it allows handlers to stop processing a request with
+// panic(flow.Abort(http.StatusNotFound)).
+func Abort(status int) HTTPError {
+	return HTTPError{Status: status}
+}
+
+func (e HTTPError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+
+	return http.StatusText(e.Status)
+}
+
+func (e HTTPError) Unwrap() error {
+	return e.Err
+}
+
+// PanicError is the error passed to an ErrorHandler by the Recover middleware
+// when a handler panics. If the panic value is itself an error, it can be
+// retrieved with errors.As or errors.Is.
+type PanicError struct {
+	Value any
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// StatusCode returns the HTTP status code for an error. It returns the status of
+// the first HTTPError in the error's chain. Failing that, if the chain contains
+// a gRPC status error (one with a GRPCStatus method, such as those returned by
+// gRPC clients), the gRPC code is translated with GRPCStatusCode. Otherwise it
+// returns 500 Internal Server Error.
+func StatusCode(err error) int {
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Status
+	}
+
+	if code, _, ok := grpcStatus(err); ok {
+		return GRPCStatusCode(code)
+	}
+
+	return http.StatusInternalServerError
+}
+
+// ErrorHandler is a function which sends an error response to the client.
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// DefaultErrorHandler sends a plain-text response using the status code from
+// StatusCode(err). Errors with a 5xx status code are logged (including the
+// stack trace, for a PanicError), but the details are not sent to the client.
+func DefaultErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	status := StatusCode(err)
+
+	if status >= 500 {
+		logError(r, err)
+	}
+
+	http.Error(w, http.StatusText(status), status)
+}
+
+// ProblemErrorHandler is like DefaultErrorHandler, but sends an
+// application/problem+json response (see Problem). For errors with a 4xx status
+// code, the detail member describes the error: the message from a gRPC status
+// error, or the error text (if it's different from the title).
+func ProblemErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	p := Problem{Status: StatusCode(err)}
+
+	if p.Status >= 500 {
+		logError(r, err)
+	} else if _, message, ok := grpcStatus(err); ok {
+		p.Detail = message
+	} else if detail := err.Error(); detail != http.StatusText(p.Status) {
+		p.Detail = detail
+	}
+
+	WriteProblem(w, p)
+}
+
+func logError(r *http.Request, err error) {
+	prefix := fmt.Sprintf("flow: %s %s", r.Method, r.URL.Path)
+	if name := HandlerName(r.Context()); name != "" {
+		prefix += " (" + name + ")"
+	}
+
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		log.Printf("%s: %s\n%s", prefix, err, panicErr.Stack)
+	} else {
+		log.Printf("%s: %s", prefix, err)
+	}
+}
+
+// Recover returns middleware which recovers from panics in later handlers and
+// passes them to errorHandler as a *PanicError. If errorHandler is nil,
+// DefaultErrorHandler is used. Panicking with an HTTPError (for example, using
+// Abort) results in a response with that status code rather than a 500.
+//
+// Panics with the value http.ErrAbortHandler are not recovered, so that they
+// abort the response as normal. If the handler hijacked the connection before
+// panicking, there is no response to send, so the panic is logged instead of
+// being passed to errorHandler.
+func Recover(errorHandler ErrorHandler) func(http.Handler) http.Handler {
+	if errorHandler == nil {
+		errorHandler = DefaultErrorHandler
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			rw := &recoverWriter{ResponseWriter: w}
+
+			defer func() {
+				if v := recover(); v != nil {
+					if v == http.ErrAbortHandler {
+						panic(v)
+					}
+
+					err := &PanicError{Value: v, Stack: debug.Stack()}
+					if IsHijacked(rw) {
+						logError(r, err)
+						return
+					}
+					errorHandler(w, r, err)
+				}
+			}()
+
+			next.ServeHTTP(rw, r)
+		})
+	}
+}
+
+// recoverWriter records whether the connection was hijacked, so that Recover
+// doesn't write an error response to it.
+type recoverWriter struct {
+	http.ResponseWriter
+	hijackTracker
+}
+
+func (rw *recoverWriter) Flush() {
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+func (rw *recoverWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return rw.hijack(rw.ResponseWriter)
+}
+
+func (rw *recoverWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,112 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecover(t *testing.T) {
+	logOutput := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(logOutput)
+
+	errSentinel := errors.New("sentinel")
+
+	var tests = []struct {
+		Name       string
+		PanicValue any
+
+		ExpectedStatus int
+	}{
+		{"abort", Abort(http.StatusNotFound), http.StatusNotFound},
+		{"wrapped http error", fmt.Errorf("loading user: %w", HTTPError{Status: http.StatusForbidden, Err: errSentinel}), http.StatusForbidden},
+		{"plain error", errSentinel, http.StatusInternalServerError},
+		{"string", "oops", http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		var received error
+
+		m := New()
+		m.Use(Recover(func(w http.ResponseWriter, r *http.Request, err error) {
+			received = err
+			DefaultErrorHandler(w, r, err)
+		}))
+		m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			panic(test.PanicValue)
+		}, "GET")
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but was %d", test.Name, test.ExpectedStatus, rr.Code)
+		}
+
+		var panicErr *PanicError
+		if !errors.As(received, &panicErr) || panicErr.Value != test.PanicValue {
+			t.Errorf("%s: expected a *PanicError with the panic value; got %v", test.Name, received)
+		}
+
+		if err, ok := test.PanicValue.(error); ok && !errors.Is(received, err) {
+			t.Errorf("%s: expected errors.Is to find the panic value", test.Name)
+		}
+	}
+}
+
+func TestRecoverErrAbortHandler(t *testing.T) {
+	m := New()
+	m.Use(Recover(nil))
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}, "GET")
+
+	defer func() {
+		if recover() != http.ErrAbortHandler {
+			t.Errorf("expected http.ErrAbortHandler to be re-panicked")
+		}
+	}()
+
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestProblemErrorHandler(t *testing.T) {
+	logOutput := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(logOutput)
+
+	var tests = []struct {
+		Err error
+
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{Abort(http.StatusNotFound), http.StatusNotFound, `{"title":"Not Found","status":404}`},
+		{HTTPError{Status: http.StatusBadRequest, Err: errors.New("missing name")}, http.StatusBadRequest, `{"title":"Bad Request","status":400,"detail":"missing name"}`},
+		{&fakeStatusError{&fakeStatus{6, "user exists"}}, http.StatusConflict, `{"title":"Conflict","status":409,"detail":"user exists"}`},
+		{errors.New("secret"), http.StatusInternalServerError, `{"title":"Internal Server Error","status":500}`},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		ProblemErrorHandler(rr, httptest.NewRequest("GET", "/", nil), test.Err)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%v: expected status %d but was %d", test.Err, test.ExpectedStatus, rr.Code)
+		}
+
+		if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("%v: expected problem+json Content-Type but was %q", test.Err, ct)
+		}
+
+		if body := strings.TrimSpace(rr.Body.String()); body != test.ExpectedBody {
+			t.Errorf("%v: expected body %s but was %s", test.Err, test.ExpectedBody, body)
+		}
+	}
+}
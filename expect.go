@@ -0,0 +1,100 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ExpectContinue is middleware which decides whether to accept a request sent
+// with an "Expect: 100-continue" header before the client sends its body.
+// Clients uploading large bodies send this header and wait for a 100 Continue
+// response, so a request which is rejected here costs the client a round trip
+// rather than a whole upload. Use its Middleware method with Use:
+//
+//	expect := &flow.ExpectContinue{
+//		MaxBytes: 100 << 20,
+//		Check: func(r *http.Request) error {
+//			if r.Header.Get("Authorization") == "" {
+//				return flow.HTTPError{Status: http.StatusUnauthorized}
+//			}
+//			return nil
+//		},
+//	}
+//	mux.Use(expect.Middleware)
+//
+// The Go HTTP server sends the 100 Continue response when the handler first
+// reads the request body, so a request which passes the checks is handled as
+// usual. A rejected request gets an error response (with "Connection: close",
+// as the client may send the body anyway) without its body being read.
+// Requests without the header aren't checked; use Mux.MaxBodyBytes to limit
+// the size of all request bodies.
+type ExpectContinue struct {
+	// MaxBytes is the largest Content-Length which is accepted. Larger
+	// requests are rejected with 413 Content Too Large. If it is zero, there
+	// is no limit.
+	MaxBytes int64
+
+	// RequireLength rejects requests without a Content-Length header (which
+	// send a chunked body of unknown size) with 411 Length Required.
+	RequireLength bool
+
+	// Check, if it is set, is called to decide whether to accept the request,
+	// for example by checking its credentials. If it returns an error, the
+	// request is rejected: with the status of the error if it is an
+	// HTTPError, and with 417 Expectation Failed otherwise.
+	Check func(r *http.Request) error
+
+	// ErrorHandler sends the response when a request is rejected. If it is
+	// nil, DefaultErrorHandler is used.
+	ErrorHandler ErrorHandler
+}
+
+// Middleware applies the checks to the requests handled by next.
+func (e *ExpectContinue) Middleware(next http.Handler) http.Handler {
+	errorHandler := e.ErrorHandler
+	if errorHandler == nil {
+		errorHandler = DefaultErrorHandler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := e.check(r); err != nil {
+			w.Header().Set("Connection", "close")
+			errorHandler(w, r, err)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (e *ExpectContinue) check(r *http.Request) error {
+	switch {
+	case r.ContentLength < 0 && e.RequireLength:
+		return HTTPError{Status: http.StatusLengthRequired, Err: errors.New("flow: request body has no Content-Length")}
+	case e.MaxBytes > 0 && r.ContentLength > e.MaxBytes:
+		return HTTPError{Status: http.StatusRequestEntityTooLarge, Err: fmt.Errorf("flow: request body of %d bytes is larger than the limit of %d bytes", r.ContentLength, e.MaxBytes)}
+	}
+
+	if e.Check == nil {
+		return nil
+	}
+
+	err := e.Check(r)
+	if err == nil {
+		return nil
+	}
+
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
+		return err
+	}
+
+	return HTTPError{Status: http.StatusExpectationFailed, Err: err}
+}
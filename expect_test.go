@@ -0,0 +1,110 @@
+package flow
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpectContinue(t *testing.T) {
+	expect := &ExpectContinue{
+		MaxBytes:      10,
+		RequireLength: true,
+		Check: func(r *http.Request) error {
+			switch r.Header.Get("Authorization") {
+			case "":
+				return HTTPError{Status: http.StatusUnauthorized}
+			case "bad":
+				return errors.New("not today")
+			}
+			return nil
+		},
+	}
+
+	m := New()
+	m.Use(expect.Middleware)
+	m.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "got %d bytes", len(body))
+	}, "PUT")
+
+	var tests = []struct {
+		Expect        string
+		Authorization string
+		Body          string
+		Chunked       bool
+
+		ExpectedStatus int
+	}{
+		{"100-continue", "token", "hello", false, http.StatusOK},
+		{"100-Continue", "token", "hello", false, http.StatusOK},
+		{"100-continue", "token", "hello world", false, http.StatusRequestEntityTooLarge},
+		{"100-continue", "token", "hello", true, http.StatusLengthRequired},
+		{"100-continue", "", "hello", false, http.StatusUnauthorized},
+		{"100-continue", "bad", "hello", false, http.StatusExpectationFailed},
+		{"", "", "hello world", true, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("PUT", "/upload", strings.NewReader(test.Body))
+		if test.Chunked {
+			r.ContentLength = -1
+		}
+		if test.Expect != "" {
+			r.Header.Set("Expect", test.Expect)
+		}
+		if test.Authorization != "" {
+			r.Header.Set("Authorization", test.Authorization)
+		}
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%+v: expected status %d but got %d", test, test.ExpectedStatus, rr.Code)
+		}
+		if closes := rr.Header().Get("Connection") == "close"; closes != (test.ExpectedStatus != http.StatusOK) {
+			t.Errorf("%+v: unexpected Connection header %q", test, rr.Header().Get("Connection"))
+		}
+	}
+}
+
+func TestExpectContinueSkipsBody(t *testing.T) {
+	expect := &ExpectContinue{MaxBytes: 1 << 20}
+
+	m := New()
+	m.Use(expect.Middleware)
+	m.HandleFunc("/upload", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+	}, "PUT")
+
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// Send only the headers, as a client waiting for 100 Continue would.
+	fmt.Fprint(conn, "PUT /upload HTTP/1.1\r\nHost: example.com\r\nExpect: 100-continue\r\nContent-Length: 10485760\r\n\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d before sending the body but got %d", http.StatusRequestEntityTooLarge, resp.StatusCode)
+	}
+}
@@ -0,0 +1,161 @@
+package flow
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// FaultConfig describes the faults injected by a FaultInjector. Faults are
+// only injected when Enabled is true, and then only for the given Percent of
+// requests (between 0 and 100). For an affected request the Latency is added
+// first, then the connection is dropped if Drop is true, or an error response
+// is sent if ErrorStatus is non-zero.
+type FaultConfig struct {
+	Enabled     bool
+	Percent     float64
+	Latency     time.Duration
+	ErrorStatus int
+	Drop        bool
+}
+
+type faultConfigJSON struct {
+	Enabled     bool    `json:"enabled"`
+	Percent     float64 `json:"percent"`
+	Latency     string  `json:"latency"`
+	ErrorStatus int     `json:"error_status"`
+	Drop        bool    `json:"drop"`
+}
+
+// MarshalJSON encodes the config as JSON, with the latency formatted as a
+// duration string like "250ms".
+func (c FaultConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(faultConfigJSON{
+		Enabled:     c.Enabled,
+		Percent:     c.Percent,
+		Latency:     c.Latency.String(),
+		ErrorStatus: c.ErrorStatus,
+		Drop:        c.Drop,
+	})
+}
+
+// UnmarshalJSON decodes a config encoded by MarshalJSON.
+func (c *FaultConfig) UnmarshalJSON(b []byte) error {
+	var v faultConfigJSON
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	var latency time.Duration
+	if v.Latency != "" {
+		var err error
+		latency, err = time.ParseDuration(v.Latency)
+		if err != nil {
+			return err
+		}
+	}
+
+	*c = FaultConfig{
+		Enabled:     v.Enabled,
+		Percent:     v.Percent,
+		Latency:     latency,
+		ErrorStatus: v.ErrorStatus,
+		Drop:        v.Drop,
+	}
+
+	return nil
+}
+
+// FaultInjector injects latency, error responses or dropped connections into a
+// percentage of requests, for testing how clients cope with an unreliable
+// server. Use the Middleware method on the routes (or group) which should be
+// affected.
+//
+// The configuration can be changed at runtime with SetConfig, or over HTTP by
+// mounting the FaultInjector itself as an admin endpoint: a GET request returns
+// the current configuration as JSON, and a PUT request replaces it. The admin
+// endpoint should be protected by authentication middleware.
+type FaultInjector struct {
+	mu     sync.RWMutex
+	config FaultConfig
+}
+
+// NewFaultInjector returns a new FaultInjector using the given configuration.
+func NewFaultInjector(config FaultConfig) *FaultInjector {
+	return &FaultInjector{config: config}
+}
+
+// Config returns the current configuration.
+func (f *FaultInjector) Config() FaultConfig {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return f.config
+}
+
+// SetConfig replaces the current configuration.
+func (f *FaultInjector) SetConfig(config FaultConfig) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.config = config
+}
+
+// Middleware injects faults into requests according to the current
+// configuration.
+func (f *FaultInjector) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		config := f.Config()
+
+		if !config.Enabled || rand.Float64()*100 >= config.Percent {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if config.Latency > 0 {
+			timer := time.NewTimer(config.Latency)
+			select {
+			case <-timer.C:
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		switch {
+		case config.Drop:
+			panic(http.ErrAbortHandler)
+		case config.ErrorStatus != 0:
+			http.Error(w, http.StatusText(config.ErrorStatus), config.ErrorStatus)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// ServeHTTP implements the admin endpoint for reading and changing the
+// configuration.
+func (f *FaultInjector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPut:
+		var config FaultConfig
+
+		err := json.NewDecoder(r.Body).Decode(&config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		f.SetConfig(config)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f.Config())
+}
@@ -0,0 +1,67 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFaultInjector(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	f := NewFaultInjector(FaultConfig{})
+
+	m := New()
+	m.Handle("/admin/faults", f, "GET", "PUT")
+	m.Group(func(m *Mux) {
+		m.Use(f.Middleware)
+		m.HandleFunc("/api", hf, "GET")
+	})
+
+	get := func() int {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", "/api", nil))
+		return rr.Code
+	}
+
+	if status := get(); status != http.StatusOK {
+		t.Errorf("disabled: expected status %d but was %d", http.StatusOK, status)
+	}
+
+	body := `{"enabled": true, "percent": 100, "latency": "10ms", "error_status": 503}`
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("PUT", "/admin/faults", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("PUT /admin/faults: expected status %d but was %d", http.StatusOK, rr.Code)
+	}
+
+	expected := FaultConfig{Enabled: true, Percent: 100, Latency: 10 * time.Millisecond, ErrorStatus: 503}
+	if f.Config() != expected {
+		t.Errorf("expected config %+v but was %+v", expected, f.Config())
+	}
+
+	start := time.Now()
+	if status := get(); status != http.StatusServiceUnavailable {
+		t.Errorf("enabled: expected status %d but was %d", http.StatusServiceUnavailable, status)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("expected latency of at least 10ms; got %s", elapsed)
+	}
+
+	f.SetConfig(FaultConfig{Enabled: true, Percent: 0, ErrorStatus: 503})
+	if status := get(); status != http.StatusOK {
+		t.Errorf("zero percent: expected status %d but was %d", http.StatusOK, status)
+	}
+
+	f.SetConfig(FaultConfig{Enabled: true, Percent: 100, Drop: true})
+	func() {
+		defer func() {
+			if recover() != http.ErrAbortHandler {
+				t.Errorf("drop: expected panic with http.ErrAbortHandler")
+			}
+		}()
+		get()
+	}()
+}
@@ -33,11 +33,20 @@
 //		// for a named parameter.
 //		mux.HandleFunc("/profile/:name/:age|^[0-9]{1,3}$", exampleHandlerFunc2, "GET")
 //
+//		// Regular expressions are matched against the percent-decoded segment, and
+//		// can use flags and unicode character classes.
+//		mux.HandleFunc("/tags/:slug|(?i)^[\p{L}0-9-]+$", exampleHandlerFunc6, "GET")
+//
 //		// The wildcard ... can be used to match the remainder of a request path.
 //		// Notice that HTTP methods are also optional (if not provided, all HTTP
-//		// methods will match the route).
+//		// methods except TRACE and CONNECT will match the route).
 //		mux.Handle("/static/...", exampleHandler)
 //
+//		// The wildcard can also be used in the middle of a pattern, in which
+//		// case it matches as many segments as possible while still allowing the
+//		// rest of the pattern to match the end of the request path.
+//		mux.HandleFunc("/files/.../meta", exampleHandlerFunc5, "GET")
+//
 //		// You can create route 'groups'.
 //		mux.Group(func(mux *flow.Mux) {
 //			// Middleware declared within in the group will only be used on the routes
@@ -61,22 +70,29 @@ package flow
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // AllMethods is a slice containing all HTTP request methods.
 var AllMethods = []string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace}
 
-var compiledRXPatterns = map[string]*regexp.Regexp{}
-
 type contextKey string
 
 // Param is used to retrieve the value of a named parameter or wildcard from the
-// request context. It returns the empty string if no matching parameter is
-// found.
+// request context. The value of a named catch-all like :path... is retrieved
+// with its name ("path"). The key "..." also retrieves the value of the
+// route's wildcard whether or not it's named, but for a named catch-all it is
+// deprecated in favour of the name. It returns the empty string if no matching
+// parameter is found.
 func Param(ctx context.Context, param string) string {
 	s, ok := ctx.Value(contextKey(param)).(string)
 	if !ok {
@@ -91,13 +107,117 @@ type Mux struct {
 	NotFound         http.Handler
 	MethodNotAllowed http.Handler
 	Options          http.Handler
-	routes           *[]route
-	middlewares      []func(http.Handler) http.Handler
+
+	// BadRequest is used for requests whose path contains NUL bytes or
+	// invalid percent-encoding. These requests are rejected before any
+	// routes are matched.
+	BadRequest http.Handler
+
+	// MaxURLLength is the maximum length of the request target (the path
+	// and query string) in bytes. Requests with a longer target are passed
+	// to the URITooLong handler before any routes are matched. A value of
+	// zero means there is no limit.
+	MaxURLLength int
+	URITooLong   http.Handler
+
+	// MaxBodyBytes limits the size of request bodies. When it is greater than
+	// zero, the request body is wrapped with http.MaxBytesReader before the
+	// request is dispatched, so reading more than MaxBodyBytes bytes returns
+	// an error (which Bind reports as 413 Content Too Large).
+	MaxBodyBytes int64
+
+	// AllowTrace and AllowConnect control whether the TRACE and CONNECT
+	// methods are included when a route is registered without any HTTP
+	// methods. They are false by default, which protects against cross-site
+	// tracing (XST) and unintended tunneling. Routes which explicitly list
+	// TRACE or CONNECT are not affected by these settings.
+	AllowTrace   bool
+	AllowConnect bool
+
+	// DefaultMethods sets the HTTP methods that a route will match when it is
+	// registered without any methods. When it is nil, AllMethods is used
+	// (subject to the AllowTrace and AllowConnect settings). When it is
+	// non-nil it is used exactly as given, so it is possible to include
+	// TRACE or CONNECT here.
+	DefaultMethods []string
+
+	// DisableAutoHead stops HEAD being added to the methods of routes which
+	// handle GET (see Handle). HEAD requests for those routes then get a 405
+	// Method Not Allowed response, unless another route handles HEAD. Like
+	// middleware, it applies to routes registered after it is set. Routes
+	// registered without any methods still handle HEAD, as it is one of the
+	// default methods.
+	DisableAutoHead bool
+
+	// DisableAutoOptions turns off the automatic responses to OPTIONS
+	// requests. OPTIONS requests which aren't handled by a route (with the
+	// OPTIONS method or an OptionsHandler) are then passed to the
+	// MethodNotAllowed handler like other unsupported methods, and OPTIONS
+	// is only listed in the Allow header for paths with a route which
+	// registers it.
+	DisableAutoOptions bool
+
+	// CustomMethods lists any non-standard HTTP methods (such as PROPFIND or
+	// PURGE) which may be used when registering routes. Registering a route
+	// with a method that isn't in AllMethods or CustomMethods will cause a
+	// panic.
+	CustomMethods []string
+
+	// WildcardNotFound controls the response when a request path matches one
+	// or more wildcard routes, but none of them allow the request method. By
+	// default a 405 Method Not Allowed response (with an Allow header) is
+	// sent. If WildcardNotFound is true, wildcard routes are ignored when
+	// working out the allowed methods, and the NotFound handler is used
+	// unless a non-wildcard route matches the path.
+	WildcardNotFound bool
+
+	// TrailingSlash controls what happens when a request path doesn't match
+	// any route, but would match with a trailing slash added or removed. By
+	// default (StrictSlash) the request gets a 404 Not Found response. See
+	// TrailingSlashPolicy for the alternatives.
+	TrailingSlash TrailingSlashPolicy
+
+	// MiddlewareTiming is an optional hook for measuring how much each
+	// middleware contributes to the latency of a route. When it is set, routes
+	// registered afterwards call it at the end of every request: once for each
+	// middleware registered with Use (with the function name of the
+	// middleware, like "flow.Recover.func1"), and once with the name "handler"
+	// for the route's handler. The duration for a middleware excludes the time
+	// spent in the middleware and handler after it. Timing adds overhead to
+	// every request, so it's intended for diagnosing slow routes rather than
+	// for permanent use.
+	MiddlewareTiming func(route, middleware string, d time.Duration)
+
+	// LoadError sends the response when a loader registered with Load
+	// returns an error. If it is nil, DefaultErrorHandler is used. Like
+	// middleware, it applies to routes registered after it is set.
+	LoadError ErrorHandler
+
+	// StaticCacheControl is the Cache-Control header sent with files served
+	// by Static. If it is empty, DefaultStaticCacheControl is used. Like
+	// middleware, it applies to routes registered after it is set.
+	StaticCacheControl string
+
+	// StaticPrecompressed controls whether Static and StaticFS look for
+	// precompressed (.br and .gz) versions of the files they serve. Like
+	// StaticCacheControl, it applies to routes registered after it is set.
+	StaticPrecompressed bool
+
+	routes      *routeTable
+	middlewares []func(http.Handler) http.Handler
+	loaders     []paramLoader
+	client      *ClientPolicy
+	log         logSettings
+	prefix      string
+	levels      []int
+	host        []segment
+	hostPattern string
+	meta        map[string]any
 }
 
-// New returns a new initialized Mux instance.
-func New() *Mux {
-	return &Mux{
+// New returns a new initialized Mux instance, with any options applied.
+func New(opts ...Option) *Mux {
+	m := &Mux{
 		NotFound: http.NotFoundHandler(),
 		MethodNotAllowed: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
@@ -105,47 +225,241 @@ func New() *Mux {
 		Options: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusNoContent)
 		}),
-		routes: &[]route{},
+		BadRequest: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		}),
+		URITooLong: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusRequestURITooLong), http.StatusRequestURITooLong)
+		}),
+		routes: &routeTable{},
+	}
+
+	for _, opt := range opts {
+		opt(m)
 	}
+
+	return m
 }
 
 // Handle registers a new handler for the given request path pattern and HTTP
-// methods.
-func (m *Mux) Handle(pattern string, handler http.Handler, methods ...string) {
-	if slices.Contains(methods, http.MethodGet) && !slices.Contains(methods, http.MethodHead) {
-		methods = append(methods, http.MethodHead)
+// methods, and returns the new Route. If no methods are given, the route will
+// match the methods in DefaultMethods or, if that isn't set, all of the methods
+// in AllMethods except TRACE and CONNECT (see the AllowTrace and AllowConnect
+// fields). Method names are case-insensitive, and Handle will panic if a
+// method is not recognized or if the pattern contains more than one wildcard.
+// The empty pattern "" is treated the same as "/", and matches requests for
+// the root path only (or for the prefix itself, inside Route). A route which
+// handles GET also handles HEAD, unless DisableAutoHead is set (see also
+// Route.WithoutHead).
+func (m *Mux) Handle(pattern string, handler http.Handler, methods ...string) *Route {
+	route, err := m.newRoute(pattern, handler, methods)
+	if err != nil {
+		panic(err.Error())
 	}
 
+	m.routes.add(route)
+
+	return route
+}
+
+// newRoute creates a route for Handle, returning an error if the pattern or
+// methods are invalid.
+func (m *Mux) newRoute(pattern string, handler http.Handler, methods []string) (*Route, error) {
+	route := new(Route)
+	if err := m.initRoute(route, nil, pattern, handler, methods); err != nil {
+		return nil, err
+	}
+
+	return route, nil
+}
+
+// initRoute sets up route in place, so that HandleAll can allocate many routes
+// at once. If buf is long enough, the parsed segments of the pattern are
+// stored at the start of it rather than in a new slice.
+func (m *Mux) initRoute(route *Route, buf []segment, pattern string, handler http.Handler, methods []string) error {
 	if len(methods) == 0 {
-		methods = AllMethods
+		methods = m.defaultMethods()
 	}
 
+	hasMethod := func(method string) bool {
+		return slices.ContainsFunc(methods, func(s string) bool { return strings.EqualFold(s, method) })
+	}
+	autoHead := !m.DisableAutoHead && hasMethod(http.MethodGet) && !hasMethod(http.MethodHead)
+	if autoHead {
+		methods = append(slices.Clip(methods), http.MethodHead)
+	}
+
+	pattern = m.fullPattern(pattern)
+	segments := strings.Split(pattern, "/")
+
+	if countWildcards(segments) > 1 {
+		return fmt.Errorf("flow: pattern %q contains more than one wildcard", pattern)
+	}
+
+	if len(buf) >= len(segments) {
+		buf = buf[:len(segments):len(segments)]
+	} else {
+		buf = nil
+	}
+
+	parsed, err := parseSegments(segments, buf)
+	if err != nil {
+		return fmt.Errorf("flow: invalid route %q: %s", pattern, err)
+	}
+
+	*route = Route{
+		pattern:     pattern,
+		segments:    parsed,
+		wildcard:    countWildcards(segments) > 0,
+		handler:     m.wrapRoute(pattern, m.wrapLoaders(parsed, handler)),
+		table:       m.routes,
+		handlerName: defaultHandlerName(handler),
+		log:         m.log,
+		levels:      m.levels,
+		host:        m.host,
+		hostPattern: m.hostPattern,
+		meta:        m.meta,
+	}
+	route.prefix, route.static = staticPrefix(pattern, parsed)
+	route.autoHead = autoHead
+
 	for _, method := range methods {
-		route := route{
-			method:   strings.ToUpper(method),
-			segments: strings.Split(pattern, "/"),
-			wildcard: strings.HasSuffix(pattern, "/..."),
-			handler:  m.wrap(handler),
+		method = strings.ToUpper(method)
+		if !slices.Contains(AllMethods, method) && !slices.ContainsFunc(m.CustomMethods, func(s string) bool { return strings.EqualFold(s, method) }) {
+			return fmt.Errorf("flow: invalid HTTP method %q in route %q (use Mux.CustomMethods to allow non-standard methods)", method, pattern)
 		}
 
-		*m.routes = append(*m.routes, route)
+		if bit := methodBit(method); bit != 0 {
+			route.methods |= bit
+		} else if !slices.Contains(route.customMethods, method) {
+			route.customMethods = append(route.customMethods, method)
+		}
 	}
 
-	// Compile any regular expression patterns and add them to the
-	// compiledRXPatterns map.
-	for _, segment := range strings.Split(pattern, "/") {
-		if strings.HasPrefix(segment, ":") {
-			_, rxPattern, containsRx := strings.Cut(segment, "|")
-			if containsRx {
-				compiledRXPatterns[rxPattern] = regexp.MustCompile(rxPattern)
-			}
+	if m.client != nil {
+		route.client, route.clientBudget = m.client, &retryBudget{}
+	}
+
+	return nil
+}
+
+// fullPattern returns the pattern for a route registered with m, including the
+// prefix of the enclosing Route groups.
+func (m *Mux) fullPattern(pattern string) string {
+	if pattern = m.prefix + pattern; pattern == "" {
+		return "/"
+	}
+
+	return pattern
+}
+
+// isWildcard reports whether a pattern segment is a wildcard: either ... or a
+// named catch-all parameter like :path... (which can't have a constraint).
+func isWildcard(segment string) bool {
+	return segment == "..." || (strings.HasPrefix(segment, ":") && strings.HasSuffix(segment, "...") && !strings.Contains(segment, "|"))
+}
+
+func countWildcards(segments []string) int {
+	n := 0
+	for _, segment := range segments {
+		if isWildcard(segment) {
+			n++
 		}
 	}
+
+	return n
+}
+
+func (m *Mux) defaultMethods() []string {
+	if m.DefaultMethods != nil {
+		return m.DefaultMethods
+	}
+
+	methods := make([]string, 0, len(AllMethods))
+
+	for _, method := range AllMethods {
+		if method == http.MethodTrace && !m.AllowTrace {
+			continue
+		}
+		if method == http.MethodConnect && !m.AllowConnect {
+			continue
+		}
+		methods = append(methods, method)
+	}
+
+	return methods
 }
 
 // HandleFunc is an adapter which allows using a http.HandlerFunc as a handler.
-func (m *Mux) HandleFunc(pattern string, fn http.HandlerFunc, methods ...string) {
-	m.Handle(pattern, fn, methods...)
+func (m *Mux) HandleFunc(pattern string, fn http.HandlerFunc, methods ...string) *Route {
+	return m.Handle(pattern, fn, methods...)
+}
+
+// Get registers fn for GET requests to the pattern (and so also HEAD requests,
+// which are handled automatically unless DisableAutoHead is set). It's
+// shorthand for m.HandleFunc(pattern, fn, "GET").
+func (m *Mux) Get(pattern string, fn http.HandlerFunc) *Route {
+	return m.Handle(pattern, fn, http.MethodGet)
+}
+
+// Post registers fn for POST requests to the pattern.
+func (m *Mux) Post(pattern string, fn http.HandlerFunc) *Route {
+	return m.Handle(pattern, fn, http.MethodPost)
+}
+
+// Put registers fn for PUT requests to the pattern.
+func (m *Mux) Put(pattern string, fn http.HandlerFunc) *Route {
+	return m.Handle(pattern, fn, http.MethodPut)
+}
+
+// Patch registers fn for PATCH requests to the pattern.
+func (m *Mux) Patch(pattern string, fn http.HandlerFunc) *Route {
+	return m.Handle(pattern, fn, http.MethodPatch)
+}
+
+// Delete registers fn for DELETE requests to the pattern.
+func (m *Mux) Delete(pattern string, fn http.HandlerFunc) *Route {
+	return m.Handle(pattern, fn, http.MethodDelete)
+}
+
+// Head registers fn for HEAD requests to the pattern. Because routes are matched
+// in the order they are declared, it must be declared before a GET route for
+// the same pattern to take over the HEAD requests from it, unless the GET route
+// doesn't handle HEAD (see DisableAutoHead and Route.WithoutHead).
+func (m *Mux) Head(pattern string, fn http.HandlerFunc) *Route {
+	return m.Handle(pattern, fn, http.MethodHead)
+}
+
+// HandleOptions registers fn for OPTIONS requests to the pattern, replacing the
+// automatic response (see Mux.Options) for that pattern. It isn't called
+// Options because of the Mux.Options field.
+func (m *Mux) HandleOptions(pattern string, fn http.HandlerFunc) *Route {
+	return m.Handle(pattern, fn, http.MethodOptions)
+}
+
+// HandleIf registers the handler in the same way as Handle, but only if cond is
+// true. It allows debug-only routes (such as test fixtures or fault injection
+// endpoints) to be declared alongside the other routes, while only being
+// registered when they are enabled. For example:
+//
+//	mux.HandleIf(flow.EnvEnabled("DEBUG_ROUTES"), "/debug/reset", resetHandler, "POST")
+//
+// If cond is false, the returned Route isn't registered with the Mux, and
+// configuring it has no effect.
+func (m *Mux) HandleIf(cond bool, pattern string, handler http.Handler, methods ...string) *Route {
+	if !cond {
+		return &Route{pattern: pattern}
+	}
+
+	return m.Handle(pattern, handler, methods...)
+}
+
+// EnvEnabled reports whether the environment variable with the given name is set
+// to a true value, as understood by strconv.ParseBool ("1", "t", "true" etc).
+// It returns false if the variable is unset or can't be parsed.
+func EnvEnabled(name string) bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(name))
+	return enabled
 }
 
 // Use registers middleware with the Mux instance. Middleware must have the
@@ -154,36 +468,154 @@ func (m *Mux) Use(mw ...func(http.Handler) http.Handler) {
 	m.middlewares = append(m.middlewares, mw...)
 }
 
+// Without removes middleware which was registered with Use (in the current
+// group, or the groups enclosing it) from the routes registered afterwards in
+// the group. It's useful for routes such as health checks and metrics
+// endpoints which shouldn't run the full middleware stack:
+//
+//	mux.Use(requestLogger, requireLogin)
+//	mux.Group(func(mux *flow.Mux) {
+//		mux.Without(requestLogger, requireLogin)
+//		mux.HandleFunc("/healthz", healthz, "GET")
+//	})
+//
+// Middleware is identified by its function, so the middleware returned by
+// different calls to the same constructor (such as flow.Recover) counts as the
+// same. Without panics if a middleware isn't in use, which usually means it was
+// registered after the group was created or the wrong function was given.
+func (m *Mux) Without(mw ...func(http.Handler) http.Handler) {
+	for _, fn := range mw {
+		if !slices.ContainsFunc(m.middlewares, func(used func(http.Handler) http.Handler) bool { return sameFunc(used, fn) }) {
+			panic(fmt.Sprintf("flow: middleware %s is not in use", funcName(fn)))
+		}
+	}
+
+	m.middlewares = slices.DeleteFunc(slices.Clone(m.middlewares), func(used func(http.Handler) http.Handler) bool {
+		return slices.ContainsFunc(mw, func(fn func(http.Handler) http.Handler) bool { return sameFunc(used, fn) })
+	})
+}
+
 // Group is used to create 'groups' of routes in a Mux. Middleware registered
 // inside the group will only be used on the routes in that group. See the
 // example code at the start of the package documentation for how to use this
 // feature.
 func (m *Mux) Group(fn func(*Mux)) {
+	fn(m.NewGroup(""))
+}
+
+// Route is like Group, but the patterns of all the routes registered inside
+// the group are prefixed with the given prefix. The prefix must begin with a
+// slash and must not end with one, and it may contain named parameters. Routes
+// can be nested, in which case the prefixes are combined. For example:
+//
+//	mux.Route("/api/v1", func(mux *flow.Mux) {
+//		mux.Use(requireAPIKey)
+//		mux.HandleFunc("/users/:id", showUser, "GET") // Matches /api/v1/users/:id
+//		mux.HandleFunc("", apiIndex, "GET")           // Matches /api/v1
+//		mux.HandleFunc("/", apiIndex, "GET")          // Matches /api/v1/
+//	})
+func (m *Mux) Route(prefix string, fn func(*Mux)) {
+	if prefix == "" {
+		panic(`flow: route prefix "" must begin with a slash and not end with one`)
+	}
+
+	fn(m.NewGroup(prefix))
+}
+
+// NewGroup returns a group like the one given to the function passed to Route
+// (or to Group, if prefix is empty), for when a callback is awkward, such as
+// when the routes are registered by another package:
+//
+//	api := mux.NewGroup("/api/v1")
+//	api.Use(requireAPIKey)
+//	users.RegisterRoutes(api)
+//	orders.RegisterRoutes(api)
+//
+// The group has the middleware and settings of m at the time NewGroup is
+// called, and changes made to the group don't affect m. It panics if prefix is
+// not empty and doesn't begin with a slash, or ends with one.
+func (m *Mux) NewGroup(prefix string) *Mux {
 	mm := *m
-	fn(&mm)
+	mm.middlewares = slices.Clip(m.middlewares)
+
+	if prefix != "" {
+		if !strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+			panic(fmt.Sprintf("flow: route prefix %q must begin with a slash and not end with one", prefix))
+		}
+
+		mm.prefix = m.prefix + prefix
+		mm.levels = append(slices.Clip(m.levels), countSegments(mm.prefix))
+	}
+
+	return &mm
 }
 
 // ServeHTTP makes the router implement the http.Handler interface.
 func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	urlSegments := strings.Split(r.URL.EscapedPath(), "/")
-	allowedMethods := []string{}
-
-	for _, route := range *m.routes {
-		ctx, ok := route.match(r.Context(), urlSegments)
-		if ok {
-			if r.Method == route.method {
-				route.handler.ServeHTTP(w, r.WithContext(ctx))
-				return
-			}
-			if !slices.Contains(allowedMethods, route.method) {
-				allowedMethods = append(allowedMethods, route.method)
-			}
+	if m.MaxURLLength > 0 && requestTargetLength(r) > m.MaxURLLength {
+		m.wrap(m.URITooLong).ServeHTTP(w, r)
+		return
+	}
+
+	if !validPath(r.URL) {
+		m.wrap(m.BadRequest).ServeHTTP(w, r)
+		return
+	}
+
+	if m.MaxBodyBytes > 0 && r.Body != nil {
+		// Copy the request, like http.MaxBytesHandler, so that the caller's
+		// request isn't modified.
+		r2 := *r
+		r2.Body = http.MaxBytesReader(w, r.Body, m.MaxBodyBytes)
+		r = &r2
+	}
+
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	n := countSegments(path)
+
+	// Track the methods allowed for the path using a bitmask (and a slice for
+	// any non-standard methods) so that no allocations are needed when the
+	// request is matched.
+	bit := methodBit(r.Method)
+	var allowed methodSet
+	var customAllowed []string
+
+	var params []param
+	host := requestHost{raw: r.Host}
+
+	for _, route := range m.routes.load() {
+		var ok bool
+		params, ok = route.match(&host, path, n, params[:0])
+		if !ok || !route.satisfies(r) {
+			continue
+		}
+
+		switch result, listed := m.resolveMethod(route, r, bit); result {
+		case routeOptions:
+			m.serveRouteOptions(w, r, route, params)
+			return
+		case routeServes:
+			r = r.WithContext(&routeContext{Context: r.Context(), route: route, params: params, header: r.Header})
+			route.handler.ServeHTTP(w, r)
+			return
+		case routeDisallows:
+			allowed, customAllowed = route.addAllowed(listed, allowed, customAllowed)
 		}
 	}
 
-	if len(allowedMethods) > 0 {
-		w.Header().Set("Allow", strings.Join(append(allowedMethods, http.MethodOptions), ", "))
-		if r.Method == http.MethodOptions {
+	if allowed == 0 && len(customAllowed) == 0 && m.serveTrailingSlash(w, r, &host, path, bit) {
+		return
+	}
+
+	if allowed != 0 || len(customAllowed) > 0 {
+		allow := m.allowHeader(allowed, customAllowed)
+		w.Header().Set("Allow", allow)
+		r = r.WithContext(context.WithValue(r.Context(), matchedMethodsContextKey{}, allow))
+
+		if r.Method == http.MethodOptions && !m.DisableAutoOptions {
 			m.wrap(m.Options).ServeHTTP(w, r)
 		} else {
 			m.wrap(m.MethodNotAllowed).ServeHTTP(w, r)
@@ -194,58 +626,399 @@ func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	m.wrap(m.NotFound).ServeHTTP(w, r)
 }
 
+// serveRouteOptions answers an OPTIONS request with the route's
+// OptionsHandler.
+func (m *Mux) serveRouteOptions(w http.ResponseWriter, r *http.Request, route *Route, params []param) {
+	allowed, custom := m.allowedMethods(r)
+	allow := m.allowHeader(allowed, custom)
+	w.Header().Set("Allow", allow)
+
+	ctx := context.WithValue(r.Context(), matchedMethodsContextKey{}, allow)
+	r = r.WithContext(&routeContext{Context: ctx, route: route, params: params, header: r.Header})
+	m.wrap(route.optionsHandler).ServeHTTP(w, r)
+}
+
+// requestTargetLength returns the length of the request target. For requests
+// received by a server this is the length of the raw RequestURI; otherwise it's
+// worked out from the URL.
+func requestTargetLength(r *http.Request) int {
+	if r.RequestURI != "" {
+		return len(r.RequestURI)
+	}
+
+	return len(r.URL.RequestURI())
+}
+
+// validPath reports whether the URL path is free of NUL bytes and, if the URL
+// has a RawPath, whether it uses valid percent-encoding.
+func validPath(u *url.URL) bool {
+	if strings.IndexByte(u.Path, 0) != -1 {
+		return false
+	}
+
+	if u.RawPath != "" {
+		if _, err := url.PathUnescape(u.RawPath); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// countSegments returns the number of segments in a request path, which is
+// one more than the number of slashes (the first segment is the empty string
+// before the leading slash).
+func countSegments(path string) int {
+	return strings.Count(path, "/") + 1
+}
+
+// nextSegment returns the segment of the path which starts at pos, running up
+// to the next slash or the end of the path.
+func nextSegment(path string, pos int) string {
+	if i := strings.IndexByte(path[pos:], '/'); i >= 0 {
+		return path[pos : pos+i]
+	}
+	return path[pos:]
+}
+
 func (m *Mux) wrap(handler http.Handler) http.Handler {
-	for i := len(m.middlewares) - 1; i >= 0; i-- {
-		handler = m.middlewares[i](handler)
+	return wrapMiddleware(handler, m.middlewares)
+}
+
+// unescape returns the percent-decoded form of a URL path segment, so that
+// regular expression constraints are matched against the actual characters in
+// the segment (which allows unicode character classes like \p{L} to work). If
+// the segment isn't validly encoded, it is returned unchanged.
+func unescape(segment string) string {
+	if !strings.Contains(segment, "%") {
+		return segment
+	}
+
+	s, err := url.PathUnescape(segment)
+	if err != nil {
+		return segment
 	}
 
-	return handler
+	return s
 }
 
-type route struct {
-	method   string
-	segments []string
-	wildcard bool
-	handler  http.Handler
+// Route is a route registered with a Mux. Its methods can be used to configure
+// the route further, and return the Route so that calls can be chained.
+type Route struct {
+	pattern       string
+	segments      []segment
+	wildcard      bool
+	autoHead      bool   // Whether HEAD was added because the route handles GET.
+	prefix        string // The static part at the start of the pattern (see staticPrefix).
+	static        int    // The number of segments in prefix.
+	methods       methodSet
+	customMethods []string
+	handler       http.Handler
+	paramTypes    []routeParamType
+	tags          []string
+	table         *routeTable
+	handlerName   string // Set by HandlerNamed, or worked out from the handler.
+	name          string // Set by HandleNamed or Named, for Reverse.
+	log           logSettings
+	levels        []int // The number of pattern segments in each enclosing Route prefix.
+	client        *ClientPolicy
+	clientBudget  *retryBudget
+	host          []segment
+	hostPattern   string
+	meta          map[string]any
+	conditions    []func(*http.Request) bool // Set by RouteBuilder.
+
+	// optionsHandler, if set, handles OPTIONS requests which match the route
+	// instead of the automatic response.
+	optionsHandler http.Handler
+
+	// allowedMethods, if set, asks a mounted handler which methods it allows
+	// for a request (see MethodLister).
+	allowedMethods func(*http.Request) []string
 }
 
-func (r *route) match(ctx context.Context, urlSegments []string) (context.Context, bool) {
-	if !r.wildcard && len(urlSegments) != len(r.segments) {
-		return ctx, false
+// wildcardKey returns the key for the value of the route's wildcard, or nil
+// if it doesn't have one.
+func (r *Route) wildcardKey() *paramKey {
+	for _, seg := range r.segments {
+		if seg.wildcard {
+			return seg.key
+		}
 	}
 
-	for i, routeSegment := range r.segments {
-		if i > len(urlSegments)-1 {
-			return ctx, false
+	return nil
+}
+
+// staticPrefix returns the part at the start of a pattern which is literal
+// text, up to and including the slash before its first parameter or wildcard,
+// and the number of segments it spans. A path which matches the pattern must
+// begin with the prefix. For a pattern without any parameters, the prefix is
+// the whole pattern. It's a substring of the pattern, so routes don't hold a
+// separate copy of it.
+func staticPrefix(pattern string, segments []segment) (string, int) {
+	n := 0
+	for i, seg := range segments {
+		if seg.param || seg.wildcard {
+			return pattern[:n], i
 		}
+		n += len(seg.value) + 1
+	}
+
+	return pattern, len(segments)
+}
 
-		if routeSegment == "..." {
-			ctx = context.WithValue(ctx, contextKey("..."), strings.Join(urlSegments[i:], "/"))
-			return ctx, true
+// satisfies reports whether the request meets the route's conditions.
+func (r *Route) satisfies(req *http.Request) bool {
+	for _, cond := range r.conditions {
+		if !cond(req) {
+			return false
 		}
+	}
 
-		if strings.HasPrefix(routeSegment, ":") {
-			key, rxPattern, containsRx := strings.Cut(strings.TrimPrefix(routeSegment, ":"), "|")
+	return true
+}
 
-			if containsRx {
-				if compiledRXPatterns[rxPattern].MatchString(urlSegments[i]) {
-					ctx = context.WithValue(ctx, contextKey(key), urlSegments[i])
-					continue
-				}
+// allows reports whether the route accepts the given request method. The bit
+// argument must be the result of methodBit(method).
+func (r *Route) allows(method string, bit methodSet) bool {
+	if bit != 0 {
+		return r.methods&bit != 0
+	}
+
+	return slices.Contains(r.customMethods, method)
+}
+
+// segment is a single parsed segment of a route pattern.
+type segment struct {
+	value    string            // The literal value, or the parameter name if param is true.
+	param    bool              // Whether the segment is a named parameter.
+	wildcard bool              // Whether the segment is the ... wildcard.
+	rx       *regexp.Regexp    // The regular expression constraint for a parameter (may be nil).
+	check    func(string) bool // The named constraint for a parameter (may be nil).
+	key      *paramKey         // The interned key for a parameter or wildcard.
+}
+
+// parseSegments parses the segments of a pattern into parsed, which must
+// either be nil or have the same length as segments.
+func parseSegments(segments []string, parsed []segment) ([]segment, error) {
+	if parsed == nil {
+		parsed = make([]segment, len(segments))
+	}
+
+	for i, s := range segments {
+		switch {
+		case s == "...":
+			parsed[i] = segment{wildcard: true, key: internParamKey("...")}
+		case isWildcard(s):
+			name := strings.TrimSuffix(strings.TrimPrefix(s, ":"), "...")
+			if name == "" {
+				return nil, fmt.Errorf("catch-all parameter %q has no name", s)
 			}
+			parsed[i] = segment{value: name, wildcard: true, key: internParamKey(name)}
+		case strings.HasPrefix(s, ":"):
+			key, rxPattern, containsRx := strings.Cut(strings.TrimPrefix(s, ":"), "|")
+			parsed[i] = segment{value: key, param: true, key: internParamKey(key)}
 
-			if !containsRx && urlSegments[i] != "" {
-				ctx = context.WithValue(ctx, contextKey(key), urlSegments[i])
+			if !containsRx {
 				continue
 			}
 
-			return ctx, false
+			if fn, ok := lookupConstraint(rxPattern); ok {
+				parsed[i].check = fn
+			} else if rxPattern != "" && isConstraintName(rxPattern) {
+				// A bare name is almost certainly a misspelt or unregistered
+				// constraint rather than a regular expression.
+				return nil, fmt.Errorf("unknown constraint %q (register it with RegisterConstraint first, or anchor a regular expression with ^ and $)", rxPattern)
+			} else {
+				rx, err := compileRX(rxPattern)
+				if err != nil {
+					return nil, fmt.Errorf("invalid regular expression %q: %w", rxPattern, err)
+				}
+				parsed[i].rx = rx
+			}
+		default:
+			parsed[i] = segment{value: s}
 		}
+	}
 
-		if urlSegments[i] != routeSegment {
-			return ctx, false
+	return parsed, nil
+}
+
+// param is the name and value of a parameter from a matched route.
+type param struct {
+	key   *paramKey
+	value string
+	typed any // The converted value, if the route has a ParamType for the parameter.
+}
+
+// paramKey holds the name of a parameter. Parameter names are interned, so
+// that routes which use the same names share a single copy of each one.
+type paramKey struct {
+	name string
+}
+
+var paramKeys sync.Map
+
+func internParamKey(name string) *paramKey {
+	if key, ok := paramKeys.Load(name); ok {
+		return key.(*paramKey)
+	}
+
+	key, _ := paramKeys.LoadOrStore(name, &paramKey{name: name})
+	return key.(*paramKey)
+}
+
+type routeContextKey struct{}
+
+// routeContext makes the matched route and its parameters available through
+// the request context. Using a single context for all of them, rather than
+// calling context.WithValue for each one, means there is only one allocation
+// per request however many parameters the route has.
+type routeContext struct {
+	context.Context
+	route  *Route
+	params []param
+	header http.Header // The request headers, for Client.
+}
+
+func (c *routeContext) Value(key any) any {
+	switch key := key.(type) {
+	case contextKey:
+		for i := range c.params {
+			if c.params[i].key.name == string(key) {
+				return c.params[i].value
+			}
+		}
+		if key == "..." {
+			if wildcard := c.route.wildcardKey(); wildcard != nil {
+				for i := range c.params {
+					if c.params[i].key == wildcard {
+						return c.params[i].value
+					}
+				}
+			}
+		}
+	case typedParamKey:
+		for i := range c.params {
+			if c.params[i].key.name == string(key) && c.params[i].typed != nil {
+				return c.params[i].typed
+			}
+		}
+	case paramsContextKey:
+		return c.params
+	case routeContextKey:
+		return c.route
+	case requestHeaderContextKey:
+		return c.header
+	}
+
+	return c.Context.Value(key)
+}
+
+// match reports whether the route matches the request, appending the values
+// of its parameters to params. The path is walked segment by segment without
+// splitting it, and n must be the number of segments in the path (as returned
+// by countSegments), so that the value of a wildcard can be taken directly from
+// the path rather than by joining the segments it spans.
+func (r *Route) match(host *requestHost, path string, n int, params []param) ([]param, bool) {
+	if r.host != nil {
+		var ok bool
+		if params, ok = r.matchHost(host, params); !ok {
+			return params, false
+		}
+	}
+
+	start := len(params)
+
+	if !r.wildcard && n != len(r.segments) {
+		return params, false
+	}
+
+	// The static segments at the start of the pattern are compared in one
+	// step, so that routes sharing a long prefix like /api/v1/internal/ are
+	// rejected (or skipped past) without walking the path a segment at a time.
+	if r.static == len(r.segments) {
+		return params, path == r.pattern
+	}
+	if !strings.HasPrefix(path, r.prefix) {
+		return params, false
+	}
+
+	// When the route contains a wildcard, offset is the number of additional
+	// URL segments consumed by it.
+	offset := 0
+
+	// pos is the position in the path of the start of the current segment.
+	pos := len(r.prefix)
+
+	for i := r.static; i < len(r.segments); i++ {
+		routeSegment := r.segments[i]
+		j := i + offset
+		if j > n-1 {
+			return params, false
+		}
+
+		switch {
+		case routeSegment.wildcard:
+			// The wildcard consumes at least one URL segment, and as many as
+			// possible while leaving enough segments to match the rest of
+			// the route.
+			end := n - (len(r.segments) - i - 1)
+			if end <= j {
+				return params, false
+			}
+
+			stop := len(path)
+			for k := len(r.segments) - i - 1; k > 0; k-- {
+				stop = strings.LastIndexByte(path[:stop], '/')
+			}
+
+			params = append(params, param{key: routeSegment.key, value: path[pos:stop]})
+			offset = end - j - 1
+			pos = stop + 1
+			continue
+		}
+
+		urlSegment := nextSegment(path, pos)
+
+		switch {
+		case routeSegment.param:
+			if routeSegment.rx != nil && !routeSegment.rx.MatchString(unescape(urlSegment)) {
+				return params, false
+			}
+
+			if routeSegment.check != nil && !routeSegment.check(unescape(urlSegment)) {
+				return params, false
+			}
+
+			if routeSegment.rx == nil && routeSegment.check == nil && urlSegment == "" {
+				return params, false
+			}
+
+			params = append(params, param{key: routeSegment.key, value: urlSegment})
+
+		default:
+			if urlSegment != routeSegment.value {
+				return params, false
+			}
+		}
+
+		pos += len(urlSegment) + 1
+	}
+
+	for _, pt := range r.paramTypes {
+		for i := start; i < len(params); i++ {
+			if params[i].key.name != pt.name {
+				continue
+			}
+
+			typed, err := pt.typ.Parse(unescape(params[i].value))
+			if err != nil {
+				return params, false
+			}
+			params[i].typed = typed
 		}
 	}
 
-	return ctx, true
+	return params, true
 }
@@ -2,9 +2,13 @@ package flow
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -69,6 +73,16 @@ func TestMatching(t *testing.T) {
 			"GET", "/prefix",
 			http.StatusNotFound, nil, "",
 		},
+		{
+			[]string{"GET", "PUT"}, "/prefix/...",
+			"DELETE", "/prefix/anything/else",
+			http.StatusMethodNotAllowed, nil, "GET, HEAD, PUT, OPTIONS",
+		},
+		{
+			[]string{"GET"}, "/prefix/...",
+			"OPTIONS", "/prefix/anything/else",
+			http.StatusNoContent, nil, "GET, HEAD, OPTIONS",
+		},
 		{
 			[]string{"GET"}, "/prefix",
 			"GET", "/prefix/anything/else",
@@ -84,6 +98,31 @@ func TestMatching(t *testing.T) {
 			"GET", "/prefix/anything/else",
 			http.StatusNotFound, nil, "",
 		},
+		{
+			[]string{"GET"}, "/files/.../meta",
+			"GET", "/files/a/b/c/meta",
+			http.StatusOK, map[string]string{"...": "a/b/c"}, "",
+		},
+		{
+			[]string{"GET"}, "/files/.../meta",
+			"GET", "/files/a/meta/meta",
+			http.StatusOK, map[string]string{"...": "a/meta"}, "",
+		},
+		{
+			[]string{"GET"}, "/files/.../meta/:format",
+			"GET", "/files/a/b/meta/json",
+			http.StatusOK, map[string]string{"...": "a/b", "format": "json"}, "",
+		},
+		{
+			[]string{"GET"}, "/files/.../meta",
+			"GET", "/files/meta",
+			http.StatusNotFound, nil, "",
+		},
+		{
+			[]string{"GET"}, "/files/.../meta",
+			"GET", "/files/a/b/other",
+			http.StatusNotFound, nil, "",
+		},
 		// path params
 		{
 			[]string{"GET"}, "/path-params/:era/:group/:member",
@@ -111,6 +150,26 @@ func TestMatching(t *testing.T) {
 			"GET", "/path-params/abc/123",
 			http.StatusNotFound, nil, "",
 		},
+		{
+			[]string{"GET"}, "/path-params/:slug|(?i)^[a-z]+$",
+			"GET", "/path-params/ABC",
+			http.StatusOK, map[string]string{"slug": "ABC"}, "",
+		},
+		{
+			[]string{"GET"}, "/path-params/:slug|^[a-z]+$",
+			"GET", "/path-params/ABC",
+			http.StatusNotFound, nil, "",
+		},
+		{
+			[]string{"GET"}, "/path-params/:word|^\\p{L}+$",
+			"GET", "/path-params/%C3%A9t%C3%A9",
+			http.StatusOK, map[string]string{"word": "%C3%A9t%C3%A9"}, "",
+		},
+		{
+			[]string{"GET"}, "/path-params/:name|^[a-z ]+$",
+			"GET", "/path-params/john%20doe",
+			http.StatusOK, map[string]string{"name": "john%20doe"}, "",
+		},
 		// kitchen sink
 		{
 			[]string{"GET"}, "/path-params/:id/:era|^[0-9]{2}$/...",
@@ -122,6 +181,32 @@ func TestMatching(t *testing.T) {
 			"GET", "/path-params/abc/12",
 			http.StatusNotFound, nil, "",
 		},
+		// root path
+		{
+			[]string{"GET"}, "/",
+			"GET", "/",
+			http.StatusOK, nil, "",
+		},
+		{
+			[]string{"GET"}, "",
+			"GET", "/",
+			http.StatusOK, nil, "",
+		},
+		{
+			[]string{"GET"}, "/",
+			"GET", "",
+			http.StatusOK, nil, "",
+		},
+		{
+			[]string{"GET"}, "/",
+			"GET", "/one",
+			http.StatusNotFound, nil, "",
+		},
+		{
+			[]string{"GET"}, "",
+			"GET", "/one",
+			http.StatusNotFound, nil, "",
+		},
 		// leading and trailing slashes
 		{
 			[]string{"GET"}, "slashes/one",
@@ -208,6 +293,21 @@ func TestMatching(t *testing.T) {
 			"DELETE", "/one",
 			http.StatusOK, nil, "",
 		},
+		{
+			[]string{}, "/one",
+			"TRACE", "/one",
+			http.StatusMethodNotAllowed, nil, "",
+		},
+		{
+			[]string{}, "/one",
+			"CONNECT", "/one",
+			http.StatusMethodNotAllowed, nil, "",
+		},
+		{
+			[]string{"TRACE"}, "/one",
+			"TRACE", "/one",
+			http.StatusOK, nil, "",
+		},
 		// method casing
 		{
 			[]string{"gEt"}, "/one",
@@ -234,13 +334,13 @@ func TestMatching(t *testing.T) {
 		{
 			[]string{"GET", "PUT"}, "/one",
 			"DELETE", "/one",
-			http.StatusMethodNotAllowed, nil, "GET, PUT, HEAD, OPTIONS",
+			http.StatusMethodNotAllowed, nil, "GET, HEAD, PUT, OPTIONS",
 		},
 		// options
 		{
 			[]string{"GET", "PUT"}, "/one",
 			"OPTIONS", "/one",
-			http.StatusNoContent, nil, "GET, PUT, HEAD, OPTIONS",
+			http.StatusNoContent, nil, "GET, HEAD, PUT, OPTIONS",
 		},
 	}
 
@@ -452,6 +552,9 @@ func TestCustomHandlers(t *testing.T) {
 	m.Options = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("custom options handler"))
 	})
+	m.BadRequest = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("custom bad request handler"))
+	})
 
 	m.HandleFunc("/", hf, "GET")
 
@@ -476,6 +579,11 @@ func TestCustomHandlers(t *testing.T) {
 			RequestPath:   "/",
 			ExpectedBody:  "custom options handler",
 		},
+		{
+			RequestMethod: "GET",
+			RequestPath:   "/foo%00bar",
+			ExpectedBody:  "custom bad request handler",
+		},
 	}
 
 	for _, test := range tests {
@@ -501,6 +609,572 @@ func TestCustomHandlers(t *testing.T) {
 	}
 }
 
+func TestAllowTraceAndConnect(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.AllowTrace = true
+	m.HandleFunc("/trace", hf)
+
+	m.AllowTrace = false
+	m.AllowConnect = true
+	m.HandleFunc("/connect", hf)
+
+	var tests = []struct {
+		RequestMethod string
+		RequestPath   string
+
+		ExpectedStatus int
+	}{
+		{"TRACE", "/trace", http.StatusOK},
+		{"CONNECT", "/trace", http.StatusMethodNotAllowed},
+		{"CONNECT", "/connect", http.StatusOK},
+		{"TRACE", "/connect", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		r, err := http.NewRequest(test.RequestMethod, test.RequestPath, nil)
+		if err != nil {
+			t.Errorf("NewRequest: %s", err)
+		}
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.RequestMethod, test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+	}
+}
+
+func TestDefaultMethods(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.DefaultMethods = []string{"GET", "OPTIONS"}
+	m.HandleFunc("/", hf)
+
+	var tests = []struct {
+		RequestMethod string
+
+		ExpectedStatus int
+	}{
+		{"GET", http.StatusOK},
+		{"HEAD", http.StatusOK},
+		{"OPTIONS", http.StatusOK},
+		{"POST", http.StatusMethodNotAllowed},
+		{"DELETE", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		r, err := http.NewRequest(test.RequestMethod, "/", nil)
+		if err != nil {
+			t.Errorf("NewRequest: %s", err)
+		}
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s /: expected status %d but was %d", test.RequestMethod, test.ExpectedStatus, rr.Code)
+		}
+	}
+}
+
+func TestMethodValidation(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	t.Run("unknown method", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic for unknown method")
+			}
+		}()
+
+		m := New()
+		m.HandleFunc("/", hf, "GTE")
+	})
+
+	t.Run("custom method", func(t *testing.T) {
+		m := New()
+		m.CustomMethods = []string{"purge"}
+		m.HandleFunc("/", hf, "PURGE")
+
+		r, err := http.NewRequest("PURGE", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("expected status %d but was %d", http.StatusOK, rr.Code)
+		}
+
+		r, err = http.NewRequest("DELETE", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr = httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if allow := rr.Header().Get("Allow"); allow != "PURGE, OPTIONS" {
+			t.Errorf("expected Allow header %q but was %q", "PURGE, OPTIONS", allow)
+		}
+	})
+}
+
+func TestMultipleWildcards(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for pattern with more than one wildcard")
+		}
+	}()
+
+	m := New()
+	m.HandleFunc("/files/.../meta/...", hf, "GET")
+}
+
+func TestNamedCatchAll(t *testing.T) {
+	var named, alias, id string
+
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		named = Param(r.Context(), "path")
+		alias = Param(r.Context(), "...")
+		id = Param(r.Context(), "id")
+	}
+
+	m := New()
+	m.HandleFunc("/repos/:id/files/:path.../raw", hf, "GET")
+	m.HandleFunc("/docs/:path...", hf, "GET")
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedStatus int
+		ExpectedPath   string
+		ExpectedID     string
+	}{
+		{"/repos/7/files/a/b%2Fc/d.go/raw", http.StatusOK, "a/b%2Fc/d.go", "7"},
+		{"/repos/7/files/raw", http.StatusNotFound, "", ""},
+		{"/docs/guide/intro", http.StatusOK, "guide/intro", ""},
+	}
+
+	for _, test := range tests {
+		named, alias, id = "", "", ""
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but was %d", test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if named != test.ExpectedPath || alias != test.ExpectedPath {
+			t.Errorf("%s: expected path %q for both keys but got %q and %q", test.RequestPath, test.ExpectedPath, named, alias)
+		}
+		if id != test.ExpectedID {
+			t.Errorf("%s: expected id %q but got %q", test.RequestPath, test.ExpectedID, id)
+		}
+	}
+
+	for _, pattern := range []string{"/files/:path.../:name...", "/files/:..."} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", pattern)
+				}
+			}()
+
+			New().HandleFunc(pattern, hf, "GET")
+		}()
+	}
+}
+
+func TestWildcardNotFound(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.WildcardNotFound = true
+	m.HandleFunc("/files/...", hf, "GET")
+	m.HandleFunc("/files/special", hf, "PUT")
+
+	var tests = []struct {
+		RequestMethod string
+		RequestPath   string
+
+		ExpectedStatus      int
+		ExpectedAllowHeader string
+	}{
+		{"GET", "/files/a/b", http.StatusOK, ""},
+		{"DELETE", "/files/a/b", http.StatusNotFound, ""},
+		{"OPTIONS", "/files/a/b", http.StatusNotFound, ""},
+		{"DELETE", "/files/special", http.StatusMethodNotAllowed, "PUT, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		r, err := http.NewRequest(test.RequestMethod, test.RequestPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.RequestMethod, test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+
+		if allow := rr.Header().Get("Allow"); allow != test.ExpectedAllowHeader {
+			t.Errorf("%s %s: expected Allow header %q but was %q", test.RequestMethod, test.RequestPath, test.ExpectedAllowHeader, allow)
+		}
+	}
+}
+
+func TestMalformedPaths(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.HandleFunc("/...", hf, "GET")
+
+	var tests = []struct {
+		Path    string
+		RawPath string
+
+		ExpectedStatus int
+	}{
+		{"/foo/bar", "", http.StatusOK},
+		{"/foo/bar baz", "/foo/bar%20baz", http.StatusOK},
+		{"/foo\x00bar", "", http.StatusBadRequest},
+		{"/foo/bar", "/foo/%zzbar", http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		r, err := http.NewRequest("GET", "/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.URL.Path = test.Path
+		r.URL.RawPath = test.RawPath
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%q: expected status %d but was %d", test.Path, test.ExpectedStatus, rr.Code)
+		}
+	}
+}
+
+func TestMaxURLLength(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.MaxURLLength = 16
+	m.HandleFunc("/...", hf, "GET")
+
+	var tests = []struct {
+		RequestTarget string
+
+		ExpectedStatus int
+	}{
+		{"/short", http.StatusOK},
+		{"/exactly-16-byte", http.StatusOK},
+		{"/this/is/far/too/long", http.StatusRequestURITooLong},
+		{"/short?q=too-long", http.StatusRequestURITooLong},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.RequestTarget, nil)
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but was %d", test.RequestTarget, test.ExpectedStatus, rr.Code)
+		}
+	}
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	var readErr error
+
+	m := New()
+	m.MaxBodyBytes = 4
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		_, readErr = io.ReadAll(r.Body)
+	}, "POST")
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("too large"))
+	body := r.Body
+	m.ServeHTTP(httptest.NewRecorder(), r)
+
+	var maxBytesErr *http.MaxBytesError
+	if !errors.As(readErr, &maxBytesErr) {
+		t.Errorf("expected a MaxBytesError but got %v", readErr)
+	}
+	if r.Body != body {
+		t.Error("expected the caller's request body not to be replaced")
+	}
+}
+
+func TestHandleIf(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	t.Setenv("FLOW_TEST_ENABLED", "true")
+	t.Setenv("FLOW_TEST_DISABLED", "0")
+
+	m := New()
+	m.HandleIf(EnvEnabled("FLOW_TEST_ENABLED"), "/enabled", http.HandlerFunc(hf), "GET")
+	m.HandleIf(EnvEnabled("FLOW_TEST_DISABLED"), "/disabled", http.HandlerFunc(hf), "GET")
+	m.HandleIf(EnvEnabled("FLOW_TEST_UNSET"), "/unset", http.HandlerFunc(hf), "GET")
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedStatus int
+	}{
+		{"/enabled", http.StatusOK},
+		{"/disabled", http.StatusNotFound},
+		{"/unset", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("GET %s: expected status %d but was %d", test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+	}
+}
+
+func TestMethodShortcuts(t *testing.T) {
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}
+	}
+
+	m := New()
+	m.Head("/items", handler("head"))
+	m.Get("/items", handler("get"))
+	m.Post("/items", handler("post"))
+	m.Put("/items", handler("put"))
+	m.Patch("/items", handler("patch"))
+	m.Delete("/items", handler("delete"))
+	m.HandleOptions("/items", handler("options"))
+	m.Get("/other", handler("get"))
+
+	var tests = []struct {
+		RequestMethod string
+		RequestPath   string
+
+		ExpectedStatus int
+		ExpectedBody   string
+		ExpectedAllow  string
+	}{
+		{"GET", "/items", http.StatusOK, "get", ""},
+		{"HEAD", "/items", http.StatusOK, "head", ""},
+		{"POST", "/items", http.StatusOK, "post", ""},
+		{"PUT", "/items", http.StatusOK, "put", ""},
+		{"PATCH", "/items", http.StatusOK, "patch", ""},
+		{"DELETE", "/items", http.StatusOK, "delete", ""},
+		{"OPTIONS", "/items", http.StatusOK, "options", ""},
+		{"HEAD", "/other", http.StatusOK, "get", ""},
+		{"POST", "/other", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.RequestMethod, test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.RequestMethod, test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedStatus == http.StatusOK && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s %s: expected body %q but was %q", test.RequestMethod, test.RequestPath, test.ExpectedBody, rr.Body.String())
+		}
+		if allow := rr.Header().Get("Allow"); allow != test.ExpectedAllow {
+			t.Errorf("%s %s: expected Allow header %q but was %q", test.RequestMethod, test.RequestPath, test.ExpectedAllow, allow)
+		}
+	}
+}
+
+func TestExplicitOptions(t *testing.T) {
+	handler := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+
+	m := New()
+	m.HandleFunc("/items/:id", handler("get"), "GET")
+	m.HandleFunc("/items/:id", handler("options"), "OPTIONS", "PUT")
+	m.HandleFunc("/files/...", handler("options"), "OPTIONS")
+	m.HandleFunc("/files/readme", handler("get"), "GET")
+
+	var tests = []struct {
+		RequestMethod string
+		RequestPath   string
+
+		ExpectedStatus int
+		ExpectedBody   string
+		ExpectedAllow  string
+	}{
+		{"OPTIONS", "/items/1", http.StatusOK, "options", ""},
+		{"DELETE", "/items/1", http.StatusMethodNotAllowed, "", "GET, HEAD, PUT, OPTIONS"},
+		{"OPTIONS", "/files/readme", http.StatusOK, "options", ""},
+		{"POST", "/files/readme", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.RequestMethod, test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.RequestMethod, test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedStatus == http.StatusOK && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s %s: expected body %q but was %q", test.RequestMethod, test.RequestPath, test.ExpectedBody, rr.Body.String())
+		}
+		if allow := rr.Header().Get("Allow"); allow != test.ExpectedAllow {
+			t.Errorf("%s %s: expected Allow header %q but was %q", test.RequestMethod, test.RequestPath, test.ExpectedAllow, allow)
+		}
+	}
+}
+
+func TestWithout(t *testing.T) {
+	var used []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				used = append(used, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	logger := mw("logger")
+	auth := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			used = append(used, "auth")
+			next.ServeHTTP(w, r)
+		})
+	}
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.Use(logger, auth)
+	m.Group(func(m *Mux) {
+		m.Without(auth)
+		m.HandleFunc("/healthz", hf, "GET")
+		m.Group(func(m *Mux) {
+			m.Without(logger)
+			m.HandleFunc("/metrics", hf, "GET")
+		})
+	})
+	m.HandleFunc("/private", hf, "GET")
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedUsed []string
+	}{
+		{"/healthz", []string{"logger"}},
+		{"/metrics", nil},
+		{"/private", []string{"logger", "auth"}},
+	}
+
+	for _, test := range tests {
+		used = nil
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if !slices.Equal(used, test.ExpectedUsed) {
+			t.Errorf("%s: expected middleware %v but got %v", test.RequestPath, test.ExpectedUsed, used)
+		}
+	}
+
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "not in use") {
+			t.Errorf("expected panic for middleware which isn't in use but got %v", r)
+		}
+	}()
+	New().Without(auth)
+}
+
+func TestRoutePrefix(t *testing.T) {
+	var used []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				used = append(used, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Param(r.Context(), "org") + ":" + Param(r.Context(), "id")))
+	}
+
+	m := New()
+	m.Route("/api/v1", func(m *Mux) {
+		m.Use(mw("api"))
+		m.HandleFunc("", hf, "GET")
+		m.HandleFunc("/", hf, "GET")
+		m.HandleFunc("/users/:id", hf, "GET")
+
+		m.Route("/orgs/:org", func(m *Mux) {
+			m.Use(mw("org"))
+			m.HandleFunc("/members/:id", hf, "GET")
+		})
+	})
+	m.HandleFunc("/users/:id", hf, "GET")
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedStatus     int
+		ExpectedBody       string
+		ExpectedMiddleware string
+	}{
+		{"/api/v1", http.StatusOK, ":", "api"},
+		{"/api/v1/", http.StatusOK, ":", "api"},
+		{"/api/v1/users/1", http.StatusOK, ":1", "api"},
+		{"/api/v1/orgs/acme/members/2", http.StatusOK, "acme:2", "api,org"},
+		{"/users/3", http.StatusOK, ":3", ""},
+		{"/orgs/acme/members/2", http.StatusNotFound, "", ""},
+	}
+
+	for _, test := range tests {
+		used = nil
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("GET %s: expected status %d but was %d", test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedStatus == http.StatusOK && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("GET %s: expected body %q but was %q", test.RequestPath, test.ExpectedBody, rr.Body.String())
+		}
+		if middleware := strings.Join(used, ","); middleware != test.ExpectedMiddleware {
+			t.Errorf("GET %s: expected middleware %q but was %q", test.RequestPath, test.ExpectedMiddleware, middleware)
+		}
+	}
+
+	for _, prefix := range []string{"", "api", "/api/"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for prefix %q", prefix)
+				}
+			}()
+			m.Route(prefix, func(m *Mux) {})
+		}()
+	}
+}
+
 func TestParams(t *testing.T) {
 	var tests = []struct {
 		RouteMethods []string
@@ -550,3 +1224,229 @@ func TestParams(t *testing.T) {
 		}
 	}
 }
+
+func BenchmarkParams(b *testing.B) {
+	m := New()
+	m.HandleFunc("/orgs/:org/repos/:repo/issues/:issue", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+
+	r := httptest.NewRequest("GET", "/orgs/acme/repos/flow/issues/42", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkWildcard(b *testing.B) {
+	m := New()
+	m.HandleFunc("/static/.../raw", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+
+	r := httptest.NewRequest("GET", "/static/css/vendor/theme/main.css/raw", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkDeepPath(b *testing.B) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.HandleFunc("/a/b/c/d/e/f/g/h", hf, "GET")
+	m.HandleFunc("/a/b/c/d/e/f/g/:h/:i", hf, "GET")
+	m.HandleFunc("/a/b/c/d/e/f/g/h/i/j", hf, "GET")
+
+	r := httptest.NewRequest("GET", "/a/b/c/d/e/f/g/h/i/j", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m.ServeHTTP(w, r)
+	}
+}
+
+func BenchmarkSharedPrefix(b *testing.B) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	for i := 0; i < 200; i++ {
+		m.HandleFunc(fmt.Sprintf("/api/v1/internal/service%d/:id", i), hf, "GET")
+	}
+
+	r := httptest.NewRequest("GET", "/api/v1/internal/service199/42", nil)
+	w := httptest.NewRecorder()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		m.ServeHTTP(w, r)
+	}
+}
+
+func TestStaticPrefix(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		prefix   string
+		segments int
+	}{
+		{"/", "/", 2},
+		{"/users/new", "/users/new", 3},
+		{"/users/:id", "/users/", 2},
+		{"/api/v1/internal/:service/...", "/api/v1/internal/", 4},
+		{"/...", "/", 1},
+		{"/:lang/about", "/", 1},
+	}
+
+	for _, test := range tests {
+		m := New()
+		route := m.HandleFunc(test.pattern, func(w http.ResponseWriter, r *http.Request) {})
+
+		if route.prefix != test.prefix || route.static != test.segments {
+			t.Errorf("pattern %q: expected prefix %q (%d segments) but got %q (%d segments)", test.pattern, test.prefix, test.segments, route.prefix, route.static)
+		}
+	}
+
+	m := New()
+	m.HandleFunc("/users/new", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, "new") }, "GET")
+	m.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, Param(r.Context(), "id")) }, "GET")
+
+	for path, expected := range map[string]string{
+		"/users/new":  "new",
+		"/users/newx": "newx",
+		"/users/ne":   "ne",
+	} {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+
+		if rr.Body.String() != expected {
+			t.Errorf("%s: expected %q but got %q", path, expected, rr.Body.String())
+		}
+	}
+}
+
+func TestMatchDoesNotAllocate(t *testing.T) {
+	m := New()
+	m.HandleFunc("/orgs/:org/repos/:repo/.../raw", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+
+	route := m.routes.load()[0]
+	path := "/orgs/acme/repos/flow/blob/main/README.md/raw"
+	n := countSegments(path)
+	params := make([]param, 0, 8)
+
+	var ok bool
+	allocs := testing.AllocsPerRun(100, func() {
+		params, ok = route.match(&requestHost{}, path, n, params[:0])
+	})
+
+	if !ok {
+		t.Fatalf("expected %s to match", path)
+	}
+	if allocs != 0 {
+		t.Errorf("expected matching not to allocate but got %v allocs", allocs)
+	}
+	if got := params[2].value; got != "blob/main/README.md" {
+		t.Errorf("expected wildcard value %q but got %q", "blob/main/README.md", got)
+	}
+}
+
+func TestInternedParamKeys(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.HandleFunc("/users/:id", hf, "GET")
+	m.HandleFunc("/posts/:id/...", hf, "GET")
+
+	routes := m.routes.load()
+	if routes[0].segments[2].key != routes[1].segments[2].key {
+		t.Errorf("expected routes to share the interned key for the same parameter name")
+	}
+
+	if key := routes[1].segments[3].key; key == nil || key.name != "..." {
+		t.Errorf("expected wildcard segment to have an interned key")
+	}
+}
+
+func registerTestRoutes(m *Mux) {
+	m.HandleFunc("/items/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(RoutePattern(r.Context())))
+	}, "GET")
+}
+
+func TestNewGroup(t *testing.T) {
+	var used []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				used = append(used, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	m := New()
+	m.Use(mw("1"))
+	m.Use(mw("2"))
+	m.Use(mw("3"))
+
+	// The groups are used in turn, to check that they don't share
+	// middleware.
+	shop := m.NewGroup("/shop")
+	admin := m.NewGroup("/admin")
+	shop.Use(mw("shop"))
+	admin.Use(mw("admin"))
+	registerTestRoutes(shop)
+	registerTestRoutes(admin)
+
+	plain := m.NewGroup("")
+	plain.Use(mw("plain"))
+	registerTestRoutes(plain)
+
+	nested := shop.NewGroup("/v2")
+	registerTestRoutes(nested)
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedBody       string
+		ExpectedMiddleware string
+	}{
+		{"/shop/items/1", "/shop/items/:id", "1,2,3,shop"},
+		{"/admin/items/1", "/admin/items/:id", "1,2,3,admin"},
+		{"/items/1", "/items/:id", "1,2,3,plain"},
+		{"/shop/v2/items/1", "/shop/v2/items/:id", "1,2,3,shop"},
+	}
+
+	for _, test := range tests {
+		used = nil
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if rr.Body.String() != test.ExpectedBody {
+			t.Errorf("GET %s: expected body %q but was %q", test.RequestPath, test.ExpectedBody, rr.Body.String())
+		}
+		if middleware := strings.Join(used, ","); middleware != test.ExpectedMiddleware {
+			t.Errorf("GET %s: expected middleware %q but was %q", test.RequestPath, test.ExpectedMiddleware, middleware)
+		}
+	}
+
+	for _, prefix := range []string{"shop", "/shop/"} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected a panic for prefix %q", prefix)
+				}
+			}()
+			m.NewGroup(prefix)
+		}()
+	}
+}
@@ -0,0 +1,271 @@
+// Package flowtest provides utilities for testing applications built with flow.
+package flowtest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alexedwards/flow"
+)
+
+// Exchange is a recorded request and response.
+type Exchange struct {
+	Route    string           `json:"route"`
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the recorded form of a request.
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Target string      `json:"target"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// RecordedResponse is the recorded form of a response.
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// SensitiveHeaders are the headers removed from exchanges by the default
+// sanitizer.
+var SensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// Recorder is middleware which records requests and their responses to files,
+// so that they can be replayed later using Replay. Exchanges are appended (as
+// JSON lines) to a file in Dir named after the route which handled the
+// request, such as "GET_users_id.jsonl".
+type Recorder struct {
+	// Dir is the directory which the files are written to.
+	Dir string
+
+	// Mux is used to find the route pattern for each request. If it is nil,
+	// exchanges are grouped by request path instead.
+	Mux *flow.Mux
+
+	// Sanitize is called on each exchange before it is written, and should
+	// remove any secrets or personal data. If it is nil, the headers in
+	// SensitiveHeaders are removed.
+	Sanitize func(*Exchange)
+
+	mu sync.Mutex
+}
+
+// Middleware records the requests handled by next.
+func (rec *Recorder) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var reqBody []byte
+		if r.Body != nil {
+			var err error
+			reqBody, err = io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(reqBody))
+		}
+
+		cw := &captureWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(cw, r)
+
+		// There is no response to record for a hijacked connection.
+		if cw.hijacked {
+			return
+		}
+
+		route := r.URL.EscapedPath()
+		if rec.Mux != nil {
+			if info, _, ok := rec.Mux.MatchRequest(r); ok {
+				route = info.Pattern
+			}
+		}
+
+		ex := Exchange{
+			Route: route,
+			Request: RecordedRequest{
+				Method: r.Method,
+				Target: r.URL.RequestURI(),
+				Header: r.Header.Clone(),
+				Body:   string(reqBody),
+			},
+			Response: RecordedResponse{
+				Status: cw.status,
+				Header: w.Header().Clone(),
+				Body:   cw.body.String(),
+			},
+		}
+
+		if rec.Sanitize != nil {
+			rec.Sanitize(&ex)
+		} else {
+			for _, h := range SensitiveHeaders {
+				ex.Request.Header.Del(h)
+				ex.Response.Header.Del(h)
+			}
+		}
+
+		rec.write(r.Method, &ex)
+	})
+}
+
+func (rec *Recorder) write(method string, ex *Exchange) {
+	line, err := json.Marshal(ex)
+	if err != nil {
+		return
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	name := filepath.Join(rec.Dir, fileName(method, ex.Route))
+
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	f.Write(append(line, '\n'))
+}
+
+// fileName returns a file name for the exchanges of a route, replacing any
+// characters which aren't letters or digits with underscores.
+func fileName(method, route string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, strings.Trim(route, "/"))
+
+	if name == "" {
+		name = "root"
+	}
+
+	return method + "_" + name + ".jsonl"
+}
+
+type captureWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	hijacked    bool
+	body        bytes.Buffer
+}
+
+func (cw *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(cw.ResponseWriter).Hijack()
+	if err == nil {
+		cw.hijacked = true
+	}
+	return conn, rw, err
+}
+
+func (cw *captureWriter) Hijacked() bool {
+	return cw.hijacked
+}
+
+func (cw *captureWriter) WriteHeader(status int) {
+	if !cw.wroteHeader {
+		cw.status = status
+		cw.wroteHeader = true
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	cw.wroteHeader = true
+	cw.body.Write(b)
+	return cw.ResponseWriter.Write(b)
+}
+
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// ReplayHeaders are the response headers compared by Replay, in addition to
+// the status code and body.
+var ReplayHeaders = []string{"Content-Type", "Location"}
+
+// Replay reads the exchanges recorded in the given files, sends each request
+// to h, and reports an error on t for every response which has a different
+// status code, body or ReplayHeaders value to the recorded response.
+func Replay(t testing.TB, h http.Handler, files ...string) {
+	t.Helper()
+
+	for _, file := range files {
+		exchanges, err := ReadExchanges(file)
+		if err != nil {
+			t.Fatalf("flowtest: reading %s: %s", file, err)
+		}
+
+		for i, ex := range exchanges {
+			r := httptest.NewRequest(ex.Request.Method, ex.Request.Target, strings.NewReader(ex.Request.Body))
+			for key, values := range ex.Request.Header {
+				r.Header[key] = values
+			}
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, r)
+
+			prefix := fmt.Sprintf("%s:%d: %s %s", file, i+1, ex.Request.Method, ex.Request.Target)
+
+			if rr.Code != ex.Response.Status {
+				t.Errorf("%s: expected status %d but was %d", prefix, ex.Response.Status, rr.Code)
+			}
+
+			for _, key := range ReplayHeaders {
+				expected, actual := ex.Response.Header.Get(key), rr.Header().Get(key)
+				if expected != actual {
+					t.Errorf("%s: expected %s header %q but was %q", prefix, key, expected, actual)
+				}
+			}
+
+			if body := rr.Body.String(); body != ex.Response.Body {
+				t.Errorf("%s: expected body %q but was %q", prefix, ex.Response.Body, body)
+			}
+		}
+	}
+}
+
+// ReadExchanges reads the exchanges recorded in a file.
+func ReadExchanges(file string) ([]Exchange, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var exchanges []Exchange
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var ex Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &ex); err != nil {
+			return nil, err
+		}
+		exchanges = append(exchanges, ex)
+	}
+
+	return exchanges, scanner.Err()
+}
@@ -0,0 +1,74 @@
+package flowtest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/flow"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	newMux := func(greeting string) *flow.Mux {
+		m := flow.New()
+		m.HandleFunc("/greet/:name", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "%s %s", greeting, flow.Param(r.Context(), "name"))
+		}, "GET")
+		return m
+	}
+
+	m := newMux("Hello")
+	rec := &Recorder{Dir: dir, Mux: m}
+	recorded := rec.Middleware(m)
+
+	for _, name := range []string{"alice", "bob"} {
+		r := httptest.NewRequest("GET", "/greet/"+name, nil)
+		r.Header.Set("Authorization", "Bearer secret")
+		recorded.ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	file := filepath.Join(dir, "GET_greet__name.jsonl")
+
+	exchanges, err := ReadExchanges(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exchanges) != 2 {
+		t.Fatalf("expected 2 exchanges but got %d", len(exchanges))
+	}
+
+	if exchanges[0].Route != "/greet/:name" {
+		t.Errorf("expected route %q but was %q", "/greet/:name", exchanges[0].Route)
+	}
+
+	if exchanges[0].Request.Header.Get("Authorization") != "" {
+		t.Errorf("expected Authorization header to be removed")
+	}
+
+	Replay(t, newMux("Hello"), file)
+
+	ft := &fakeT{TB: t}
+	Replay(ft, newMux("Goodbye"), file)
+
+	if len(ft.errors) != 2 || !strings.Contains(ft.errors[0], `expected body "Hello alice" but was "Goodbye alice"`) {
+		t.Errorf("expected replay against a changed handler to report two body differences; got %q", ft.errors)
+	}
+}
+
+type fakeT struct {
+	testing.TB
+	errors []string
+}
+
+func (ft *fakeT) Helper() {}
+
+func (ft *fakeT) Errorf(format string, args ...any) {
+	ft.errors = append(ft.errors, fmt.Sprintf(format, args...))
+}
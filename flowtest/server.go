@@ -0,0 +1,105 @@
+package flowtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// traceHeader is the request header used to associate middleware executions
+// with the request which caused them.
+const traceHeader = "X-Flowtest-Trace"
+
+// Server is a httptest.Server which can record the middleware executed for each
+// request, so that tests can assert which middleware ran and in what order.
+type Server struct {
+	*httptest.Server
+
+	nextID atomic.Int64
+	mu     sync.Mutex
+	traces map[string][]string
+}
+
+// NewServer starts and returns a new Server which dispatches requests to h. The
+// caller should call Close when finished, to shut it down.
+func NewServer(h http.Handler) *Server {
+	return &Server{
+		Server: httptest.NewServer(h),
+		traces: map[string][]string{},
+	}
+}
+
+// Trace wraps the middleware mw so that its execution is recorded under the
+// given name. Register the result with the Mux in place of mw. For example:
+//
+//	srv := flowtest.NewServer(mux)
+//	mux.Use(srv.Trace("logger", logger))
+func (s *Server) Trace(name string, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		h := mw(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if id := r.Header.Get(traceHeader); id != "" {
+				s.mu.Lock()
+				s.traces[id] = append(s.traces[id], name)
+				s.mu.Unlock()
+			}
+
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Do sends a request to the server, and returns the response along with the
+// names of the traced middleware which were executed, in order. The request
+// URL may be relative to the server's URL.
+func (s *Server) Do(r *http.Request) (*http.Response, []string, error) {
+	r = r.Clone(r.Context())
+	if r.URL.Host == "" {
+		base, err := url.Parse(s.URL)
+		if err != nil {
+			return nil, nil, err
+		}
+		r.URL = base.ResolveReference(r.URL)
+		r.RequestURI = ""
+	}
+
+	id := strconv.FormatInt(s.nextID.Add(1), 10)
+	r.Header.Set(traceHeader, id)
+
+	resp, err := s.Client().Do(r)
+
+	s.mu.Lock()
+	trace := s.traces[id]
+	delete(s.traces, id)
+	s.mu.Unlock()
+
+	return resp, trace, err
+}
+
+// AssertMiddleware sends a request with the given method and path to the
+// server, and reports an error on t unless exactly the expected traced
+// middleware were executed, in the given order.
+func (s *Server) AssertMiddleware(t testing.TB, method, path string, expected ...string) {
+	t.Helper()
+
+	r, err := http.NewRequest(method, path, nil)
+	if err != nil {
+		t.Fatalf("flowtest: %s", err)
+	}
+
+	resp, trace, err := s.Do(r)
+	if err != nil {
+		t.Fatalf("flowtest: %s %s: %s", method, path, err)
+	}
+	resp.Body.Close()
+
+	if !slices.Equal(trace, expected) {
+		t.Errorf("%s %s: expected middleware %q but was %q", method, path, expected, trace)
+	}
+}
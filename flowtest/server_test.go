@@ -0,0 +1,36 @@
+package flowtest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alexedwards/flow"
+)
+
+func TestServerAssertMiddleware(t *testing.T) {
+	noop := func(next http.Handler) http.Handler { return next }
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := flow.New()
+	srv := NewServer(m)
+	defer srv.Close()
+
+	m.Use(srv.Trace("recover", noop), srv.Trace("logger", noop))
+	m.HandleFunc("/", hf, "GET")
+
+	m.Group(func(m *flow.Mux) {
+		m.Use(srv.Trace("auth", noop))
+		m.HandleFunc("/admin", hf, "GET")
+	})
+
+	srv.AssertMiddleware(t, "GET", "/", "recover", "logger")
+	srv.AssertMiddleware(t, "GET", "/admin", "recover", "logger", "auth")
+	srv.AssertMiddleware(t, "GET", "/missing", "recover", "logger")
+	srv.AssertMiddleware(t, "POST", "/admin", "recover", "logger")
+
+	ft := &fakeT{TB: t}
+	srv.AssertMiddleware(ft, "GET", "/admin", "auth", "logger", "recover")
+	if len(ft.errors) != 1 {
+		t.Errorf("expected wrong middleware order to be reported; got %q", ft.errors)
+	}
+}
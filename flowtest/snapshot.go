@@ -0,0 +1,126 @@
+package flowtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("flowtest.update", false, "update flowtest snapshot files instead of comparing against them")
+
+// Replacement is a normalization rule which replaces every match of Pattern in
+// a response with With. It's used to remove values which change between test
+// runs, such as timestamps or generated IDs.
+type Replacement struct {
+	Pattern *regexp.Regexp
+	With    string
+}
+
+// Snapshot compares responses against golden files. Run the tests with the
+// -flowtest.update flag (or with the FLOWTEST_UPDATE environment variable set
+// to 1) to create or update the files.
+type Snapshot struct {
+	// Dir is the directory containing the snapshot files. If it is empty,
+	// "testdata/snapshots" is used.
+	Dir string
+
+	// Headers are the response headers included in the snapshot. If it is
+	// nil, only the Content-Type header is included.
+	Headers []string
+
+	// Replace contains normalization rules applied to the snapshot before it
+	// is compared or written.
+	Replace []Replacement
+}
+
+// AssertSnapshot compares resp against the snapshot with the given name, using
+// the default Snapshot settings.
+func AssertSnapshot(t testing.TB, name string, resp *http.Response) {
+	t.Helper()
+	Snapshot{}.Assert(t, name, resp)
+}
+
+// Assert compares the status code, headers and body of resp against the
+// snapshot file with the given name, and reports an error on t if they are
+// different. JSON bodies are indented before comparison, so that the files are
+// readable and differences in whitespace are ignored.
+func (s Snapshot) Assert(t testing.TB, name string, resp *http.Response) {
+	t.Helper()
+
+	actual, err := s.render(resp)
+	if err != nil {
+		t.Fatalf("flowtest: rendering snapshot %s: %s", name, err)
+	}
+
+	dir := s.Dir
+	if dir == "" {
+		dir = filepath.Join("testdata", "snapshots")
+	}
+	file := filepath.Join(dir, name+".snap")
+
+	if *update || os.Getenv("FLOWTEST_UPDATE") == "1" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("flowtest: %s", err)
+		}
+		if err := os.WriteFile(file, []byte(actual), 0o644); err != nil {
+			t.Fatalf("flowtest: %s", err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("flowtest: reading snapshot %s (run with -flowtest.update to create it): %s", name, err)
+	}
+
+	if string(expected) != actual {
+		t.Errorf("flowtest: response does not match snapshot %s\n--- expected\n%s\n--- actual\n%s", name, expected, actual)
+	}
+}
+
+func (s Snapshot) render(resp *http.Response) (string, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	if strings.Contains(resp.Header.Get("Content-Type"), "json") {
+		var indented bytes.Buffer
+		if json.Indent(&indented, body, "", "  ") == nil {
+			body = indented.Bytes()
+		}
+	}
+
+	headers := s.Headers
+	if headers == nil {
+		headers = []string{"Content-Type"}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s\n", resp.StatusCode, http.StatusText(resp.StatusCode))
+	for _, key := range headers {
+		for _, value := range resp.Header.Values(key) {
+			fmt.Fprintf(&b, "%s: %s\n", http.CanonicalHeaderKey(key), value)
+		}
+	}
+	b.WriteString("\n")
+	b.Write(bytes.TrimRight(body, "\n"))
+	b.WriteString("\n")
+
+	snapshot := b.String()
+	for _, r := range s.Replace {
+		snapshot = r.Pattern.ReplaceAllString(snapshot, r.With)
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,56 @@
+package flowtest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"name":%q,"time":%q}`, name, time.Now().Format(time.RFC3339Nano))
+		}
+	}
+
+	s := Snapshot{
+		Dir:     dir,
+		Replace: []Replacement{{regexp.MustCompile(`"time": "[^"]+"`), `"time": "<time>"`}},
+	}
+
+	serve := func(h http.Handler) *http.Response {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		return rr.Result()
+	}
+
+	t.Setenv("FLOWTEST_UPDATE", "1")
+	s.Assert(t, "user", serve(handler("alice")))
+
+	contents, err := os.ReadFile(filepath.Join(dir, "user.snap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "200 OK\nContent-Type: application/json\n\n{\n  \"name\": \"alice\",\n  \"time\": \"<time>\"\n}\n"
+	if string(contents) != expected {
+		t.Errorf("expected snapshot %q but was %q", expected, contents)
+	}
+
+	t.Setenv("FLOWTEST_UPDATE", "")
+	s.Assert(t, "user", serve(handler("alice")))
+
+	ft := &fakeT{TB: t}
+	s.Assert(ft, "user", serve(handler("bob")))
+	if len(ft.errors) != 1 {
+		t.Errorf("expected a changed response to be reported; got %q", ft.errors)
+	}
+}
@@ -0,0 +1,108 @@
+package flow
+
+import (
+	"net/http"
+	"reflect"
+)
+
+// grpcStatusCodes maps gRPC status codes to HTTP status codes, following the
+// mapping used by grpc-gateway.
+var grpcStatusCodes = [...]int{
+	0:  http.StatusInternalServerError, // OK (an error shouldn't have this code)
+	1:  499,                            // Canceled (client closed request)
+	2:  http.StatusInternalServerError, // Unknown
+	3:  http.StatusBadRequest,          // InvalidArgument
+	4:  http.StatusGatewayTimeout,      // DeadlineExceeded
+	5:  http.StatusNotFound,            // NotFound
+	6:  http.StatusConflict,            // AlreadyExists
+	7:  http.StatusForbidden,           // PermissionDenied
+	8:  http.StatusTooManyRequests,     // ResourceExhausted
+	9:  http.StatusBadRequest,          // FailedPrecondition
+	10: http.StatusConflict,            // Aborted
+	11: http.StatusBadRequest,          // OutOfRange
+	12: http.StatusNotImplemented,      // Unimplemented
+	13: http.StatusInternalServerError, // Internal
+	14: http.StatusServiceUnavailable,  // Unavailable
+	15: http.StatusInternalServerError, // DataLoss
+	16: http.StatusUnauthorized,        // Unauthenticated
+}
+
+// GRPCStatusCode returns the HTTP status code corresponding to a gRPC status
+// code (such as codes.NotFound from google.golang.org/grpc/codes). Unknown
+// codes map to 500 Internal Server Error.
+func GRPCStatusCode(code uint32) int {
+	if int(code) >= len(grpcStatusCodes) {
+		return http.StatusInternalServerError
+	}
+	return grpcStatusCodes[code]
+}
+
+// grpcStatus looks for an error in err's chain with a GRPCStatus method, like
+// the errors returned by gRPC clients, and returns the code and message from
+// its status. Reflection is used so that flow doesn't depend on the grpc
+// module.
+func grpcStatus(err error) (code uint32, message string, ok bool) {
+	walkErrors(err, func(err error) bool {
+		method := reflect.ValueOf(err).MethodByName("GRPCStatus")
+		if !method.IsValid() || method.Type().NumIn() != 0 || method.Type().NumOut() != 1 {
+			return false
+		}
+
+		status := method.Call(nil)[0]
+		if status.Kind() == reflect.Pointer && status.IsNil() {
+			return false
+		}
+
+		codeMethod := status.MethodByName("Code")
+		if !codeMethod.IsValid() || codeMethod.Type().NumIn() != 0 || codeMethod.Type().NumOut() != 1 {
+			return false
+		}
+
+		c := codeMethod.Call(nil)[0]
+		switch c.Kind() {
+		case reflect.Uint32, reflect.Uint, reflect.Uint64:
+			code = uint32(c.Uint())
+		case reflect.Int32, reflect.Int, reflect.Int64:
+			code = uint32(c.Int())
+		default:
+			return false
+		}
+
+		if messageMethod := status.MethodByName("Message"); messageMethod.IsValid() &&
+			messageMethod.Type().NumIn() == 0 && messageMethod.Type().NumOut() == 1 &&
+			messageMethod.Type().Out(0).Kind() == reflect.String {
+			message = messageMethod.Call(nil)[0].String()
+		}
+
+		ok = true
+		return true
+	})
+
+	return code, message, ok
+}
+
+// walkErrors calls fn for each error in err's tree, in the same order as
+// errors.As, until fn returns true.
+func walkErrors(err error, fn func(error) bool) bool {
+	for err != nil {
+		if fn(err) {
+			return true
+		}
+
+		switch u := err.(type) {
+		case interface{ Unwrap() error }:
+			err = u.Unwrap()
+		case interface{ Unwrap() []error }:
+			for _, err := range u.Unwrap() {
+				if walkErrors(err, fn) {
+					return true
+				}
+			}
+			return false
+		default:
+			return false
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,54 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// fakeCode, fakeStatus and fakeStatusError mimic the shape of the types in
+// google.golang.org/grpc/codes and google.golang.org/grpc/status.
+type fakeCode uint32
+
+type fakeStatus struct {
+	code    fakeCode
+	message string
+}
+
+func (s *fakeStatus) Code() fakeCode  { return s.code }
+func (s *fakeStatus) Message() string { return s.message }
+
+type fakeStatusError struct {
+	s *fakeStatus
+}
+
+func (e *fakeStatusError) Error() string           { return "rpc error: " + e.s.message }
+func (e *fakeStatusError) GRPCStatus() *fakeStatus { return e.s }
+
+func TestGRPCStatusCode(t *testing.T) {
+	var tests = []struct {
+		Err error
+
+		ExpectedStatus  int
+		ExpectedMessage string
+	}{
+		{&fakeStatusError{&fakeStatus{5, "user not found"}}, http.StatusNotFound, "user not found"},
+		{fmt.Errorf("loading user: %w", &fakeStatusError{&fakeStatus{7, "denied"}}), http.StatusForbidden, "denied"},
+		{errors.Join(errors.New("other"), &fakeStatusError{&fakeStatus{14, "down"}}), http.StatusServiceUnavailable, "down"},
+		{&fakeStatusError{&fakeStatus{99, "weird"}}, http.StatusInternalServerError, "weird"},
+		{HTTPError{Status: http.StatusTeapot, Err: &fakeStatusError{&fakeStatus{5, "x"}}}, http.StatusTeapot, "x"},
+		{&fakeStatusError{}, http.StatusInternalServerError, ""},
+		{errors.New("plain"), http.StatusInternalServerError, ""},
+	}
+
+	for _, test := range tests {
+		if status := StatusCode(test.Err); status != test.ExpectedStatus {
+			t.Errorf("%v: expected status %d but was %d", test.Err, test.ExpectedStatus, status)
+		}
+
+		if _, message, _ := grpcStatus(test.Err); message != test.ExpectedMessage {
+			t.Errorf("%v: expected message %q but was %q", test.Err, test.ExpectedMessage, message)
+		}
+	}
+}
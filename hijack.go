@@ -0,0 +1,59 @@
+package flow
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// IsHijacked reports whether the connection for w has been hijacked (for
+// example, to upgrade it to a WebSocket). It checks each ResponseWriter in the
+// chain of wrappers (following Unwrap methods) for a Hijacked method, which
+// the wrappers used by flow's middleware implement. Middleware should use it
+// after calling the next handler to avoid writing to, buffering or otherwise
+// tracking a response that no longer exists:
+//
+//	next.ServeHTTP(w, r)
+//	if flow.IsHijacked(w) {
+//		return
+//	}
+//
+// Only hijacks made through a wrapper which records them can be detected, so
+// the chain for w must include one: for example, middleware registered after
+// IdleTimeout receives a ResponseWriter which does. ResponseWriter wrappers in
+// other packages can take part by implementing Hijack (recording the hijack)
+// and a Hijacked() bool method.
+func IsHijacked(w http.ResponseWriter) bool {
+	for w != nil {
+		if h, ok := w.(interface{ Hijacked() bool }); ok && h.Hijacked() {
+			return true
+		}
+
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return false
+		}
+		w = u.Unwrap()
+	}
+
+	return false
+}
+
+// hijackTracker records whether a ResponseWriter wrapper has been hijacked.
+// It's embedded in the wrappers used by flow's middleware.
+type hijackTracker struct {
+	hijacked bool
+}
+
+func (t *hijackTracker) Hijacked() bool {
+	return t.hijacked
+}
+
+// hijack hijacks the connection for w, recording it in t if it succeeds.
+func (t *hijackTracker) hijack(w http.ResponseWriter) (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err == nil {
+		t.hijacked = true
+	}
+	return conn, rw, err
+}
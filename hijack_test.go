@@ -0,0 +1,148 @@
+package flow
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsHijacked(t *testing.T) {
+	results := make(chan error, 1)
+	hijacked := make(chan bool, 1)
+
+	m := New()
+	m.Use(IdleTimeout(20 * time.Millisecond))
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			hijacked <- IsHijacked(w)
+		})
+	})
+	m.Use(Envelope)
+	m.HandleFunc("/upgrade", func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			results <- err
+			return
+		}
+		defer conn.Close()
+
+		if !IsHijacked(w) {
+			t.Errorf("expected IsHijacked to be true inside the handler")
+		}
+
+		// Wait for longer than the idle timeout; the context shouldn't be
+		// canceled because the connection has been hijacked.
+		time.Sleep(60 * time.Millisecond)
+		results <- r.Context().Err()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\nhello")
+		rw.Flush()
+	}, "GET")
+	m.HandleFunc("/plain", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET /upgrade HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+
+	if err := <-results; err != nil {
+		t.Errorf("unexpected error in handler: %v", err)
+	}
+
+	b, err := io.ReadAll(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\nhello"; string(b) != expected {
+		t.Errorf("expected raw response %q but got %q", expected, b)
+	}
+
+	if !<-hijacked {
+		t.Errorf("expected IsHijacked to be true in outer middleware")
+	}
+
+	resp, err := http.Get(ts.URL + "/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if <-hijacked {
+		t.Errorf("expected IsHijacked to be false for a normal request")
+	}
+}
+
+func TestHijackedMiddleware(t *testing.T) {
+	var access bytes.Buffer
+	logging := &Logging{Access: slog.New(slog.NewTextHandler(&access, nil))}
+	uow := &testUnitOfWork{}
+	handled := make(chan struct{})
+
+	m := New()
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer close(handled)
+			next.ServeHTTP(w, r)
+		})
+	})
+	m.Use(logging.Middleware)
+	m.Use(Transaction[*testTx](uow, nil))
+	m.Use(Recover(nil))
+	m.HandleFunc("/upgrade", func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n")
+		rw.Flush()
+		panic("connection failed")
+	}, "GET")
+
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	conn.Write([]byte("GET /upgrade HTTP/1.1\r\nHost: example.com\r\n\r\n"))
+
+	b, err := io.ReadAll(bufio.NewReader(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-handled
+
+	// Recover mustn't write an error response to the hijacked connection.
+	if expected := "HTTP/1.1 101 Switching Protocols\r\nConnection: close\r\n\r\n"; string(b) != expected {
+		t.Errorf("expected raw response %q but got %q", expected, b)
+	}
+
+	if uow.last.committed || !uow.last.rolledBack {
+		t.Errorf("expected transaction to be rolled back but got %+v", uow.last)
+	}
+
+	if line := access.String(); !strings.Contains(line, "status=101") || !strings.Contains(line, "hijacked=true") {
+		t.Errorf("expected hijacked request to be logged with status 101 but got %q", line)
+	}
+}
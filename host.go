@@ -0,0 +1,117 @@
+package flow
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Host is like Group, but the routes registered inside the group only match
+// requests for the given host (from the request's Host header, ignoring any
+// port). The host pattern is matched label by label, and a label can be a
+// named parameter, whose value is available with Param like a path parameter.
+// Literal labels are matched case-insensitively. For example:
+//
+//	mux.Host("api.example.com", func(mux *flow.Mux) {
+//		mux.HandleFunc("/users/:id", showUser, "GET")
+//	})
+//	mux.Host(":tenant.example.com", func(mux *flow.Mux) {
+//		mux.HandleFunc("/", tenantHome, "GET") // flow.Param(ctx, "tenant")
+//	})
+//
+// Routes registered outside of a Host group match requests for any host.
+// Because routes are matched in the order they are declared, host-specific
+// routes should usually be registered before any catch-all routes for the same
+// paths.
+func (m *Mux) Host(pattern string, fn func(*Mux)) {
+	if pattern == "" {
+		panic("flow: host pattern must not be empty")
+	}
+
+	labels := strings.Split(strings.ToLower(pattern), ".")
+	host := make([]segment, len(labels))
+
+	for i, label := range labels {
+		switch {
+		case label == "":
+			panic(fmt.Sprintf("flow: host pattern %q has an empty label", pattern))
+		case strings.HasPrefix(label, ":"):
+			name := strings.Split(pattern, ".")[i][1:]
+			if name == "" {
+				panic(fmt.Sprintf("flow: host pattern %q has a parameter without a name", pattern))
+			}
+			host[i] = segment{value: name, param: true, key: internParamKey(name)}
+		default:
+			host[i] = segment{value: label}
+		}
+	}
+
+	mm := *m
+	mm.host = host
+	mm.hostPattern = pattern
+	fn(&mm)
+}
+
+// requestHost splits the host of a request into labels on first use, so that
+// the host is only parsed if a route registered with Host is tried.
+type requestHost struct {
+	raw    string
+	labels []string
+	split  bool
+}
+
+func (h *requestHost) get() []string {
+	if !h.split {
+		h.labels = splitHost(h.raw)
+		h.split = true
+	}
+
+	return h.labels
+}
+
+// splitHost removes any port and trailing dot from a host, and splits it into
+// lowercase labels.
+func splitHost(host string) []string {
+	if i := strings.LastIndexByte(host, ':'); i != -1 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+
+	return strings.Split(host, ".")
+}
+
+func (r *Route) matchHost(host *requestHost, params []param) ([]param, bool) {
+	labels := host.get()
+	if len(labels) != len(r.host) {
+		return params, false
+	}
+
+	for i, seg := range r.host {
+		switch {
+		case seg.param:
+			if labels[i] == "" {
+				return params, false
+			}
+			params = append(params, param{key: seg.key, value: labels[i]})
+		case labels[i] != seg.value:
+			return params, false
+		}
+	}
+
+	return params, true
+}
+
+// matchTarget splits the argument to Mux.Match into a scheme, host and path.
+// The path may be a full URL, in which case its scheme and host are used.
+func matchTarget(target string) (string, string, string) {
+	if strings.HasPrefix(target, "/") {
+		return "", "", target
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", "", target
+	}
+
+	return u.Scheme, u.Host, u.EscapedPath()
+}
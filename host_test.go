@@ -0,0 +1,91 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHost(t *testing.T) {
+	hf := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name + " " + Param(r.Context(), "tenant") + " " + Param(r.Context(), "id")))
+		}
+	}
+
+	m := New()
+	m.Host("api.example.com", func(m *Mux) {
+		m.HandleFunc("/users/:id", hf("api"), "GET")
+	})
+	m.Host(":tenant.Example.com", func(m *Mux) {
+		m.HandleFunc("/", hf("tenant"), "GET")
+		m.HandleFunc("/users/:id", hf("tenant"), "POST")
+	})
+	m.HandleFunc("/", hf("any"), "GET")
+
+	var tests = []struct {
+		RequestMethod string
+		RequestURL    string
+
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{"GET", "http://api.example.com/users/1", http.StatusOK, "api  1"},
+		{"GET", "http://API.example.com:8080/users/1", http.StatusOK, "api  1"},
+		{"GET", "http://api.example.com./users/1", http.StatusOK, "api  1"},
+		{"GET", "http://acme.example.com/", http.StatusOK, "tenant acme "},
+		{"POST", "http://acme.example.com/users/2", http.StatusOK, "tenant acme 2"},
+		{"GET", "http://acme.example.com/users/2", http.StatusMethodNotAllowed, ""},
+		{"POST", "http://api.example.com/users/2", http.StatusOK, "tenant api 2"},
+		{"GET", "http://www.other.com/", http.StatusOK, "any  "},
+		{"GET", "http://www.other.com/users/1", http.StatusNotFound, ""},
+		{"GET", "http://a.b.example.com/", http.StatusOK, "any  "},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.RequestMethod, test.RequestURL, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.RequestMethod, test.RequestURL, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedStatus == http.StatusOK && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s %s: expected body %q but was %q", test.RequestMethod, test.RequestURL, test.ExpectedBody, rr.Body.String())
+		}
+	}
+
+	info, params, ok := m.Match("GET", "https://acme.example.com/")
+	if !ok || info.Host != ":tenant.Example.com" || params["tenant"] != "acme" {
+		t.Errorf("unexpected match %+v %v %t", info, params, ok)
+	}
+	if info, _, _ := m.Match("GET", "/users/1"); info.Host != "" || info.Pattern != "" {
+		t.Errorf("expected host routes not to match a plain path but got %+v", info)
+	}
+}
+
+func TestSplitHost(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected string
+	}{
+		{"example.com", "example|com"},
+		{"Example.COM:443", "example|com"},
+		{"example.com.", "example|com"},
+		{"[::1]:8080", "[::1]"},
+		{"[::1]", "[::1]"},
+	}
+
+	for _, test := range tests {
+		labels := splitHost(test.host)
+		got := ""
+		for i, label := range labels {
+			if i > 0 {
+				got += "|"
+			}
+			got += label
+		}
+		if got != test.expected {
+			t.Errorf("%q: expected %q but got %q", test.host, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+// Package jsonapi renders and decodes JSON:API (https://jsonapi.org) documents
+// for applications built with flow.
+//
+// Responses are built from a Document containing Resources (with their
+// relationships and any included resources) or Errors, and sent with Write or
+// WriteErrors. ErrorHandler can be used anywhere flow expects a
+// flow.ErrorHandler (such as with the flow.Recover middleware), so that errors
+// are reported as JSON:API error documents. Decode reads a resource from a
+// request body, returning errors which carry the appropriate status code.
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/alexedwards/flow"
+)
+
+// MediaType is the JSON:API media type.
+const MediaType = "application/vnd.api+json"
+
+// Document is a JSON:API top-level document. A document should contain Data or
+// Errors, but not both. Data is usually a Resource or a []Resource.
+type Document struct {
+	Data     any               `json:"data,omitempty"`
+	Errors   []Error           `json:"errors,omitempty"`
+	Included []Resource        `json:"included,omitempty"`
+	Meta     map[string]any    `json:"meta,omitempty"`
+	Links    map[string]string `json:"links,omitempty"`
+}
+
+// Resource is a JSON:API resource object. Attributes is usually a struct or a
+// map, and is encoded as the resource's attributes object.
+type Resource struct {
+	Type          string                  `json:"type"`
+	ID            string                  `json:"id,omitempty"`
+	Attributes    any                     `json:"attributes,omitempty"`
+	Relationships map[string]Relationship `json:"relationships,omitempty"`
+	Links         map[string]string       `json:"links,omitempty"`
+	Meta          map[string]any          `json:"meta,omitempty"`
+}
+
+// Identifier returns the resource identifier object for r.
+func (r Resource) Identifier() ResourceIdentifier {
+	return ResourceIdentifier{Type: r.Type, ID: r.ID}
+}
+
+// ResourceIdentifier identifies a single resource, for use in relationships.
+type ResourceIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// Relationship is a JSON:API relationship object. Use ToOne or ToMany to
+// create one with resource linkage.
+type Relationship struct {
+	Data  any               `json:"data"`
+	Links map[string]string `json:"links,omitempty"`
+	Meta  map[string]any    `json:"meta,omitempty"`
+}
+
+// ToOne returns a to-one relationship linking to the given resource. If id is
+// nil, the relationship is empty and its data is encoded as null.
+func ToOne(id *ResourceIdentifier) Relationship {
+	if id == nil {
+		return Relationship{}
+	}
+	return Relationship{Data: *id}
+}
+
+// ToMany returns a to-many relationship linking to the given resources. With no
+// arguments, the relationship is empty and its data is encoded as [].
+func ToMany(ids ...ResourceIdentifier) Relationship {
+	if ids == nil {
+		ids = []ResourceIdentifier{}
+	}
+	return Relationship{Data: ids}
+}
+
+// Error is a JSON:API error object. It implements the error interface, so
+// handlers can return (or panic with) one to have it sent by ErrorHandler.
+type Error struct {
+	ID     string         `json:"id,omitempty"`
+	Status string         `json:"status,omitempty"`
+	Code   string         `json:"code,omitempty"`
+	Title  string         `json:"title,omitempty"`
+	Detail string         `json:"detail,omitempty"`
+	Source *ErrorSource   `json:"source,omitempty"`
+	Meta   map[string]any `json:"meta,omitempty"`
+}
+
+func (e Error) Error() string {
+	if e.Detail != "" {
+		return e.Detail
+	}
+	return e.Title
+}
+
+// ErrorSource identifies the part of the request which caused an error.
+type ErrorSource struct {
+	Pointer   string `json:"pointer,omitempty"`
+	Parameter string `json:"parameter,omitempty"`
+	Header    string `json:"header,omitempty"`
+}
+
+// Errors is a list of JSON:API errors which implements the error interface, for
+// reporting several problems (such as validation failures) at once.
+type Errors []Error
+
+func (e Errors) Error() string {
+	switch len(e) {
+	case 0:
+		return "no errors"
+	case 1:
+		return e[0].Error()
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", e[0].Error(), len(e)-1)
+	}
+}
+
+// Write sends doc as a JSON:API response with the given status code.
+func Write(w http.ResponseWriter, status int, doc Document) error {
+	w.Header().Set("Content-Type", MediaType)
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(doc)
+}
+
+// WriteErrors sends a JSON:API error document containing errs. The status code
+// is taken from the errors if they all have the same status. Otherwise it is
+// 400 Bad Request if they are all client errors, or 500 Internal Server Error.
+func WriteErrors(w http.ResponseWriter, errs ...Error) error {
+	return Write(w, errorsStatus(errs), Document{Errors: errs})
+}
+
+func errorsStatus(errs []Error) int {
+	status := 0
+
+	for _, e := range errs {
+		s, err := strconv.Atoi(e.Status)
+		if err != nil {
+			s = http.StatusInternalServerError
+		}
+
+		switch {
+		case status == 0 || status == s:
+			status = s
+		case status < 500 && s < 500:
+			status = http.StatusBadRequest
+		default:
+			return http.StatusInternalServerError
+		}
+	}
+
+	if status == 0 {
+		return http.StatusInternalServerError
+	}
+
+	return status
+}
+
+// ErrorHandler is a flow.ErrorHandler which sends errors as JSON:API error
+// documents. If err is (or wraps) an Error or Errors, they are sent as they
+// are. Otherwise a single error object is sent, with the status code from
+// flow.StatusCode. As with flow.DefaultErrorHandler, errors with a 5xx status
+// code are logged and their details are not sent to the client.
+func ErrorHandler(w http.ResponseWriter, r *http.Request, err error) {
+	var list Errors
+	if errors.As(err, &list) {
+		WriteErrors(w, list...)
+		return
+	}
+
+	var single Error
+	if errors.As(err, &single) {
+		WriteErrors(w, single)
+		return
+	}
+
+	status := flow.StatusCode(err)
+	e := Error{Status: strconv.Itoa(status), Title: http.StatusText(status)}
+
+	if status >= 500 {
+		log.Printf("flow: %s %s: %s", r.Method, r.URL.Path, err)
+	} else if detail := err.Error(); detail != e.Title {
+		e.Detail = detail
+	}
+
+	WriteErrors(w, e)
+}
+
+// Decode reads a JSON:API document containing a single resource from the
+// request body into res. To decode the attributes into a struct, set
+// res.Attributes to a pointer to it before calling Decode.
+//
+// The returned error is a flow.HTTPError with the status code required by the
+// specification: 415 Unsupported Media Type if the request doesn't use the
+// JSON:API media type, 409 Conflict if the resource type isn't typ, or 400
+// Bad Request if the document is malformed.
+func Decode(r *http.Request, typ string, res *Resource) error {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != MediaType || len(params) > 0 {
+		return flow.HTTPError{Status: http.StatusUnsupportedMediaType}
+	}
+
+	doc := struct {
+		Data *Resource `json:"data"`
+	}{Data: res}
+
+	if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+		return flow.HTTPError{Status: http.StatusBadRequest, Err: fmt.Errorf("jsonapi: invalid document: %w", err)}
+	}
+
+	if doc.Data == nil || res.Type == "" {
+		return flow.HTTPError{Status: http.StatusBadRequest, Err: errors.New("jsonapi: document does not contain a resource")}
+	}
+
+	if res.Type != typ {
+		return flow.HTTPError{Status: http.StatusConflict, Err: fmt.Errorf("jsonapi: expected resource type %q but got %q", typ, res.Type)}
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package jsonapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alexedwards/flow"
+)
+
+func TestWrite(t *testing.T) {
+	type article struct {
+		Title string `json:"title"`
+	}
+
+	author := ResourceIdentifier{Type: "people", ID: "9"}
+
+	doc := Document{
+		Data: Resource{
+			Type:       "articles",
+			ID:         "1",
+			Attributes: article{Title: "Hello"},
+			Relationships: map[string]Relationship{
+				"author":   ToOne(&author),
+				"editor":   ToOne(nil),
+				"comments": ToMany(),
+			},
+		},
+		Included: []Resource{{Type: "people", ID: "9", Attributes: map[string]string{"name": "Dan"}}},
+	}
+
+	rr := httptest.NewRecorder()
+	if err := Write(rr, http.StatusOK, doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if ct := rr.Header().Get("Content-Type"); ct != MediaType {
+		t.Errorf("expected Content-Type %q but was %q", MediaType, ct)
+	}
+
+	expected := `{"data":{"type":"articles","id":"1","attributes":{"title":"Hello"},"relationships":{"author":{"data":{"type":"people","id":"9"}},"comments":{"data":[]},"editor":{"data":null}}},"included":[{"type":"people","id":"9","attributes":{"name":"Dan"}}]}`
+	if body := strings.TrimSpace(rr.Body.String()); body != expected {
+		t.Errorf("expected body %s but was %s", expected, body)
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	var tests = []struct {
+		Err error
+
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{
+			flow.Abort(http.StatusNotFound),
+			http.StatusNotFound,
+			`{"errors":[{"status":"404","title":"Not Found"}]}`,
+		},
+		{
+			flow.HTTPError{Status: http.StatusForbidden, Err: errors.New("not yours")},
+			http.StatusForbidden,
+			`{"errors":[{"status":"403","title":"Forbidden","detail":"not yours"}]}`,
+		},
+		{
+			errors.New("database is down"),
+			http.StatusInternalServerError,
+			`{"errors":[{"status":"500","title":"Internal Server Error"}]}`,
+		},
+		{
+			Errors{
+				{Status: "422", Detail: "title is required", Source: &ErrorSource{Pointer: "/data/attributes/title"}},
+				{Status: "400", Detail: "bad page", Source: &ErrorSource{Parameter: "page"}},
+			},
+			http.StatusBadRequest,
+			`{"errors":[{"status":"422","detail":"title is required","source":{"pointer":"/data/attributes/title"}},{"status":"400","detail":"bad page","source":{"parameter":"page"}}]}`,
+		},
+		{
+			Error{Status: "409", Title: "Conflict"},
+			http.StatusConflict,
+			`{"errors":[{"status":"409","title":"Conflict"}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		rr := httptest.NewRecorder()
+
+		ErrorHandler(rr, r, test.Err)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%v: expected status %d but was %d", test.Err, test.ExpectedStatus, rr.Code)
+		}
+
+		if body := strings.TrimSpace(rr.Body.String()); body != test.ExpectedBody {
+			t.Errorf("%v: expected body %s but was %s", test.Err, test.ExpectedBody, body)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	type article struct {
+		Title string `json:"title"`
+	}
+
+	var tests = []struct {
+		ContentType string
+		Body        string
+
+		ExpectedStatus int
+		ExpectedTitle  string
+	}{
+		{MediaType, `{"data":{"type":"articles","attributes":{"title":"Hello"}}}`, 0, "Hello"},
+		{"application/json", `{"data":{"type":"articles","attributes":{"title":"Hello"}}}`, http.StatusUnsupportedMediaType, ""},
+		{MediaType + "; ext=foo", `{"data":{"type":"articles"}}`, http.StatusUnsupportedMediaType, ""},
+		{MediaType, `{"data":{"type":"people","attributes":{"name":"Dan"}}}`, http.StatusConflict, ""},
+		{MediaType, `{"data":`, http.StatusBadRequest, ""},
+		{MediaType, `{"meta":{}}`, http.StatusBadRequest, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/articles", strings.NewReader(test.Body))
+		r.Header.Set("Content-Type", test.ContentType)
+
+		var a article
+		res := Resource{Attributes: &a}
+
+		err := Decode(r, "articles", &res)
+
+		if test.ExpectedStatus == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.Body, err)
+			}
+		} else if status := flow.StatusCode(err); err == nil || status != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but got error %v", test.Body, test.ExpectedStatus, err)
+		}
+
+		if a.Title != test.ExpectedTitle {
+			t.Errorf("%s: expected title %q but was %q", test.Body, test.ExpectedTitle, a.Title)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type langContextKey struct{}
+
+// Languages returns middleware which chooses the best language for the
+// response from the request's Accept-Language header and the given supported
+// language tags (such as "en", "en-GB" or "fr"). The first supported language
+// is used if none of the requested languages are supported.
+//
+// The chosen tag is stored in the request context (see Lang), and the middleware
+// sets the Content-Language header and adds Accept-Language to the Vary header.
+// Handlers which render templates can pass Lang(r.Context()) to them to select
+// localized content.
+//
+// A requested tag matches a supported tag if they are equal (ignoring case), or
+// failing that if they have the same primary language subtag (so a request for
+// "en-AU" will match "en", and "en" will match "en-GB").
+func Languages(supported ...string) func(http.Handler) http.Handler {
+	if len(supported) == 0 {
+		panic("flow: Languages requires at least one supported language")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lang := negotiateLanguage(r.Header.Get("Accept-Language"), supported)
+
+			w.Header().Set("Content-Language", lang)
+			w.Header().Add("Vary", "Accept-Language")
+
+			ctx := context.WithValue(r.Context(), langContextKey{}, lang)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Lang returns the language tag chosen by the Languages middleware, or the
+// empty string if the middleware wasn't used.
+func Lang(ctx context.Context) string {
+	lang, _ := ctx.Value(langContextKey{}).(string)
+	return lang
+}
+
+type weightedValue struct {
+	value string
+	q     float64
+}
+
+// parseWeighted parses a header value containing a comma-separated list of
+// values with optional quality factors (like Accept-Language), and returns the
+// values with a non-zero quality in order of preference.
+func parseWeighted(header string) []weightedValue {
+	var values []weightedValue
+
+	for _, part := range strings.Split(header, ",") {
+		value, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if value == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			k, v, ok := strings.Cut(strings.TrimSpace(p), "=")
+			if ok && strings.EqualFold(k, "q") {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		if q > 0 {
+			values = append(values, weightedValue{value: strings.TrimSpace(value), q: q})
+		}
+	}
+
+	slices.SortStableFunc(values, func(a, b weightedValue) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	return values
+}
+
+func negotiateLanguage(header string, supported []string) string {
+	primary := func(tag string) string {
+		p, _, _ := strings.Cut(tag, "-")
+		return p
+	}
+
+	for _, requested := range parseWeighted(header) {
+		if requested.value == "*" {
+			return supported[0]
+		}
+
+		for _, s := range supported {
+			if strings.EqualFold(requested.value, s) {
+				return s
+			}
+		}
+
+		for _, s := range supported {
+			if strings.EqualFold(primary(requested.value), primary(s)) {
+				return s
+			}
+		}
+	}
+
+	return supported[0]
+}
@@ -0,0 +1,55 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLanguages(t *testing.T) {
+	var lang string
+
+	m := New()
+	m.Use(Languages("en-GB", "fr", "de"))
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		lang = Lang(r.Context())
+	}, "GET")
+
+	var tests = []struct {
+		AcceptLanguage string
+
+		ExpectedLang string
+	}{
+		{"", "en-GB"},
+		{"fr", "fr"},
+		{"FR-ca", "fr"},
+		{"en-US,en;q=0.9", "en-GB"},
+		{"de;q=0.5, fr;q=0.8", "fr"},
+		{"es, de;q=0.1", "de"},
+		{"es, *;q=0.5", "en-GB"},
+		{"fr;q=0, de", "de"},
+		{"ja", "en-GB"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		if test.AcceptLanguage != "" {
+			r.Header.Set("Accept-Language", test.AcceptLanguage)
+		}
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if lang != test.ExpectedLang {
+			t.Errorf("%q: expected language %q but was %q", test.AcceptLanguage, test.ExpectedLang, lang)
+		}
+
+		if cl := rr.Header().Get("Content-Language"); cl != test.ExpectedLang {
+			t.Errorf("%q: expected Content-Language %q but was %q", test.AcceptLanguage, test.ExpectedLang, cl)
+		}
+
+		if vary := rr.Header().Get("Vary"); vary != "Accept-Language" {
+			t.Errorf("%q: expected Vary header %q but was %q", test.AcceptLanguage, "Accept-Language", vary)
+		}
+	}
+}
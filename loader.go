@@ -0,0 +1,103 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"slices"
+)
+
+// A Loader loads the resource identified by the value of a route parameter,
+// such as the user for a :userID parameter. It is called with the request
+// context and the value of the parameter (as returned by Param).
+type Loader func(ctx context.Context, value string) (any, error)
+
+// ErrNotFound can be returned by a Loader when there is no resource for the
+// parameter value. Like any error which wraps an HTTPError, it sets the status
+// code of the error response, in this case to 404 Not Found.
+var ErrNotFound = HTTPError{Status: http.StatusNotFound}
+
+type paramLoader struct {
+	name string
+	load Loader
+}
+
+type loadedContextKey string
+
+// Load registers a loader for a named parameter. Every route registered
+// afterwards (in the same group, or groups nested inside it) which has a
+// parameter with the name calls the loader before its handler, and the loaded
+// value is available to the handler through Loaded. For example:
+//
+//	mux.Load("userID", func(ctx context.Context, id string) (any, error) {
+//		user, err := db.GetUser(ctx, id)
+//		if errors.Is(err, sql.ErrNoRows) {
+//			return nil, flow.ErrNotFound
+//		}
+//		return user, err
+//	})
+//
+//	mux.HandleFunc("/users/:userID", showUser, "GET")
+//
+// Loaders run after the route's middleware, so they can use anything the
+// middleware has added to the context (such as the current user, to check
+// they are authorized to see the resource). If a loader returns an error, the
+// handler isn't called and the error is passed to LoadError, so the status code
+// of the response comes from StatusCode(err).
+//
+// Loading a name which already has a loader replaces it for routes registered
+// afterwards. Load panics if the name is empty or load is nil.
+func (m *Mux) Load(name string, load Loader) {
+	if name == "" {
+		panic("flow: Load requires a parameter name")
+	}
+	if load == nil {
+		panic("flow: nil loader for parameter " + name)
+	}
+
+	m.loaders = slices.DeleteFunc(slices.Clone(m.loaders), func(l paramLoader) bool { return l.name == name })
+	m.loaders = append(m.loaders, paramLoader{name: name, load: load})
+}
+
+// Loaded retrieves the value loaded for a named parameter by a loader
+// registered with Load. It returns false if nothing was loaded for the
+// parameter, or if the value isn't of type T.
+func Loaded[T any](ctx context.Context, param string) (T, bool) {
+	v, ok := ctx.Value(loadedContextKey(param)).(T)
+	return v, ok
+}
+
+// wrapLoaders wraps handler so that it calls the loaders for any of the
+// parameters in segments first.
+func (m *Mux) wrapLoaders(segments []segment, handler http.Handler) http.Handler {
+	var loaders []paramLoader
+	for _, b := range m.loaders {
+		if slices.ContainsFunc(segments, func(s segment) bool { return (s.param || s.wildcard) && s.value == b.name }) ||
+			slices.ContainsFunc(m.host, func(s segment) bool { return s.param && s.value == b.name }) {
+			loaders = append(loaders, b)
+		}
+	}
+
+	if len(loaders) == 0 {
+		return handler
+	}
+
+	errorHandler := m.LoadError
+	if errorHandler == nil {
+		errorHandler = DefaultErrorHandler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+
+		for _, b := range loaders {
+			v, err := b.load(ctx, Param(ctx, b.name))
+			if err != nil {
+				errorHandler(w, r, err)
+				return
+			}
+			ctx = context.WithValue(ctx, loadedContextKey(b.name), v)
+		}
+
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
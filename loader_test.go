@@ -0,0 +1,127 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type loadedUser struct {
+	ID    string
+	Owner string
+}
+
+type bindCallerKey struct{}
+
+func TestLoaders(t *testing.T) {
+	users := map[string]loadedUser{"1": {"1", "alice"}, "2": {"2", "bob"}}
+
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		user, ok := Loaded[loadedUser](r.Context(), "userID")
+		if !ok {
+			w.Write([]byte("none"))
+			return
+		}
+		if _, ok := Loaded[string](r.Context(), "userID"); ok {
+			t.Errorf("expected Loaded to fail for the wrong type")
+		}
+		fmt.Fprintf(w, "%s:%s", user.ID, user.Owner)
+	}
+
+	caller := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), bindCallerKey{}, r.Header.Get("X-Caller"))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+
+	m := New()
+	m.Use(caller)
+	m.Load("userID", func(ctx context.Context, id string) (any, error) {
+		if id == "boom" {
+			return nil, errors.New("database unavailable")
+		}
+		user, ok := users[id]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		if caller, _ := ctx.Value(bindCallerKey{}).(string); caller != user.Owner {
+			return nil, Abort(http.StatusForbidden)
+		}
+		return user, nil
+	})
+
+	m.HandleFunc("/users/:userID", hf, "GET")
+	m.HandleFunc("/accounts/:accountID", hf, "GET")
+	m.Group(func(m *Mux) {
+		m.Load("userID", func(ctx context.Context, id string) (any, error) {
+			return loadedUser{ID: id, Owner: "anyone"}, nil
+		})
+		m.LoadError = func(w http.ResponseWriter, r *http.Request, err error) {
+			w.WriteHeader(http.StatusTeapot)
+		}
+		m.HandleFunc("/public/:userID", hf, "GET")
+	})
+	m.HandleFunc("/after/:userID", hf, "GET")
+
+	var tests = []struct {
+		RequestPath string
+		Caller      string
+
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{"/users/1", "alice", http.StatusOK, "1:alice"},
+		{"/users/1", "bob", http.StatusForbidden, ""},
+		{"/users/3", "alice", http.StatusNotFound, ""},
+		{"/users/boom", "alice", http.StatusInternalServerError, ""},
+		{"/accounts/1", "", http.StatusOK, "none"},
+		{"/public/9", "", http.StatusOK, "9:anyone"},
+		{"/after/2", "bob", http.StatusOK, "2:bob"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.RequestPath, nil)
+		r.Header.Set("X-Caller", test.Caller)
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s as %q: expected status %d but was %d", test.RequestPath, test.Caller, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedStatus == http.StatusOK && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s as %q: expected body %q but was %q", test.RequestPath, test.Caller, test.ExpectedBody, rr.Body.String())
+		}
+	}
+}
+
+func TestLoadPanics(t *testing.T) {
+	load := func(ctx context.Context, value string) (any, error) { return value, nil }
+
+	var tests = []struct {
+		Name   string
+		Loader Loader
+
+		ExpectedPanic string
+	}{
+		{"", load, "requires a parameter name"},
+		{"id", nil, "nil loader"},
+	}
+
+	for _, test := range tests {
+		func() {
+			defer func() {
+				r := recover()
+				if r == nil || !strings.Contains(fmt.Sprint(r), test.ExpectedPanic) {
+					t.Errorf("%q: expected panic containing %q but got %v", test.Name, test.ExpectedPanic, r)
+				}
+			}()
+
+			New().Load(test.Name, test.Loader)
+		}()
+	}
+}
@@ -0,0 +1,169 @@
+package flow
+
+import (
+	"bufio"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Logging is middleware which writes a structured access log entry (using
+// log/slog) for each request, and records server errors separately. Use its
+// Middleware method with Use:
+//
+//	logging := &flow.Logging{
+//		Access: accessLogger,
+//		Errors: errorLogger,
+//		Sinks:  map[string]*slog.Logger{"admin": auditLogger},
+//	}
+//	mux.Use(logging.Middleware)
+//
+// Routes log at slog.LevelInfo by default. The level and destination can be
+// changed for a single route with Route.LogLevel and Route.LogTo, or for all
+// the routes registered afterwards in a group with Mux.LogLevel and
+// Mux.LogTo. For example, health checks can be logged at slog.LevelDebug so
+// that they are silenced by a logger which only records Info and above.
+type Logging struct {
+	// Access receives an entry for every request. If it is nil,
+	// slog.Default() is used.
+	Access *slog.Logger
+
+	// Errors receives an additional entry, at slog.LevelError, for each
+	// request with a 5xx response status. If it is nil, these entries aren't
+	// written.
+	Errors *slog.Logger
+
+	// Sinks are named loggers which routes can send their access log
+	// entries to instead of Access (see Route.LogTo). A route whose sink
+	// isn't in the map uses Access.
+	Sinks map[string]*slog.Logger
+}
+
+// logSettings holds the log level and sink for a route.
+type logSettings struct {
+	level    slog.Level
+	levelSet bool
+	sink     string
+}
+
+// LogLevel sets the level of the access log entries for the route (see
+// Logging).
+func (r *Route) LogLevel(level slog.Level) *Route {
+	r.log.level, r.log.levelSet = level, true
+	return r
+}
+
+// LogTo sends the access log entries for the route to the named sink (see
+// Logging.Sinks).
+func (r *Route) LogTo(sink string) *Route {
+	r.log.sink = sink
+	return r
+}
+
+// LogLevel sets the level of the access log entries for routes registered
+// afterwards (see Logging). Like middleware, it is scoped to the current
+// group.
+func (m *Mux) LogLevel(level slog.Level) {
+	m.log.level, m.log.levelSet = level, true
+}
+
+// LogTo sends the access log entries for routes registered afterwards to the
+// named sink (see Logging.Sinks). Like middleware, it is scoped to the current
+// group.
+func (m *Mux) LogTo(sink string) {
+	m.log.sink = sink
+}
+
+// Middleware logs requests to the next handler.
+func (l *Logging) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+
+		next.ServeHTTP(sw, r)
+
+		logger := l.Access
+		if logger == nil {
+			logger = slog.Default()
+		}
+		level := slog.LevelInfo
+
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", sw.status()),
+			slog.Int64("bytes", sw.bytes),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if sw.hijacked {
+			attrs = append(attrs, slog.Bool("hijacked", true))
+		}
+
+		route, _ := r.Context().Value(routeContextKey{}).(*Route)
+		if route != nil {
+			if route.log.levelSet {
+				level = route.log.level
+			}
+			if sink, ok := l.Sinks[route.log.sink]; ok {
+				logger = sink
+			}
+			attrs = append(attrs, slog.String("route", route.pattern), slog.String("handler", route.handlerName))
+		}
+
+		ctx := r.Context()
+		logger.LogAttrs(ctx, level, "request", attrs...)
+
+		if sw.status() >= 500 && l.Errors != nil {
+			l.Errors.LogAttrs(ctx, slog.LevelError, "server error", attrs...)
+		}
+	})
+}
+
+// statusWriter records the status code and number of bytes of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	hijackTracker
+	code  int
+	bytes int64
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack(w.ResponseWriter)
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 && code >= 200 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// status returns the status code of the response. A hijacked connection (such
+// as a WebSocket upgrade) is reported as 101 Switching Protocols.
+func (w *statusWriter) status() int {
+	if w.hijacked {
+		return http.StatusSwitchingProtocols
+	}
+	if w.code == 0 {
+		return http.StatusOK
+	}
+	return w.code
+}
@@ -0,0 +1,107 @@
+package flow
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLogging(t *testing.T) {
+	var access, errs, admin bytes.Buffer
+
+	newLogger := func(buf *bytes.Buffer) *slog.Logger {
+		return slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey || a.Key == "duration" {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}))
+	}
+
+	logging := &Logging{
+		Access: newLogger(&access),
+		Errors: newLogger(&errs),
+		Sinks:  map[string]*slog.Logger{"admin": newLogger(&admin)},
+	}
+
+	m := New()
+	m.Use(logging.Middleware)
+	m.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("user"))
+	}, "GET").HandlerNamed("ShowUser")
+	m.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {}, "GET").LogLevel(slog.LevelDebug)
+	m.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}, "GET").HandlerNamed("Fail")
+	m.Group(func(m *Mux) {
+		m.LogTo("admin")
+		m.LogLevel(slog.LevelWarn)
+		m.HandleFunc("/admin/reset", func(w http.ResponseWriter, r *http.Request) {}, "POST").HandlerNamed("Reset")
+		m.HandleFunc("/admin/status", func(w http.ResponseWriter, r *http.Request) {}, "GET").HandlerNamed("Status").LogLevel(slog.LevelInfo)
+	})
+	m.HandleFunc("/after-group", func(w http.ResponseWriter, r *http.Request) {}, "GET").HandlerNamed("AfterGroup")
+
+	for _, req := range []struct{ method, path string }{
+		{"GET", "/users/1"},
+		{"GET", "/healthz"},
+		{"GET", "/fail"},
+		{"POST", "/admin/reset"},
+		{"GET", "/admin/status"},
+		{"GET", "/after-group"},
+		{"GET", "/missing"},
+	} {
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	expectedAccess := strings.Join([]string{
+		`level=INFO msg=request method=GET path=/users/1 status=200 bytes=4 route=/users/:id handler=ShowUser`,
+		`level=INFO msg=request method=GET path=/fail status=503 bytes=0 route=/fail handler=Fail`,
+		`level=INFO msg=request method=GET path=/after-group status=200 bytes=0 route=/after-group handler=AfterGroup`,
+		`level=INFO msg=request method=GET path=/missing status=404 bytes=19`,
+		``,
+	}, "\n")
+	if access.String() != expectedAccess {
+		t.Errorf("unexpected access log:\n%s\nexpected:\n%s", access.String(), expectedAccess)
+	}
+
+	expectedErrors := `level=ERROR msg="server error" method=GET path=/fail status=503 bytes=0 route=/fail handler=Fail` + "\n"
+	if errs.String() != expectedErrors {
+		t.Errorf("unexpected error log:\n%s\nexpected:\n%s", errs.String(), expectedErrors)
+	}
+
+	expectedAdmin := strings.Join([]string{
+		`level=WARN msg=request method=POST path=/admin/reset status=200 bytes=0 route=/admin/reset handler=Reset`,
+		`level=INFO msg=request method=GET path=/admin/status status=200 bytes=0 route=/admin/status handler=Status`,
+		``,
+	}, "\n")
+	if admin.String() != expectedAdmin {
+		t.Errorf("unexpected admin log:\n%s\nexpected:\n%s", admin.String(), expectedAdmin)
+	}
+}
+
+func TestLoggingFlush(t *testing.T) {
+	logging := &Logging{Access: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	m := New()
+	m.Use(logging.Middleware)
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("expected the ResponseWriter to implement http.Flusher")
+		}
+		f.Flush()
+	}, "GET")
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if !rr.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+}
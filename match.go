@@ -0,0 +1,142 @@
+package flow
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// RouteInfo describes a registered route.
+type RouteInfo struct {
+	Name    string   `json:"name,omitempty"`
+	Host    string   `json:"host,omitempty"`
+	Pattern string   `json:"pattern"`
+	Methods []string `json:"methods"`
+	Tags    []string `json:"tags,omitempty"`
+	Handler string   `json:"handler,omitempty"`
+
+	// Meta holds the values set with Route.Meta. It isn't included in JSON,
+	// as the values may not be encodable.
+	Meta map[string]any `json:"-"`
+}
+
+// Params holds the values of the named parameters from a matched route, keyed
+// by parameter name. The value of a wildcard is stored under the key "...", or
+// under its name for a named catch-all like :path....
+type Params map[string]string
+
+// Match reports whether a request with the given method and path would be
+// dispatched to one of the routes registered with m, without calling the
+// handler. The path should be in its escaped form (as returned by
+// url.URL.EscapedPath), or a full URL such as "http://api.example.com/users"
+// to take routes registered with Host into account (a plain path never matches
+// them). If a route matches, Match returns information about
+// the route and the values of its parameters.
+//
+// The header and scheme conditions of routes registered with a RouteBuilder
+// are checked as they would be for a request without any headers, whose
+// scheme is that of the URL (or "http" for a plain path). Use MatchRequest to
+// check them against a request.
+// The methods reported by a mounted MethodLister, and a route's
+// OptionsHandler, are taken into account in the same way as by ServeHTTP.
+//
+// Match is useful for checking the routing table in tests, for precomputing
+// authorization decisions, and for tools such as link checkers.
+func (m *Mux) Match(method, path string) (RouteInfo, Params, bool) {
+	scheme, hostname, path := matchTarget(path)
+	r := &http.Request{
+		Method: method,
+		URL:    &url.URL{Scheme: scheme, Host: hostname},
+		Host:   hostname,
+		Header: http.Header{},
+	}
+
+	return m.match(r, path)
+}
+
+// MatchRequest is like Match, but matches the method, host, path, headers and
+// scheme of a request, in the same way as ServeHTTP.
+func (m *Mux) MatchRequest(r *http.Request) (RouteInfo, Params, bool) {
+	return m.match(r, r.URL.EscapedPath())
+}
+
+func (m *Mux) match(r *http.Request, path string) (RouteInfo, Params, bool) {
+	host := requestHost{raw: r.Host}
+	if path == "" {
+		path = "/"
+	}
+	n := countSegments(path)
+	bit := methodBit(r.Method)
+
+	var params []param
+
+	for _, route := range m.routes.load() {
+		var ok bool
+		params, ok = route.match(&host, path, n, params[:0])
+		if !ok || !route.satisfies(r) {
+			continue
+		}
+
+		if result, _ := m.resolveMethod(route, r, bit); result == routeServes || result == routeOptions {
+			values := make(Params, len(params))
+			for _, p := range params {
+				values[p.key.name] = p.value
+			}
+
+			return route.info(), values, true
+		}
+	}
+
+	return RouteInfo{}, nil, false
+}
+
+// Routes returns information about all of the routes registered with m, in the
+// order that they were registered (which is also the order in which they are
+// matched). The result can be encoded as JSON to produce a route dump for use
+// with the flowctl command.
+func (m *Mux) Routes() []RouteInfo {
+	routes := m.routes.load()
+	infos := make([]RouteInfo, len(routes))
+
+	for i := range routes {
+		infos[i] = routes[i].info()
+	}
+
+	return infos
+}
+
+// Walk calls fn for each method of each route registered with m, in the order
+// that the routes are matched, with the route's pattern and the handler which
+// serves it (including its middleware). Routes registered with Host have the
+// host pattern prepended to the path pattern, like "api.example.com/users". If
+// fn returns an error, Walk stops and returns it. For example, to print the
+// routing table at startup:
+//
+//	mux.Walk(func(method, pattern string, h http.Handler) error {
+//		fmt.Printf("%-7s %s\n", method, pattern)
+//		return nil
+//	})
+func (m *Mux) Walk(fn func(method, pattern string, h http.Handler) error) error {
+	for _, route := range m.routes.load() {
+		pattern := route.hostPattern + route.pattern
+
+		for _, method := range append(route.methods.methods(), route.customMethods...) {
+			if err := fn(method, pattern, route.handler); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (r *Route) info() RouteInfo {
+	return RouteInfo{
+		Name:    r.name,
+		Host:    r.hostPattern,
+		Pattern: r.pattern,
+		Methods: append(r.methods.methods(), r.customMethods...),
+		Tags:    r.tags,
+		Handler: r.handlerName,
+		Meta:    r.meta,
+	}
+}
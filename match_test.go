@@ -0,0 +1,175 @@
+package flow
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.HandleFunc("/users/:id|^[0-9]+$", hf, "GET", "PUT")
+	m.HandleFunc("/files/...", hf, "GET")
+	m.HandleFunc("/docs/:path.../raw", hf, "GET")
+
+	var tests = []struct {
+		Method string
+		Path   string
+
+		ExpectedMatch   bool
+		ExpectedPattern string
+		ExpectedMethods []string
+		ExpectedParams  Params
+	}{
+		{"GET", "/users/42", true, "/users/:id|^[0-9]+$", []string{"GET", "HEAD", "PUT"}, Params{"id": "42"}},
+		{"PUT", "/users/42", true, "/users/:id|^[0-9]+$", []string{"GET", "HEAD", "PUT"}, Params{"id": "42"}},
+		{"DELETE", "/users/42", false, "", nil, nil},
+		{"GET", "/users/abc", false, "", nil, nil},
+		{"GET", "/files/a/b", true, "/files/...", []string{"GET", "HEAD"}, Params{"...": "a/b"}},
+		{"GET", "/docs/a/b/raw", true, "/docs/:path.../raw", []string{"GET", "HEAD"}, Params{"path": "a/b"}},
+		{"GET", "/missing", false, "", nil, nil},
+	}
+
+	for _, test := range tests {
+		info, params, ok := m.Match(test.Method, test.Path)
+
+		if ok != test.ExpectedMatch {
+			t.Errorf("%s %s: expected match %t but was %t", test.Method, test.Path, test.ExpectedMatch, ok)
+			continue
+		}
+
+		if info.Pattern != test.ExpectedPattern {
+			t.Errorf("%s %s: expected pattern %q but was %q", test.Method, test.Path, test.ExpectedPattern, info.Pattern)
+		}
+
+		if !slices.Equal(info.Methods, test.ExpectedMethods) {
+			t.Errorf("%s %s: expected methods %v but was %v", test.Method, test.Path, test.ExpectedMethods, info.Methods)
+		}
+
+		if !maps.Equal(params, test.ExpectedParams) {
+			t.Errorf("%s %s: expected params %v but was %v", test.Method, test.Path, test.ExpectedParams, params)
+		}
+	}
+}
+
+func TestMatchConditions(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.NewRoute().Path("/items").Methods("GET").Headers("Accept", "application/json").HandlerFunc(hf).Meta("route", "json")
+	m.NewRoute().Path("/items").Methods("GET").Schemes("https").HandlerFunc(hf).Meta("route", "https")
+	m.HandleFunc("/items", hf, "GET").Meta("route", "plain")
+	m.NewRoute().Path("/internal").Headers("X-Internal", "").HandlerFunc(hf)
+
+	var tests = []struct {
+		Target string
+		Accept string
+
+		ExpectedRoute string
+	}{
+		{"/items", "application/json", "json"},
+		{"/items", "", "plain"},
+		{"https://example.com/items", "", "https"},
+		{"http://example.com/items", "", "plain"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.Target, nil)
+		if test.Accept != "" {
+			r.Header.Set("Accept", test.Accept)
+		}
+
+		info, _, ok := m.MatchRequest(r)
+		if !ok || info.Meta["route"] != test.ExpectedRoute {
+			t.Errorf("MatchRequest %s (Accept %q): expected route %q but got %v", test.Target, test.Accept, test.ExpectedRoute, info.Meta["route"])
+		}
+
+		if test.Accept != "" {
+			continue
+		}
+
+		info, _, ok = m.Match("GET", test.Target)
+		if !ok || info.Meta["route"] != test.ExpectedRoute {
+			t.Errorf("Match %s: expected route %q but got %v", test.Target, test.ExpectedRoute, info.Meta["route"])
+		}
+	}
+
+	if _, _, ok := m.Match("GET", "/internal"); ok {
+		t.Error("expected route with an unmet header condition not to match")
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.HandleFunc("/users/:id", hf, "GET")
+	m.Group(func(m *Mux) {
+		m.HandleFunc("/admin", hf, "POST")
+	})
+
+	expected := []RouteInfo{
+		{Pattern: "/users/:id", Methods: []string{"GET", "HEAD"}},
+		{Pattern: "/admin", Methods: []string{"POST"}},
+	}
+
+	routes := m.Routes()
+	if len(routes) != len(expected) {
+		t.Fatalf("expected %d routes but got %d", len(expected), len(routes))
+	}
+
+	for i := range expected {
+		if routes[i].Pattern != expected[i].Pattern || !slices.Equal(routes[i].Methods, expected[i].Methods) {
+			t.Errorf("expected route %v but got %v", expected[i], routes[i])
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}
+
+	m := New()
+	m.CustomMethods = []string{"PURGE"}
+	m.HandleFunc("/users/:id", hf, "GET", "PURGE")
+	m.Host("api.example.com", func(m *Mux) {
+		m.HandleFunc("/status", hf, "POST")
+	})
+	m.HandleFunc("/last", hf, "DELETE")
+
+	var walked []string
+	err := m.Walk(func(method, pattern string, h http.Handler) error {
+		walked = append(walked, method+" "+pattern)
+
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest(method, "/", nil))
+		if rr.Body.String() != "ok" {
+			t.Errorf("%s %s: expected the route's handler", method, pattern)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"GET /users/:id", "HEAD /users/:id", "PURGE /users/:id", "POST api.example.com/status", "DELETE /last"}
+	if !slices.Equal(walked, expected) {
+		t.Errorf("expected %q but got %q", expected, walked)
+	}
+
+	stop := errors.New("stop")
+	walked = nil
+	err = m.Walk(func(method, pattern string, h http.Handler) error {
+		walked = append(walked, method+" "+pattern)
+		return stop
+	})
+	if err != stop || len(walked) != 1 {
+		t.Errorf("expected Walk to stop at the first error but got %v after %q", err, walked)
+	}
+}
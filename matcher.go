@@ -0,0 +1,148 @@
+package flow
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// Matcher matches paths against patterns using the same syntax and rules as a
+// Mux, but without any of the HTTP handling: the routes hold values of type T
+// (such as handler IDs or functions) rather than http.Handlers. This makes
+// flow's pattern semantics reusable in CLIs, message routers and tests:
+//
+//	var commands flow.Matcher[func(flow.Params)]
+//	commands.Add("/users/:id/disable", disableUser)
+//	commands.Add("/users/:id", showUser, "GET")
+//
+//	if fn, params, ok := commands.Match("", "/users/42/disable"); ok {
+//		fn(params)
+//	}
+//
+// Routes are matched in the order they were added. The zero value is an empty
+// Matcher ready to use, and it's safe to call Match while routes are being
+// added.
+type Matcher[T any] struct {
+	mu     sync.Mutex
+	routes atomic.Pointer[[]*matcherRoute[T]]
+}
+
+type matcherRoute[T any] struct {
+	route   *Route
+	methods []string
+	value   T
+}
+
+// Add adds a route for the pattern with the given value. If methods are given,
+// the route only matches those methods (which are compared exactly, and don't
+// need to be HTTP methods). Otherwise it matches any method. The pattern is
+// checked with ValidatePattern, and Add returns the error if it isn't valid.
+func (m *Matcher[T]) Add(pattern string, value T, methods ...string) error {
+	if err := ValidatePattern(pattern); err != nil {
+		return err
+	}
+
+	if pattern == "" {
+		pattern = "/"
+	}
+
+	segments := strings.Split(pattern, "/")
+	parsed, err := parseSegments(segments, nil)
+	if err != nil {
+		return err
+	}
+
+	route := &Route{
+		pattern:  pattern,
+		segments: parsed,
+		wildcard: countWildcards(segments) > 0,
+	}
+	route.prefix, route.static = staticPrefix(pattern, parsed)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var routes []*matcherRoute[T]
+	if current := m.routes.Load(); current != nil {
+		routes = *current
+	}
+
+	routes = append(slices.Clip(routes), &matcherRoute[T]{route: route, methods: slices.Clone(methods), value: value})
+	m.routes.Store(&routes)
+
+	return nil
+}
+
+// Match returns the value of the first route which matches the method and
+// path, and the values of its parameters. The path should be in its escaped
+// form, like the paths matched by a Mux. Routes added without any methods
+// match whatever the method is (including the empty string).
+func (m *Matcher[T]) Match(method, path string) (T, Params, bool) {
+	routes := m.routes.Load()
+	if routes == nil {
+		var zero T
+		return zero, nil, false
+	}
+
+	if path == "" {
+		path = "/"
+	}
+	n := countSegments(path)
+
+	var params []param
+
+	for _, mr := range *routes {
+		if len(mr.methods) > 0 && !slices.Contains(mr.methods, method) {
+			continue
+		}
+
+		var ok bool
+		params, ok = mr.route.match(nil, path, n, params[:0])
+		if ok {
+			values := make(Params, len(params))
+			for _, p := range params {
+				values[p.key.name] = p.value
+			}
+
+			return mr.value, values, true
+		}
+	}
+
+	var zero T
+	return zero, nil, false
+}
+
+// Allowed returns the methods of the routes which match the path, in the
+// order they were added, and whether any route without methods matches it.
+func (m *Matcher[T]) Allowed(path string) (methods []string, anyMethod bool) {
+	routes := m.routes.Load()
+	if routes == nil {
+		return nil, false
+	}
+
+	if path == "" {
+		path = "/"
+	}
+	n := countSegments(path)
+
+	var params []param
+
+	for _, mr := range *routes {
+		var ok bool
+		if params, ok = mr.route.match(nil, path, n, params[:0]); !ok {
+			continue
+		}
+
+		if len(mr.methods) == 0 {
+			anyMethod = true
+		}
+		for _, method := range mr.methods {
+			if !slices.Contains(methods, method) {
+				methods = append(methods, method)
+			}
+		}
+	}
+
+	return methods, anyMethod
+}
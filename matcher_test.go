@@ -0,0 +1,76 @@
+package flow
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	var m Matcher[int]
+
+	for i, route := range []struct {
+		pattern string
+		methods []string
+	}{
+		{"/users/new", []string{"GET"}},
+		{"/users/:id|^[0-9]+$", []string{"GET", "DELETE"}},
+		{"/users/:id/disable", nil},
+		{"/files/:path...", []string{"READ"}},
+		{"", nil},
+	} {
+		if err := m.Add(route.pattern, i, route.methods...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		Method string
+		Path   string
+
+		ExpectedOK     bool
+		ExpectedValue  int
+		ExpectedParams Params
+	}{
+		{"GET", "/users/new", true, 0, Params{}},
+		{"GET", "/users/42", true, 1, Params{"id": "42"}},
+		{"DELETE", "/users/42", true, 1, Params{"id": "42"}},
+		{"PUT", "/users/42", false, 0, nil},
+		{"GET", "/users/abc", false, 0, nil},
+		{"", "/users/abc/disable", true, 2, Params{"id": "abc"}},
+		{"anything", "/users/abc/disable", true, 2, Params{"id": "abc"}},
+		{"READ", "/files/a/b%20c.txt", true, 3, Params{"path": "a/b%20c.txt"}},
+		{"", "", true, 4, Params{}},
+		{"GET", "/missing", false, 0, nil},
+	}
+
+	for _, test := range tests {
+		value, params, ok := m.Match(test.Method, test.Path)
+
+		if ok != test.ExpectedOK || value != test.ExpectedValue || !maps.Equal(params, test.ExpectedParams) {
+			t.Errorf("%s %s: expected %d %v %t but got %d %v %t", test.Method, test.Path, test.ExpectedValue, test.ExpectedParams, test.ExpectedOK, value, params, ok)
+		}
+	}
+
+	if methods, anyMethod := m.Allowed("/users/42"); !slices.Equal(methods, []string{"GET", "DELETE"}) || anyMethod {
+		t.Errorf("expected [GET DELETE] false but got %v %t", methods, anyMethod)
+	}
+	if methods, anyMethod := m.Allowed("/users/42/disable"); methods != nil || !anyMethod {
+		t.Errorf("expected [] true but got %v %t", methods, anyMethod)
+	}
+
+	if err := m.Add("/a/:id/:id", 9); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+}
+
+func TestMatcherZeroValue(t *testing.T) {
+	var m Matcher[string]
+
+	if value, params, ok := m.Match("GET", "/"); ok || value != "" || params != nil {
+		t.Errorf("expected no match but got %q %v %t", value, params, ok)
+	}
+	if methods, anyMethod := m.Allowed("/"); methods != nil || anyMethod {
+		t.Errorf("expected no methods but got %v %t", methods, anyMethod)
+	}
+}
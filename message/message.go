@@ -0,0 +1,123 @@
+// Package message routes non-HTTP messages, such as NATS or AMQP messages or
+// command strings, using flow's pattern syntax, so that a service can use the
+// same routing rules for every transport it receives requests on.
+//
+// A message is routed by its subject, which is split into tokens on a
+// separator (like "." for NATS subjects or " " for commands) and matched as if
+// the tokens were the segments of a URL path. Patterns are written exactly as
+// they are for a flow.Mux:
+//
+//	router := message.New[*nats.Msg](".")
+//	router.Handle("/orders/:id/created", orderCreated)
+//	router.Handle("/audit/...", audit)
+//
+//	nc.Subscribe(">", func(msg *nats.Msg) {
+//		if err := router.Dispatch(context.Background(), msg.Subject, msg); err != nil {
+//			log.Print(err)
+//		}
+//	})
+//
+// Here the subject "orders.42.created" matches the first route with the
+// parameter id set to "42", and "audit.users.login" matches the second with
+// the wildcard set to "users.login" (wildcard values are joined with the
+// separator).
+package message
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/alexedwards/flow"
+)
+
+// ErrNoRoute is returned by Dispatch when no route matches the subject and the
+// router has no NotFound handler.
+var ErrNoRoute = errors.New("message: no route matches the subject")
+
+// Handler handles a message of type M, with the values of the parameters from
+// the matched pattern.
+type Handler[M any] func(ctx context.Context, msg M, params flow.Params) error
+
+// Router dispatches messages to the handlers of the routes which match their
+// subjects. Routes are matched in the order they were registered. It is safe
+// to call Dispatch concurrently, including while routes are being registered.
+type Router[M any] struct {
+	// NotFound handles messages whose subject doesn't match any route. If it
+	// is nil, Dispatch returns ErrNoRoute for them.
+	NotFound Handler[M]
+
+	separator   string
+	matcher     flow.Matcher[Handler[M]]
+	middlewares []func(Handler[M]) Handler[M]
+}
+
+// New returns a Router for subjects whose tokens are separated by separator.
+// It panics if the separator is empty.
+func New[M any](separator string) *Router[M] {
+	if separator == "" {
+		panic("message: separator must not be empty")
+	}
+
+	return &Router[M]{separator: separator}
+}
+
+// Use adds middleware to the router. Like the middleware of a flow.Mux, it is
+// used by the routes registered after it.
+func (r *Router[M]) Use(mw ...func(Handler[M]) Handler[M]) {
+	r.middlewares = append(r.middlewares, mw...)
+}
+
+// Handle registers a route for the pattern. It panics if the pattern isn't
+// valid (see flow.ValidatePattern).
+func (r *Router[M]) Handle(pattern string, h Handler[M]) {
+	for i := len(r.middlewares) - 1; i >= 0; i-- {
+		h = r.middlewares[i](h)
+	}
+
+	if err := r.matcher.Add(pattern, h); err != nil {
+		panic(err.Error())
+	}
+}
+
+// Dispatch calls the handler of the first route which matches the subject
+// (or the NotFound handler) with the message, and returns its error.
+func (r *Router[M]) Dispatch(ctx context.Context, subject string, msg M) error {
+	h, params, ok := r.Match(subject)
+	if !ok {
+		if r.NotFound == nil {
+			return fmt.Errorf("%w: %q", ErrNoRoute, subject)
+		}
+		return r.NotFound(ctx, msg, flow.Params{})
+	}
+
+	return h(ctx, msg, params)
+}
+
+// Match returns the handler (including its middleware) of the first route
+// which matches the subject, and the values of its parameters.
+func (r *Router[M]) Match(subject string) (Handler[M], flow.Params, bool) {
+	tokens := strings.Split(subject, r.separator)
+	for i, token := range tokens {
+		tokens[i] = url.PathEscape(token)
+	}
+
+	h, params, ok := r.matcher.Match("", "/"+strings.Join(tokens, "/"))
+	if !ok {
+		return nil, nil, false
+	}
+
+	for name, value := range params {
+		parts := strings.Split(value, "/")
+		for i, part := range parts {
+			if unescaped, err := url.PathUnescape(part); err == nil {
+				parts[i] = unescaped
+			}
+		}
+		params[name] = strings.Join(parts, r.separator)
+	}
+
+	return h, params, true
+}
@@ -0,0 +1,119 @@
+package message
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"github.com/alexedwards/flow"
+)
+
+func TestRouter(t *testing.T) {
+	var calls []string
+
+	handler := func(name string) Handler[string] {
+		return func(ctx context.Context, msg string, params flow.Params) error {
+			calls = append(calls, name+":"+msg)
+			return nil
+		}
+	}
+
+	router := New[string](".")
+	router.Handle("/orders/new", handler("new"))
+	router.Handle("/orders/:id|^[0-9]+$/created", handler("created"))
+	router.Handle("/audit/...", handler("audit"))
+	router.Handle("/files/:path.../uploaded", handler("uploaded"))
+
+	var tests = []struct {
+		Subject string
+
+		ExpectedOK     bool
+		ExpectedParams flow.Params
+	}{
+		{"orders.new", true, flow.Params{}},
+		{"orders.42.created", true, flow.Params{"id": "42"}},
+		{"orders.abc.created", false, nil},
+		{"audit.users.login", true, flow.Params{"...": "users.login"}},
+		{"files.a/b.c d.uploaded", true, flow.Params{"path": "a/b.c d"}},
+		{"files.uploaded", false, nil},
+		{"orders", false, nil},
+	}
+
+	for _, test := range tests {
+		_, params, ok := router.Match(test.Subject)
+
+		if ok != test.ExpectedOK || !maps.Equal(params, test.ExpectedParams) {
+			t.Errorf("%s: expected %v %t but got %v %t", test.Subject, test.ExpectedParams, test.ExpectedOK, params, ok)
+		}
+	}
+
+	if err := router.Dispatch(context.Background(), "orders.7.created", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(calls, []string{"created:hello"}) {
+		t.Errorf("unexpected calls %q", calls)
+	}
+
+	if err := router.Dispatch(context.Background(), "unknown", "hello"); !errors.Is(err, ErrNoRoute) {
+		t.Errorf("expected ErrNoRoute but got %v", err)
+	}
+
+	router.NotFound = handler("not found")
+	if err := router.Dispatch(context.Background(), "unknown", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(calls, []string{"created:hello", "not found:hello"}) {
+		t.Errorf("unexpected calls %q", calls)
+	}
+}
+
+func TestRouterMiddleware(t *testing.T) {
+	var calls []string
+
+	mw := func(name string) func(Handler[int]) Handler[int] {
+		return func(next Handler[int]) Handler[int] {
+			return func(ctx context.Context, msg int, params flow.Params) error {
+				calls = append(calls, name)
+				return next(ctx, msg, params)
+			}
+		}
+	}
+
+	fail := errors.New("fail")
+
+	router := New[int](" ")
+	router.Use(mw("first"))
+	router.Handle("/ping", func(ctx context.Context, msg int, params flow.Params) error {
+		calls = append(calls, "ping")
+		return nil
+	})
+	router.Use(mw("second"))
+	router.Handle("/user/:name/disable", func(ctx context.Context, msg int, params flow.Params) error {
+		calls = append(calls, "disable "+params["name"])
+		return fail
+	})
+
+	if err := router.Dispatch(context.Background(), "ping", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := router.Dispatch(context.Background(), "user alice disable", 2); err != fail {
+		t.Errorf("expected the handler's error but got %v", err)
+	}
+
+	expected := []string{"first", "ping", "first", "second", "disable alice"}
+	if !slices.Equal(calls, expected) {
+		t.Errorf("expected calls %q but got %q", expected, calls)
+	}
+}
+
+func TestRouterInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for an invalid pattern")
+		}
+	}()
+
+	New[string](".").Handle("orders/:id/:id", nil)
+}
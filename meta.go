@@ -0,0 +1,60 @@
+package flow
+
+import (
+	"context"
+	"maps"
+)
+
+// Meta attaches a value to the route under the given key, replacing any value
+// already set for the key. Middleware can read it with RouteMeta, which makes
+// it possible to configure policies such as authorization or rate limit tiers
+// where the routes are declared:
+//
+//	mux.HandleFunc("/admin/users", listUsers, "GET").Meta("role", "admin")
+//
+//	func requireRole(next http.Handler) http.Handler {
+//		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+//			role, ok := flow.RouteMeta[string](r.Context(), "role")
+//			...
+//		})
+//	}
+//
+// The metadata is also included in the RouteInfo returned by Routes and
+// Match.
+func (r *Route) Meta(key string, value any) *Route {
+	r.meta = withMeta(r.meta, key, value)
+	return r
+}
+
+// Meta attaches a value under the given key to the routes registered
+// afterwards (see Route.Meta). Like middleware, it is scoped to the current
+// group.
+func (m *Mux) Meta(key string, value any) {
+	m.meta = withMeta(m.meta, key, value)
+}
+
+// RouteMeta retrieves the metadata value set with Meta for the route which
+// matched the request. It returns false if the route has no value for the key,
+// if the value isn't of type T, or if no route has been matched.
+func RouteMeta[T any](ctx context.Context, key string) (T, bool) {
+	route, _ := ctx.Value(routeContextKey{}).(*Route)
+	if route == nil {
+		var zero T
+		return zero, false
+	}
+
+	v, ok := route.meta[key].(T)
+	return v, ok
+}
+
+// withMeta returns a copy of meta with the key set, so that maps shared
+// between groups and routes are never modified.
+func withMeta(meta map[string]any, key string, value any) map[string]any {
+	meta = maps.Clone(meta)
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	meta[key] = value
+
+	return meta
+}
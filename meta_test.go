@@ -0,0 +1,75 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteMeta(t *testing.T) {
+	requireRole := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if role, ok := RouteMeta[string](r.Context(), "role"); ok && r.Header.Get("X-Role") != role {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+
+	tier := func(w http.ResponseWriter, r *http.Request) {
+		if n, ok := RouteMeta[int](r.Context(), "tier"); ok {
+			w.Write([]byte{'0' + byte(n)})
+		}
+	}
+
+	m := New()
+	m.Use(requireRole)
+	m.HandleFunc("/public", tier, "GET")
+	m.HandleFunc("/reports", tier, "GET").Meta("role", "analyst").Meta("tier", 2)
+	m.Group(func(mux *Mux) {
+		mux.Meta("role", "admin")
+		mux.Meta("tier", 1)
+		mux.HandleFunc("/admin", tier, "GET")
+		mux.HandleFunc("/admin/unlimited", tier, "GET").Meta("tier", 3)
+		mux.HandleFunc("/admin/wrong-type", tier, "GET").Meta("tier", "3")
+	})
+	m.HandleFunc("/after-group", tier, "GET")
+
+	var tests = []struct {
+		RequestPath string
+		Role        string
+
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{"/public", "", http.StatusOK, ""},
+		{"/reports", "", http.StatusForbidden, ""},
+		{"/reports", "analyst", http.StatusOK, "2"},
+		{"/admin", "analyst", http.StatusForbidden, ""},
+		{"/admin", "admin", http.StatusOK, "1"},
+		{"/admin/unlimited", "admin", http.StatusOK, "3"},
+		{"/admin/wrong-type", "admin", http.StatusOK, ""},
+		{"/after-group", "", http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.RequestPath, nil)
+		r.Header.Set("X-Role", test.Role)
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("GET %s as %q: expected status %d but was %d", test.RequestPath, test.Role, test.ExpectedStatus, rr.Code)
+		}
+		if rr.Body.String() != test.ExpectedBody {
+			t.Errorf("GET %s as %q: expected body %q but was %q", test.RequestPath, test.Role, test.ExpectedBody, rr.Body.String())
+		}
+	}
+
+	info, _, _ := m.Match("GET", "/admin/unlimited")
+	if info.Meta["role"] != "admin" || info.Meta["tier"] != 3 {
+		t.Errorf("unexpected RouteInfo.Meta %v", info.Meta)
+	}
+}
@@ -0,0 +1,292 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// standardMethods contains the standard HTTP methods in the order that they are
+// listed in the Allow header. Each method is represented by a bit in a
+// methodSet.
+var standardMethods = [...]string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace}
+
+// methodSet is a bitmask of standard HTTP methods.
+type methodSet uint16
+
+// methodBit returns the bit representing the given method, or zero if it isn't
+// a standard method.
+func methodBit(method string) methodSet {
+	for i, m := range standardMethods {
+		if m == method {
+			return 1 << i
+		}
+	}
+
+	return 0
+}
+
+func (s methodSet) methods() []string {
+	methods := make([]string, 0, len(standardMethods))
+
+	for i, m := range standardMethods {
+		if s&(1<<i) != 0 {
+			methods = append(methods, m)
+		}
+	}
+
+	return methods
+}
+
+// allowHeaders contains a precomputed Allow header value for every possible
+// methodSet, so that building the header for a standard set of methods doesn't
+// allocate.
+var allowHeaders = func() []string {
+	headers := make([]string, 1<<len(standardMethods))
+
+	for i := range headers {
+		headers[i] = strings.Join(append((methodSet(i)&^methodBit(http.MethodOptions)).methods(), http.MethodOptions), ", ")
+	}
+
+	return headers
+}()
+
+// WithoutHead stops the route handling HEAD requests, if HEAD was added to its
+// methods because it handles GET (see Handle). HEAD requests for the route's
+// path are then handled by a later route which handles HEAD, or get a 405
+// Method Not Allowed response:
+//
+//	mux.Get("/reports/:id", downloadReport).WithoutHead()
+//	mux.Head("/reports/:id", reportHeaders)
+//
+// It has no effect on a route registered with HEAD explicitly.
+func (r *Route) WithoutHead() *Route {
+	if r.autoHead {
+		r.methods &^= methodBit(http.MethodHead)
+		r.autoHead = false
+	}
+
+	return r
+}
+
+// AllowHeader returns a value for the Allow header listing the given methods,
+// formatted in the same way as the header which the Mux sets on OPTIONS and
+// 405 Method Not Allowed responses. The standard methods are listed in a
+// fixed order (GET, HEAD, POST, PUT, PATCH, DELETE, CONNECT, TRACE), followed by
+// any other methods in the order they are given, and then OPTIONS, which is
+// always included. Duplicates are removed.
+//
+// It is useful for custom MethodNotAllowed handlers which need to build an
+// Allow header for a different set of methods.
+func AllowHeader(methods []string) string {
+	return allowHeader(addMethods(0, nil, methods))
+}
+
+// addMethods adds methods to the set of standard methods and the list of
+// custom methods.
+func addMethods(allowed methodSet, custom []string, methods []string) (methodSet, []string) {
+	for _, method := range methods {
+		if bit := methodBit(method); bit != 0 {
+			allowed |= bit
+		} else if !slices.Contains(custom, method) {
+			custom = append(custom, method)
+		}
+	}
+
+	return allowed, custom
+}
+
+// MethodLister is implemented by handlers which can report the methods they
+// allow for a request. When a handler mounted with Mux.Mount implements it,
+// the Mux asks the handler which methods it allows for each request, instead
+// of assuming it allows all of the mount's methods. Requests with a method
+// which isn't listed get the Mux's own 405 Method Not Allowed or OPTIONS
+// response, with an Allow header listing the handler's methods. If the list is
+// empty (for example, because the handler has nothing at the path), the
+// request is passed to the handler as usual.
+//
+// Mux implements MethodLister, so a Mux mounted inside another answers for its
+// own routes. Other handlers can be given a fixed list with
+// WithAllowedMethods.
+type MethodLister interface {
+	AllowedMethods(r *http.Request) []string
+}
+
+// AllowedMethods returns the methods allowed by the routes which match the
+// request's path (and host), in the order they are listed in the Allow
+// header, including OPTIONS (unless DisableAutoOptions is set and no route
+// registers OPTIONS explicitly). It returns nil if no routes match. If
+// WildcardNotFound is set, wildcard routes which don't allow the request's
+// method aren't counted, as they aren't in the Allow header of a 405 response.
+func (m *Mux) AllowedMethods(r *http.Request) []string {
+	allowed, custom := m.allowedMethods(r)
+	if allowed == 0 && len(custom) == 0 {
+		return nil
+	}
+
+	return strings.Split(m.allowHeader(allowed, custom), ", ")
+}
+
+// allowedMethods returns the methods allowed by the routes which match the
+// request's path, as they would be listed in the Allow header by ServeHTTP.
+func (m *Mux) allowedMethods(r *http.Request) (methodSet, []string) {
+	path := r.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	n := countSegments(path)
+	bit := methodBit(r.Method)
+
+	var allowed methodSet
+	var custom []string
+	var params []param
+	host := requestHost{raw: r.Host}
+
+	for _, route := range m.routes.load() {
+		var ok bool
+		if params, ok = route.match(&host, path, n, params[:0]); !ok || !route.satisfies(r) {
+			continue
+		}
+
+		result, listed := m.resolveMethod(route, r, bit)
+		if result == routeIgnored {
+			continue
+		}
+		allowed, custom = route.addAllowed(listed, allowed, custom)
+	}
+
+	return allowed, custom
+}
+
+// routeResult says how a route which matches the path of a request handles
+// the request's method.
+type routeResult int
+
+const (
+	// routeDisallows means the route doesn't handle the method, and its
+	// methods are listed in the Allow header.
+	routeDisallows routeResult = iota
+
+	// routeServes means the request is passed to the route's handler.
+	routeServes
+
+	// routeOptions means the request is passed to the route's
+	// OptionsHandler.
+	routeOptions
+
+	// routeIgnored means the route doesn't handle the method and isn't
+	// listed in the Allow header either, because it's a wildcard route and
+	// WildcardNotFound is set.
+	routeIgnored
+)
+
+// resolveMethod works out how route, which matches the path of r, handles the
+// request's method. ServeHTTP, Match and AllowedMethods all use it, so that
+// they agree. It also returns the methods listed by a mounted MethodLister, if
+// there are any.
+func (m *Mux) resolveMethod(route *Route, r *http.Request, bit methodSet) (routeResult, []string) {
+	var listed []string
+	if route.allowedMethods != nil {
+		listed = route.allowedMethods(r)
+	}
+
+	switch {
+	case route.optionsHandler != nil && r.Method == http.MethodOptions && !route.allows(r.Method, bit):
+		return routeOptions, listed
+	case len(listed) > 0 && !slices.Contains(listed, r.Method):
+		return routeDisallows, listed
+	case route.allows(r.Method, bit):
+		return routeServes, listed
+	case route.wildcard && m.WildcardNotFound && len(listed) == 0:
+		return routeIgnored, listed
+	}
+
+	return routeDisallows, listed
+}
+
+// addAllowed adds the methods which r allows to allowed and custom: the
+// methods listed by a mounted MethodLister, or otherwise the route's own.
+func (r *Route) addAllowed(listed []string, allowed methodSet, custom []string) (methodSet, []string) {
+	if len(listed) > 0 {
+		return addMethods(allowed, custom, listed)
+	}
+
+	return addMethods(allowed|r.methods, custom, r.customMethods)
+}
+
+// WithAllowedMethods returns a handler which passes requests to h, and which
+// implements MethodLister by reporting the given methods for every request.
+// It's useful for mounting handlers which only support some methods:
+//
+//	mux.Mount("/static", flow.WithAllowedMethods(http.FileServer(http.Dir("./public")), "GET", "HEAD"))
+func WithAllowedMethods(h http.Handler, methods ...string) http.Handler {
+	return &methodListHandler{Handler: h, methods: methods}
+}
+
+type methodListHandler struct {
+	http.Handler
+	methods []string
+}
+
+func (h *methodListHandler) AllowedMethods(r *http.Request) []string {
+	return h.methods
+}
+
+// allowHeader returns the value of the Allow header for the given methods.
+// OPTIONS is always listed last, and only once, whether or not a route was
+// registered for it explicitly.
+func allowHeader(allowed methodSet, custom []string) string {
+	if len(custom) == 0 {
+		return allowHeaders[allowed]
+	}
+
+	methods := append((allowed &^ methodBit(http.MethodOptions)).methods(), custom...)
+	return strings.Join(append(methods, http.MethodOptions), ", ")
+}
+
+// allowHeader returns the value of the Allow header for the given methods,
+// leaving out OPTIONS if automatic OPTIONS responses are disabled and no route
+// allows it explicitly.
+func (m *Mux) allowHeader(allowed methodSet, custom []string) string {
+	if !m.DisableAutoOptions || allowed&methodBit(http.MethodOptions) != 0 {
+		return allowHeader(allowed, custom)
+	}
+
+	return strings.Join(append(allowed.methods(), custom...), ", ")
+}
+
+type matchedMethodsContextKey struct{}
+
+// MatchedMethods returns the methods allowed by the routes which match the
+// request's path, as listed in the Allow header. It's for use in the Options
+// and MethodNotAllowed handlers (and in a route's OptionsHandler), so that
+// they can decide how to respond, for example to answer a CORS preflight
+// request. It returns nil elsewhere.
+func MatchedMethods(ctx context.Context) []string {
+	allow, _ := ctx.Value(matchedMethodsContextKey{}).(string)
+	if allow == "" {
+		return nil
+	}
+
+	return strings.Split(allow, ", ")
+}
+
+// OptionsHandler sets the handler for OPTIONS requests which match the route,
+// replacing the automatic response (see Mux.Options) for them. Unlike
+// HandleOptions, it's attached to an existing route, so the handler can read
+// the route's parameters and metadata with Param and RouteMeta:
+//
+//	mux.Get("/reports/:id", showReport).
+//		Meta("cors", "public").
+//		OptionsHandler(preflight)
+//
+// Like other routes, the first route which matches the request is used, so an
+// earlier route which matches and allows OPTIONS explicitly takes precedence.
+// The Allow header is set before the handler is called, and the top-level
+// middleware (registered with Use outside any group) is used, as it is for
+// Mux.Options. The handler is used even if DisableAutoOptions is set.
+func (r *Route) OptionsHandler(h http.Handler) *Route {
+	r.optionsHandler = h
+	return r
+}
@@ -0,0 +1,218 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+func TestAllowHeader(t *testing.T) {
+	var tests = []struct {
+		Allowed methodSet
+		Custom  []string
+
+		ExpectedHeader string
+	}{
+		{0, nil, "OPTIONS"},
+		{methodBit("PUT") | methodBit("GET"), nil, "GET, PUT, OPTIONS"},
+		{methodBit("TRACE") | methodBit("HEAD"), nil, "HEAD, TRACE, OPTIONS"},
+		{methodBit("GET"), []string{"PURGE"}, "GET, PURGE, OPTIONS"},
+		{methodBit("OPTIONS") | methodBit("TRACE") | methodBit("GET"), nil, "GET, TRACE, OPTIONS"},
+		{methodBit("OPTIONS"), []string{"PURGE"}, "PURGE, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		actual := allowHeader(test.Allowed, test.Custom)
+		if actual != test.ExpectedHeader {
+			t.Errorf("expected %q but was %q", test.ExpectedHeader, actual)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		allowHeader(methodBit("GET")|methodBit("POST"), nil)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations but got %v", allocs)
+	}
+}
+
+func TestExportedAllowHeader(t *testing.T) {
+	var tests = []struct {
+		Methods []string
+
+		ExpectedHeader string
+	}{
+		{nil, "OPTIONS"},
+		{[]string{"PUT", "GET", "HEAD"}, "GET, HEAD, PUT, OPTIONS"},
+		{[]string{"OPTIONS", "TRACE", "DELETE", "DELETE"}, "DELETE, TRACE, OPTIONS"},
+		{[]string{"PURGE", "GET", "PROPFIND", "PURGE"}, "GET, PURGE, PROPFIND, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		actual := AllowHeader(test.Methods)
+		if actual != test.ExpectedHeader {
+			t.Errorf("%v: expected %q but was %q", test.Methods, test.ExpectedHeader, actual)
+		}
+	}
+}
+
+func TestDisableAutoHead(t *testing.T) {
+	reply := func(s string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Handler", s)
+		}
+	}
+
+	m := New()
+	m.Get("/auto", reply("auto"))
+	m.Get("/without", reply("get")).WithoutHead()
+	m.Head("/without", reply("head"))
+	m.HandleFunc("/explicit", reply("explicit"), "GET", "HEAD").WithoutHead()
+	m.HandleFunc("/lower", reply("lower"), "get")
+	m.Group(func(m *Mux) {
+		m.DisableAutoHead = true
+		m.Get("/disabled", reply("disabled"))
+		m.HandleFunc("/defaults", reply("defaults"))
+	})
+	m.Get("/after", reply("after"))
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedStatus  int
+		ExpectedHandler string
+		ExpectedAllow   string
+	}{
+		{"/auto", http.StatusOK, "auto", ""},
+		{"/without", http.StatusOK, "head", ""},
+		{"/explicit", http.StatusOK, "explicit", ""},
+		{"/lower", http.StatusOK, "lower", ""},
+		{"/disabled", http.StatusMethodNotAllowed, "", "GET, OPTIONS"},
+		{"/defaults", http.StatusOK, "defaults", ""},
+		{"/after", http.StatusOK, "after", ""},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("HEAD", test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("HEAD %s: expected status %d but got %d", test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if handler := rr.Header().Get("X-Handler"); handler != test.ExpectedHandler {
+			t.Errorf("HEAD %s: expected handler %q but got %q", test.RequestPath, test.ExpectedHandler, handler)
+		}
+		if allow := rr.Header().Get("Allow"); allow != test.ExpectedAllow {
+			t.Errorf("HEAD %s: expected Allow %q but got %q", test.RequestPath, test.ExpectedAllow, allow)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/without", nil))
+	if handler := rr.Header().Get("X-Handler"); handler != "get" {
+		t.Errorf("GET /without: expected handler %q but got %q", "get", handler)
+	}
+}
+
+func TestOptionsHandler(t *testing.T) {
+	var matched []string
+	preflight := func(w http.ResponseWriter, r *http.Request) {
+		matched = MatchedMethods(r.Context())
+		w.Header().Set("X-Handler", "preflight:"+Param(r.Context(), "id"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	m := New()
+	m.Options = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		matched = MatchedMethods(r.Context())
+		w.Header().Set("X-Handler", "options")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	m.HandleOptions("/reports/special", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "explicit")
+	})
+	m.Get("/reports/:id", func(w http.ResponseWriter, r *http.Request) {}).OptionsHandler(http.HandlerFunc(preflight))
+	m.Post("/reports/:id", func(w http.ResponseWriter, r *http.Request) {})
+	m.Get("/plain", func(w http.ResponseWriter, r *http.Request) {})
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedHandler string
+		ExpectedAllow   string
+		ExpectedMatched []string
+	}{
+		{"/reports/42", "preflight:42", "GET, HEAD, POST, OPTIONS", []string{"GET", "HEAD", "POST", "OPTIONS"}},
+		{"/reports/special", "explicit", "", nil},
+		{"/plain", "options", "GET, HEAD, OPTIONS", []string{"GET", "HEAD", "OPTIONS"}},
+	}
+
+	for _, test := range tests {
+		matched = nil
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("OPTIONS", test.RequestPath, nil))
+
+		if handler := rr.Header().Get("X-Handler"); handler != test.ExpectedHandler {
+			t.Errorf("OPTIONS %s: expected handler %q but got %q", test.RequestPath, test.ExpectedHandler, handler)
+		}
+		if allow := rr.Header().Get("Allow"); allow != test.ExpectedAllow {
+			t.Errorf("OPTIONS %s: expected Allow %q but got %q", test.RequestPath, test.ExpectedAllow, allow)
+		}
+		if !slices.Equal(matched, test.ExpectedMatched) {
+			t.Errorf("OPTIONS %s: expected matched methods %q but got %q", test.RequestPath, test.ExpectedMatched, matched)
+		}
+	}
+
+	if methods := MatchedMethods(context.Background()); methods != nil {
+		t.Errorf("expected no matched methods but got %q", methods)
+	}
+}
+
+func TestDisableAutoOptions(t *testing.T) {
+	m := New()
+	m.DisableAutoOptions = true
+	m.Get("/items", func(w http.ResponseWriter, r *http.Request) {})
+	m.HandleOptions("/explicit", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "explicit")
+	})
+	m.Get("/explicit", func(w http.ResponseWriter, r *http.Request) {})
+	m.Get("/preflight", func(w http.ResponseWriter, r *http.Request) {}).OptionsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "preflight")
+	}))
+
+	var tests = []struct {
+		RequestMethod string
+		RequestPath   string
+
+		ExpectedStatus  int
+		ExpectedHandler string
+		ExpectedAllow   string
+	}{
+		{"OPTIONS", "/items", http.StatusMethodNotAllowed, "", "GET, HEAD"},
+		{"POST", "/items", http.StatusMethodNotAllowed, "", "GET, HEAD"},
+		{"OPTIONS", "/explicit", http.StatusOK, "explicit", ""},
+		{"POST", "/explicit", http.StatusMethodNotAllowed, "", "GET, HEAD, OPTIONS"},
+		{"OPTIONS", "/preflight", http.StatusOK, "preflight", "GET, HEAD"},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.RequestMethod, test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but got %d", test.RequestMethod, test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if handler := rr.Header().Get("X-Handler"); handler != test.ExpectedHandler {
+			t.Errorf("%s %s: expected handler %q but got %q", test.RequestMethod, test.RequestPath, test.ExpectedHandler, handler)
+		}
+		if allow := rr.Header().Get("Allow"); allow != test.ExpectedAllow {
+			t.Errorf("%s %s: expected Allow %q but got %q", test.RequestMethod, test.RequestPath, test.ExpectedAllow, allow)
+		}
+	}
+
+	if methods := m.AllowedMethods(httptest.NewRequest("GET", "/items", nil)); !slices.Equal(methods, []string{"GET", "HEAD"}) {
+		t.Errorf("expected allowed methods GET, HEAD but got %q", methods)
+	}
+}
@@ -0,0 +1,210 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMirrorDeleteHeaders are the headers which a Mirror removes from
+// mirrored requests, unless its Transform lists different ones, so that
+// credentials aren't sent to the secondary environment.
+var DefaultMirrorDeleteHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// Mirror is middleware which sends copies of a percentage of requests to a
+// secondary environment, such as a staging deployment, so that a new version
+// can be validated with real traffic. Use its Middleware method with Use:
+//
+//	u, _ := url.Parse("https://staging.internal")
+//	mirror := &flow.Mirror{Target: u, Percent: 5, MaxPerSecond: 20}
+//	mux.Use(mirror.Middleware)
+//
+// Mirrored requests are sent in the background after the request has been
+// received, and their responses are discarded, so they don't delay or change
+// the response to the client. Requests with a body larger than MaxBodyBytes (or
+// of unknown length) aren't mirrored.
+//
+// A Mirror must not be changed after it has handled its first request.
+type Mirror struct {
+	// Target is the base URL of the secondary environment. The path and
+	// query of the request are appended to it.
+	Target *url.URL
+
+	// Percent is the percentage of requests (between 0 and 100) which are
+	// mirrored.
+	Percent float64
+
+	// MaxPerSecond limits the number of requests mirrored each second. If
+	// it is zero, there is no limit.
+	MaxPerSecond float64
+
+	// MaxInFlight limits the number of mirrored requests which can be in
+	// progress at once. Requests which would exceed it aren't mirrored. If
+	// it is zero, 100 is used.
+	MaxInFlight int
+
+	// MaxBodyBytes is the size of the largest request body which is
+	// mirrored. Bodies are buffered in memory. If it is zero, 1MB is used.
+	MaxBodyBytes int64
+
+	// Timeout limits the time taken by each mirrored request. If it is
+	// zero, 10 seconds is used.
+	Timeout time.Duration
+
+	// Transform describes changes to make to mirrored requests, such as
+	// removing sensitive headers or marking them as mirrored. If its
+	// DeleteHeaders is nil, DefaultMirrorDeleteHeaders is used.
+	Transform RequestTransform
+
+	// Transport is used to make the mirrored requests. If it is nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	once      sync.Once
+	transform RequestTransform
+	inFlight  chan struct{}
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func (mr *Mirror) init() {
+	mr.transform = mr.Transform
+	if mr.transform.DeleteHeaders == nil {
+		mr.transform.DeleteHeaders = DefaultMirrorDeleteHeaders
+	}
+
+	maxInFlight := mr.MaxInFlight
+	if maxInFlight <= 0 {
+		maxInFlight = 100
+	}
+	mr.inFlight = make(chan struct{}, maxInFlight)
+}
+
+// Middleware mirrors requests to the next handler.
+func (mr *Mirror) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr.once.Do(mr.init)
+
+		if rand.Float64()*100 < mr.Percent && mr.allow() {
+			select {
+			case mr.inFlight <- struct{}{}:
+				if out, cancel, ok := mr.newRequest(r); ok {
+					go mr.send(out, cancel)
+				} else {
+					<-mr.inFlight
+				}
+			default:
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow reports whether a request may be mirrored under the rate limit. The
+// limit allows bursts of up to one second's worth of requests.
+func (mr *Mirror) allow() bool {
+	if mr.MaxPerSecond <= 0 {
+		return true
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	now := time.Now()
+	burst := max(mr.MaxPerSecond, 1)
+	if mr.last.IsZero() {
+		mr.tokens = burst
+	} else {
+		mr.tokens = min(mr.tokens+now.Sub(mr.last).Seconds()*mr.MaxPerSecond, burst)
+	}
+	mr.last = now
+
+	if mr.tokens < 1 {
+		return false
+	}
+	mr.tokens--
+	return true
+}
+
+// bufferBody reads the body of r so that it can be sent twice, replacing
+// r.Body with a copy. It returns false if the body can't be mirrored.
+func (mr *Mirror) bufferBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return nil, true
+	}
+
+	maxBody := mr.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = maxRetryBodySize
+	}
+	if r.ContentLength < 0 || r.ContentLength > maxBody {
+		return nil, false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+	if err != nil {
+		// Pass on whatever was read, and let the handler deal with the
+		// truncated body.
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	return body, true
+}
+
+// newRequest builds the mirrored copy of r. It returns false if r can't be
+// mirrored.
+func (mr *Mirror) newRequest(r *http.Request) (*http.Request, context.CancelFunc, bool) {
+	body, ok := mr.bufferBody(r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	u := *mr.Target
+	u.Path = strings.TrimSuffix(u.Path, "/") + r.URL.Path
+	u.RawPath = ""
+	u.RawQuery = r.URL.RawQuery
+
+	timeout := mr.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(r.Context()), timeout)
+
+	out, err := http.NewRequestWithContext(ctx, r.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		cancel()
+		return nil, nil, false
+	}
+	out.Header = r.Header.Clone()
+
+	return mr.transform.apply(out), cancel, true
+}
+
+// send makes the mirrored request and discards the response.
+func (mr *Mirror) send(out *http.Request, cancel context.CancelFunc) {
+	defer func() { <-mr.inFlight }()
+	defer cancel()
+
+	transport := mr.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	resp, err := transport.RoundTrip(out)
+	if err != nil {
+		return
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+}
@@ -0,0 +1,136 @@
+package flow
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mirroredRequest struct {
+	Method string
+	URI    string
+	Body   string
+	Header http.Header
+}
+
+func newMirrorTarget(t *testing.T) (*url.URL, chan mirroredRequest) {
+	received := make(chan mirroredRequest, 10)
+	staging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- mirroredRequest{r.Method, r.RequestURI, string(body), r.Header}
+		w.Write([]byte("ignored"))
+	}))
+	t.Cleanup(staging.Close)
+
+	u, err := url.Parse(staging.URL + "/mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return u, received
+}
+
+func TestMirror(t *testing.T) {
+	target, received := newMirrorTarget(t)
+
+	mirror := &Mirror{
+		Target:    target,
+		Percent:   100,
+		Transform: RequestTransform{SetHeaders: map[string]string{"X-Mirrored": "true"}},
+	}
+
+	var handlerBody string
+
+	m := New()
+	m.Use(mirror.Middleware)
+	m.HandleFunc("/orders", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		handlerBody = string(body)
+		w.Write([]byte("created"))
+	}, "POST")
+
+	r := httptest.NewRequest("POST", "/orders?source=web", strings.NewReader(`{"item":7}`))
+	r.Header.Set("Authorization", "Bearer secret")
+	r.Header.Set("Cookie", "session=secret")
+	r.Header.Set("Content-Type", "application/json")
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, r)
+
+	if rr.Body.String() != "created" {
+		t.Errorf("expected response %q but was %q", "created", rr.Body.String())
+	}
+	if handlerBody != `{"item":7}` {
+		t.Errorf("expected handler to read body %q but got %q", `{"item":7}`, handlerBody)
+	}
+
+	select {
+	case got := <-received:
+		if got.Method != "POST" || got.URI != "/mirror/orders?source=web" || got.Body != `{"item":7}` {
+			t.Errorf("unexpected mirrored request: %s %s %q", got.Method, got.URI, got.Body)
+		}
+		for _, name := range DefaultMirrorDeleteHeaders {
+			if value := got.Header.Get(name); value != "" {
+				t.Errorf("expected %s header to be removed but was %q", name, value)
+			}
+		}
+		if got.Header.Get("Content-Type") != "application/json" || got.Header.Get("X-Mirrored") != "true" {
+			t.Errorf("unexpected mirrored headers: %v", got.Header)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("request wasn't mirrored")
+	}
+}
+
+func TestMirrorLimits(t *testing.T) {
+	var tests = []struct {
+		Name    string
+		Mirror  *Mirror
+		Body    string
+		Repeats int
+
+		ExpectedMirrored int
+	}{
+		{"percent", &Mirror{Percent: 0}, "", 5, 0},
+		{"rate", &Mirror{Percent: 100, MaxPerSecond: 2}, "", 5, 2},
+		{"body size", &Mirror{Percent: 100, MaxBodyBytes: 4}, "too large", 3, 0},
+		{"small body", &Mirror{Percent: 100, MaxBodyBytes: 4}, "ok", 3, 3},
+	}
+
+	for _, test := range tests {
+		target, received := newMirrorTarget(t)
+
+		mirror := test.Mirror
+		mirror.Target = target
+
+		m := New()
+		m.Use(mirror.Middleware)
+		m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			if body, _ := io.ReadAll(r.Body); string(body) != test.Body {
+				t.Errorf("%s: expected handler to read body %q but got %q", test.Name, test.Body, body)
+			}
+		}, "POST")
+
+		for i := 0; i < test.Repeats; i++ {
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader(test.Body)))
+		}
+
+		mirrored := 0
+		for done := false; !done; {
+			select {
+			case <-received:
+				mirrored++
+			case <-time.After(100 * time.Millisecond):
+				done = true
+			}
+		}
+
+		if mirrored != test.ExpectedMirrored {
+			t.Errorf("%s: expected %d mirrored requests but got %d", test.Name, test.ExpectedMirrored, mirrored)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package flow
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Mount delegates all requests for the prefix, and for any path below it, to
+// handler. The prefix is removed from the request path before the handler is
+// called, so a request for /admin/users/1 to a handler mounted at "/admin" is
+// seen by the handler as a request for /users/1 (and requests for /admin and
+// /admin/ are seen as requests for /). This allows third-party handlers, such
+// as net/http/pprof, an http.FileServer or another router, to be embedded in a
+// subtree:
+//
+//	mux.Mount("/debug/files", http.FileServer(http.Dir("./public")))
+//
+// The prefix must begin with a slash and must not end with one. It may contain
+// named parameters, which are available to the handler with Param, but it must
+// not contain a wildcard. Mount registers two routes (for the prefix itself and
+// for "prefix/..."), both using the default methods (see Handle), and returns
+// the second one. If handler implements MethodLister, it decides which methods
+// are allowed for each request instead.
+func (m *Mux) Mount(prefix string, handler http.Handler) *Route {
+	if !strings.HasPrefix(prefix, "/") || strings.HasSuffix(prefix, "/") {
+		panic(fmt.Sprintf("flow: mount prefix %q must begin with a slash and not end with one", prefix))
+	}
+	if countWildcards(strings.Split(prefix, "/")) > 0 {
+		panic(fmt.Sprintf("flow: mount prefix %q must not contain a wildcard", prefix))
+	}
+
+	depth := strings.Count(m.prefix+prefix, "/")
+	stripped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(w, stripSegments(r, depth))
+	})
+
+	root := m.Handle(prefix, stripped)
+	route := m.Handle(prefix+"/...", stripped)
+
+	if lister, ok := handler.(MethodLister); ok {
+		allowed := func(r *http.Request) []string {
+			return lister.AllowedMethods(stripSegments(r, depth))
+		}
+		root.allowedMethods, route.allowedMethods = allowed, allowed
+	}
+
+	return route
+}
+
+// stripSegments returns a shallow copy of r with the first n segments removed
+// from the URL path.
+func stripSegments(r *http.Request, n int) *http.Request {
+	escaped := r.URL.EscapedPath()
+
+	rest := "/"
+	for i := 0; i <= n && escaped != ""; i++ {
+		_, after, found := strings.Cut(escaped, "/")
+		if !found {
+			escaped = ""
+			break
+		}
+		escaped = after
+	}
+	if escaped != "" {
+		rest = "/" + escaped
+	}
+
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+
+	if path, err := url.PathUnescape(rest); err == nil {
+		r2.URL.Path = path
+	} else {
+		r2.URL.Path = rest
+	}
+	r2.URL.RawPath = ""
+	if rest != r2.URL.Path {
+		r2.URL.RawPath = rest
+	}
+
+	return r2
+}
@@ -0,0 +1,162 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + r.URL.RawPath + " " + Param(r.Context(), "org")))
+	})
+
+	inner := New()
+	inner.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("inner user " + Param(r.Context(), "id")))
+	}, "GET")
+
+	m := New()
+	m.Mount("/echo", echo)
+	m.Mount("/admin", inner)
+	m.Route("/orgs/:org", func(m *Mux) {
+		m.Mount("/files", echo)
+	})
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{"/echo", http.StatusOK, "/  "},
+		{"/echo/", http.StatusOK, "/  "},
+		{"/echo/a/b/c", http.StatusOK, "/a/b/c  "},
+		{"/echo/a%2Fb/c", http.StatusOK, "/a/b/c /a%2Fb/c "},
+		{"/admin/users/42", http.StatusOK, "inner user 42"},
+		{"/admin/missing", http.StatusNotFound, ""},
+		{"/orgs/acme/files/docs/readme.txt", http.StatusOK, "/docs/readme.txt  acme"},
+		{"/echoes", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("GET %s: expected status %d but was %d", test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedStatus == http.StatusOK && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("GET %s: expected body %q but was %q", test.RequestPath, test.ExpectedBody, rr.Body.String())
+		}
+	}
+
+	for _, prefix := range []string{"", "admin", "/admin/", "/files/..."} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for prefix %q", prefix)
+				}
+			}()
+			m.Mount(prefix, echo)
+		}()
+	}
+}
+
+func TestMountAllowedMethods(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}
+
+	inner := New()
+	inner.HandleFunc("/users/:id", ok, "GET", "PUT")
+	inner.MethodNotAllowed = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	m := New()
+	m.Mount("/admin", inner)
+	m.Mount("/static", WithAllowedMethods(http.HandlerFunc(ok), "GET", "HEAD"))
+	m.Mount("/echo", http.HandlerFunc(ok))
+	m.HandleFunc("/static/upload", ok, "POST")
+
+	var tests = []struct {
+		RequestMethod string
+		RequestPath   string
+
+		ExpectedStatus int
+		ExpectedAllow  string
+	}{
+		{"GET", "/admin/users/1", http.StatusOK, ""},
+		{"DELETE", "/admin/users/1", http.StatusMethodNotAllowed, "GET, HEAD, PUT, OPTIONS"},
+		{"OPTIONS", "/admin/users/1", http.StatusNoContent, "GET, HEAD, PUT, OPTIONS"},
+		{"DELETE", "/admin/missing", http.StatusNotFound, ""},
+		{"GET", "/static/site.css", http.StatusOK, ""},
+		{"POST", "/static/site.css", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS"},
+		{"OPTIONS", "/static/site.css", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{"POST", "/static/upload", http.StatusOK, ""},
+		{"DELETE", "/static/upload", http.StatusMethodNotAllowed, "GET, HEAD, POST, OPTIONS"},
+		{"DELETE", "/echo/anything", http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.RequestMethod, test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.RequestMethod, test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if allow := rr.Header().Get("Allow"); allow != test.ExpectedAllow {
+			t.Errorf("%s %s: expected Allow header %q but was %q", test.RequestMethod, test.RequestPath, test.ExpectedAllow, allow)
+		}
+	}
+}
+
+func TestMethodResolutionAgrees(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.WildcardNotFound = true
+	m.Mount("/static", WithAllowedMethods(http.HandlerFunc(ok), "GET", "HEAD"))
+	m.HandleFunc("/files/...", ok, "GET")
+	m.HandleFunc("/items/:id", ok, "GET").OptionsHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	var tests = []struct {
+		RequestMethod string
+		RequestPath   string
+
+		ExpectedStatus  int
+		ExpectedMatch   bool
+		ExpectedAllowed string
+	}{
+		{"GET", "/static/site.css", http.StatusOK, true, "GET, HEAD, OPTIONS"},
+		{"POST", "/static/site.css", http.StatusMethodNotAllowed, false, "GET, HEAD, OPTIONS"},
+		{"GET", "/files/a/b", http.StatusOK, true, "GET, HEAD, OPTIONS"},
+		{"POST", "/files/a/b", http.StatusNotFound, false, ""},
+		{"OPTIONS", "/items/1", http.StatusAccepted, true, "GET, HEAD, OPTIONS"},
+		{"DELETE", "/items/1", http.StatusMethodNotAllowed, false, "GET, HEAD, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.RequestMethod, test.RequestPath, nil)
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.RequestMethod, test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if _, _, matched := m.Match(test.RequestMethod, test.RequestPath); matched != test.ExpectedMatch {
+			t.Errorf("%s %s: expected Match to return %t but was %t", test.RequestMethod, test.RequestPath, test.ExpectedMatch, matched)
+		}
+		if allowed := strings.Join(m.AllowedMethods(r), ", "); allowed != test.ExpectedAllowed {
+			t.Errorf("%s %s: expected AllowedMethods %q but was %q", test.RequestMethod, test.RequestPath, test.ExpectedAllowed, allowed)
+		}
+		if allow := rr.Header().Get("Allow"); allow != "" && allow != test.ExpectedAllowed {
+			t.Errorf("%s %s: expected Allow header %q but was %q", test.RequestMethod, test.RequestPath, test.ExpectedAllowed, allow)
+		}
+	}
+}
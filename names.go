@@ -0,0 +1,99 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// HandlerNamed sets the name of the route's handler, which is shown in the route
+// listing (see RouteInfo), in errors logged by DefaultErrorHandler and
+// ProblemErrorHandler, and is available to middleware through HandlerName.
+// By default the name is worked out from the handler when the route is
+// registered: the function name for a handler function (like
+// "users.GetUser"), or the type name for other handlers (like "*users.API").
+// Anonymous functions get names like "main.main.func1", so naming them
+// explicitly is more useful:
+//
+//	mux.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+//		...
+//	}, "GET").HandlerNamed("GetUser")
+//
+// The handler name is only descriptive; to give the route a name for use with
+// Reverse, use Named.
+func (r *Route) HandlerNamed(name string) *Route {
+	r.handlerName = name
+	return r
+}
+
+// HandlerName returns the name of the handler for the route which matched the
+// request (see Route.HandlerNamed), or the empty string if no route has been matched.
+func HandlerName(ctx context.Context) string {
+	route, _ := ctx.Value(routeContextKey{}).(*Route)
+	if route == nil {
+		return ""
+	}
+
+	return route.handlerName
+}
+
+// RoutePattern returns the pattern of the route which matched the request,
+// including the prefixes of any groups created with Route (for example
+// "/api/v1/users/:id"), or the empty string if no route has been matched. Unlike
+// the request path, it has a small number of values, so it's suitable for use as
+// a metrics label. The host pattern of a route registered with Host isn't
+// included.
+func RoutePattern(ctx context.Context) string {
+	route, _ := ctx.Value(routeContextKey{}).(*Route)
+	if route == nil {
+		return ""
+	}
+
+	return route.pattern
+}
+
+func defaultHandlerName(h http.Handler) string {
+	if hf, ok := h.(http.HandlerFunc); ok {
+		return funcName(hf)
+	}
+
+	return trimPackagePath(fmt.Sprintf("%T", h))
+}
+
+// sameFunc reports whether a and b are the same function. Closures created by
+// the same function literal count as the same.
+func sameFunc(a, b any) bool {
+	return reflect.ValueOf(a).Pointer() == reflect.ValueOf(b).Pointer()
+}
+
+// funcName returns the name of a function, without the path of its package
+// (for example "flow.Recover.func1").
+func funcName(fn any) string {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+
+	f := runtime.FuncForPC(v.Pointer())
+	if f == nil {
+		return ""
+	}
+
+	return trimPackagePath(f.Name())
+}
+
+func trimPackagePath(name string) string {
+	prefix := ""
+	if strings.HasPrefix(name, "*") {
+		prefix, name = "*", name[1:]
+	}
+
+	if slash := strings.LastIndexByte(name, '/'); slash != -1 {
+		name = name[slash+1:]
+	}
+
+	return prefix + name
+}
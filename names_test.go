@@ -0,0 +1,115 @@
+package flow
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func namedTestHandler(w http.ResponseWriter, r *http.Request) {}
+
+func TestHandlerNames(t *testing.T) {
+	var name string
+
+	m := New()
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			name = HandlerName(r.Context())
+		})
+	})
+
+	m.HandleFunc("/func", namedTestHandler, "GET")
+	m.HandleFunc("/anon", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+	m.HandleFunc("/named", func(w http.ResponseWriter, r *http.Request) {}, "GET").HandlerNamed("GetThing")
+	m.Handle("/type", NewAdmin(New(), func(h http.Handler) http.Handler { return h }), "GET")
+
+	var tests = []struct {
+		Path string
+
+		ExpectedName string
+	}{
+		{"/func", "flow.namedTestHandler"},
+		{"/anon", "flow.TestHandlerNames.func2"},
+		{"/named", "GetThing"},
+		{"/type", "*flow.Admin"},
+	}
+
+	for _, test := range tests {
+		name = ""
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.Path, nil))
+
+		if name != test.ExpectedName {
+			t.Errorf("%s: expected handler name %q but was %q", test.Path, test.ExpectedName, name)
+		}
+
+		info, _, _ := m.Match("GET", test.Path)
+		if info.Handler != test.ExpectedName {
+			t.Errorf("%s: expected RouteInfo.Handler %q but was %q", test.Path, test.ExpectedName, info.Handler)
+		}
+	}
+}
+
+func TestHandlerNameInErrorLog(t *testing.T) {
+	var buf bytes.Buffer
+	logOutput := log.Writer()
+	log.SetOutput(&buf)
+	defer log.SetOutput(logOutput)
+
+	m := New()
+	m.Use(Recover(nil))
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}, "GET").HandlerNamed("Home")
+
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if !strings.Contains(buf.String(), "flow: GET / (Home): panic: boom") {
+		t.Errorf("expected the handler name in the log output; got %q", buf.String())
+	}
+}
+
+func TestRoutePattern(t *testing.T) {
+	var pattern string
+
+	m := New()
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			pattern = RoutePattern(r.Context())
+		})
+	})
+
+	m.HandleFunc("/users/:id", namedTestHandler, "GET")
+	m.Route("/api/v1", func(mux *Mux) {
+		mux.HandleFunc("/orders/:id|int", namedTestHandler, "GET")
+	})
+	m.Host("api.example.com", func(mux *Mux) {
+		mux.HandleFunc("/status", namedTestHandler, "GET")
+	})
+	m.Mount("/assets", http.NotFoundHandler())
+
+	var tests = []struct {
+		Path string
+
+		ExpectedPattern string
+	}{
+		{"/users/7", "/users/:id"},
+		{"/api/v1/orders/42", "/api/v1/orders/:id|int"},
+		{"http://api.example.com/status", "/status"},
+		{"/assets/css/site.css", "/assets/..."},
+		{"/missing", ""},
+	}
+
+	for _, test := range tests {
+		pattern = ""
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.Path, nil))
+
+		if pattern != test.ExpectedPattern {
+			t.Errorf("%s: expected route pattern %q but was %q", test.Path, test.ExpectedPattern, pattern)
+		}
+	}
+}
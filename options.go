@@ -0,0 +1,106 @@
+package flow
+
+import (
+	"net/http"
+	"slices"
+)
+
+// An Option configures a Mux when it is created with New. Each option sets the
+// Mux field of the corresponding name, so the two styles of configuration can
+// be mixed freely:
+//
+//	mux := flow.New(flow.WithNotFound(notFound), flow.WithMaxBody(1<<20))
+type Option func(*Mux)
+
+// WithNotFound sets the handler for requests which don't match any route.
+func WithNotFound(h http.Handler) Option {
+	return func(m *Mux) { m.NotFound = h }
+}
+
+// WithMethodNotAllowed sets the handler for requests which match a route, but
+// not its methods.
+func WithMethodNotAllowed(h http.Handler) Option {
+	return func(m *Mux) { m.MethodNotAllowed = h }
+}
+
+// WithOptionsHandler sets the handler for OPTIONS requests which match a route
+// without an explicit OPTIONS method.
+func WithOptionsHandler(h http.Handler) Option {
+	return func(m *Mux) { m.Options = h }
+}
+
+// WithBadRequest sets the handler for requests with a malformed path.
+func WithBadRequest(h http.Handler) Option {
+	return func(m *Mux) { m.BadRequest = h }
+}
+
+// WithMaxURLLength sets the maximum length of the request target, and
+// optionally the handler for requests which exceed it (if h is not nil).
+func WithMaxURLLength(n int, h http.Handler) Option {
+	return func(m *Mux) {
+		m.MaxURLLength = n
+		if h != nil {
+			m.URITooLong = h
+		}
+	}
+}
+
+// WithMaxBody sets the maximum size of request bodies in bytes.
+func WithMaxBody(n int64) Option {
+	return func(m *Mux) { m.MaxBodyBytes = n }
+}
+
+// WithAllowTrace includes TRACE in the methods for routes registered without
+// any methods.
+func WithAllowTrace() Option {
+	return func(m *Mux) { m.AllowTrace = true }
+}
+
+// WithAllowConnect includes CONNECT in the methods for routes registered
+// without any methods.
+func WithAllowConnect() Option {
+	return func(m *Mux) { m.AllowConnect = true }
+}
+
+// WithDefaultMethods sets the methods for routes registered without any
+// methods.
+func WithDefaultMethods(methods ...string) Option {
+	return func(m *Mux) { m.DefaultMethods = slices.Clone(methods) }
+}
+
+// WithCustomMethods adds non-standard HTTP methods which may be used when
+// registering routes.
+func WithCustomMethods(methods ...string) Option {
+	return func(m *Mux) { m.CustomMethods = append(m.CustomMethods, methods...) }
+}
+
+// WithWildcardNotFound makes wildcard routes which don't allow the request
+// method result in a 404 Not Found response rather than 405 Method Not
+// Allowed.
+func WithWildcardNotFound() Option {
+	return func(m *Mux) { m.WildcardNotFound = true }
+}
+
+// WithDisableAutoHead stops HEAD being added to the methods of routes which
+// handle GET.
+func WithDisableAutoHead() Option {
+	return func(m *Mux) { m.DisableAutoHead = true }
+}
+
+// WithDisableAutoOptions turns off the automatic responses to OPTIONS
+// requests.
+func WithDisableAutoOptions() Option {
+	return func(m *Mux) { m.DisableAutoOptions = true }
+}
+
+// WithTrailingSlash sets the policy for requests which only match a route with
+// a trailing slash added or removed.
+func WithTrailingSlash(p TrailingSlashPolicy) Option {
+	return func(m *Mux) { m.TrailingSlash = p }
+}
+
+// WithRedirectTrailingSlash is shorthand for
+// WithTrailingSlash(RedirectTrailingSlash).
+func WithRedirectTrailingSlash() Option {
+	return WithTrailingSlash(RedirectTrailingSlash)
+}
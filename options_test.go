@@ -0,0 +1,67 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOptions(t *testing.T) {
+	m := New(
+		WithNotFound(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})),
+		WithMaxURLLength(30, nil),
+		WithMaxBody(10),
+		WithCustomMethods("PURGE"),
+		WithDefaultMethods("GET", "PURGE"),
+		WithWildcardNotFound(),
+		WithDisableAutoHead(),
+		WithDisableAutoOptions(),
+	)
+
+	var bindErr error
+
+	m.HandleFunc("/cache", func(w http.ResponseWriter, r *http.Request) {})
+	m.HandleFunc("/things", func(w http.ResponseWriter, r *http.Request) {
+		var v map[string]any
+		bindErr = Bind(r, &v)
+	}, "POST")
+	m.HandleFunc("/files/...", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+
+	var tests = []struct {
+		Method string
+		Path   string
+		Body   string
+
+		ExpectedStatus int
+	}{
+		{"PURGE", "/cache", "", http.StatusOK},
+		{"GET", "/missing", "", http.StatusTeapot},
+		{"POST", "/files/a", "", http.StatusTeapot},
+		{"HEAD", "/files/a", "", http.StatusTeapot},
+		{"GET", "/cache?" + strings.Repeat("x", 30), "", http.StatusRequestURITooLong},
+		{"POST", "/things", `{"a":1}`, http.StatusOK},
+		{"OPTIONS", "/cache", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.Method, test.Path, strings.NewReader(test.Body)))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.Method, test.Path, test.ExpectedStatus, rr.Code)
+		}
+	}
+
+	if bindErr != nil {
+		t.Errorf("unexpected bind error %v", bindErr)
+	}
+
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/things", strings.NewReader(`{"a":"0123456789"}`)))
+
+	if status := StatusCode(bindErr); status != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status 413 for an oversized body but got %d (%v)", status, bindErr)
+	}
+}
@@ -0,0 +1,121 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// PanicRecord describes a panic recovered by the Recover middleware.
+type PanicRecord struct {
+	Time    time.Time `json:"time"`
+	Method  string    `json:"method"`
+	Path    string    `json:"path"`
+	Route   string    `json:"route,omitempty"`
+	Handler string    `json:"handler,omitempty"`
+	Params  []KV      `json:"params,omitempty"`
+	Value   string    `json:"value"`
+	Stack   string    `json:"stack"`
+}
+
+// PanicLog keeps the most recent panics recovered by the Recover middleware
+// in memory, so that operators can inspect crashes without searching the
+// logs. Use its ErrorHandler method to record panics:
+//
+//	panics := flow.NewPanicLog(50)
+//	mux.Use(flow.Recover(panics.ErrorHandler(nil)))
+//
+// The records can be exposed through an Admin section, or by mounting the
+// PanicLog itself as a debug endpoint, which returns them as JSON in response
+// to a GET request. Stack traces can reveal details of the application, so
+// the endpoint should be protected by authentication middleware.
+type PanicLog struct {
+	mu      sync.Mutex
+	records []PanicRecord
+	next    int
+	full    bool
+}
+
+// NewPanicLog returns a PanicLog which keeps the last size panics. It panics
+// if size is less than 1.
+func NewPanicLog(size int) *PanicLog {
+	if size < 1 {
+		panic("flow: panic log size must be at least 1")
+	}
+
+	return &PanicLog{records: make([]PanicRecord, size)}
+}
+
+// ErrorHandler returns an ErrorHandler which records any *PanicError in the
+// log, and then passes the error to next. Panics used to send a client error
+// response (such as panic(flow.Abort(http.StatusNotFound))) aren't recorded.
+// If next is nil, DefaultErrorHandler is used.
+func (l *PanicLog) ErrorHandler(next ErrorHandler) ErrorHandler {
+	if next == nil {
+		next = DefaultErrorHandler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		if panicErr, ok := err.(*PanicError); ok && StatusCode(err) >= 500 {
+			l.record(r, panicErr)
+		}
+		next(w, r, err)
+	}
+}
+
+func (l *PanicLog) record(r *http.Request, err *PanicError) {
+	rec := PanicRecord{
+		Time:    time.Now(),
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Handler: HandlerName(r.Context()),
+		Params:  ParamSlice(r.Context()),
+		Value:   fmt.Sprint(err.Value),
+		Stack:   string(err.Stack),
+	}
+	if route, _ := r.Context().Value(routeContextKey{}).(*Route); route != nil {
+		rec.Route = route.pattern
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.records[l.next] = rec
+	l.next = (l.next + 1) % len(l.records)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// Records returns the recorded panics, most recent first.
+func (l *PanicLog) Records() []PanicRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := l.next
+	if l.full {
+		n = len(l.records)
+	}
+
+	records := make([]PanicRecord, n)
+	for i := range records {
+		records[i] = l.records[(l.next-1-i+len(l.records))%len(l.records)]
+	}
+
+	return records
+}
+
+// ServeHTTP implements the debug endpoint for reading the records.
+func (l *PanicLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(l.Records())
+}
@@ -0,0 +1,66 @@
+package flow
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPanicLog(t *testing.T) {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(io.Discard)
+
+	panics := NewPanicLog(2)
+
+	m := New()
+	m.Use(Recover(panics.ErrorHandler(nil)))
+	m.HandleFunc("/crash/:id", func(w http.ResponseWriter, r *http.Request) {
+		panic(fmt.Sprintf("crash %s", Param(r.Context(), "id")))
+	}, "GET").HandlerNamed("Crash")
+	m.HandleFunc("/abort", func(w http.ResponseWriter, r *http.Request) {
+		panic(Abort(http.StatusNotFound))
+	}, "GET")
+	m.Handle("/debug/panics", panics, "GET")
+
+	for _, path := range []string{"/crash/1", "/crash/2", "/abort", "/crash/3"} {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+	}
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/debug/panics", nil))
+
+	var records []PanicRecord
+	if err := json.NewDecoder(rr.Body).Decode(&records); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records but got %d", len(records))
+	}
+
+	for i, expected := range []string{"3", "2"} {
+		rec := records[i]
+		if rec.Value != "crash "+expected || rec.Path != "/crash/"+expected || rec.Method != "GET" {
+			t.Errorf("unexpected record %d: %+v", i, rec)
+		}
+		if rec.Route != "/crash/:id" || rec.Handler != "Crash" {
+			t.Errorf("unexpected route %q or handler %q", rec.Route, rec.Handler)
+		}
+		if len(rec.Params) != 1 || rec.Params[0] != (KV{Key: "id", Value: expected}) {
+			t.Errorf("unexpected params %v", rec.Params)
+		}
+		if !strings.Contains(rec.Stack, "panics_test.go") {
+			t.Errorf("expected stack trace to include the handler")
+		}
+	}
+
+	if records := NewPanicLog(3).Records(); len(records) != 0 {
+		t.Errorf("expected no records but got %d", len(records))
+	}
+}
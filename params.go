@@ -0,0 +1,180 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// KV is the name and value of a route parameter.
+type KV struct {
+	Key   string
+	Value string
+}
+
+type paramsContextKey struct{}
+
+// ParamSlice returns all of the parameters from the matched route, in the order
+// that they appear in the route pattern. The value of a wildcard has the key
+// "..." (or its name, for a named catch-all like :path...). It's useful for
+// reconstructing a path or logging parameters in a deterministic order. It
+// returns nil if the route has no parameters.
+func ParamSlice(ctx context.Context) []KV {
+	params, _ := ctx.Value(paramsContextKey{}).([]param)
+	if len(params) == 0 {
+		return nil
+	}
+
+	kvs := make([]KV, len(params))
+	for i, p := range params {
+		kvs[i] = KV{Key: p.key.name, Value: p.value}
+	}
+
+	return kvs
+}
+
+// ParamChain returns the parameters from the matched route grouped by the level
+// of Route nesting that they were declared at, outermost first. The last
+// element holds the parameters from the pattern given to Handle itself. For
+// example, with:
+//
+//	mux.Route("/users/:userID", func(mux *flow.Mux) {
+//		mux.HandleFunc("/orders/:orderID", showOrder, "GET")
+//	})
+//
+// a request to /users/7/orders/42 gives [{"userID": "7"}, {"orderID": "42"}].
+// Levels without any parameters are included as empty maps, so the index
+// always matches the depth of nesting. Parameters from a Host pattern are
+// included in the first element. It returns nil if no route has been matched.
+func ParamChain(ctx context.Context) []Params {
+	route, _ := ctx.Value(routeContextKey{}).(*Route)
+	if route == nil {
+		return nil
+	}
+	params, _ := ctx.Value(paramsContextKey{}).([]param)
+
+	chain := make([]Params, len(route.levels)+1)
+	for i := range chain {
+		chain[i] = Params{}
+	}
+
+	// The parameters from the host come before those from the path.
+	for _, seg := range route.host {
+		if seg.param && len(params) > 0 {
+			chain[0][params[0].key.name] = params[0].value
+			params = params[1:]
+		}
+	}
+
+	level := 0
+	for i, seg := range route.segments {
+		for level < len(route.levels) && i >= route.levels[level] {
+			level++
+		}
+		if (seg.param || seg.wildcard) && len(params) > 0 {
+			chain[level][params[0].key.name] = params[0].value
+			params = params[1:]
+		}
+	}
+
+	return chain
+}
+
+// ParamInt retrieves the value of a named parameter from the request context
+// and converts it to an int. It returns an error if the parameter is missing or
+// isn't a valid integer.
+func ParamInt(ctx context.Context, param string) (int, error) {
+	s := Param(ctx, param)
+	if s == "" {
+		return 0, fmt.Errorf("flow: parameter %q is missing", param)
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("flow: parameter %q must be an integer", param)
+	}
+
+	return n, nil
+}
+
+// MustParamInt is like ParamInt, but if the parameter can't be converted it
+// sends a 400 Bad Request problem response (see WriteProblem) and returns false.
+// It allows handlers to validate parameters without repeating the error
+// handling:
+//
+//	id, ok := flow.MustParamInt(w, r, "id")
+//	if !ok {
+//		return
+//	}
+func MustParamInt(w http.ResponseWriter, r *http.Request, param string) (int, bool) {
+	n, err := ParamInt(r.Context(), param)
+	if err != nil {
+		WriteProblem(w, Problem{
+			Status:   http.StatusBadRequest,
+			Detail:   fmt.Sprintf("The %q path parameter must be an integer.", param),
+			Instance: r.URL.Path,
+		})
+		return 0, false
+	}
+
+	return n, true
+}
+
+// ParamType describes how the value of a path parameter is validated and
+// converted. Parse is called with the percent-decoded value of the parameter,
+// and should return an error if the value isn't valid.
+type ParamType struct {
+	Name  string
+	Parse func(string) (any, error)
+}
+
+// Built-in parameter types for use with Route.Param.
+var (
+	Int     = ParamType{Name: "int", Parse: func(s string) (any, error) { return strconv.Atoi(s) }}
+	Int64   = ParamType{Name: "int64", Parse: func(s string) (any, error) { return strconv.ParseInt(s, 10, 64) }}
+	Float64 = ParamType{Name: "float64", Parse: func(s string) (any, error) { return strconv.ParseFloat(s, 64) }}
+	Bool    = ParamType{Name: "bool", Parse: func(s string) (any, error) { return strconv.ParseBool(s) }}
+)
+
+// Date returns a ParamType for dates in the given layout (as used by
+// time.Parse). The converted value is a time.Time.
+func Date(layout string) ParamType {
+	return ParamType{
+		Name:  "date(" + layout + ")",
+		Parse: func(s string) (any, error) { return time.Parse(layout, s) },
+	}
+}
+
+type routeParamType struct {
+	name string
+	typ  ParamType
+}
+
+type typedParamKey string
+
+// Param sets the type of a named parameter in the route. Requests where the
+// parameter value can't be parsed by the type don't match the route (so they
+// will usually get a 404 Not Found response), and the converted value is
+// available to handlers through TypedParam. For example:
+//
+//	mux.HandleFunc("/events/:date", showEvents, "GET").Param("date", flow.Date("2006-01-02"))
+//
+// Param panics if the route's path pattern has no parameter with the name.
+func (r *Route) Param(name string, typ ParamType) *Route {
+	if !r.hasParam(name) {
+		panic(fmt.Sprintf("flow: route %q has no parameter named %q", r.pattern, name))
+	}
+
+	r.paramTypes = append(r.paramTypes, routeParamType{name: name, typ: typ})
+	return r
+}
+
+// TypedParam retrieves the converted value of a named parameter which has a
+// ParamType set on its route. It returns false if the parameter doesn't have a
+// converted value, or if the value isn't of type T.
+func TypedParam[T any](ctx context.Context, param string) (T, bool) {
+	v, ok := ctx.Value(typedParamKey(param)).(T)
+	return v, ok
+}
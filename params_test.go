@@ -0,0 +1,206 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMustParamInt(t *testing.T) {
+	var id int
+
+	m := New()
+	m.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		id, ok = MustParamInt(w, r, "id")
+		if !ok {
+			return
+		}
+	}, "GET")
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedStatus int
+		ExpectedID     int
+	}{
+		{"/users/42", http.StatusOK, 42},
+		{"/users/-7", http.StatusOK, -7},
+		{"/users/abc", http.StatusBadRequest, 0},
+		{"/users/4.2", http.StatusBadRequest, 0},
+	}
+
+	for _, test := range tests {
+		id = 0
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("GET %s: expected status %d but was %d", test.RequestPath, test.ExpectedStatus, rr.Code)
+			continue
+		}
+
+		if id != test.ExpectedID {
+			t.Errorf("GET %s: expected id %d but was %d", test.RequestPath, test.ExpectedID, id)
+		}
+
+		if rr.Code == http.StatusBadRequest {
+			if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("GET %s: expected problem content type but was %q", test.RequestPath, ct)
+			}
+
+			var p Problem
+			if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+
+			if p.Status != http.StatusBadRequest || p.Title != "Bad Request" || p.Instance != test.RequestPath {
+				t.Errorf("GET %s: unexpected problem %+v", test.RequestPath, p)
+			}
+		}
+	}
+}
+
+func TestRouteParamTypes(t *testing.T) {
+	var ctx context.Context
+
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		ctx = r.Context()
+	}
+
+	m := New()
+	m.HandleFunc("/users/:id", hf, "GET").Param("id", Int)
+	m.HandleFunc("/events/:date", hf, "GET").Param("date", Date("2006-01-02"))
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/users/42", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but was %d", http.StatusOK, rr.Code)
+	}
+
+	id, ok := TypedParam[int](ctx, "id")
+	if !ok || id != 42 {
+		t.Errorf("expected typed id 42 but was %d (ok %t)", id, ok)
+	}
+
+	if _, ok := TypedParam[string](ctx, "id"); ok {
+		t.Errorf("expected TypedParam with the wrong type to return false")
+	}
+
+	rr = httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/events/2024-02-29", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d but was %d", http.StatusOK, rr.Code)
+	}
+
+	date, ok := TypedParam[time.Time](ctx, "date")
+	if !ok || !date.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected typed date 2024-02-29 but was %s (ok %t)", date, ok)
+	}
+
+	for _, path := range []string{"/users/abc", "/events/2023-02-29", "/events/tomorrow"} {
+		rr = httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusNotFound {
+			t.Errorf("GET %s: expected status %d but was %d", path, http.StatusNotFound, rr.Code)
+		}
+	}
+}
+
+func TestRouteParamUnknownName(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), `no parameter named "idd"`) {
+			t.Errorf("expected panic for an unknown parameter name but got %v", r)
+		}
+	}()
+
+	m := New()
+	m.HandleFunc("/files/:path...", func(w http.ResponseWriter, r *http.Request) {}, "GET").Param("path", Int)
+	m.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {}, "GET").Param("idd", Int)
+}
+
+func TestParamSlice(t *testing.T) {
+	var kvs []KV
+
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		kvs = ParamSlice(r.Context())
+	}
+
+	m := New()
+	m.HandleFunc("/orgs/:org/repos/:repo/files/.../raw", hf, "GET")
+	m.HandleFunc("/static", hf, "GET")
+
+	var tests = []struct {
+		Path string
+
+		Expected []KV
+	}{
+		{"/orgs/acme/repos/flow/files/a/b.go/raw", []KV{{"org", "acme"}, {"repo", "flow"}, {"...", "a/b.go"}}},
+		{"/static", nil},
+	}
+
+	for _, test := range tests {
+		kvs = nil
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.Path, nil))
+
+		if !slices.Equal(kvs, test.Expected) {
+			t.Errorf("%s: expected params %v but got %v", test.Path, test.Expected, kvs)
+		}
+	}
+}
+
+func TestParamChain(t *testing.T) {
+	var chain []Params
+
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		chain = ParamChain(r.Context())
+	}
+
+	m := New()
+	m.Route("/users/:userID", func(m *Mux) {
+		m.HandleFunc("", hf, "GET")
+		m.Route("/orders/:orderID", func(m *Mux) {
+			m.Route("/items", func(m *Mux) {
+				m.HandleFunc("/:itemID/...", hf, "GET")
+			})
+		})
+	})
+	m.Host(":tenant.example.com", func(m *Mux) {
+		m.Route("/projects/:project", func(m *Mux) {
+			m.HandleFunc("/tasks/:task", hf, "GET")
+		})
+	})
+	m.HandleFunc("/plain/:id", hf, "GET")
+
+	var tests = []struct {
+		Target string
+
+		Expected []Params
+	}{
+		{"/users/7", []Params{{"userID": "7"}, {}}},
+		{"/users/7/orders/42/items/3/a/b", []Params{{"userID": "7"}, {"orderID": "42"}, {}, {"itemID": "3", "...": "a/b"}}},
+		{"http://acme.example.com/projects/flow/tasks/9", []Params{{"tenant": "acme", "project": "flow"}, {"task": "9"}}},
+		{"/plain/1", []Params{{"id": "1"}}},
+	}
+
+	for _, test := range tests {
+		chain = nil
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", test.Target, nil))
+
+		if !slices.EqualFunc(chain, test.Expected, func(a, b Params) bool { return maps.Equal(a, b) }) {
+			t.Errorf("%s: expected chain %v but got %v", test.Target, test.Expected, chain)
+		}
+	}
+
+	if chain := ParamChain(context.Background()); chain != nil {
+		t.Errorf("expected nil chain without a matched route but got %v", chain)
+	}
+}
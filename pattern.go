@@ -0,0 +1,77 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ValidatePattern checks a route pattern for mistakes which would otherwise
+// result in a route that silently never matches (or matches unexpectedly). It
+// reports empty segments in the middle of the pattern, parameters without a
+// name, duplicate parameter names, more than one wildcard, unknown named
+// constraints and invalid (or overly complex) regular expressions. If there
+// are any problems, the returned error describes all of them.
+func ValidatePattern(pattern string) error {
+	var errs []error
+
+	if pattern != "" && !strings.HasPrefix(pattern, "/") {
+		errs = append(errs, fmt.Errorf("flow: pattern %q must begin with a slash", pattern))
+	}
+
+	segments := strings.Split(pattern, "/")
+	seen := map[string]bool{}
+
+	for i, segment := range segments {
+		switch {
+		case segment == "":
+			if i > 0 && i < len(segments)-1 {
+				errs = append(errs, fmt.Errorf("flow: pattern %q has an empty segment at position %d", pattern, i))
+			}
+		case isWildcard(segment):
+			if countWildcards(segments[:i]) == 1 {
+				errs = append(errs, fmt.Errorf("flow: pattern %q contains more than one wildcard", pattern))
+			}
+			if segment != "..." {
+				key := strings.TrimSuffix(strings.TrimPrefix(segment, ":"), "...")
+				if key == "" {
+					errs = append(errs, fmt.Errorf("flow: pattern %q has a catch-all parameter without a name at position %d", pattern, i))
+				} else if seen[key] {
+					errs = append(errs, fmt.Errorf("flow: pattern %q uses the parameter name %q more than once", pattern, key))
+				}
+				seen[key] = true
+			}
+		case strings.HasPrefix(segment, ":"):
+			key, rxPattern, containsRx := strings.Cut(strings.TrimPrefix(segment, ":"), "|")
+			if key == "" {
+				errs = append(errs, fmt.Errorf("flow: pattern %q has a parameter without a name at position %d", pattern, i))
+			} else if seen[key] {
+				errs = append(errs, fmt.Errorf("flow: pattern %q uses the parameter name %q more than once", pattern, key))
+			}
+			seen[key] = true
+
+			if _, named := lookupConstraint(rxPattern); containsRx && !named {
+				if rxPattern != "" && isConstraintName(rxPattern) {
+					errs = append(errs, fmt.Errorf("flow: pattern %q uses the unknown constraint %q for parameter %q", pattern, rxPattern, key))
+				} else if _, err := compileRX(rxPattern); err != nil {
+					errs = append(errs, fmt.Errorf("flow: pattern %q has an invalid regular expression for parameter %q: %w", pattern, key, err))
+				}
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// MustHandle is like Handle, but it first checks the pattern with
+// ValidatePattern and panics with a description of all the problems if it isn't
+// valid. It's intended for registering routes from configuration files or other
+// sources where a mistake in the pattern should be caught at startup.
+func (m *Mux) MustHandle(pattern string, handler http.Handler, methods ...string) *Route {
+	if err := ValidatePattern(pattern); err != nil {
+		panic(err)
+	}
+
+	return m.Handle(pattern, handler, methods...)
+}
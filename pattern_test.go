@@ -0,0 +1,71 @@
+package flow
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidatePattern(t *testing.T) {
+	var tests = []struct {
+		Pattern string
+
+		ExpectedErrors []string
+	}{
+		{"", nil},
+		{"/", nil},
+		{"/one/two/", nil},
+		{"/path-params/:id/:era|^[0-9]{2}$/...", nil},
+		{"no/leading/slash", []string{"must begin with a slash"}},
+		{"/baz//:age", []string{"empty segment at position 2"}},
+		{"/users/:id/posts/:id", []string{`parameter name "id" more than once`}},
+		{"/files/.../meta", nil},
+		{"/files/.../meta/...", []string{"more than one wildcard"}},
+		{"/files/:", []string{"parameter without a name at position 2"}},
+		{"/files/:path.../meta", nil},
+		{"/files/:path.../:name...", []string{"more than one wildcard"}},
+		{"/files/:...", []string{"catch-all parameter without a name at position 2"}},
+		{"/files/:path/:path...", []string{`parameter name "path" more than once`}},
+		{"/files/:id|^[0-9+$", []string{`invalid regular expression for parameter "id"`}},
+		{"/files/:id|^[a-z]{0,999}$", []string{"too complex"}},
+		{
+			"/a//:id/:id/.../.../b",
+			[]string{"empty segment at position 2", `parameter name "id" more than once`, "more than one wildcard"},
+		},
+	}
+
+	for _, test := range tests {
+		err := ValidatePattern(test.Pattern)
+
+		if len(test.ExpectedErrors) == 0 {
+			if err != nil {
+				t.Errorf("%s: expected no error but got %q", test.Pattern, err)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%s: expected an error but got nil", test.Pattern)
+			continue
+		}
+
+		for _, expected := range test.ExpectedErrors {
+			if !strings.Contains(err.Error(), expected) {
+				t.Errorf("%s: expected error to contain %q but was %q", test.Pattern, expected, err)
+			}
+		}
+	}
+}
+
+func TestMustHandle(t *testing.T) {
+	hf := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic for invalid pattern")
+		}
+	}()
+
+	m := New()
+	m.MustHandle("/baz//:age", hf, "GET")
+}
@@ -0,0 +1,30 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Problem is an RFC 9457 (formerly RFC 7807) problem details object, used for
+// machine-readable error responses.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// WriteProblem sends p as an application/problem+json response, using
+// p.Status as the status code. If p.Title is empty, the standard text for the
+// status code is used.
+func WriteProblem(w http.ResponseWriter, p Problem) {
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,341 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Proxy is a reverse proxy handler which forwards requests to an upstream
+// server, for using flow as a lightweight API gateway. It is built on
+// httputil.ReverseProxy, so hop-by-hop headers (such as Connection and
+// Keep-Alive) are always removed from requests and responses, and the
+// X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto headers are set on
+// requests to the upstream.
+//
+// A Proxy can balance requests between several upstreams. Passive health
+// checking is enabled by setting MaxFails: an upstream which fails that many
+// requests in a row (with a connection error, or a 502 Bad Gateway, 503
+// Service Unavailable or 504 Gateway Timeout response) is ejected, and receives
+// no requests until EjectDuration has passed.
+//
+// A Proxy must not be changed after it has handled its first request.
+type Proxy struct {
+	// Target is the URL of the upstream server. The path of the request is
+	// appended to the target's path. If Upstreams is also set, Target is
+	// used as an additional upstream.
+	Target *url.URL
+
+	// Upstreams are the servers to balance requests between, using the
+	// given Strategy.
+	Upstreams []*Upstream
+	Strategy  BalanceStrategy
+
+	// MaxFails is the number of consecutive failures after which an
+	// upstream is ejected. Zero disables passive health checking.
+	MaxFails int
+	// EjectDuration is how long an upstream is ejected for. If it is zero,
+	// DefaultEjectDuration is used.
+	EjectDuration time.Duration
+
+	// Retry, if set, retries requests which fail.
+	Retry *RetryPolicy
+
+	// Cache, if set, stores responses to GET requests (see
+	// ResponseCache).
+	Cache *ResponseCache
+
+	// Affinity, if set, routes each client consistently to the same
+	// upstream.
+	Affinity *Affinity
+
+	// StripPrefix is removed from the start of the request path before it is
+	// forwarded. For example, with the route "/api/..." and StripPrefix
+	// "/api", a request for /api/users is forwarded to Target + "/users".
+	StripPrefix string
+
+	// Response describes changes to make to upstream responses.
+	Response ResponseTransform
+
+	// Transport is used to make requests to the upstream. If it is nil,
+	// http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	once      sync.Once
+	proxy     *httputil.ReverseProxy
+	upstreams []*Upstream
+	next      atomic.Uint64
+	mu        sync.Mutex
+	budget    retryBudget
+}
+
+// ResponseTransform describes changes to make to responses from a Proxy's
+// upstream. Like RequestTransform, it's plain data which can be loaded from
+// configuration.
+type ResponseTransform struct {
+	// DeleteHeaders lists headers to remove, such as Server or
+	// X-Powered-By.
+	DeleteHeaders []string `json:"delete_headers,omitempty"`
+	// SetHeaders sets headers, replacing any existing values.
+	SetHeaders map[string]string `json:"set_headers,omitempty"`
+
+	// RewriteLocation rewrites Location headers which point at the upstream
+	// so that they point at the proxy instead (using the host and scheme of
+	// the client's request, and adding back StripPrefix).
+	RewriteLocation bool `json:"rewrite_location,omitempty"`
+
+	// CookieDomains maps the Domain attribute of Set-Cookie headers from the
+	// upstream to the domain to use instead. For example,
+	// {"api.internal": "example.com"}.
+	CookieDomains map[string]string `json:"cookie_domains,omitempty"`
+
+	// CORSOrigins lists origins which are allowed to read responses. If the
+	// request's Origin is in the list (or the list contains "*"), the
+	// Access-Control-Allow-Origin header is set to it. Any CORS headers from
+	// the upstream are replaced.
+	CORSOrigins []string `json:"cors_origins,omitempty"`
+}
+
+// Proxy registers a Proxy for the given pattern and methods. It's equivalent
+// to m.Handle(pattern, p, methods...), except that it panics if p has no
+// Target or Upstreams, or if an upstream has no URL.
+func (m *Mux) Proxy(pattern string, p *Proxy, methods ...string) *Route {
+	if p == nil || (p.Target == nil && len(p.Upstreams) == 0) {
+		panic("flow: proxy must have a target")
+	}
+	for _, u := range p.Upstreams {
+		if u == nil || u.URL == nil {
+			panic("flow: proxy upstream must have a URL")
+		}
+	}
+
+	return m.Handle(pattern, p, methods...)
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.once.Do(p.init)
+
+	var lookup cacheLookup
+	if p.Cache != nil {
+		var served bool
+		if lookup, served = p.Cache.serve(w, r); served {
+			return
+		}
+	}
+
+	retries, body := p.retries(r)
+
+	for attempt := 0; ; attempt++ {
+		// Each attempt gets its own copy of the buffered body, on a clone of
+		// the request so that the caller's request isn't modified.
+		req := r
+		if body != nil {
+			req = r.Clone(r.Context())
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		reserved := attempt < retries && p.reserveRetry()
+
+		a := &proxyAttempt{
+			upstream: p.pick(req),
+			final:    !reserved,
+			cache:    &lookup,
+		}
+		p.serveAttempt(w, req, a)
+
+		if !a.retry {
+			if reserved {
+				p.budget.refund()
+			}
+			return
+		}
+		if !p.Retry.wait(r.Context(), attempt+1) {
+			return
+		}
+	}
+}
+
+func (p *Proxy) serveAttempt(w http.ResponseWriter, r *http.Request, a *proxyAttempt) {
+	u := a.upstream
+	u.active.Add(1)
+	start := time.Now()
+
+	defer func() {
+		u.active.Add(-1)
+		u.requests.Add(1)
+		u.latency.Add(int64(time.Since(start)))
+	}()
+
+	ctx := context.WithValue(r.Context(), attemptContextKey{}, a)
+
+	if p.Retry != nil && p.Retry.TryTimeout > 0 {
+		var cancel context.CancelCauseFunc
+		ctx, cancel = context.WithCancelCause(ctx)
+		defer cancel(nil)
+
+		a.timer = time.AfterFunc(p.Retry.TryTimeout, func() { cancel(errTryTimeout) })
+		defer a.stopTimer()
+	}
+
+	p.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (p *Proxy) init() {
+	if p.Target != nil {
+		p.upstreams = append(p.upstreams, &Upstream{URL: p.Target})
+	}
+	p.upstreams = append(p.upstreams, p.Upstreams...)
+	for _, u := range p.upstreams {
+		u.id = upstreamID(u.URL)
+	}
+
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if p.StripPrefix != "" {
+				pr.Out.URL.Path = strings.TrimPrefix(pr.Out.URL.Path, p.StripPrefix)
+				pr.Out.URL.RawPath = strings.TrimPrefix(pr.Out.URL.RawPath, p.StripPrefix)
+			}
+			pr.SetURL(attemptFromContext(pr.In.Context()).upstream.URL)
+			pr.SetXForwarded()
+			attemptFromContext(pr.In.Context()).cache.prepare(pr.Out)
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			a := attemptFromContext(resp.Request.Context())
+			a.stopTimer()
+
+			switch resp.StatusCode {
+			case http.StatusBadGateway, http.StatusServiceUnavailable:
+				p.report(a.upstream, false)
+				if !a.final {
+					a.retry = true
+					return errRetry
+				}
+			case http.StatusGatewayTimeout:
+				p.report(a.upstream, false)
+			default:
+				p.report(a.upstream, true)
+			}
+
+			if p.Affinity != nil {
+				p.Affinity.setCookie(resp, a.upstream)
+			}
+
+			p.Response.apply(resp, p)
+			a.cache.handle(resp)
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			if err == errRetry {
+				return
+			}
+
+			status := http.StatusBadGateway
+			if context.Cause(r.Context()) == errTryTimeout {
+				err, status = errTryTimeout, http.StatusGatewayTimeout
+			} else if errors.Is(err, context.Canceled) {
+				// A request cancelled by the client says nothing about
+				// the health of the upstream.
+				w.WriteHeader(status)
+				return
+			}
+
+			a := attemptFromContext(r.Context())
+			p.report(a.upstream, false)
+			if !a.final {
+				a.retry = true
+				return
+			}
+
+			log.Printf("flow: proxy error: %s %s: %s", r.Method, r.URL, err)
+			w.WriteHeader(status)
+		},
+		Transport: p.Transport,
+	}
+}
+
+func (t ResponseTransform) apply(resp *http.Response, p *Proxy) {
+	for _, name := range t.DeleteHeaders {
+		resp.Header.Del(name)
+	}
+	for name, value := range t.SetHeaders {
+		resp.Header.Set(name, value)
+	}
+
+	if t.RewriteLocation {
+		if location := resp.Header.Get("Location"); location != "" {
+			resp.Header.Set("Location", rewriteLocation(location, resp.Request, p))
+		}
+	}
+
+	if len(t.CookieDomains) > 0 {
+		cookies := resp.Header.Values("Set-Cookie")
+		for i, cookie := range cookies {
+			cookies[i] = rewriteCookieDomain(cookie, t.CookieDomains)
+		}
+	}
+
+	if len(t.CORSOrigins) > 0 {
+		for name := range resp.Header {
+			if strings.HasPrefix(name, "Access-Control-") {
+				resp.Header.Del(name)
+			}
+		}
+
+		origin := resp.Request.Header.Get("Origin")
+		if origin != "" && (slices.Contains(t.CORSOrigins, "*") || slices.Contains(t.CORSOrigins, origin)) {
+			resp.Header.Set("Access-Control-Allow-Origin", origin)
+		}
+		resp.Header.Add("Vary", "Origin")
+	}
+}
+
+// rewriteLocation rewrites a Location header pointing at the upstream so that
+// it points at the proxy. The outgoing request carries the client's host and
+// scheme in the X-Forwarded headers set by the proxy.
+func rewriteLocation(location string, out *http.Request, p *Proxy) string {
+	target := attemptFromContext(out.Context()).upstream.URL
+
+	u, err := url.Parse(location)
+	if err != nil || (u.Host != "" && !strings.EqualFold(u.Host, target.Host)) {
+		return location
+	}
+
+	if u.Host != "" {
+		u.Scheme = out.Header.Get("X-Forwarded-Proto")
+		u.Host = out.Header.Get("X-Forwarded-Host")
+	}
+
+	if strings.HasPrefix(u.Path, "/") {
+		u.Path = p.StripPrefix + "/" + strings.TrimPrefix(strings.TrimPrefix(u.Path, strings.TrimSuffix(target.Path, "/")), "/")
+		u.RawPath = ""
+	}
+
+	return u.String()
+}
+
+func rewriteCookieDomain(cookie string, domains map[string]string) string {
+	parts := strings.Split(cookie, ";")
+
+	for i, part := range parts {
+		name, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok || !strings.EqualFold(name, "domain") {
+			continue
+		}
+
+		if replacement, ok := domains[strings.TrimPrefix(strings.ToLower(value), ".")]; ok {
+			parts[i] = " Domain=" + replacement
+		}
+	}
+
+	return strings.Join(parts, ";")
+}
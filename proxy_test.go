@@ -0,0 +1,151 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set("X-Forwarded", r.Header.Get("X-Forwarded-Host"))
+		w.Header().Set("Server", "upstream/1.0")
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Add("Set-Cookie", "session=abc; Path=/; Domain=.api.internal; HttpOnly")
+		w.Header().Add("Set-Cookie", "other=xyz; Domain=elsewhere.com")
+		if r.URL.Path == "/v1/login" {
+			w.Header().Set("Location", "http://"+r.Host+"/v1/users/1")
+			w.WriteHeader(http.StatusSeeOther)
+			return
+		}
+		w.Write([]byte("upstream"))
+	}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL + "/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var transform ResponseTransform
+	err = json.NewDecoder(strings.NewReader(`{
+		"delete_headers": ["Server"],
+		"set_headers": {"X-Gateway": "flow"},
+		"rewrite_location": true,
+		"cookie_domains": {"api.internal": "example.com"},
+		"cors_origins": ["https://app.example.com"]
+	}`)).Decode(&transform)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New()
+	m.Proxy("/api/...", &Proxy{Target: target, StripPrefix: "/api", Response: transform})
+
+	t.Run("forwarding", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "http://gateway.example.com/api/users", nil)
+		r.Header.Set("Origin", "https://app.example.com")
+		m.ServeHTTP(rr, r)
+
+		rs := rr.Result()
+
+		if rs.StatusCode != http.StatusOK || rr.Body.String() != "upstream" {
+			t.Fatalf("unexpected response %d %q", rs.StatusCode, rr.Body.String())
+		}
+		if path := rs.Header.Get("X-Path"); path != "/v1/users" {
+			t.Errorf("expected upstream path %q but got %q", "/v1/users", path)
+		}
+		if host := rs.Header.Get("X-Forwarded"); host != "gateway.example.com" {
+			t.Errorf("expected X-Forwarded-Host %q but got %q", "gateway.example.com", host)
+		}
+		if server := rs.Header.Get("Server"); server != "" {
+			t.Errorf("expected Server header to be removed but got %q", server)
+		}
+		if gateway := rs.Header.Get("X-Gateway"); gateway != "flow" {
+			t.Errorf("expected X-Gateway header %q but got %q", "flow", gateway)
+		}
+		if origin := rs.Header.Get("Access-Control-Allow-Origin"); origin != "https://app.example.com" {
+			t.Errorf("expected Access-Control-Allow-Origin %q but got %q", "https://app.example.com", origin)
+		}
+		if vary := rs.Header.Get("Vary"); vary != "Origin" {
+			t.Errorf("expected Vary %q but got %q", "Origin", vary)
+		}
+
+		cookies := rs.Header.Values("Set-Cookie")
+		expected := []string{"session=abc; Path=/; Domain=example.com; HttpOnly", "other=xyz; Domain=elsewhere.com"}
+		if len(cookies) != len(expected) {
+			t.Fatalf("expected cookies %q but got %q", expected, cookies)
+		}
+		for i := range expected {
+			if cookies[i] != expected[i] {
+				t.Errorf("expected cookie %q but got %q", expected[i], cookies[i])
+			}
+		}
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/api/users", nil)
+		r.Header.Set("Origin", "https://evil.example.com")
+		m.ServeHTTP(rr, r)
+
+		if origin := rr.Header().Get("Access-Control-Allow-Origin"); origin != "" {
+			t.Errorf("expected no Access-Control-Allow-Origin but got %q", origin)
+		}
+	})
+
+	t.Run("location", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r := httptest.NewRequest("POST", "http://gateway.example.com/api/login", nil)
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Fatalf("expected status %d but got %d", http.StatusSeeOther, rr.Code)
+		}
+		if location := rr.Header().Get("Location"); location != "http://gateway.example.com/api/users/1" {
+			t.Errorf("expected Location %q but got %q", "http://gateway.example.com/api/users/1", location)
+		}
+	})
+}
+
+func TestProxyWithoutTarget(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+
+	New().Proxy("/api/...", &Proxy{})
+}
+
+func TestRewriteLocation(t *testing.T) {
+	target, _ := url.Parse("http://api.internal:8080/v1")
+	p := &Proxy{Target: target, StripPrefix: "/api"}
+
+	out := httptest.NewRequest("GET", "http://api.internal:8080/v1/users", nil)
+	out = out.WithContext(context.WithValue(out.Context(), attemptContextKey{}, &proxyAttempt{upstream: &Upstream{URL: target}}))
+	out.Header.Set("X-Forwarded-Host", "example.com")
+	out.Header.Set("X-Forwarded-Proto", "https")
+
+	tests := []struct {
+		location string
+		expected string
+	}{
+		{"http://api.internal:8080/v1/users/1", "https://example.com/api/users/1"},
+		{"/v1/users/1?tab=info", "/api/users/1?tab=info"},
+		{"https://other.example.com/login", "https://other.example.com/login"},
+		{"users/1", "users/1"},
+	}
+
+	for _, test := range tests {
+		if got := rewriteLocation(test.location, out, p); got != test.expected {
+			t.Errorf("%q: expected %q but got %q", test.location, test.expected, got)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package pubsub
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alexedwards/flow/websocket"
+)
+
+// ServeSSE returns a handler which subscribes to the topics returned by the
+// topics function and streams the messages to the client as server-sent
+// events, using the topic as the event type. The stream ends when the client
+// disconnects or the subscription is evicted.
+func ServeSSE(h *Hub, topics func(r *http.Request) []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+
+		sub := h.Subscribe(topics(r)...)
+		defer sub.Unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case msg, ok := <-sub.C():
+				if !ok {
+					return
+				}
+
+				fmt.Fprintf(w, "event: %s\n", msg.Topic)
+				for _, line := range strings.Split(string(msg.Data), "\n") {
+					fmt.Fprintf(w, "data: %s\n", line)
+				}
+				fmt.Fprint(w, "\n")
+
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
+
+// ServeWebSocket returns a handler which upgrades the request using u,
+// subscribes to the topics returned by the topics function and sends each
+// message to the client as a text message. Messages from the client are
+// read (so that pings and close frames are handled) and discarded. The
+// connection is closed when the client disconnects or the subscription is
+// evicted. A client which stops reading is disconnected once a write takes
+// longer than the Upgrader's WriteTimeout.
+func ServeWebSocket(h *Hub, u *websocket.Upgrader, topics func(r *http.Request) []string) http.Handler {
+	return u.Handler(func(c *websocket.Conn, r *http.Request) {
+		sub := h.Subscribe(topics(r)...)
+		defer sub.Unsubscribe()
+
+		closed := make(chan struct{})
+		go func() {
+			defer close(closed)
+			for {
+				if _, _, err := c.ReadMessage(); err != nil {
+					return
+				}
+			}
+		}()
+
+		for {
+			select {
+			case <-closed:
+				return
+			case msg, ok := <-sub.C():
+				if !ok {
+					return
+				}
+				if err := c.WriteMessage(websocket.TextMessage, msg.Data); err != nil {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package pubsub
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/flow"
+	"github.com/alexedwards/flow/websocket"
+)
+
+func waitForSubscribers(t *testing.T, h *Hub, topic string) {
+	t.Helper()
+
+	for i := 0; i < 100; i++ {
+		if h.Subscribers(topic) > 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("no subscribers to %s", topic)
+}
+
+func TestServeSSE(t *testing.T) {
+	h := NewHub(4)
+
+	m := flow.New()
+	m.Handle("/users/:id/events", ServeSSE(h, func(r *http.Request) []string {
+		return []string{"user:" + flow.Param(r.Context(), "id")}
+	}), "GET")
+
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/users/42/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+
+	waitForSubscribers(t, h, "user:42")
+	h.Publish("user:42", []byte("line one\nline two"))
+
+	br := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 4 {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, line)
+	}
+
+	expected := "event: user:42\ndata: line one\ndata: line two\n\n"
+	if got := strings.Join(lines, ""); got != expected {
+		t.Errorf("expected event %q but got %q", expected, got)
+	}
+}
+
+func TestServeWebSocket(t *testing.T) {
+	h := NewHub(4)
+
+	m := flow.New()
+	m.Handle("/ws", ServeWebSocket(h, &websocket.Upgrader{}, func(r *http.Request) []string {
+		return []string{"news"}
+	}), "GET")
+
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Write(conn)
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101 but was %d", resp.StatusCode)
+	}
+
+	waitForSubscribers(t, h, "news")
+	h.Publish("news", []byte("hello"))
+
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	frame := make([]byte, 7)
+	if _, err := io.ReadFull(br, frame); err != nil {
+		t.Fatal(err)
+	}
+
+	if frame[0] != 0x81 || frame[1] != 5 || string(frame[2:]) != "hello" {
+		t.Errorf("unexpected frame %v", frame)
+	}
+}
+
+func TestServeWebSocketSlowClient(t *testing.T) {
+	h := NewHub(1)
+	returned := make(chan struct{})
+
+	ws := ServeWebSocket(h, &websocket.Upgrader{WriteTimeout: 50 * time.Millisecond}, func(r *http.Request) []string {
+		return []string{"news"}
+	})
+
+	m := flow.New()
+	m.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
+		defer close(returned)
+		ws.ServeHTTP(w, r)
+	}, "GET")
+
+	ts := httptest.NewServer(m)
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req, _ := http.NewRequest("GET", ts.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Write(conn)
+
+	waitForSubscribers(t, h, "news")
+
+	// The client never reads, so the socket buffers fill up, the handler
+	// blocks writing and the subscription is evicted. Messages are published
+	// slowly enough that the handler keeps up until it blocks.
+	msg := make([]byte, 64<<10)
+	deadline := time.After(5 * time.Second)
+	for h.Subscribers("news") > 0 {
+		h.Publish("news", msg)
+		select {
+		case <-deadline:
+			t.Fatal("subscription wasn't evicted")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	select {
+	case <-returned:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler didn't return after the subscription was evicted")
+	}
+}
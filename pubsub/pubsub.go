@@ -0,0 +1,159 @@
+// Package pubsub is a small in-process publish/subscribe hub, for fanning out
+// messages to clients connected over server-sent events or WebSockets.
+//
+// Each subscriber has its own buffer. Publishing never blocks: if a
+// subscriber's buffer is full, the subscriber is evicted (its channel is
+// closed and Err returns ErrSlowConsumer), so that one slow client can't hold
+// up delivery to the others. Clients which are evicted can reconnect.
+//
+// ServeSSE and ServeWebSocket connect a hub to HTTP clients:
+//
+//	hub := pubsub.NewHub(16)
+//	topics := func(r *http.Request) []string { return []string{"user:" + flow.Param(r.Context(), "id")} }
+//
+//	mux.Handle("/users/:id/events", pubsub.ServeSSE(hub, topics), "GET")
+//	mux.Handle("/users/:id/ws", pubsub.ServeWebSocket(hub, &websocket.Upgrader{}, topics), "GET")
+//
+//	hub.Publish("user:42", []byte(`{"type":"ping"}`))
+package pubsub
+
+import (
+	"errors"
+	"sync"
+)
+
+// ErrSlowConsumer is returned by Subscription.Err when the subscription was
+// evicted because its buffer was full.
+var ErrSlowConsumer = errors.New("pubsub: subscriber evicted because it was too slow")
+
+// Message is a message published to a topic.
+type Message struct {
+	Topic string
+	Data  []byte
+}
+
+// Hub routes published messages to the subscribers of each topic. It is safe
+// for concurrent use.
+type Hub struct {
+	bufferSize int
+
+	mu     sync.RWMutex
+	topics map[string]map[*Subscription]struct{}
+}
+
+// NewHub returns a new Hub which gives each subscriber a buffer of the given
+// number of messages. A buffer size less than 1 is treated as 1.
+func NewHub(bufferSize int) *Hub {
+	return &Hub{
+		bufferSize: max(bufferSize, 1),
+		topics:     map[string]map[*Subscription]struct{}{},
+	}
+}
+
+// Subscription receives the messages published to one or more topics.
+type Subscription struct {
+	hub    *Hub
+	topics []string
+	ch     chan Message
+	closed bool
+	err    error
+}
+
+// Subscribe returns a new Subscription to the given topics.
+func (h *Hub) Subscribe(topics ...string) *Subscription {
+	s := &Subscription{hub: h, topics: topics, ch: make(chan Message, h.bufferSize)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, topic := range topics {
+		if h.topics[topic] == nil {
+			h.topics[topic] = map[*Subscription]struct{}{}
+		}
+		h.topics[topic][s] = struct{}{}
+	}
+
+	return s
+}
+
+// Publish sends a message to every subscriber of the topic, and returns the
+// number of subscribers it was delivered to. Subscribers whose buffers are
+// full are evicted.
+func (h *Hub) Publish(topic string, data []byte) int {
+	msg := Message{Topic: topic, Data: data}
+	delivered := 0
+
+	var slow []*Subscription
+
+	h.mu.RLock()
+	for s := range h.topics[topic] {
+		select {
+		case s.ch <- msg:
+			delivered++
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	if len(slow) > 0 {
+		h.mu.Lock()
+		for _, s := range slow {
+			h.remove(s, ErrSlowConsumer)
+		}
+		h.mu.Unlock()
+	}
+
+	return delivered
+}
+
+// Subscribers returns the number of subscribers to the topic.
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return len(h.topics[topic])
+}
+
+// remove unsubscribes s from all of its topics and closes its channel. It must
+// be called with h.mu held for writing; because Publish only sends while
+// holding the read lock, the channel is never closed during a send.
+func (h *Hub) remove(s *Subscription, err error) {
+	if s.closed {
+		return
+	}
+
+	for _, topic := range s.topics {
+		delete(h.topics[topic], s)
+		if len(h.topics[topic]) == 0 {
+			delete(h.topics, topic)
+		}
+	}
+
+	s.closed = true
+	s.err = err
+	close(s.ch)
+}
+
+// C returns the channel on which messages are delivered. It is closed when
+// the subscription ends, either through Unsubscribe or eviction.
+func (s *Subscription) C() <-chan Message {
+	return s.ch
+}
+
+// Unsubscribe ends the subscription. It is safe to call more than once.
+func (s *Subscription) Unsubscribe() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.remove(s, nil)
+}
+
+// Err returns ErrSlowConsumer if the subscription was evicted, and nil
+// otherwise.
+func (s *Subscription) Err() error {
+	s.hub.mu.RLock()
+	defer s.hub.mu.RUnlock()
+
+	return s.err
+}
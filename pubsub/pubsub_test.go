@@ -0,0 +1,84 @@
+package pubsub
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestHub(t *testing.T) {
+	h := NewHub(2)
+
+	a := h.Subscribe("news", "sport")
+	b := h.Subscribe("news")
+
+	if n := h.Publish("news", []byte("one")); n != 2 {
+		t.Errorf("expected delivery to 2 subscribers but was %d", n)
+	}
+	if n := h.Publish("sport", []byte("two")); n != 1 {
+		t.Errorf("expected delivery to 1 subscriber but was %d", n)
+	}
+	if n := h.Publish("weather", []byte("three")); n != 0 {
+		t.Errorf("expected delivery to 0 subscribers but was %d", n)
+	}
+
+	for _, expected := range []Message{{"news", []byte("one")}, {"sport", []byte("two")}} {
+		msg := <-a.C()
+		if msg.Topic != expected.Topic || string(msg.Data) != string(expected.Data) {
+			t.Errorf("expected %v but got %v", expected, msg)
+		}
+	}
+
+	// b has one message buffered; two more fill its buffer and overflow it.
+	h.Publish("news", []byte("four"))
+	h.Publish("news", []byte("five"))
+
+	if err := b.Err(); err != ErrSlowConsumer {
+		t.Errorf("expected slow subscriber to be evicted but Err was %v", err)
+	}
+
+	count := 0
+	for range b.C() {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected the buffered messages to be readable after eviction, got %d", count)
+	}
+
+	if err := a.Err(); err != nil {
+		t.Errorf("expected a to still be subscribed but Err was %v", err)
+	}
+
+	a.Unsubscribe()
+	a.Unsubscribe()
+
+	if n := h.Subscribers("news"); n != 0 {
+		t.Errorf("expected no subscribers left but there were %d", n)
+	}
+}
+
+func TestHubConcurrency(t *testing.T) {
+	h := NewHub(1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				h.Publish("t", []byte("x"))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			s := h.Subscribe("t")
+			for j := 0; j < 10; j++ {
+				select {
+				case <-s.C():
+				default:
+				}
+			}
+			s.Unsubscribe()
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,114 @@
+package flow
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Redirect registers a route which redirects requests for pattern to target,
+// with the given status code, which must be one of 301, 302, 303, 307 or 308.
+// The target can use the parameters and wildcard of the pattern, which are
+// filled in with their values from the request path:
+//
+//	mux.Redirect("/old/:id", "/new/:id", http.StatusMovedPermanently)
+//	mux.Redirect("/docs/...", "https://docs.example.com/...", http.StatusFound)
+//
+// Values are copied in their escaped form, so they're not escaped again. If
+// the target doesn't have a query string, the request's query string is added
+// to it. Leading slashes in a relative target are collapsed to one, so it
+// can't become a URL for another host. The target isn't affected by the prefix
+// of an enclosing Route group. The route uses the given methods, or the
+// default methods (see Handle) if there are none. Redirect panics if the code
+// isn't a redirect status, or if the target uses a parameter which isn't in
+// the pattern.
+func (m *Mux) Redirect(pattern, target string, code int, methods ...string) *Route {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		panic(fmt.Sprintf("flow: redirect status %d isn't a redirect", code))
+	}
+
+	// base is the scheme and host of an absolute target, which are copied
+	// unchanged.
+	var base string
+	if scheme, rest, ok := strings.Cut(target, "://"); ok {
+		host, path, _ := strings.Cut(rest, "/")
+		base, target = scheme+"://"+host, "/"+path
+	}
+
+	target, query, hasQuery := strings.Cut(target, "?")
+
+	var parts []redirectPart
+	for i, s := range strings.Split(target, "/") {
+		switch {
+		case s == "...":
+			parts = append(parts, redirectPart{param: "..."})
+		case isWildcard(s):
+			parts = append(parts, redirectPart{param: strings.TrimSuffix(strings.TrimPrefix(s, ":"), "...")})
+		case strings.HasPrefix(s, ":"):
+			parts = append(parts, redirectPart{param: strings.TrimPrefix(s, ":")})
+		default:
+			parts = append(parts, redirectPart{literal: s})
+		}
+
+		if i > 0 {
+			parts[len(parts)-1].literal = "/" + parts[len(parts)-1].literal
+		}
+	}
+
+	segments := strings.Split(m.fullPattern(pattern), "/")
+	if parsed, err := parseSegments(segments, nil); err == nil {
+		source := &Route{segments: parsed}
+		for _, part := range parts {
+			if part.param != "" && !source.hasParam(part.param) && !(part.param == "..." && countWildcards(segments) > 0) {
+				panic(fmt.Sprintf("flow: redirect target uses parameter %q which isn't in the pattern %q", part.param, pattern))
+			}
+		}
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var sb strings.Builder
+		sb.WriteString(base)
+
+		for _, part := range parts {
+			sb.WriteString(part.literal)
+			if part.param != "" {
+				sb.WriteString(Param(r.Context(), part.param))
+			}
+		}
+
+		if hasQuery {
+			sb.WriteString("?" + query)
+		} else if r.URL.RawQuery != "" {
+			sb.WriteString("?" + r.URL.RawQuery)
+		}
+
+		location := sb.String()
+		if base == "" {
+			location = sameHostPath(location)
+		}
+
+		http.Redirect(w, r, location, code)
+	})
+
+	return m.Handle(pattern, handler, methods...)
+}
+
+// sameHostPath collapses a run of leading slashes and backslashes in a
+// relative redirect target to a single slash, so that a value from the request
+// path like "//evil.com" can't turn it into a protocol-relative URL which
+// points at another host.
+func sameHostPath(location string) string {
+	if len(location) < 2 || location[0] != '/' || (location[1] != '/' && location[1] != '\\') {
+		return location
+	}
+	return "/" + strings.TrimLeft(location, "/\\")
+}
+
+// redirectPart is a piece of a redirect target: literal text, followed by the
+// value of a parameter if param is set.
+type redirectPart struct {
+	literal string
+	param   string
+}
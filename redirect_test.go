@@ -0,0 +1,79 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	m := New()
+	m.Redirect("/old/:id", "/new/:id", http.StatusMovedPermanently)
+	m.Redirect("/old/...", "/...", http.StatusMovedPermanently)
+	m.Redirect("/docs/...", "https://docs.example.com/v2/...", http.StatusFound, "GET")
+	m.Redirect("/files/:path...", "/archive/:path?from=files", http.StatusPermanentRedirect)
+	m.Route("/api", func(m *Mux) {
+		m.Redirect("/users/:id", "/api/v2/users/:id", http.StatusSeeOther)
+	})
+
+	var tests = []struct {
+		Method      string
+		RequestPath string
+
+		ExpectedStatus   int
+		ExpectedLocation string
+	}{
+		{"GET", "/old/42", http.StatusMovedPermanently, "/new/42"},
+		{"POST", "/old/42?a=1&b=2", http.StatusMovedPermanently, "/new/42?a=1&b=2"},
+		{"GET", "/old/a%2Fb", http.StatusMovedPermanently, "/new/a%2Fb"},
+		{"GET", "/old//evil.com", http.StatusMovedPermanently, "/evil.com"},
+		{"GET", "/old//evil.com/a?x=1", http.StatusMovedPermanently, "/evil.com/a?x=1"},
+		{"GET", "/docs/guide/intro", http.StatusFound, "https://docs.example.com/v2/guide/intro"},
+		{"POST", "/docs/guide/intro", http.StatusMethodNotAllowed, ""},
+		{"PUT", "/files/a/b.txt?x=1", http.StatusPermanentRedirect, "/archive/a/b.txt?from=files"},
+		{"GET", "/files//evil.example.com", http.StatusPermanentRedirect, "/archive/evil.example.com?from=files"},
+		{"GET", "/api/users/7", http.StatusSeeOther, "/api/v2/users/7"},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.Method, test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s: expected status %d but was %d", test.Method, test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if location := rr.Header().Get("Location"); location != test.ExpectedLocation {
+			t.Errorf("%s %s: expected location %q but was %q", test.Method, test.RequestPath, test.ExpectedLocation, location)
+		}
+	}
+}
+
+func TestRedirectInvalid(t *testing.T) {
+	var tests = []struct {
+		Pattern string
+		Target  string
+		Code    int
+	}{
+		{"/old/:id", "/new/:id", http.StatusOK},
+		{"/old/:id", "/new/:slug", http.StatusFound},
+		{"/old/:id", "/new/...", http.StatusFound},
+	}
+
+	for _, test := range tests {
+		m := New()
+
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("expected panic for %s -> %s (%d)", test.Pattern, test.Target, test.Code)
+				}
+			}()
+
+			m.Redirect(test.Pattern, test.Target, test.Code)
+		}()
+
+		if n := len(m.routes.load()); n != 0 {
+			t.Errorf("%s -> %s: expected no routes to be registered but got %d", test.Pattern, test.Target, n)
+		}
+	}
+}
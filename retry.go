@@ -0,0 +1,193 @@
+package flow
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RetryPolicy describes when a Proxy (or a client returned by Client) retries a
+// request which fails with a connection error, a timeout, or a 502 Bad Gateway
+// or 503 Service Unavailable response. Each retry by a Proxy is sent to the
+// upstream chosen by the proxy's strategy at that moment, which may be a
+// different one.
+//
+// Retries are limited by a budget, so that a struggling upstream isn't
+// overwhelmed by retries when many requests are failing: each request adds
+// Budget to a balance (up to a maximum of 10), and each retry uses 1. When the
+// balance runs out, failed requests aren't retried.
+//
+// Request bodies must be buffered in memory to be retried, so requests with a
+// body larger than 1MB (or of unknown length) are never retried.
+type RetryPolicy struct {
+	// MaxRetries is the maximum number of retries for each request.
+	MaxRetries int `json:"max_retries"`
+
+	// Methods lists the methods of requests which may be retried. If it is
+	// empty, only idempotent methods (GET, HEAD, OPTIONS, TRACE, PUT and
+	// DELETE) are retried.
+	Methods []string `json:"methods,omitempty"`
+
+	// Budget is the ratio of retries to requests. If it is zero,
+	// DefaultRetryBudget is used.
+	Budget float64 `json:"budget,omitempty"`
+
+	// Backoff is the delay before the first retry, which doubles for each
+	// later retry. A random jitter of up to half the delay is subtracted, so
+	// that clients don't retry in lockstep.
+	Backoff time.Duration `json:"backoff,omitempty"`
+
+	// TryTimeout limits the time each attempt waits for the upstream to
+	// start responding. An attempt which times out counts as a failure. If
+	// the final attempt times out, a 504 Gateway Timeout response is sent.
+	TryTimeout time.Duration `json:"try_timeout,omitempty"`
+}
+
+// DefaultRetryBudget allows one retry for every five requests.
+const DefaultRetryBudget = 0.2
+
+const (
+	maxRetryBalance  = 10
+	maxRetryBodySize = 1 << 20
+)
+
+var (
+	errRetry      = errors.New("flow: retrying proxy request")
+	errTryTimeout = errors.New("flow: proxy attempt timed out")
+)
+
+var idempotentMethods = []string{http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete}
+
+// proxyAttempt is stored in the request context for each attempt at
+// forwarding a request, so that the ReverseProxy hooks know which upstream is
+// being used and whether a failure should be retried.
+type proxyAttempt struct {
+	upstream *Upstream
+	final    bool
+	retry    bool
+	timer    *time.Timer
+	cache    *cacheLookup
+}
+
+func (a *proxyAttempt) stopTimer() {
+	if a.timer != nil {
+		a.timer.Stop()
+	}
+}
+
+// retries returns the number of times r may be retried, buffering its body
+// if necessary.
+func (p *Proxy) retries(r *http.Request) (int, []byte) {
+	if p.Retry == nil || p.Retry.MaxRetries <= 0 {
+		return 0, nil
+	}
+
+	methods := p.Retry.Methods
+	if len(methods) == 0 {
+		methods = idempotentMethods
+	}
+	if !slices.Contains(methods, r.Method) {
+		return 0, nil
+	}
+
+	p.budget.deposit(p.Retry.Budget)
+
+	if r.ContentLength == 0 {
+		return p.Retry.MaxRetries, nil
+	}
+	if r.ContentLength < 0 || r.ContentLength > maxRetryBodySize {
+		return 0, nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, r.ContentLength))
+	if err != nil {
+		// Forward whatever was read, and let the upstream deal with the
+		// truncated body.
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		return 0, nil
+	}
+
+	return p.Retry.MaxRetries, body
+}
+
+// reserveRetry withdraws a retry from the budget before an attempt, so that
+// concurrent requests can't all rely on the same part of the balance. The
+// retry must be refunded if the attempt isn't retried.
+func (p *Proxy) reserveRetry() bool {
+	return p.budget.withdraw()
+}
+
+// wait sleeps for the backoff before the given retry (counting from 1),
+// returning false if ctx is done in the meantime.
+func (rp *RetryPolicy) wait(ctx context.Context, retry int) bool {
+	delay := rp.Backoff << (retry - 1)
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	delay -= time.Duration(rand.Int63n(int64(delay/2) + 1))
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// retryBudget holds the balance which limits retries to a proportion of
+// requests (see RetryPolicy). The balance starts full.
+type retryBudget struct {
+	mu      sync.Mutex
+	balance float64
+	started bool
+}
+
+// deposit adds the budget for a new request to the balance.
+func (b *retryBudget) deposit(budget float64) {
+	if budget <= 0 {
+		budget = DefaultRetryBudget
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.started {
+		b.balance, b.started = maxRetryBalance, true
+	}
+	b.balance = min(b.balance+budget, maxRetryBalance)
+}
+
+// withdraw takes one retry from the balance, returning false (and leaving the
+// balance unchanged) if there isn't enough left. The check and the withdrawal
+// are a single step, so that concurrent requests can't overspend the budget.
+func (b *retryBudget) withdraw() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.started {
+		b.balance, b.started = maxRetryBalance, true
+	}
+	if b.balance < 1 {
+		return false
+	}
+
+	b.balance--
+	return true
+}
+
+// refund returns a withdrawn retry which wasn't used to the balance.
+func (b *retryBudget) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.balance = min(b.balance+1, maxRetryBalance)
+}
@@ -0,0 +1,157 @@
+package flow
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newRetryTestUpstream(t *testing.T, h http.HandlerFunc) *Upstream {
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &Upstream{URL: u}
+}
+
+func TestProxyRetry(t *testing.T) {
+	var failed atomic.Int64
+
+	unavailable := newRetryTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		failed.Add(1)
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	healthy := newRetryTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte("ok " + string(body)))
+	})
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL, _ := url.Parse(closed.URL)
+	closed.Close()
+
+	var tests = []struct {
+		Name      string
+		Upstreams []*Upstream
+		Method    string
+		Body      string
+
+		ExpectedStatus int
+		ExpectedBody   string
+	}{
+		{"503 then success", []*Upstream{unavailable, healthy}, "GET", "", http.StatusOK, "ok "},
+		{"connection error then success", []*Upstream{{URL: closedURL}, healthy}, "GET", "", http.StatusOK, "ok "},
+		{"body is resent", []*Upstream{unavailable, healthy}, "PUT", "payload", http.StatusOK, "ok payload"},
+		{"non-idempotent method", []*Upstream{unavailable, healthy}, "POST", "payload", http.StatusServiceUnavailable, ""},
+		{"retries exhausted", []*Upstream{unavailable}, "GET", "", http.StatusServiceUnavailable, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			p := &Proxy{Upstreams: test.Upstreams, Retry: &RetryPolicy{MaxRetries: 2, Backoff: time.Millisecond}}
+
+			req := httptest.NewRequest(test.Method, "/", strings.NewReader(test.Body))
+			body := req.Body
+
+			rr := httptest.NewRecorder()
+			p.ServeHTTP(rr, req)
+
+			if rr.Code != test.ExpectedStatus {
+				t.Errorf("expected status %d but got %d", test.ExpectedStatus, rr.Code)
+			}
+			if req.Body != body {
+				t.Error("expected the request body not to be replaced")
+			}
+			if test.ExpectedBody != "" && rr.Body.String() != test.ExpectedBody {
+				t.Errorf("expected body %q but got %q", test.ExpectedBody, rr.Body.String())
+			}
+		})
+	}
+
+	if n := failed.Load(); n != 6 {
+		t.Errorf("expected 6 requests to the unavailable upstream but got %d", n)
+	}
+}
+
+func TestProxyRetryBudget(t *testing.T) {
+	var requests atomic.Int64
+
+	unavailable := newRetryTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	m := New()
+	m.Proxy("/...", &Proxy{Upstreams: []*Upstream{unavailable}, Retry: &RetryPolicy{MaxRetries: 1, Budget: 0.01}})
+
+	for i := 0; i < 20; i++ {
+		m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+
+	// The balance starts at 10, so only the first 10 requests are retried.
+	if n := requests.Load(); n != 30 {
+		t.Errorf("expected 30 upstream requests but got %d", n)
+	}
+}
+
+func TestRetryBudgetConcurrent(t *testing.T) {
+	var b retryBudget
+	var withdrawn atomic.Int64
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.withdraw() {
+				withdrawn.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The balance starts at 10, and no more than that can be withdrawn.
+	if n := withdrawn.Load(); n != maxRetryBalance {
+		t.Errorf("expected %d retries to be withdrawn but got %d", maxRetryBalance, n)
+	}
+}
+
+func TestProxyTryTimeout(t *testing.T) {
+	var requests atomic.Int64
+
+	slow := newRetryTestUpstream(t, func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	})
+
+	m := New()
+	m.Proxy("/...", &Proxy{Upstreams: []*Upstream{slow}, Retry: &RetryPolicy{MaxRetries: 1, TryTimeout: 20 * time.Millisecond}})
+
+	start := time.Now()
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status %d but got %d", http.StatusGatewayTimeout, rr.Code)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("expected 2 attempts but got %d", n)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("expected attempts to time out quickly but took %s", d)
+	}
+}
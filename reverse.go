@@ -0,0 +1,149 @@
+package flow
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Args holds the values used to fill in the parameters of a route pattern when
+// building a URL with Reverse, keyed by parameter name. The value for a
+// wildcard uses the key "...", or the name of a named catch-all like
+// :path....
+type Args map[string]string
+
+// HandleNamed registers a route in the same way as Handle, and gives it a name
+// which can be used to build URLs for the route with Reverse. Names must be
+// unique within a Mux (including its groups), and HandleNamed panics if the
+// name is empty or has already been used.
+//
+//	mux.HandleNamed("user.show", "/users/:id", showUser, "GET")
+//	...
+//	path, err := mux.Reverse("user.show", flow.Args{"id": "42"}) // "/users/42"
+//
+// Calling Named on the route returned by Handle (or Get, and so on) does the
+// same.
+func (m *Mux) HandleNamed(name, pattern string, handler http.Handler, methods ...string) *Route {
+	if name == "" {
+		panic("flow: route name must not be empty")
+	}
+
+	if m.namedRoute(name) != nil {
+		panic(fmt.Sprintf("flow: route name %q is already in use", name))
+	}
+
+	route := m.Handle(pattern, handler, methods...)
+	route.name = name
+
+	return route
+}
+
+// Named gives the route a name for use with Reverse, like HandleNamed:
+//
+//	mux.Get("/users/:id", showUser).Named("user.show")
+//
+// It panics if the name is empty or is already used by another route of the
+// Mux. To set the name of the route's handler, use HandlerNamed.
+func (r *Route) Named(name string) *Route {
+	if name == "" {
+		panic("flow: route name must not be empty")
+	}
+
+	for _, route := range r.table.load() {
+		if route != r && route.name == name {
+			panic(fmt.Sprintf("flow: route name %q is already in use", name))
+		}
+	}
+
+	r.name = name
+	return r
+}
+
+// Reverse builds the path for the route with the given name, filling in its
+// parameters from args. Values are percent-encoded, and the value for a
+// wildcard may contain slashes. It returns an error if there is no route with
+// the name, if a parameter is missing from args or its value doesn't match the
+// parameter's regular expression, or if args contains values which aren't
+// used by the pattern (which usually means a parameter name is misspelt).
+//
+// Only the path is built: the host pattern of a route registered with Host
+// (or RouteBuilder.Host) isn't included, and its parameters can't be given in
+// args.
+func (m *Mux) Reverse(name string, args Args) (string, error) {
+	route := m.namedRoute(name)
+	if route == nil {
+		return "", fmt.Errorf("flow: no route named %q", name)
+	}
+
+	var sb strings.Builder
+	used := 0
+
+	for i, seg := range route.segments {
+		if i > 0 {
+			sb.WriteByte('/')
+		}
+
+		switch {
+		case seg.wildcard:
+			value, ok := args[seg.key.name]
+			if !ok {
+				return "", fmt.Errorf("flow: route %q requires a value for the wildcard", name)
+			}
+			used++
+
+			parts := strings.Split(value, "/")
+			for j, part := range parts {
+				parts[j] = url.PathEscape(part)
+			}
+			sb.WriteString(strings.Join(parts, "/"))
+		case seg.param:
+			value, ok := args[seg.value]
+			if !ok || value == "" {
+				return "", fmt.Errorf("flow: route %q requires a value for parameter %q", name, seg.value)
+			}
+			used++
+
+			if seg.rx != nil && !seg.rx.MatchString(value) {
+				return "", fmt.Errorf("flow: value %q for parameter %q of route %q doesn't match %q", value, seg.value, name, seg.rx)
+			}
+			if seg.check != nil && !seg.check(value) {
+				return "", fmt.Errorf("flow: value %q for parameter %q of route %q doesn't satisfy its constraint", value, seg.value, name)
+			}
+			sb.WriteString(url.PathEscape(value))
+		default:
+			sb.WriteString(seg.value)
+		}
+	}
+
+	if used != len(args) {
+		var unknown []string
+		for key := range args {
+			if !route.hasParam(key) {
+				unknown = append(unknown, key)
+			}
+		}
+		slices.Sort(unknown)
+
+		return "", fmt.Errorf("flow: route %q has no parameters named %q", name, unknown)
+	}
+
+	return sb.String(), nil
+}
+
+func (m *Mux) namedRoute(name string) *Route {
+	for _, route := range m.routes.load() {
+		if route.name == name {
+			return route
+		}
+	}
+
+	return nil
+}
+
+func (r *Route) hasParam(key string) bool {
+	return slices.ContainsFunc(r.segments, func(seg segment) bool {
+		return (seg.param || seg.wildcard) && seg.key.name == key
+	})
+}
@@ -0,0 +1,106 @@
+package flow
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReverse(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.HandleNamed("home", "/", http.HandlerFunc(hf), "GET")
+	m.HandleNamed("user.show", "/users/:id|^[0-9]+$", http.HandlerFunc(hf), "GET")
+	m.Group(func(m *Mux) {
+		m.HandleNamed("post.show", "/users/:id/posts/:slug", http.HandlerFunc(hf), "GET")
+	})
+	m.HandleNamed("files", "/files/.../meta", http.HandlerFunc(hf), "GET")
+	m.HandleNamed("key.show", "/keys/:key|uuid", http.HandlerFunc(hf), "GET")
+	m.HandleNamed("docs", "/docs/:path...", http.HandlerFunc(hf), "GET")
+
+	var tests = []struct {
+		Name string
+		Args Args
+
+		ExpectedPath  string
+		ExpectedError string
+	}{
+		{"home", nil, "/", ""},
+		{"user.show", Args{"id": "42"}, "/users/42", ""},
+		{"post.show", Args{"id": "42", "slug": "hello world/again"}, "/users/42/posts/hello%20world%2Fagain", ""},
+		{"files", Args{"...": "a b/c"}, "/files/a%20b/c/meta", ""},
+		{"user.show", Args{"id": "abc"}, "", `doesn't match`},
+		{"key.show", Args{"key": "123e4567-e89b-12d3-a456-426614174000"}, "/keys/123e4567-e89b-12d3-a456-426614174000", ""},
+		{"key.show", Args{"key": "abc"}, "", `doesn't satisfy its constraint`},
+		{"user.show", Args{}, "", `requires a value for parameter "id"`},
+		{"user.show", Args{"id": "1", "iid": "2"}, "", `no parameters named ["iid"]`},
+		{"files", nil, "", `requires a value for the wildcard`},
+		{"docs", Args{"path": "a/b c"}, "/docs/a/b%20c", ""},
+		{"docs", Args{"...": "a"}, "", `requires a value for the wildcard`},
+		{"missing", nil, "", `no route named "missing"`},
+	}
+
+	for _, test := range tests {
+		path, err := m.Reverse(test.Name, test.Args)
+
+		if test.ExpectedError != "" {
+			if err == nil || !strings.Contains(err.Error(), test.ExpectedError) {
+				t.Errorf("%s %v: expected error containing %q but got %v", test.Name, test.Args, test.ExpectedError, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s %v: unexpected error %v", test.Name, test.Args, err)
+		} else if path != test.ExpectedPath {
+			t.Errorf("%s %v: expected %q but got %q", test.Name, test.Args, test.ExpectedPath, path)
+		}
+
+		if _, _, ok := m.Match("GET", path); !ok {
+			t.Errorf("%s: reversed path %q doesn't match any route", test.Name, path)
+		}
+	}
+
+	if routes := m.Routes(); routes[1].Name != "user.show" {
+		t.Errorf("expected route name %q in listing but got %q", "user.show", routes[1].Name)
+	}
+}
+
+func TestHandleNamedDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic")
+		}
+	}()
+
+	m := New()
+	m.HandleNamed("user.show", "/users/:id", http.NotFoundHandler(), "GET")
+	m.Group(func(m *Mux) {
+		m.HandleNamed("user.show", "/people/:id", http.NotFoundHandler(), "GET")
+	})
+}
+
+func TestRouteNamed(t *testing.T) {
+	m := New()
+	m.Get("/users/:id", http.NotFound).Named("user.show").HandlerNamed("ShowUser")
+
+	path, err := m.Reverse("user.show", Args{"id": "42"})
+	if err != nil || path != "/users/42" {
+		t.Errorf("expected path /users/42 but got %q (%v)", path, err)
+	}
+
+	if info := m.Routes()[0]; info.Name != "user.show" || info.Handler != "ShowUser" {
+		t.Errorf("expected route name user.show and handler ShowUser but got %q and %q", info.Name, info.Handler)
+	}
+
+	// Naming the same route again is allowed.
+	m.Get("/users/:id/edit", http.NotFound).Named("user.edit").Named("user.edit")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for a duplicate name")
+		}
+	}()
+	m.Get("/people/:id", http.NotFound).Named("user.show")
+}
@@ -0,0 +1,122 @@
+package flow
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sync"
+)
+
+// MaxConstraintSize is the maximum size of a regular expression constraint in
+// a route pattern, measured in compiled instructions. Registering a route with
+// a larger constraint causes a panic (and ValidatePattern reports an error). A
+// value of zero or less means there is no limit.
+//
+// Go's regexp package guarantees matching in time linear in the length of the
+// input, so constraints can't cause catastrophic backtracking. But the cost of
+// matching each byte grows with the size of the expression, and expressions
+// with large counted repetitions such as [a-z]{0,999} compile to very large
+// programs. The limit means that a pathological constraint (for example, one
+// taken from configuration) is rejected at startup rather than slowing down
+// every request to the route.
+var MaxConstraintSize = 1000
+
+// constraintSize returns the number of instructions which expr compiles to.
+func constraintSize(expr string) (int, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return 0, err
+	}
+
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return 0, err
+	}
+
+	return len(prog.Inst), nil
+}
+
+// checkConstraintSize returns an error if an expression of the given size is
+// larger than MaxConstraintSize allows.
+func checkConstraintSize(expr string, size int) error {
+	if MaxConstraintSize > 0 && size > MaxConstraintSize {
+		return fmt.Errorf("regular expression %q is too complex (%d instructions, the limit is %d)", expr, size, MaxConstraintSize)
+	}
+
+	return nil
+}
+
+// rxCache holds the compiled regular expressions used in route patterns. It is
+// shared by all Mux instances and keyed by the full expression (including any
+// flags like (?i)), so identical constraints are only compiled once no matter
+// how many routers use them. The number of entries is bounded by the number of
+// distinct expressions in the application's route patterns. The size of each
+// expression is cached with it, so that it's checked against the current value
+// of MaxConstraintSize whenever the expression is used.
+var rxCache = struct {
+	sync.RWMutex
+	compiled map[string]cachedRX
+}{
+	compiled: map[string]cachedRX{},
+}
+
+type cachedRX struct {
+	rx   *regexp.Regexp
+	size int
+}
+
+func compileRX(expr string) (*regexp.Regexp, error) {
+	rxCache.RLock()
+	cached, ok := rxCache.compiled[expr]
+	rxCache.RUnlock()
+
+	if ok {
+		if err := checkConstraintSize(expr, cached.size); err != nil {
+			return nil, err
+		}
+		return cached.rx, nil
+	}
+
+	size, err := constraintSize(expr)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConstraintSize(expr, size); err != nil {
+		return nil, err
+	}
+
+	rx, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	rxCache.Lock()
+	defer rxCache.Unlock()
+
+	// Another goroutine may have compiled the same expression in the
+	// meantime, in which case use that one so that the cached value is
+	// shared.
+	if existing, ok := rxCache.compiled[expr]; ok {
+		return existing.rx, nil
+	}
+	rxCache.compiled[expr] = cachedRX{rx: rx, size: size}
+
+	return rx, nil
+}
+
+// PrecompileConstraints compiles the given regular expressions and adds them to
+// the cache which is shared by all Mux instances. Calling it at startup means
+// that constructing routers later (for example, one per test or per tenant)
+// doesn't need to compile the same constraints again. The expressions should be
+// given exactly as they appear after the | character in route patterns. It
+// returns an error if any of the expressions are invalid or larger than
+// MaxConstraintSize.
+func PrecompileConstraints(exprs ...string) error {
+	for _, expr := range exprs {
+		if _, err := compileRX(expr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+package flow
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+)
+
+func TestPrecompileConstraints(t *testing.T) {
+	err := PrecompileConstraints("^[0-9]+$", "(?i)^[a-z]+$")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	err = PrecompileConstraints("^[0-9+$")
+	if err == nil {
+		t.Errorf("expected an error for an invalid expression")
+	}
+}
+
+func TestConstraintsSharedBetweenMuxes(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	var wg sync.WaitGroup
+	muxes := make([]*Mux, 10)
+
+	for i := range muxes {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			muxes[i] = New()
+			muxes[i].HandleFunc("/users/:id|^[0-9]+$", hf, "GET")
+		}(i)
+	}
+	wg.Wait()
+
+	rx := muxes[0].routes.load()[0].segments[2].rx
+	for _, m := range muxes[1:] {
+		if m.routes.load()[0].segments[2].rx != rx {
+			t.Fatalf("expected compiled constraint to be shared between muxes")
+		}
+	}
+}
+
+func TestMaxConstraintSizeLowered(t *testing.T) {
+	defer func(size int) { MaxConstraintSize = size }(MaxConstraintSize)
+
+	expr := "^[a-z]{0,50}-lowered$"
+	if err := PrecompileConstraints(expr); err != nil {
+		t.Fatal(err)
+	}
+
+	// The expression is cached, but it must still be checked against the
+	// lower limit.
+	MaxConstraintSize = 10
+	if err := PrecompileConstraints(expr); err == nil {
+		t.Errorf("expected an error for a cached expression larger than the lowered limit")
+	}
+	if err := ValidatePattern("/:slug|" + expr); err == nil {
+		t.Errorf("expected ValidatePattern to report a cached expression larger than the lowered limit")
+	}
+
+	MaxConstraintSize = 0
+	if err := PrecompileConstraints(expr); err != nil {
+		t.Errorf("unexpected error with no limit: %v", err)
+	}
+}
+
+func TestMaxConstraintSize(t *testing.T) {
+	var tests = []struct {
+		Expr string
+
+		ExpectedError bool
+	}{
+		{"^[0-9]{1,10}$", false},
+		{"^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$", false},
+		{`^\p{L}+$`, false},
+		{"^[a-z]{0,900}[0-9]{0,900}$", true},
+		{"^((a|b|c){20}){20}$", true},
+	}
+
+	for _, test := range tests {
+		err := PrecompileConstraints(test.Expr)
+		if (err != nil) != test.ExpectedError {
+			t.Errorf("%s: expected error %v but got %v", test.Expr, test.ExpectedError, err)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected panic when registering a route with an overly complex constraint")
+		}
+	}()
+
+	m := New()
+	m.HandleFunc("/:slug|^[a-z]{0,999}$", func(w http.ResponseWriter, r *http.Request) {}, "GET")
+}
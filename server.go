@@ -0,0 +1,93 @@
+package flow
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// The default settings used by NewServer. The zero values used by http.Server
+// for the timeouts mean "no timeout", which leaves a server open to slowloris
+// attacks and to running out of connections.
+const (
+	DefaultReadHeaderTimeout = 5 * time.Second
+	DefaultReadTimeout       = 15 * time.Second
+	DefaultWriteTimeout      = 30 * time.Second
+	DefaultIdleTimeout       = 2 * time.Minute
+	DefaultMaxHeaderBytes    = 1 << 20
+)
+
+// Server is a http.Server with secure default settings. All of the fields of
+// the embedded http.Server can be changed after calling NewServer and before
+// starting the server.
+//
+// Requests which contain both a Transfer-Encoding and a Content-Length header,
+// or conflicting Content-Length headers, are handled safely by Go's HTTP/1.1
+// parser: the Content-Length is ignored when Transfer-Encoding is present, and
+// invalid or duplicate Content-Length values are rejected with a 400 response
+// before the handler is called.
+//
+// When Shutdown is called, the channel returned by ShuttingDown is closed for
+// all requests being handled by the server, so that long-running handlers can
+// finish gracefully.
+type Server struct {
+	http.Server
+	shutdown     chan struct{}
+	shutdownOnce sync.Once
+}
+
+type shutdownContextKey struct{}
+
+// ShuttingDown returns a channel which is closed when the Server handling the
+// request starts shutting down. Long-running handlers, such as those streaming
+// server-sent events or proxying websockets, should select on this channel and
+// return promptly when it is closed, rather than being cut off when the
+// shutdown deadline is reached.
+//
+// If the request isn't being handled by a Server created with NewServer,
+// ShuttingDown returns a nil channel (which is never closed).
+func ShuttingDown(ctx context.Context) <-chan struct{} {
+	ch, _ := ctx.Value(shutdownContextKey{}).(chan struct{})
+	return ch
+}
+
+// NewServer returns a new Server which will listen on addr and dispatch
+// requests to handler. The server uses DefaultReadHeaderTimeout,
+// DefaultReadTimeout, DefaultWriteTimeout, DefaultIdleTimeout and
+// DefaultMaxHeaderBytes.
+//
+// The WriteTimeout is a limit on the total time taken to handle a request, so
+// applications with long-running responses (such as downloads or server-sent
+// events) will need to increase it or set it to zero and use a timeout
+// middleware instead.
+//
+// NewServer sets the BaseContext field so that ShuttingDown works. If you
+// replace BaseContext, derive the returned context from the one it was
+// originally going to return.
+func NewServer(addr string, handler http.Handler) *Server {
+	s := &Server{
+		Server: http.Server{
+			Addr:              addr,
+			Handler:           handler,
+			ReadHeaderTimeout: DefaultReadHeaderTimeout,
+			ReadTimeout:       DefaultReadTimeout,
+			WriteTimeout:      DefaultWriteTimeout,
+			IdleTimeout:       DefaultIdleTimeout,
+			MaxHeaderBytes:    DefaultMaxHeaderBytes,
+		},
+		shutdown: make(chan struct{}),
+	}
+
+	s.BaseContext = func(net.Listener) context.Context {
+		return context.WithValue(context.Background(), shutdownContextKey{}, s.shutdown)
+	}
+	// The OnShutdown hooks run on every call to Shutdown, so the channel
+	// must only be closed the first time.
+	s.RegisterOnShutdown(func() {
+		s.shutdownOnce.Do(func() { close(s.shutdown) })
+	})
+
+	return s
+}
@@ -0,0 +1,112 @@
+package flow
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewServer(t *testing.T) {
+	srv := NewServer(":2323", New())
+
+	if srv.ReadHeaderTimeout != DefaultReadHeaderTimeout {
+		t.Errorf("expected ReadHeaderTimeout %s but was %s", DefaultReadHeaderTimeout, srv.ReadHeaderTimeout)
+	}
+	if srv.ReadTimeout != DefaultReadTimeout {
+		t.Errorf("expected ReadTimeout %s but was %s", DefaultReadTimeout, srv.ReadTimeout)
+	}
+	if srv.WriteTimeout != DefaultWriteTimeout {
+		t.Errorf("expected WriteTimeout %s but was %s", DefaultWriteTimeout, srv.WriteTimeout)
+	}
+	if srv.IdleTimeout != DefaultIdleTimeout {
+		t.Errorf("expected IdleTimeout %s but was %s", DefaultIdleTimeout, srv.IdleTimeout)
+	}
+	if srv.MaxHeaderBytes != DefaultMaxHeaderBytes {
+		t.Errorf("expected MaxHeaderBytes %d but was %d", DefaultMaxHeaderBytes, srv.MaxHeaderBytes)
+	}
+}
+
+func TestServerRejectsConflictingContentLength(t *testing.T) {
+	m := New()
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {}, "POST")
+
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = &NewServer("", m).Server
+	ts.Start()
+	defer ts.Close()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	req := "POST / HTTP/1.1\r\nHost: example.com\r\nContent-Length: 3\r\nContent-Length: 4\r\n\r\nabcd"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		t.Fatal(err)
+	}
+
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(line, "400") {
+		t.Errorf("expected a 400 response but got %q", line)
+	}
+}
+
+func TestShuttingDown(t *testing.T) {
+	started := make(chan struct{})
+	finished := make(chan string, 1)
+
+	m := New()
+	m.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		select {
+		case <-ShuttingDown(r.Context()):
+			finished <- "shutdown"
+		case <-time.After(5 * time.Second):
+			finished <- "timeout"
+		}
+	}, "GET")
+
+	srv := NewServer("", m)
+	ts := httptest.NewUnstartedServer(nil)
+	ts.Config = &srv.Server
+	ts.Start()
+	defer ts.Close()
+
+	go http.Get(ts.URL + "/stream")
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := <-finished; result != "shutdown" {
+		t.Errorf("expected handler to observe shutdown; got %q", result)
+	}
+
+	// A second Shutdown runs the OnShutdown hooks again, in new goroutines,
+	// which would panic if they closed the channel again.
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+}
+
+func TestShuttingDownWithoutServer(t *testing.T) {
+	if ShuttingDown(context.Background()) != nil {
+		t.Errorf("expected nil channel")
+	}
+}
@@ -0,0 +1,119 @@
+package flow
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// TrailingSlashPolicy controls how a Mux treats a request whose path doesn't
+// match any route, but would match if a trailing slash were added or removed.
+type TrailingSlashPolicy int
+
+const (
+	// StrictSlash treats paths with and without a trailing slash as
+	// different paths, so /foo and /foo/ only match routes registered with
+	// those exact patterns. This is the default.
+	StrictSlash TrailingSlashPolicy = iota
+
+	// RedirectTrailingSlash redirects the request to the path which does
+	// match a route, with a 301 Moved Permanently response for GET and HEAD
+	// requests, and 308 Permanent Redirect for other methods (so that the
+	// method and body are preserved). The query string is kept, and leading
+	// slashes are collapsed to one so the redirect stays on the same host.
+	RedirectTrailingSlash
+
+	// IgnoreTrailingSlash dispatches the request to the route which matches
+	// the other form of the path, without a redirect.
+	IgnoreTrailingSlash
+)
+
+var trailingSlashPolicies = [...]string{"strict", "redirect", "ignore"}
+
+func (p TrailingSlashPolicy) String() string {
+	if p < 0 || int(p) >= len(trailingSlashPolicies) {
+		return fmt.Sprintf("TrailingSlashPolicy(%d)", int(p))
+	}
+
+	return trailingSlashPolicies[p]
+}
+
+// MarshalText encodes the policy as "strict", "redirect" or "ignore".
+func (p TrailingSlashPolicy) MarshalText() ([]byte, error) {
+	if p < 0 || int(p) >= len(trailingSlashPolicies) {
+		return nil, fmt.Errorf("flow: invalid trailing slash policy %d", int(p))
+	}
+
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText decodes a policy encoded by MarshalText. The empty string is
+// decoded as StrictSlash.
+func (p *TrailingSlashPolicy) UnmarshalText(b []byte) error {
+	s := strings.ToLower(string(b))
+	if s == "" {
+		*p = StrictSlash
+		return nil
+	}
+
+	for i, name := range trailingSlashPolicies {
+		if s == name {
+			*p = TrailingSlashPolicy(i)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("flow: unknown trailing slash policy %q (use strict, redirect or ignore)", string(b))
+}
+
+// serveTrailingSlash applies the TrailingSlash policy to a request which
+// didn't match any route. It returns false if the other form of the path
+// doesn't match a route which allows the request method either.
+func (m *Mux) serveTrailingSlash(w http.ResponseWriter, r *http.Request, host *requestHost, path string, bit methodSet) bool {
+	if m.TrailingSlash == StrictSlash || path == "/" {
+		return false
+	}
+
+	alt := path + "/"
+	if strings.HasSuffix(path, "/") {
+		alt = strings.TrimSuffix(path, "/")
+	}
+	n := countSegments(alt)
+
+	var params []param
+
+	for _, route := range m.routes.load() {
+		var ok bool
+		params, ok = route.match(host, alt, n, params[:0])
+		if !ok || !route.satisfies(r) {
+			continue
+		}
+		if result, _ := m.resolveMethod(route, r, bit); result != routeServes {
+			continue
+		}
+
+		if m.TrailingSlash == IgnoreTrailingSlash {
+			r = r.WithContext(&routeContext{Context: r.Context(), route: route, params: params, header: r.Header})
+			route.handler.ServeHTTP(w, r)
+			return true
+		}
+
+		location := sameHostPath(alt)
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+
+		m.wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", location)
+			w.WriteHeader(code)
+		})).ServeHTTP(w, r)
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,63 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrailingSlash(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path + " " + Param(r.Context(), "id")))
+	}
+
+	newMux := func(policy TrailingSlashPolicy) *Mux {
+		m := New(WithTrailingSlash(policy))
+		m.HandleFunc("/users/:id", hf, "GET", "POST")
+		m.HandleFunc("/posts/", hf, "GET")
+		m.HandleFunc("/only-get", hf, "GET")
+		m.HandleFunc("/.../x", hf, "GET")
+		return m
+	}
+
+	var tests = []struct {
+		Policy        TrailingSlashPolicy
+		RequestMethod string
+		RequestPath   string
+
+		ExpectedStatus   int
+		ExpectedBody     string
+		ExpectedLocation string
+	}{
+		{StrictSlash, "GET", "/users/1", http.StatusOK, "/users/1 1", ""},
+		{StrictSlash, "GET", "/users/1/", http.StatusNotFound, "", ""},
+		{StrictSlash, "GET", "/posts", http.StatusNotFound, "", ""},
+
+		{RedirectTrailingSlash, "GET", "/users/1/?tab=info", http.StatusMovedPermanently, "", "/users/1?tab=info"},
+		{RedirectTrailingSlash, "HEAD", "/posts", http.StatusMovedPermanently, "", "/posts/"},
+		{RedirectTrailingSlash, "POST", "/users/1/", http.StatusPermanentRedirect, "", "/users/1"},
+		{RedirectTrailingSlash, "POST", "/only-get/", http.StatusNotFound, "", ""},
+		{RedirectTrailingSlash, "GET", "/missing/", http.StatusNotFound, "", ""},
+		{RedirectTrailingSlash, "GET", "/users/1", http.StatusOK, "/users/1 1", ""},
+		{RedirectTrailingSlash, "GET", "//evil.com/x/", http.StatusMovedPermanently, "", "/evil.com/x"},
+
+		{IgnoreTrailingSlash, "GET", "/users/2/", http.StatusOK, "/users/2/ 2", ""},
+		{IgnoreTrailingSlash, "GET", "/posts", http.StatusOK, "/posts ", ""},
+		{IgnoreTrailingSlash, "GET", "/", http.StatusNotFound, "", ""},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		newMux(test.Policy).ServeHTTP(rr, httptest.NewRequest(test.RequestMethod, test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s %s %s: expected status %d but was %d", test.Policy, test.RequestMethod, test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedStatus == http.StatusOK && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s %s %s: expected body %q but was %q", test.Policy, test.RequestMethod, test.RequestPath, test.ExpectedBody, rr.Body.String())
+		}
+		if location := rr.Header().Get("Location"); location != test.ExpectedLocation {
+			t.Errorf("%s %s %s: expected Location %q but was %q", test.Policy, test.RequestMethod, test.RequestPath, test.ExpectedLocation, location)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package flow
+
+import (
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// SOAPEnvelopeNS is the namespace of SOAP 1.1 envelopes.
+const SOAPEnvelopeNS = "http://schemas.xmlsoap.org/soap/envelope/"
+
+// SOAP12EnvelopeNS is the namespace of SOAP 1.2 envelopes.
+const SOAP12EnvelopeNS = "http://www.w3.org/2003/05/soap-envelope"
+
+// SOAPFault is a SOAP 1.1 fault. It implements the error interface.
+type SOAPFault struct {
+	XMLName xml.Name `xml:"soap:Fault"`
+	Code    string   `xml:"faultcode"`
+	String  string   `xml:"faultstring"`
+	Actor   string   `xml:"faultactor,omitempty"`
+	Detail  any      `xml:"detail,omitempty"`
+}
+
+func (f *SOAPFault) Error() string {
+	return fmt.Sprintf("soap fault: %s: %s", f.Code, f.String)
+}
+
+// BindSOAP decodes the content of the body of a SOAP envelope in the request
+// into v. The request must have an XML Content-Type (such as text/xml, or
+// application/soap+xml for SOAP 1.2 clients), and is decoded with the codec
+// registered for it, so the charset handling and safe decoding of XMLCodec
+// apply. Envelopes in either the SOAP 1.1 or the SOAP 1.2 namespace are
+// accepted. Errors are reported in the same way as by Bind.
+func BindSOAP(r *http.Request, v any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !strings.HasSuffix(mediaType, "/xml") && !strings.HasSuffix(mediaType, "+xml") {
+		return HTTPError{Status: http.StatusUnsupportedMediaType, Err: fmt.Errorf("flow: SOAP request has non-XML Content-Type %q", mediaType)}
+	}
+
+	return Bind(r, &soapRequestEnvelope{Body: soapRequestBody{content: v}})
+}
+
+// RenderSOAP sends v as the content of the body of a SOAP 1.1 envelope, using
+// the codec registered for text/xml.
+func RenderSOAP(w http.ResponseWriter, status int, v any) error {
+	codec, ok := lookupCodec("text/xml")
+	if !ok {
+		return HTTPError{Status: http.StatusNotAcceptable}
+	}
+
+	body, err := codec.Marshal(soapResponseEnvelope{NS: SOAPEnvelopeNS, Body: soapResponseBody{Content: v}})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	return err
+}
+
+// WriteSOAPFault sends fault in a SOAP envelope, with the status 500 Internal
+// Server Error required by SOAP 1.1.
+func WriteSOAPFault(w http.ResponseWriter, fault *SOAPFault) error {
+	return RenderSOAP(w, http.StatusInternalServerError, fault)
+}
+
+// The response envelope uses literal "soap:" prefixes, because encoding/xml
+// would otherwise declare the envelope namespace as the default namespace and
+// the content of the body would inherit it.
+type soapResponseEnvelope struct {
+	XMLName xml.Name         `xml:"soap:Envelope"`
+	NS      string           `xml:"xmlns:soap,attr"`
+	Body    soapResponseBody `xml:"soap:Body"`
+}
+
+type soapResponseBody struct {
+	Content any
+}
+
+type soapRequestEnvelope struct {
+	Body soapRequestBody
+}
+
+// UnmarshalXML decodes a SOAP 1.1 or 1.2 envelope, finding the Body element in
+// the namespace of the envelope.
+func (e *soapRequestEnvelope) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	ns := start.Name.Space
+	if start.Name.Local != "Envelope" || (ns != SOAPEnvelopeNS && ns != SOAP12EnvelopeNS) {
+		return fmt.Errorf("flow: expected a SOAP envelope but found element %q in namespace %q", start.Name.Local, ns)
+	}
+
+	found := false
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if tok.Name.Space != ns || tok.Name.Local != "Body" || found {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.DecodeElement(&e.Body, &tok); err != nil {
+				return err
+			}
+			found = true
+		case xml.EndElement:
+			if !found {
+				return fmt.Errorf("flow: SOAP envelope has no body")
+			}
+			return nil
+		}
+	}
+}
+
+type soapRequestBody struct {
+	content any
+}
+
+// UnmarshalXML decodes the first child element of the SOAP body into the
+// content value.
+func (b *soapRequestBody) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	decoded := false
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if decoded {
+				if err := d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := d.DecodeElement(b.content, &tok); err != nil {
+				return err
+			}
+			decoded = true
+		case xml.EndElement:
+			if !decoded {
+				return fmt.Errorf("flow: SOAP body is empty")
+			}
+			return nil
+		}
+	}
+}
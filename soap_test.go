@@ -0,0 +1,128 @@
+package flow
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type getUser struct {
+	XMLName xml.Name `xml:"urn:users GetUser"`
+	ID      string   `xml:"urn:users id"`
+}
+
+type getUserResponse struct {
+	XMLName xml.Name `xml:"urn:users GetUserResponse"`
+	Name    string   `xml:"name"`
+}
+
+func TestBindSOAP(t *testing.T) {
+	var tests = []struct {
+		ContentType string
+		Body        string
+
+		ExpectedStatus int
+		ExpectedID     string
+	}{
+		{
+			"text/xml; charset=utf-8",
+			`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Header/><soap:Body><u:GetUser xmlns:u="urn:users"><u:id>42</u:id></u:GetUser></soap:Body></soap:Envelope>`,
+			0, "42",
+		},
+		{
+			"application/soap+xml; charset=utf-8; action=\"urn:users/GetUser\"",
+			`<?xml version="1.0" encoding="utf-8"?><env:Envelope xmlns:env="http://www.w3.org/2003/05/soap-envelope"><env:Header><m:trace xmlns:m="urn:trace" env:mustUnderstand="false">abc</m:trace></env:Header><env:Body><u:GetUser xmlns:u="urn:users"><u:id>7</u:id></u:GetUser></env:Body></env:Envelope>`,
+			0, "7",
+		},
+		{
+			"text/xml",
+			`<soap:Envelope xmlns:soap="urn:not-soap"><soap:Body><GetUser xmlns="urn:users"><id>1</id></GetUser></soap:Body></soap:Envelope>`,
+			http.StatusBadRequest, "",
+		},
+		{
+			"text/xml",
+			`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Header/></soap:Envelope>`,
+			http.StatusBadRequest, "",
+		},
+		{
+			"text/xml; charset=iso-8859-1",
+			"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><soap:Envelope xmlns:soap=\"http://schemas.xmlsoap.org/soap/envelope/\"><soap:Body><GetUser xmlns=\"urn:users\"><id>caf\xe9</id></GetUser></soap:Body></soap:Envelope>",
+			0, "café",
+		},
+		{
+			"text/xml",
+			"<?xml version=\"1.0\" encoding=\"latin1\"?><soap:Envelope xmlns:soap=\"http://schemas.xmlsoap.org/soap/envelope/\"><soap:Body><GetUser xmlns=\"urn:users\"><id>na\xefve</id></GetUser></soap:Body></soap:Envelope>",
+			0, "naïve",
+		},
+		{
+			"text/xml",
+			`<!DOCTYPE foo [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><GetUser xmlns="urn:users"><id>&xxe;</id></GetUser></soap:Body></soap:Envelope>`,
+			http.StatusBadRequest, "",
+		},
+		{
+			"text/xml; charset=shift_jis",
+			`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body/></soap:Envelope>`,
+			http.StatusUnsupportedMediaType, "",
+		},
+		{
+			"text/xml",
+			`<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body></soap:Body></soap:Envelope>`,
+			http.StatusBadRequest, "",
+		},
+		{
+			"application/json",
+			`{}`,
+			http.StatusUnsupportedMediaType, "",
+		},
+	}
+
+	for i, test := range tests {
+		r := httptest.NewRequest("POST", "/soap", strings.NewReader(test.Body))
+		r.Header.Set("Content-Type", test.ContentType)
+
+		var req getUser
+		err := BindSOAP(r, &req)
+
+		if test.ExpectedStatus == 0 {
+			if err != nil {
+				t.Errorf("test %d: unexpected error %v", i, err)
+			}
+		} else if err == nil || StatusCode(err) != test.ExpectedStatus {
+			t.Errorf("test %d: expected status %d but got error %v", i, test.ExpectedStatus, err)
+		}
+
+		if req.ID != test.ExpectedID {
+			t.Errorf("test %d: expected id %q but was %q", i, test.ExpectedID, req.ID)
+		}
+	}
+}
+
+func TestRenderSOAP(t *testing.T) {
+	rr := httptest.NewRecorder()
+	if err := RenderSOAP(rr, http.StatusOK, getUserResponse{Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := xml.Header + `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><GetUserResponse xmlns="urn:users"><name>alice</name></GetUserResponse></soap:Body></soap:Envelope>`
+	if body := rr.Body.String(); body != expected {
+		t.Errorf("expected body %s but was %s", expected, body)
+	}
+
+	if ct := rr.Header().Get("Content-Type"); ct != "text/xml; charset=utf-8" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+
+	rr = httptest.NewRecorder()
+	WriteSOAPFault(rr, &SOAPFault{Code: "soap:Client", String: "unknown user"})
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 for fault but was %d", rr.Code)
+	}
+
+	expected = xml.Header + `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/"><soap:Body><soap:Fault><faultcode>soap:Client</faultcode><faultstring>unknown user</faultstring></soap:Fault></soap:Body></soap:Envelope>`
+	if body := rr.Body.String(); body != expected {
+		t.Errorf("expected body %s but was %s", expected, body)
+	}
+}
@@ -0,0 +1,106 @@
+package flow
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// Split is a http.Handler which sends each request to one of two handlers,
+// Blue or Green, for blue/green deployments or experiments within an
+// application. Register it with Mux.Split:
+//
+//	mux.Split("/checkout", &flow.Split{
+//		Blue:    oldCheckout,
+//		Green:   newCheckout,
+//		Percent: 10,
+//		Cookie:  "checkout_variant",
+//	}, "GET", "POST")
+//
+// Assignments are sticky, so that a client keeps seeing the same handler. If
+// Cookie is set and the request has the cookie (with the value "blue" or
+// "green"), it's used; otherwise the client is assigned a handler and the
+// cookie is set on the response. Setting the cookie by hand is a convenient way
+// to try out a handler. If Header is set, clients without the cookie are
+// assigned based on a hash of the header's value (such as a user ID), so the
+// assignment is the same on every server. Other requests are assigned at
+// random.
+type Split struct {
+	Blue  http.Handler
+	Green http.Handler
+
+	// Percent is the percentage of clients (between 0 and 100) which are
+	// assigned to Green.
+	Percent float64
+
+	// Cookie is the name of the cookie which records the assignment.
+	Cookie string
+
+	// CookieMaxAge sets the Max-Age of the cookie. If it is zero the cookie
+	// lasts until the browser is closed.
+	CookieMaxAge time.Duration
+
+	// Header is the name of a request header whose value is used to assign
+	// clients which don't have the cookie.
+	Header string
+}
+
+// Split registers a Split for the given pattern and methods. It's equivalent
+// to m.Handle(pattern, s, methods...), except that it panics if s doesn't
+// have both handlers.
+func (m *Mux) Split(pattern string, s *Split, methods ...string) *Route {
+	if s == nil || s.Blue == nil || s.Green == nil {
+		panic("flow: split must have blue and green handlers")
+	}
+
+	return m.Handle(pattern, s, methods...)
+}
+
+func (s *Split) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	variant := ""
+	if s.Cookie != "" {
+		if c, err := r.Cookie(s.Cookie); err == nil && (c.Value == "blue" || c.Value == "green") {
+			variant = c.Value
+		}
+	}
+
+	if variant == "" {
+		variant = s.assign(r)
+
+		if s.Cookie != "" {
+			http.SetCookie(w, &http.Cookie{
+				Name:     s.Cookie,
+				Value:    variant,
+				Path:     "/",
+				MaxAge:   int(s.CookieMaxAge.Seconds()),
+				Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+	}
+
+	if variant == "green" {
+		s.Green.ServeHTTP(w, r)
+		return
+	}
+	s.Blue.ServeHTTP(w, r)
+}
+
+// assign chooses the handler for a client which doesn't have the cookie.
+func (s *Split) assign(r *http.Request) string {
+	n := rand.Float64() * 100
+	if s.Header != "" {
+		if key := r.Header.Get(s.Header); key != "" {
+			h := fnv.New64a()
+			h.Write([]byte(key))
+			n = float64(h.Sum64()%10000) / 100
+		}
+	}
+
+	if n < s.Percent {
+		return "green"
+	}
+	return "blue"
+}
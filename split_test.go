@@ -0,0 +1,73 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	variant := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(name))
+		}
+	}
+
+	var tests = []struct {
+		Name    string
+		Split   *Split
+		Cookie  string
+		Header  string
+		Repeats int
+
+		ExpectedBody      string
+		ExpectedSetCookie string
+	}{
+		{"all blue", &Split{Percent: 0}, "", "", 20, "blue", ""},
+		{"all green", &Split{Percent: 100}, "", "", 20, "green", ""},
+		{"cookie assigned", &Split{Percent: 100, Cookie: "variant"}, "", "", 1, "green", "variant=green; Path=/; HttpOnly; SameSite=Lax"},
+		{"cookie sticky", &Split{Percent: 100, Cookie: "variant"}, "blue", "", 20, "blue", ""},
+		{"invalid cookie reassigned", &Split{Percent: 0, Cookie: "variant"}, "purple", "", 1, "blue", "variant=blue; Path=/; HttpOnly; SameSite=Lax"},
+		{"cookie ignored without name", &Split{Percent: 100}, "blue", "", 20, "green", ""},
+		// The hash of "user-1" puts it at 7.08%.
+		{"header hash below percent", &Split{Percent: 10, Header: "X-User"}, "", "user-1", 20, "green", ""},
+		{"header hash above percent", &Split{Percent: 5, Header: "X-User"}, "", "user-1", 20, "blue", ""},
+	}
+
+	for _, test := range tests {
+		test.Split.Blue, test.Split.Green = variant("blue"), variant("green")
+
+		m := New()
+		m.Split("/checkout", test.Split, "GET")
+
+		for i := 0; i < test.Repeats; i++ {
+			r := httptest.NewRequest("GET", "/checkout", nil)
+			if test.Cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "variant", Value: test.Cookie})
+			}
+			if test.Header != "" {
+				r.Header.Set("X-User", test.Header)
+			}
+
+			rr := httptest.NewRecorder()
+			m.ServeHTTP(rr, r)
+
+			if rr.Body.String() != test.ExpectedBody {
+				t.Errorf("%s: expected body %q but was %q", test.Name, test.ExpectedBody, rr.Body.String())
+			}
+			if cookie := rr.Header().Get("Set-Cookie"); cookie != test.ExpectedSetCookie {
+				t.Errorf("%s: expected Set-Cookie %q but was %q", test.Name, test.ExpectedSetCookie, cookie)
+			}
+		}
+	}
+}
+
+func TestSplitPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a split without a green handler")
+		}
+	}()
+
+	New().Split("/", &Split{Blue: http.NotFoundHandler()})
+}
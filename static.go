@@ -0,0 +1,290 @@
+package flow
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Static serves the files in fsys for requests below prefix, so a request for
+// /assets/css/site.css is answered with the file css/site.css:
+//
+//	mux.Static("/assets/", http.Dir("./public"))
+//
+// It registers a wildcard route for GET and HEAD requests (and a route for the
+// prefix itself, which redirects to the prefix with a trailing slash), and
+// returns the wildcard route. The prefix must begin with a slash, and may end
+// with one.
+//
+// Requests for paths containing a ".." segment, and for files or directories
+// whose names begin with a dot (like .env or .git), get a 404 Not Found
+// response (using the NotFound handler), as do requests for files which don't
+// exist. A request for a directory is answered with the index.html file in it,
+// after redirecting to add a trailing slash if necessary. Directory listings
+// are never shown.
+//
+// Responses have ETag and Last-Modified headers, and conditional and range
+// requests are handled by http.ServeContent. The Cache-Control header is set
+// to the value of StaticCacheControl when the route is registered. If
+// StaticPrecompressed is true, files compressed ahead of time are served to
+// clients which accept them: a request for app.js is answered with app.js.br
+// or app.js.gz (with a Content-Encoding header) if it exists, in the client's
+// order of preference.
+func (m *Mux) Static(prefix string, fsys http.FileSystem) *Route {
+	return m.static(prefix, &staticHandler{fsys: fsys})
+}
+
+// StaticFS is like Static, but serves the files in the directory dir of fsys,
+// which makes it convenient to serve files embedded with the embed package:
+//
+//	//go:embed public
+//	var files embed.FS
+//
+//	mux.StaticFS("/assets/", files, "public")
+//
+// Files in an embed.FS have no modification time, so their ETag is a hash of
+// their contents instead (worked out when each file is first requested), and
+// there is no Last-Modified header. StaticFS panics if dir isn't a directory
+// in fsys. Use "." to serve all of fsys.
+func (m *Mux) StaticFS(prefix string, fsys fs.FS, dir string) *Route {
+	if info, err := fs.Stat(fsys, dir); err != nil || !info.IsDir() {
+		panic(fmt.Sprintf("flow: static directory %q doesn't exist", dir))
+	}
+
+	sub, err := fs.Sub(fsys, dir)
+	if err != nil {
+		panic(fmt.Sprintf("flow: invalid static directory %q: %s", dir, err))
+	}
+
+	return m.static(prefix, &staticHandler{fsys: http.FS(sub)})
+}
+
+func (m *Mux) static(prefix string, h *staticHandler) *Route {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if !strings.HasPrefix(prefix, "/") && prefix != "" {
+		panic(fmt.Sprintf("flow: static prefix %q must begin with a slash", prefix))
+	}
+
+	h.notFound = m.NotFound
+	if h.notFound == nil {
+		h.notFound = http.NotFoundHandler()
+	}
+	h.cacheControl = m.StaticCacheControl
+	if h.cacheControl == "" {
+		h.cacheControl = DefaultStaticCacheControl
+	}
+	h.precompressed = m.StaticPrecompressed
+
+	if prefix != "" {
+		m.HandleFunc(prefix, func(w http.ResponseWriter, r *http.Request) {
+			localRedirect(w, r, path.Base(r.URL.Path)+"/")
+		}, http.MethodGet)
+	}
+
+	return m.Handle(prefix+"/...", h, http.MethodGet)
+}
+
+// DefaultStaticCacheControl is the Cache-Control header sent with files served
+// by Static when Mux.StaticCacheControl isn't set. It allows the files to be
+// cached, but makes clients revalidate them (using the ETag or Last-Modified
+// header) before each use, so changes are picked up straight away. For assets
+// with fingerprinted names, a long max-age is a better choice.
+const DefaultStaticCacheControl = "no-cache"
+
+// staticHandler serves the files for Static and StaticFS.
+type staticHandler struct {
+	fsys          http.FileSystem
+	cacheControl  string
+	precompressed bool
+	notFound      http.Handler
+
+	// hashes caches the ETags of files without a modification time.
+	hashes sync.Map
+}
+
+func (h *staticHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := staticName(Param(r.Context(), "..."))
+	if !ok {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		h.notFound.ServeHTTP(w, r)
+		return
+	}
+
+	if info.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			localRedirect(w, r, path.Base(r.URL.Path)+"/")
+			return
+		}
+
+		name = path.Join(name, "index.html")
+		index, err := h.fsys.Open(name)
+		if err != nil {
+			h.notFound.ServeHTTP(w, r)
+			return
+		}
+		defer index.Close()
+
+		if info, err = index.Stat(); err != nil || info.IsDir() {
+			h.notFound.ServeHTTP(w, r)
+			return
+		}
+		f = index
+	}
+
+	w.Header().Set("Cache-Control", h.cacheControl)
+
+	if h.precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if encoded, encodedInfo, encoding := h.openEncoded(name, r.Header.Get("Accept-Encoding")); encoded != nil {
+			defer encoded.Close()
+
+			// The Content-Type is that of the uncompressed file, so it must be
+			// set before ServeContent sees the compressed one.
+			contentType, err := staticContentType(name, f)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Encoding", encoding)
+			f, info, name = encoded, encodedInfo, name+staticEncodings[encoding]
+		}
+	}
+
+	etag, err := h.etag(name, info, f)
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, path.Base(name), info.ModTime(), f)
+}
+
+// staticEncodings maps the content codings which Static can serve
+// precompressed files for to the extensions of the files.
+var staticEncodings = map[string]string{"br": ".br", "gzip": ".gz"}
+
+// openEncoded opens the precompressed version of a file which is most preferred
+// by the Accept-Encoding header, returning nil if the client doesn't accept any
+// of the precompressed versions which exist.
+func (h *staticHandler) openEncoded(name, acceptEncoding string) (http.File, fs.FileInfo, string) {
+	var encodings []string
+	for _, accepted := range parseWeighted(acceptEncoding) {
+		switch value := strings.ToLower(accepted.value); value {
+		case "*":
+			encodings = append(encodings, "br", "gzip")
+		case "br", "gzip", "x-gzip":
+			encodings = append(encodings, strings.TrimPrefix(value, "x-"))
+		}
+	}
+
+	for _, encoding := range encodings {
+		f, err := h.fsys.Open(name + staticEncodings[encoding])
+		if err != nil {
+			continue
+		}
+
+		if info, err := f.Stat(); err == nil && !info.IsDir() {
+			return f, info, encoding
+		}
+		f.Close()
+	}
+
+	return nil, nil, ""
+}
+
+// etag returns the ETag for a file. It's worked out from the modification time
+// and size if the file has a modification time, and otherwise (as for files in
+// an embed.FS) from a hash of the contents, which is only computed once.
+func (h *staticHandler) etag(name string, info fs.FileInfo, f http.File) (string, error) {
+	if !info.ModTime().IsZero() {
+		return fmt.Sprintf(`W/"%x-%x"`, info.ModTime().UnixNano(), info.Size()), nil
+	}
+
+	if etag, ok := h.hashes.Load(name); ok {
+		return etag.(string), nil
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := fmt.Sprintf(`"%x"`, hash.Sum(nil)[:16])
+	h.hashes.Store(name, etag)
+
+	return etag, nil
+}
+
+// staticContentType returns the Content-Type for a file, using its extension
+// or, if the extension isn't known, the start of its contents.
+func staticContentType(name string, f http.File) (string, error) {
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		return contentType, nil
+	}
+
+	var buf [512]byte
+	n, err := io.ReadFull(f, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// staticName returns the name of the file to open for the (escaped) wildcard
+// value of a Static route. It returns false if the value can't be decoded, or
+// if it contains a ".." segment, a segment beginning with a dot, or a
+// backslash or NUL byte (which some file systems treat specially).
+func staticName(wildcard string) (string, bool) {
+	name, err := url.PathUnescape(wildcard)
+	if err != nil || strings.ContainsAny(name, "\\\x00") {
+		return "", false
+	}
+
+	for _, segment := range strings.Split(name, "/") {
+		if strings.HasPrefix(segment, ".") {
+			return "", false
+		}
+	}
+
+	return path.Clean("/" + name), true
+}
+
+// localRedirect redirects to a path relative to the request path, keeping the
+// query string.
+func localRedirect(w http.ResponseWriter, r *http.Request, target string) {
+	if r.URL.RawQuery != "" {
+		target += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Location", target)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
@@ -0,0 +1,189 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStatic(t *testing.T) {
+	files := fstest.MapFS{
+		"css/site.css":      {Data: []byte("body {}")},
+		"docs/index.html":   {Data: []byte("<h1>docs</h1>")},
+		"empty/placeholder": {Data: []byte("")},
+		".env":              {Data: []byte("SECRET=1")},
+		"index.html":        {Data: []byte("<h1>home</h1>")},
+	}
+
+	m := New()
+	m.Static("/assets/", http.FS(files))
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedStatus   int
+		ExpectedBody     string
+		ExpectedLocation string
+	}{
+		{"/assets/css/site.css", http.StatusOK, "body {}", ""},
+		{"/assets/", http.StatusOK, "<h1>home</h1>", ""},
+		{"/assets", http.StatusMovedPermanently, "", "assets/"},
+		{"/assets/docs/", http.StatusOK, "<h1>docs</h1>", ""},
+		{"/assets/docs?v=2", http.StatusMovedPermanently, "", "docs/?v=2"},
+		{"/assets/empty/", http.StatusNotFound, "", ""},
+		{"/assets/missing.css", http.StatusNotFound, "", ""},
+		{"/assets/.env", http.StatusNotFound, "", ""},
+		{"/assets/css/../.env", http.StatusNotFound, "", ""},
+		{"/assets/%2e%2e/static.go", http.StatusNotFound, "", ""},
+		{"/assets/css%5Csite.css", http.StatusNotFound, "", ""},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("GET %s: expected status %d but was %d", test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if test.ExpectedStatus == http.StatusOK && rr.Body.String() != test.ExpectedBody {
+			t.Errorf("GET %s: expected body %q but was %q", test.RequestPath, test.ExpectedBody, rr.Body.String())
+		}
+		if location := rr.Header().Get("Location"); location != test.ExpectedLocation {
+			t.Errorf("GET %s: expected location %q but was %q", test.RequestPath, test.ExpectedLocation, location)
+		}
+	}
+}
+
+func TestStaticHeaders(t *testing.T) {
+	files := fstest.MapFS{"app.js": {Data: []byte("run()")}}
+
+	m := New()
+	m.Static("/a", http.FS(files))
+	m.StaticCacheControl = "public, max-age=31536000, immutable"
+	m.Static("/b", http.FS(files))
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/a/app.js", nil))
+
+	if cc := rr.Header().Get("Cache-Control"); cc != DefaultStaticCacheControl {
+		t.Errorf("expected Cache-Control %q but got %q", DefaultStaticCacheControl, cc)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/javascript; charset=utf-8" {
+		t.Errorf("expected Content-Type %q but got %q", "text/javascript; charset=utf-8", ct)
+	}
+
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected an ETag")
+	}
+
+	r := httptest.NewRequest("GET", "/a/app.js", nil)
+	r.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	m.ServeHTTP(rr, r)
+
+	if rr.Code != http.StatusNotModified {
+		t.Errorf("expected status %d but got %d", http.StatusNotModified, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("HEAD", "/b/app.js", nil))
+
+	if cc := rr.Header().Get("Cache-Control"); cc != "public, max-age=31536000, immutable" {
+		t.Errorf("expected Cache-Control %q but got %q", "public, max-age=31536000, immutable", cc)
+	}
+	if rr.Code != http.StatusOK || rr.Body.Len() != 0 {
+		t.Errorf("expected an empty 200 response to HEAD but got %d %q", rr.Code, rr.Body.String())
+	}
+}
+
+func TestStaticFS(t *testing.T) {
+	files := fstest.MapFS{
+		"public/app.js":        {Data: []byte("run()")},
+		"public/app.js.br":     {Data: []byte("br-data")},
+		"public/app.js.gz":     {Data: []byte("gzip-data")},
+		"public/logo":          {Data: []byte("\x89PNG\r\n\x1a\n")},
+		"public/logo.gz":       {Data: []byte("gzip-logo")},
+		"public/docs/index.md": {Data: []byte("# docs")},
+		"secret.txt":           {Data: []byte("secret")},
+	}
+
+	m := New()
+	m.StaticFS("/plain", files, "public")
+	m.StaticPrecompressed = true
+	m.StaticFS("/assets/", files, "public")
+
+	var tests = []struct {
+		RequestPath    string
+		AcceptEncoding string
+
+		ExpectedBody       string
+		ExpectedType       string
+		ExpectedEncoding   string
+		ExpectedVary       string
+		ExpectedStatusCode int
+	}{
+		{"/plain/app.js", "br, gzip", "run()", "text/javascript; charset=utf-8", "", "", http.StatusOK},
+		{"/assets/app.js", "", "run()", "text/javascript; charset=utf-8", "", "Accept-Encoding", http.StatusOK},
+		{"/assets/app.js", "gzip, br", "gzip-data", "text/javascript; charset=utf-8", "gzip", "Accept-Encoding", http.StatusOK},
+		{"/assets/app.js", "gzip;q=0.5, br", "br-data", "text/javascript; charset=utf-8", "br", "Accept-Encoding", http.StatusOK},
+		{"/assets/app.js", "*", "br-data", "text/javascript; charset=utf-8", "br", "Accept-Encoding", http.StatusOK},
+		{"/assets/app.js", "br;q=0, identity", "run()", "text/javascript; charset=utf-8", "", "Accept-Encoding", http.StatusOK},
+		{"/assets/logo", "br, gzip", "gzip-logo", "image/png", "gzip", "Accept-Encoding", http.StatusOK},
+		{"/assets/docs/", "", "", "", "", "", http.StatusNotFound},
+		{"/assets/secret.txt", "", "", "", "", "", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", test.RequestPath, nil)
+		if test.AcceptEncoding != "" {
+			r.Header.Set("Accept-Encoding", test.AcceptEncoding)
+		}
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatusCode {
+			t.Errorf("%s (%s): expected status %d but was %d", test.RequestPath, test.AcceptEncoding, test.ExpectedStatusCode, rr.Code)
+			continue
+		}
+		if test.ExpectedStatusCode != http.StatusOK {
+			continue
+		}
+
+		if rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s (%s): expected body %q but was %q", test.RequestPath, test.AcceptEncoding, test.ExpectedBody, rr.Body.String())
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != test.ExpectedType {
+			t.Errorf("%s (%s): expected Content-Type %q but was %q", test.RequestPath, test.AcceptEncoding, test.ExpectedType, ct)
+		}
+		if ce := rr.Header().Get("Content-Encoding"); ce != test.ExpectedEncoding {
+			t.Errorf("%s (%s): expected Content-Encoding %q but was %q", test.RequestPath, test.AcceptEncoding, test.ExpectedEncoding, ce)
+		}
+		if vary := rr.Header().Get("Vary"); vary != test.ExpectedVary {
+			t.Errorf("%s (%s): expected Vary %q but was %q", test.RequestPath, test.AcceptEncoding, test.ExpectedVary, vary)
+		}
+		if lm := rr.Header().Get("Last-Modified"); lm != "" {
+			t.Errorf("%s (%s): expected no Last-Modified header but got %q", test.RequestPath, test.AcceptEncoding, lm)
+		}
+	}
+
+	etag := func(acceptEncoding string) string {
+		r := httptest.NewRequest("GET", "/assets/app.js", nil)
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, r)
+		return rr.Header().Get("ETag")
+	}
+
+	if plain, br := etag(""), etag("br"); plain == "" || plain == br || plain != etag("") {
+		t.Errorf("expected stable and distinct ETags but got %q and %q", plain, br)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic for a missing directory")
+		}
+	}()
+	m.StaticFS("/missing", files, "pubilc")
+}
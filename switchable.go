@@ -0,0 +1,47 @@
+package flow
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// Switchable is a http.Handler which dispatches requests to a Mux that can be
+// replaced at any time. It allows an application to build a new Mux in the
+// background (for example, after reloading its configuration) and switch to it
+// without restarting the server or taking any locks in the request path.
+//
+// The zero value is ready to use, and responds with 503 Service Unavailable
+// until a Mux has been stored.
+type Switchable struct {
+	mux atomic.Pointer[Mux]
+}
+
+// NewSwitchable returns a new Switchable which dispatches requests to m.
+func NewSwitchable(m *Mux) *Switchable {
+	s := &Switchable{}
+	s.Store(m)
+	return s
+}
+
+// Store atomically replaces the Mux used for new requests. Requests which are
+// already in flight complete using the previous Mux.
+func (s *Switchable) Store(m *Mux) {
+	s.mux.Store(m)
+}
+
+// Load returns the Mux currently used for requests, or nil if none has been
+// stored.
+func (s *Switchable) Load() *Mux {
+	return s.mux.Load()
+}
+
+// ServeHTTP makes Switchable implement the http.Handler interface.
+func (s *Switchable) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m := s.mux.Load()
+	if m == nil {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	m.ServeHTTP(w, r)
+}
@@ -0,0 +1,47 @@
+package flow
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSwitchable(t *testing.T) {
+	newMux := func(body string) *Mux {
+		m := New()
+		m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}, "GET")
+		return m
+	}
+
+	var s Switchable
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d but was %d", http.StatusServiceUnavailable, rr.Code)
+	}
+
+	for _, body := range []string{"first", "second"} {
+		m := newMux(body)
+		s.Store(m)
+
+		if s.Load() != m {
+			t.Errorf("Load: expected the most recently stored mux")
+		}
+
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+		actual, err := io.ReadAll(rr.Result().Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(actual) != body {
+			t.Errorf("expected body %q; got %q", body, string(actual))
+		}
+	}
+}
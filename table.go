@@ -0,0 +1,214 @@
+package flow
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// routeTable holds the routes for a Mux (and any groups created from it).
+// Requests read the routes with an atomic load, so the table can be appended
+// to or replaced without blocking requests which are being served.
+type routeTable struct {
+	mu     sync.Mutex
+	routes atomic.Pointer[[]*Route]
+
+	// tagMiddleware holds the middleware registered with UseFor, keyed by tag.
+	// It is protected by mu.
+	tagMiddleware map[string][]func(http.Handler) http.Handler
+}
+
+func (t *routeTable) load() []*Route {
+	routes := t.routes.Load()
+	if routes == nil {
+		return nil
+	}
+
+	return *routes
+}
+
+// add appends routes to the table. Appending may write to the existing backing
+// array, but only beyond the length of any slice that has previously been
+// loaded, so it doesn't affect requests which are in flight.
+func (t *routeTable) add(routes ...*Route) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	updated := append(t.load(), routes...)
+	t.routes.Store(&updated)
+}
+
+// store replaces all of the routes in the table. The slice is clipped so that
+// a later add won't write into a backing array that may be shared with another
+// table.
+func (t *routeTable) store(routes []*Route) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	routes = slices.Clip(routes)
+	t.routes.Store(&routes)
+}
+
+// update replaces the routes in the table with the result of calling fn with
+// the current routes. fn must not modify the slice it is given, or the routes
+// in it. It's called with mu held, so it may also update tagMiddleware.
+func (t *routeTable) update(fn func([]*Route) []*Route) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	routes := slices.Clip(fn(t.load()))
+	t.routes.Store(&routes)
+}
+
+// cloneTagMiddleware returns a copy of the middleware registered with UseFor.
+func (t *routeTable) cloneTagMiddleware() map[string][]func(http.Handler) http.Handler {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.tagMiddleware == nil {
+		return nil
+	}
+
+	cloned := make(map[string][]func(http.Handler) http.Handler, len(t.tagMiddleware))
+	for tag, mw := range t.tagMiddleware {
+		cloned[tag] = slices.Clone(mw)
+	}
+
+	return cloned
+}
+
+// Clone returns a copy of the Mux. The copy has its own route table containing
+// copies of the same routes (sharing the same handlers and middleware) as the
+// original, so routes can be added to either one without affecting the other.
+// This makes it cheap to build variations of a base set of routes, such as
+// per-tenant or staged routing tables.
+func (m *Mux) Clone() *Mux {
+	mm := *m
+	mm.routes = &routeTable{}
+
+	routes := m.routes.load()
+	cloned := make([]*Route, len(routes))
+	for i, route := range routes {
+		r := *route
+		r.tags = slices.Clone(route.tags)
+		r.table = mm.routes
+		cloned[i] = &r
+	}
+	mm.routes.store(cloned)
+	mm.routes.tagMiddleware = m.routes.cloneTagMiddleware()
+	mm.middlewares = slices.Clone(m.middlewares)
+
+	return &mm
+}
+
+// Swap atomically replaces the routes in m with the routes from other. Requests
+// which are in flight when Swap is called complete using the old routes, and
+// all later requests use the new ones. Only the routes are swapped; the
+// NotFound, MethodNotAllowed and Options handlers and other settings of m are
+// unchanged. Routes added to other after calling Swap are not added to m.
+func (m *Mux) Swap(other *Mux) {
+	m.routes.store(other.routes.load())
+}
+
+// Reload replaces the routes in m with the routes registered by fn, without
+// interrupting requests which are being served. fn is called with a Mux which
+// is like a group of m, with the same settings and middleware but no routes.
+// Requests continue to use the old routes while fn runs, and the new routes
+// are swapped in atomically once it returns. This makes it possible to
+// rebuild the routes from a configuration file when it changes:
+//
+//	err := mux.Reload(func(mux *flow.Mux) {
+//		for _, tenant := range config.Tenants {
+//			mux.HandleFunc("/"+tenant.Name+"/...", tenant.Handler, "GET")
+//		}
+//	})
+//
+// If fn panics, for example because a pattern is invalid, the routes are left
+// unchanged and Reload returns the panic as an error.
+func (m *Mux) Reload(fn func(*Mux)) (err error) {
+	mm := *m
+	mm.routes = &routeTable{tagMiddleware: m.routes.cloneTagMiddleware()}
+
+	defer func() {
+		if v := recover(); v != nil {
+			if e, ok := v.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", v)
+			}
+		}
+	}()
+
+	fn(&mm)
+
+	routes := mm.routes.load()
+	for _, route := range routes {
+		route.table = m.routes
+	}
+	m.routes.store(routes)
+
+	return nil
+}
+
+// Remove deletes the routes registered with pattern, which (like the pattern
+// given to Handle) is relative to the group's prefix and host. If methods are
+// given, only those methods are removed from the routes, and routes left
+// without any methods are deleted; removing GET also removes the HEAD method
+// which was added to the route automatically (but not one registered
+// explicitly). Otherwise the routes are deleted for all of their methods.
+//
+// Remove is safe to call while requests are being served: requests in flight
+// complete using the routes they matched, and later requests are handled as
+// if the removed routes had never been registered. A *Route returned by Handle
+// for a route which only had some of its methods removed no longer refers to
+// the registered route.
+func (m *Mux) Remove(pattern string, methods ...string) {
+	pattern = m.prefix + pattern
+	if pattern == "" {
+		pattern = "/"
+	}
+
+	var remove methodSet
+	var custom []string
+	for _, method := range methods {
+		method = strings.ToUpper(method)
+		if bit := methodBit(method); bit != 0 {
+			remove |= bit
+		} else {
+			custom = append(custom, method)
+		}
+	}
+
+	m.routes.update(func(routes []*Route) []*Route {
+		updated := make([]*Route, 0, len(routes))
+		for _, route := range routes {
+			if route.pattern != pattern || route.hostPattern != m.hostPattern {
+				updated = append(updated, route)
+				continue
+			}
+			if len(methods) == 0 {
+				continue
+			}
+
+			r := *route
+			r.methods &^= remove
+			if remove&methodBit(http.MethodGet) != 0 && route.autoHead {
+				r.methods &^= methodBit(http.MethodHead)
+				r.autoHead = false
+			}
+			r.customMethods = slices.DeleteFunc(slices.Clone(route.customMethods), func(s string) bool { return slices.Contains(custom, s) })
+
+			switch {
+			case r.methods == route.methods && len(r.customMethods) == len(route.customMethods):
+				updated = append(updated, route)
+			case r.methods != 0 || len(r.customMethods) > 0:
+				updated = append(updated, &r)
+			}
+		}
+
+		return updated
+	})
+}
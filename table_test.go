@@ -0,0 +1,249 @@
+package flow
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	hf := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+
+	base := New()
+	base.HandleFunc("/shared", hf("shared"), "GET")
+
+	tenant := base.Clone()
+	tenant.HandleFunc("/tenant", hf("tenant"), "GET")
+	base.HandleFunc("/base", hf("base"), "GET")
+
+	var tests = []struct {
+		Mux         *Mux
+		RequestPath string
+
+		ExpectedStatus int
+	}{
+		{base, "/shared", http.StatusOK},
+		{base, "/base", http.StatusOK},
+		{base, "/tenant", http.StatusNotFound},
+		{tenant, "/shared", http.StatusOK},
+		{tenant, "/tenant", http.StatusOK},
+		{tenant, "/base", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		r, err := http.NewRequest("GET", test.RequestPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := httptest.NewRecorder()
+		test.Mux.ServeHTTP(rr, r)
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("GET %s: expected status %d but was %d", test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+	}
+}
+
+func TestSwap(t *testing.T) {
+	m := New()
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("old"))
+	}, "GET")
+
+	next := New()
+	next.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("new"))
+	}, "GET")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest("GET", "/", nil)
+			m.ServeHTTP(httptest.NewRecorder(), r)
+		}()
+	}
+	m.Swap(next)
+	wg.Wait()
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	body, err := io.ReadAll(rr.Result().Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "new" {
+		t.Errorf("expected body %q; got %q", "new", string(body))
+	}
+}
+
+func TestRemove(t *testing.T) {
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	var tests = []struct {
+		Name          string
+		Pattern       string
+		Methods       []string
+		RequestMethod string
+		RequestPath   string
+
+		ExpectedStatus int
+		ExpectedAllow  string
+	}{
+		{"all methods", "/posts/:id", nil, "GET", "/posts/1", http.StatusNotFound, ""},
+		{"other route kept", "/posts/:id", nil, "GET", "/posts", http.StatusOK, ""},
+		{"one method", "/posts/:id", []string{"delete"}, "DELETE", "/posts/1", http.StatusMethodNotAllowed, "GET, HEAD, PUT, PURGE, OPTIONS"},
+		{"remaining methods kept", "/posts/:id", []string{"DELETE"}, "PUT", "/posts/1", http.StatusOK, ""},
+		{"GET removes automatic HEAD", "/posts/:id", []string{"GET"}, "HEAD", "/posts/1", http.StatusMethodNotAllowed, "PUT, DELETE, PURGE, OPTIONS"},
+		{"GET keeps explicit HEAD", "/pages", []string{"GET"}, "HEAD", "/pages", http.StatusOK, ""},
+		{"custom method", "/posts/:id", []string{"PURGE"}, "PURGE", "/posts/1", http.StatusMethodNotAllowed, "GET, HEAD, PUT, DELETE, OPTIONS"},
+		{"group prefix", "/admin/users", nil, "GET", "/admin/users", http.StatusNotFound, ""},
+		{"unknown pattern", "/missing", nil, "GET", "/posts/1", http.StatusOK, ""},
+		{"pattern must match exactly", "/posts/:slug", nil, "GET", "/posts/1", http.StatusOK, ""},
+	}
+
+	for _, test := range tests {
+		m := New()
+		m.CustomMethods = []string{"PURGE"}
+		m.HandleFunc("/posts", hf, "GET")
+		m.HandleFunc("/posts/:id", hf, "GET", "PUT", "DELETE", "PURGE")
+		m.HandleFunc("/pages", hf, "GET", "HEAD")
+		m.Route("/admin", func(mux *Mux) {
+			mux.HandleFunc("/users", hf, "GET")
+		})
+
+		if strings.HasPrefix(test.Pattern, "/admin") {
+			m.Route("/admin", func(mux *Mux) {
+				mux.Remove(strings.TrimPrefix(test.Pattern, "/admin"), test.Methods...)
+			})
+		} else {
+			m.Remove(test.Pattern, test.Methods...)
+		}
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.RequestMethod, test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but was %d", test.Name, test.ExpectedStatus, rr.Code)
+		}
+		if allow := rr.Header().Get("Allow"); allow != test.ExpectedAllow {
+			t.Errorf("%s: expected Allow header %q but was %q", test.Name, test.ExpectedAllow, allow)
+		}
+	}
+}
+
+func TestRemoveInFlight(t *testing.T) {
+	m := New()
+	for i := 0; i < 10; i++ {
+		m.HandleFunc(fmt.Sprintf("/tenants/%d", i), func(w http.ResponseWriter, r *http.Request) {}, "GET")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		i := i
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", fmt.Sprintf("/tenants/%d", i), nil))
+		}()
+		go func() {
+			defer wg.Done()
+			m.Remove(fmt.Sprintf("/tenants/%d", i))
+		}()
+	}
+	wg.Wait()
+
+	if routes := m.routes.load(); len(routes) != 0 {
+		t.Errorf("expected all routes to be removed but %d remain", len(routes))
+	}
+}
+
+func TestReload(t *testing.T) {
+	hf := func(body string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		}
+	}
+
+	m := New()
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Middleware", "yes")
+			next.ServeHTTP(w, r)
+		})
+	})
+	m.UseFor("beta", func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Beta", "yes")
+			next.ServeHTTP(w, r)
+		})
+	})
+	m.HandleFunc("/old", hf("old"), "GET")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/old", nil))
+		}()
+	}
+
+	err := m.Reload(func(mux *Mux) {
+		mux.HandleFunc("/new", hf("new"), "GET").Tag("beta")
+	})
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Reload(func(mux *Mux) {
+		mux.HandleFunc("/broken", hf("broken"), "GET")
+		mux.HandleFunc("/.../...", hf("broken"), "GET")
+	})
+	if err == nil || !strings.Contains(err.Error(), "more than one wildcard") {
+		t.Errorf("expected an error for the invalid pattern but got %v", err)
+	}
+
+	var tests = []struct {
+		RequestPath string
+
+		ExpectedStatus int
+		ExpectedBody   string
+		ExpectedHeader string
+	}{
+		{"/old", http.StatusNotFound, "404 page not found\n", ""},
+		{"/new", http.StatusOK, "new", "yes"},
+		{"/broken", http.StatusNotFound, "404 page not found\n", ""},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", test.RequestPath, nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("GET %s: expected status %d but was %d", test.RequestPath, test.ExpectedStatus, rr.Code)
+		}
+		if rr.Body.String() != test.ExpectedBody {
+			t.Errorf("GET %s: expected body %q but was %q", test.RequestPath, test.ExpectedBody, rr.Body.String())
+		}
+		if header := rr.Header().Get("X-Beta"); header != test.ExpectedHeader {
+			t.Errorf("GET %s: expected X-Beta header %q but was %q", test.RequestPath, test.ExpectedHeader, header)
+		}
+		if header := rr.Header().Get("X-Middleware"); header != "yes" {
+			t.Errorf("GET %s: expected middleware to be used", test.RequestPath)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package flow
+
+import (
+	"net/http"
+	"slices"
+)
+
+// Tag adds one or more tags to the route, and applies any middleware which has
+// been registered for those tags with UseFor. Tags make it possible to apply
+// cross-cutting policies to routes independently of how they are grouped:
+//
+//	mux.HandleFunc("/login", login, "POST").Tag("public")
+//	mux.HandleFunc("/status", status, "GET").Tag("public", "monitoring")
+//	mux.UseFor("public", rateLimit)
+//
+// Tags are included in the RouteInfo returned by Routes and Match.
+func (r *Route) Tag(tags ...string) *Route {
+	for _, tag := range tags {
+		if slices.Contains(r.tags, tag) {
+			continue
+		}
+		r.tags = append(r.tags, tag)
+
+		if r.table == nil {
+			continue
+		}
+
+		r.table.mu.Lock()
+		mw := r.table.tagMiddleware[tag]
+		r.table.mu.Unlock()
+
+		r.handler = wrapMiddleware(r.handler, mw)
+	}
+
+	return r
+}
+
+// UseFor registers middleware which is applied to every route carrying the
+// given tag, whether the route was tagged before or after UseFor is called,
+// and whatever group it was registered in. The middleware is shared with the
+// parent Mux and any groups.
+//
+// Tag middleware wraps the route's handler after the middleware registered
+// with Use, so it runs before it. When a route gets tag middleware from more
+// than one call to UseFor or Tag, the most recently applied runs first.
+//
+// UseFor is safe to call while requests are being served. The routes which
+// already carry the tag are replaced with copies using the new middleware, so
+// (as with Remove) a *Route returned by Handle for one of them no longer
+// refers to the registered route.
+func (m *Mux) UseFor(tag string, mw ...func(http.Handler) http.Handler) {
+	m.routes.update(func(routes []*Route) []*Route {
+		if m.routes.tagMiddleware == nil {
+			m.routes.tagMiddleware = map[string][]func(http.Handler) http.Handler{}
+		}
+		m.routes.tagMiddleware[tag] = append(m.routes.tagMiddleware[tag], mw...)
+
+		updated := make([]*Route, len(routes))
+		for i, route := range routes {
+			if slices.Contains(route.tags, tag) {
+				r := *route
+				r.handler = wrapMiddleware(route.handler, mw)
+				r.table = m.routes
+				route = &r
+			}
+			updated[i] = route
+		}
+
+		return updated
+	})
+}
+
+func wrapMiddleware(handler http.Handler, mw []func(http.Handler) http.Handler) http.Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+
+	return handler
+}
@@ -0,0 +1,151 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Middleware", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	hf := func(w http.ResponseWriter, r *http.Request) {}
+
+	m := New()
+	m.Use(tag("global"))
+	m.HandleFunc("/login", hf, "POST").Tag("public")
+
+	m.Group(func(m *Mux) {
+		m.Use(tag("admin"))
+		m.HandleFunc("/admin/status", hf, "GET").Tag("public", "monitoring")
+		m.UseFor("monitoring", tag("metrics"))
+	})
+
+	m.UseFor("public", tag("ratelimit"))
+	m.HandleFunc("/signup", hf, "POST").Tag("public", "public")
+	m.HandleFunc("/private", hf, "GET")
+
+	var tests = []struct {
+		Method string
+		Path   string
+
+		ExpectedMiddleware string
+	}{
+		{"POST", "/login", "ratelimit,global"},
+		{"GET", "/admin/status", "ratelimit,metrics,global,admin"},
+		{"POST", "/signup", "ratelimit,global"},
+		{"GET", "/private", "global"},
+	}
+
+	for _, test := range tests {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest(test.Method, test.Path, nil))
+
+		if mw := strings.Join(rr.Header().Values("X-Middleware"), ","); mw != test.ExpectedMiddleware {
+			t.Errorf("%s %s: expected middleware %q but was %q", test.Method, test.Path, test.ExpectedMiddleware, mw)
+		}
+	}
+
+	info, _, _ := m.Match("GET", "/admin/status")
+	if !slices.Equal(info.Tags, []string{"public", "monitoring"}) {
+		t.Errorf("expected tags [public monitoring] but got %v", info.Tags)
+	}
+}
+
+func TestTagsClone(t *testing.T) {
+	var calls []string
+
+	counter := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	m := New()
+	m.UseFor("public", counter("original"))
+
+	clone := m.Clone()
+	clone.UseFor("public", counter("clone"))
+	clone.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {}, "GET").Tag("public")
+
+	clone.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if !slices.Equal(calls, []string{"original", "clone"}) {
+		t.Errorf("unexpected middleware calls %v", calls)
+	}
+
+	if len(m.routes.tagMiddleware["public"]) != 1 {
+		t.Errorf("expected UseFor on the clone not to affect the original")
+	}
+}
+
+func TestUseForSwap(t *testing.T) {
+	var calls []string
+
+	counter := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	next := New()
+	next.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {}, "GET").Tag("public")
+
+	m := New()
+	m.Swap(next)
+
+	// The routes are shared after Swap, so UseFor on next mustn't change the
+	// routes which m is serving.
+	next.UseFor("public", counter("next"))
+
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if len(calls) != 0 {
+		t.Errorf("expected no middleware calls for m but got %v", calls)
+	}
+
+	next.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !slices.Equal(calls, []string{"next"}) {
+		t.Errorf("unexpected middleware calls %v", calls)
+	}
+}
+
+func TestUseForWhileServing(t *testing.T) {
+	m := New()
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {}, "GET").Tag("public")
+
+	passThrough := func(next http.Handler) http.Handler { return next }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+		go func() {
+			defer wg.Done()
+			m.UseFor("public", passThrough)
+		}()
+	}
+	wg.Wait()
+
+	if n := len(m.routes.tagMiddleware["public"]); n != 10 {
+		t.Errorf("expected 10 tag middleware but got %d", n)
+	}
+}
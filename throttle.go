@@ -0,0 +1,104 @@
+package flow
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Throttle returns middleware which limits the rate at which response bodies
+// are sent to bytesPerSecond, so that a few large downloads can't use all of
+// the server's outgoing bandwidth. The limit applies to each response
+// separately:
+//
+//	mux.Group(func(mux *flow.Mux) {
+//		mux.Use(flow.Throttle(512 << 10)) // 512KB/s
+//		mux.Handle("/downloads/...", http.FileServer(http.Dir("./files")))
+//	})
+//
+// Writes are split into chunks of a tenth of a second's worth of data, and the
+// writer waits before each chunk so that the average rate doesn't exceed the
+// limit. Time which the handler spends not writing isn't saved up to send a
+// burst later. If the request context is canceled while a write is waiting
+// (for example, because the client went away), the write returns the
+// context's error. It panics if bytesPerSecond is less than 1.
+func Throttle(bytesPerSecond int) func(http.Handler) http.Handler {
+	if bytesPerSecond < 1 {
+		panic("flow: throttle rate must be at least 1 byte per second")
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tw := &throttleWriter{ResponseWriter: w, ctx: r.Context(), rate: bytesPerSecond}
+			next.ServeHTTP(tw, r)
+		})
+	}
+}
+
+// throttleWriter paces writes to the response.
+type throttleWriter struct {
+	http.ResponseWriter
+	hijackTracker
+	ctx  context.Context
+	rate int
+
+	// next is the earliest time the next chunk may be written.
+	next time.Time
+}
+
+func (tw *throttleWriter) Write(b []byte) (int, error) {
+	chunk := max(tw.rate/10, 1)
+	written := 0
+
+	for len(b) > 0 {
+		n := min(len(b), chunk)
+		if err := tw.wait(n); err != nil {
+			return written, err
+		}
+
+		m, err := tw.ResponseWriter.Write(b[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		b = b[n:]
+	}
+
+	return written, nil
+}
+
+// wait sleeps until n more bytes may be written.
+func (tw *throttleWriter) wait(n int) error {
+	now := time.Now()
+	if tw.next.Before(now) {
+		tw.next = now
+	}
+
+	if delay := tw.next.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-tw.ctx.Done():
+			return tw.ctx.Err()
+		}
+	}
+
+	tw.next = tw.next.Add(time.Duration(n) * time.Second / time.Duration(tw.rate))
+	return nil
+}
+
+func (tw *throttleWriter) Flush() {
+	http.NewResponseController(tw.ResponseWriter).Flush()
+}
+
+func (tw *throttleWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return tw.hijack(tw.ResponseWriter)
+}
+
+func (tw *throttleWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
@@ -0,0 +1,108 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	body := strings.Repeat("x", 300)
+
+	var tests = []struct {
+		Name  string
+		Rate  int
+		Pause time.Duration
+
+		ExpectedMin time.Duration
+		ExpectedMax time.Duration
+	}{
+		// 300 bytes at 1000 bytes/sec is sent in three 100 byte chunks, with
+		// a 100ms wait before each chunk after the first.
+		{"paced", 1000, 0, 200 * time.Millisecond, time.Second},
+		{"fast", 1 << 20, 0, 0, 100 * time.Millisecond},
+		// Pauses between writes aren't saved up to send a burst later.
+		{"no burst after pause", 1000, 300 * time.Millisecond, 500 * time.Millisecond, 1500 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		m := New()
+		m.Use(Throttle(test.Rate))
+		m.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body[:1]))
+			time.Sleep(test.Pause)
+			if _, err := w.Write([]byte(body[1:])); err != nil {
+				t.Errorf("%s: unexpected error %v", test.Name, err)
+			}
+		}, "GET")
+
+		start := time.Now()
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", "/download", nil))
+		elapsed := time.Since(start)
+
+		if rr.Body.String() != body {
+			t.Errorf("%s: expected %d bytes but got %d", test.Name, len(body), rr.Body.Len())
+		}
+		if elapsed < test.ExpectedMin || elapsed > test.ExpectedMax {
+			t.Errorf("%s: expected the response to take between %s and %s but took %s", test.Name, test.ExpectedMin, test.ExpectedMax, elapsed)
+		}
+	}
+}
+
+func TestThrottleCanceled(t *testing.T) {
+	errs := make(chan error, 1)
+
+	m := New()
+	m.Use(Throttle(10))
+	m.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		_, err := w.Write([]byte(strings.Repeat("x", 100)))
+		errs <- err
+	}, "GET")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/download", nil).WithContext(ctx))
+
+	if err := <-errs; err != context.DeadlineExceeded {
+		t.Errorf("expected error %v but got %v", context.DeadlineExceeded, err)
+	}
+	if rr.Body.Len() != 1 {
+		t.Errorf("expected 1 byte to be written before the cancellation but got %d", rr.Body.Len())
+	}
+}
+
+// unwrapWriter wraps a ResponseWriter without implementing any of its
+// optional interfaces, so they can only be reached through Unwrap.
+type unwrapWriter struct {
+	http.ResponseWriter
+}
+
+func (w unwrapWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func TestThrottleFlush(t *testing.T) {
+	m := New()
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(unwrapWriter{w}, r)
+		})
+	}, Throttle(1<<20))
+	m.HandleFunc("/download", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("x"))
+		w.(http.Flusher).Flush()
+	}, "GET")
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/download", nil))
+
+	if !rr.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+}
@@ -0,0 +1,247 @@
+package flow
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrIdleTimeout is the cause of the request context being canceled by the
+// IdleTimeout middleware. It can be retrieved using context.Cause.
+var ErrIdleTimeout = errors.New("flow: idle timeout exceeded")
+
+// IdleTimeout returns middleware which cancels the request context if the
+// handler goes for longer than d without writing to the response. Unlike
+// http.TimeoutHandler, it doesn't limit the total duration of the request or
+// buffer the response, so it is suitable for streaming routes such as
+// server-sent events which may stay open indefinitely but should give up if
+// they stop sending data.
+//
+// If the connection is hijacked (for example, to upgrade to a WebSocket), the
+// idle timer is stopped, and the hijacking handler becomes responsible for
+// managing the connection's lifetime.
+//
+// Each write also extends the connection's write deadline by d (where the
+// underlying ResponseWriter supports it), so streaming routes keep working
+// when the server has a WriteTimeout set.
+func IdleTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithCancelCause(r.Context())
+			defer cancel(nil)
+
+			timer := time.AfterFunc(d, func() {
+				cancel(ErrIdleTimeout)
+			})
+			defer timer.Stop()
+
+			iw := &idleWriter{ResponseWriter: w, timer: timer, timeout: d}
+			iw.reset()
+
+			next.ServeHTTP(iw, r.WithContext(ctx))
+		})
+	}
+}
+
+type idleWriter struct {
+	http.ResponseWriter
+	hijackTracker
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (iw *idleWriter) reset() {
+	iw.timer.Reset(iw.timeout)
+	// Ignore the error, which is returned if the underlying ResponseWriter
+	// doesn't support deadlines.
+	_ = http.NewResponseController(iw.ResponseWriter).SetWriteDeadline(time.Now().Add(iw.timeout))
+}
+
+func (iw *idleWriter) Write(b []byte) (int, error) {
+	iw.reset()
+	return iw.ResponseWriter.Write(b)
+}
+
+func (iw *idleWriter) Flush() {
+	iw.reset()
+	http.NewResponseController(iw.ResponseWriter).Flush()
+}
+
+// Hijack stops the idle timer, so that the request context isn't canceled
+// while the hijacked connection (such as a WebSocket) is in use, and clears
+// the write deadline set by the last reset.
+func (iw *idleWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := iw.hijack(iw.ResponseWriter)
+	if err == nil {
+		iw.timer.Stop()
+		conn.SetWriteDeadline(time.Time{})
+	}
+	return conn, rw, err
+}
+
+func (iw *idleWriter) Unwrap() http.ResponseWriter {
+	return iw.ResponseWriter
+}
+
+// ErrHandlerTimeout is the cause of the request context being canceled by the
+// Timeout middleware. It can be retrieved using context.Cause.
+var ErrHandlerTimeout = errors.New("flow: handler timeout exceeded")
+
+// Timeout is middleware which limits the time a handler has to produce its
+// response, like http.TimeoutHandler, but with a configurable response. Use
+// its Middleware method with Use:
+//
+//	timeout := &flow.Timeout{
+//		Duration:  5 * time.Second,
+//		Status:    http.StatusGatewayTimeout,
+//		OnTimeout: func(r *http.Request) { log.Printf("timed out: %s", r.URL) },
+//	}
+//	mux.Use(timeout.Middleware)
+//
+// The handler's response is buffered until it returns, so that it can be
+// replaced if the timeout fires first. The request context is canceled with
+// the cause ErrHandlerTimeout when that happens, and any later writes by the
+// handler return http.ErrHandlerTimeout. Because of the buffering, handlers
+// can't flush the response or hijack the connection, so it shouldn't be used
+// for streaming routes (see IdleTimeout).
+type Timeout struct {
+	// Duration is the time limit for the handler.
+	Duration time.Duration
+
+	// Status is the status code of the response sent when the handler times
+	// out. If it is zero, 503 Service Unavailable is used. 504 Gateway Timeout
+	// is often more appropriate for handlers which are waiting on another
+	// service.
+	Status int
+
+	// Body is the plain-text body of the response sent when the handler
+	// times out. If it is empty, the status text for Status is used.
+	Body string
+
+	// OnTimeout, if it is set, is called before the timeout response is sent.
+	// It can be used to log the timeout or to cancel work which doesn't
+	// watch the request context.
+	OnTimeout func(r *http.Request)
+}
+
+// Middleware applies the timeout to next.
+func (t *Timeout) Middleware(next http.Handler) http.Handler {
+	status := t.Status
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+
+	body := t.Body
+	if body == "" {
+		body = http.StatusText(status)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeoutCause(r.Context(), t.Duration, ErrHandlerTimeout)
+		defer cancel()
+		r = r.WithContext(ctx)
+
+		tw := &timeoutWriter{ctx: ctx, header: make(http.Header)}
+		done := make(chan struct{})
+		panicked := make(chan any, 1)
+
+		go func() {
+			defer func() {
+				if v := recover(); v != nil {
+					panicked <- v
+				}
+			}()
+			next.ServeHTTP(tw, r)
+			close(done)
+		}()
+
+		select {
+		case v := <-panicked:
+			panic(v)
+
+		case <-done:
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+
+			// The handler may have returned after the deadline, with some of
+			// its writes rejected.
+			if tw.timedOut {
+				break
+			}
+
+			for key, values := range tw.header {
+				w.Header()[key] = values
+			}
+			if tw.status == 0 {
+				tw.status = http.StatusOK
+			}
+			w.WriteHeader(tw.status)
+			w.Write(tw.body.Bytes())
+			return
+
+		case <-ctx.Done():
+			tw.mu.Lock()
+			defer tw.mu.Unlock()
+
+			tw.timedOut = true
+		}
+
+		// If the client has gone away, there's no one to send the timeout
+		// response to.
+		if context.Cause(ctx) != ErrHandlerTimeout {
+			return
+		}
+
+		if t.OnTimeout != nil {
+			t.OnTimeout(r)
+		}
+		http.Error(w, body, status)
+	})
+}
+
+// timeoutWriter buffers the response from a handler run by Timeout.
+type timeoutWriter struct {
+	ctx      context.Context
+	mu       sync.Mutex
+	header   http.Header
+	body     bytes.Buffer
+	status   int
+	timedOut bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.header
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.ctx.Err() != nil {
+		tw.timedOut = true
+		return 0, http.ErrHandlerTimeout
+	}
+	if tw.status == 0 {
+		tw.status = http.StatusOK
+	}
+	return tw.body.Write(b)
+}
+
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut || tw.ctx.Err() != nil {
+		tw.timedOut = true
+		return
+	}
+	if tw.status != 0 {
+		return
+	}
+	tw.status = status
+}
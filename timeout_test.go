@@ -0,0 +1,183 @@
+package flow
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdleTimeout(t *testing.T) {
+	var tests = []struct {
+		Name    string
+		Handler http.HandlerFunc
+
+		ExpectedCause error
+	}{
+		{
+			Name: "writes keep request alive",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				for i := 0; i < 5; i++ {
+					time.Sleep(20 * time.Millisecond)
+					w.Write([]byte("data\n"))
+					w.(http.Flusher).Flush()
+				}
+			},
+			ExpectedCause: nil,
+		},
+		{
+			Name: "idle handler is canceled",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("data\n"))
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+			},
+			ExpectedCause: ErrIdleTimeout,
+		},
+	}
+
+	for _, test := range tests {
+		var cause error
+
+		m := New()
+		m.Use(IdleTimeout(50 * time.Millisecond))
+		m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+			test.Handler(w, r)
+			cause = context.Cause(r.Context())
+		}, "GET")
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+		if !errors.Is(cause, test.ExpectedCause) {
+			t.Errorf("%s: expected cause %v but was %v", test.Name, test.ExpectedCause, cause)
+		}
+	}
+}
+
+func TestIdleTimeoutFlush(t *testing.T) {
+	logging := &Logging{Access: slog.New(slog.NewTextHandler(io.Discard, nil))}
+
+	m := New()
+	m.Use(logging.Middleware, IdleTimeout(time.Second))
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("data\n"))
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	}, "GET")
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+
+	if !rr.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	writeErrs := make(chan error, 1)
+
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		if cause := context.Cause(r.Context()); cause != ErrHandlerTimeout {
+			t.Errorf("expected cause %v but got %v", ErrHandlerTimeout, cause)
+		}
+		_, err := w.Write([]byte("too late"))
+		writeErrs <- err
+	}
+
+	var timedOut []string
+
+	var tests = []struct {
+		Name    string
+		Timeout *Timeout
+		Handler http.HandlerFunc
+
+		ExpectedStatus int
+		ExpectedBody   string
+		ExpectedHeader string
+	}{
+		{
+			Name:    "fast handler",
+			Timeout: &Timeout{Duration: time.Second},
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Test", "yes")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("created"))
+			},
+			ExpectedStatus: http.StatusCreated,
+			ExpectedBody:   "created",
+			ExpectedHeader: "yes",
+		},
+		{
+			Name:           "default response",
+			Timeout:        &Timeout{Duration: 10 * time.Millisecond},
+			Handler:        slow,
+			ExpectedStatus: http.StatusServiceUnavailable,
+			ExpectedBody:   "Service Unavailable\n",
+		},
+		{
+			Name: "custom response",
+			Timeout: &Timeout{
+				Duration:  10 * time.Millisecond,
+				Status:    http.StatusGatewayTimeout,
+				Body:      "upstream too slow",
+				OnTimeout: func(r *http.Request) { timedOut = append(timedOut, r.URL.Path) },
+			},
+			Handler:        slow,
+			ExpectedStatus: http.StatusGatewayTimeout,
+			ExpectedBody:   "upstream too slow\n",
+		},
+	}
+
+	for _, test := range tests {
+		m := New()
+		m.Use(test.Timeout.Middleware)
+		m.HandleFunc("/test", test.Handler, "GET")
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("GET", "/test", nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but was %d", test.Name, test.ExpectedStatus, rr.Code)
+		}
+		if rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s: expected body %q but was %q", test.Name, test.ExpectedBody, rr.Body.String())
+		}
+		if header := rr.Header().Get("X-Test"); header != test.ExpectedHeader {
+			t.Errorf("%s: expected X-Test header %q but was %q", test.Name, test.ExpectedHeader, header)
+		}
+
+		if test.ExpectedStatus != http.StatusCreated {
+			if err := <-writeErrs; !errors.Is(err, http.ErrHandlerTimeout) {
+				t.Errorf("%s: expected write after timeout to return %v but got %v", test.Name, http.ErrHandlerTimeout, err)
+			}
+		}
+	}
+
+	if len(timedOut) != 1 || timedOut[0] != "/test" {
+		t.Errorf("expected OnTimeout to be called once but got %v", timedOut)
+	}
+}
+
+func TestTimeoutPanic(t *testing.T) {
+	timeout := &Timeout{Duration: time.Second}
+	h := timeout.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	defer func() {
+		if v := recover(); v != "boom" {
+			t.Errorf("expected the handler's panic to be propagated but got %v", v)
+		}
+	}()
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
@@ -0,0 +1,110 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type locationContextKey struct{}
+
+// A ZoneSource extracts the name of a client's preferred time zone (such as
+// "Europe/London") from a request. It should return the empty string if the
+// request doesn't specify one.
+type ZoneSource func(r *http.Request) string
+
+// ZoneFromHeader returns a ZoneSource which reads the time zone name from the
+// given request header, such as "Time-Zone".
+func ZoneFromHeader(name string) ZoneSource {
+	return func(r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// ZoneFromCookie returns a ZoneSource which reads the time zone name from the
+// cookie with the given name.
+func ZoneFromCookie(name string) ZoneSource {
+	return func(r *http.Request) string {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// ZoneFromParam returns a ZoneSource which reads the time zone name from the
+// named route parameter, or from the URL query string if the route doesn't
+// have a parameter with that name.
+func ZoneFromParam(name string) ZoneSource {
+	return func(r *http.Request) string {
+		if zone := Param(r.Context(), name); zone != "" {
+			return zone
+		}
+		return r.URL.Query().Get(name)
+	}
+}
+
+// TimeZone returns middleware which resolves the client's preferred time zone
+// once per request and stores it in the request context (see Location). The
+// sources are tried in order, and the first one which returns the name of a
+// valid IANA time zone wins. If none do, the fallback location is used (or UTC
+// if fallback is nil).
+func TimeZone(fallback *time.Location, sources ...ZoneSource) func(http.Handler) http.Handler {
+	if fallback == nil {
+		fallback = time.UTC
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			loc := fallback
+
+			for _, source := range sources {
+				if l, ok := loadLocation(source(r)); ok {
+					loc = l
+					break
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), locationContextKey{}, loc)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// Location returns the time zone resolved by the TimeZone middleware, or UTC
+// if the middleware wasn't used. It's intended for handlers which format dates
+// and times for the client, for example t.In(flow.Location(r.Context())).
+func Location(ctx context.Context) *time.Location {
+	loc, ok := ctx.Value(locationContextKey{}).(*time.Location)
+	if !ok {
+		return time.UTC
+	}
+	return loc
+}
+
+// locations caches the result of successful time.LoadLocation calls, which
+// otherwise read from the time zone database every time. Unknown names aren't
+// cached, so the size of the cache is bounded by the size of the database.
+var locations sync.Map
+
+func loadLocation(name string) (*time.Location, bool) {
+	// "Local" is accepted by time.LoadLocation, but the server's local time zone
+	// isn't a meaningful client preference.
+	if name == "" || name == "Local" {
+		return nil, false
+	}
+
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), true
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, false
+	}
+
+	locations.Store(name, loc)
+	return loc, true
+}
@@ -0,0 +1,69 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTimeZone(t *testing.T) {
+	var zone string
+
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	m := New()
+	m.Use(TimeZone(paris, ZoneFromHeader("Time-Zone"), ZoneFromCookie("tz"), ZoneFromParam("tz")))
+	m.HandleFunc("/...", func(w http.ResponseWriter, r *http.Request) {
+		zone = Location(r.Context()).String()
+	}, "GET")
+	m.HandleFunc("/in/:tz", func(w http.ResponseWriter, r *http.Request) {
+		zone = Location(r.Context()).String()
+	}, "POST")
+
+	var tests = []struct {
+		Method string
+		Path   string
+		Header string
+		Cookie string
+
+		ExpectedZone string
+	}{
+		{"GET", "/", "", "", "Europe/Paris"},
+		{"GET", "/", "America/New_York", "", "America/New_York"},
+		{"GET", "/", "", "Asia/Tokyo", "Asia/Tokyo"},
+		{"GET", "/", "Not/AZone", "Asia/Tokyo", "Asia/Tokyo"},
+		{"GET", "/", "America/New_York", "Asia/Tokyo", "America/New_York"},
+		{"GET", "/?tz=Australia/Sydney", "", "", "Australia/Sydney"},
+		{"GET", "/?tz=Local", "", "", "Europe/Paris"},
+		{"POST", "/in/UTC", "", "", "UTC"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(test.Method, test.Path, nil)
+		if test.Header != "" {
+			r.Header.Set("Time-Zone", test.Header)
+		}
+		if test.Cookie != "" {
+			r.AddCookie(&http.Cookie{Name: "tz", Value: test.Cookie})
+		}
+
+		zone = ""
+		m.ServeHTTP(httptest.NewRecorder(), r)
+
+		if zone != test.ExpectedZone {
+			t.Errorf("%s %s: expected zone %q but was %q", test.Method, test.Path, test.ExpectedZone, zone)
+		}
+	}
+}
+
+func TestLocationDefault(t *testing.T) {
+	r := httptest.NewRequest("GET", "/", nil)
+
+	if loc := Location(r.Context()); loc != time.UTC {
+		t.Errorf("expected UTC but got %v", loc)
+	}
+}
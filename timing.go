@@ -0,0 +1,51 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type timingContextKey struct{}
+
+// wrapRoute wraps a route's handler with the middleware registered with Use,
+// adding timing instrumentation if MiddlewareTiming is set.
+func (m *Mux) wrapRoute(pattern string, handler http.Handler) http.Handler {
+	if m.MiddlewareTiming == nil {
+		return m.wrap(handler)
+	}
+
+	report := m.MiddlewareTiming
+	names := middlewareNames(m.middlewares)
+	n := len(m.middlewares)
+
+	// Each layer records the total time spent in it and everything after it.
+	// Layer n is the handler itself.
+	wrapped := timedLayer(n, handler)
+	for i := n - 1; i >= 0; i-- {
+		wrapped = timedLayer(i, m.middlewares[i](wrapped))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		totals := make([]time.Duration, n+1)
+		ctx := context.WithValue(r.Context(), timingContextKey{}, totals)
+
+		wrapped.ServeHTTP(w, r.WithContext(ctx))
+
+		for i, name := range names {
+			report(pattern, name, totals[i]-totals[i+1])
+		}
+		report(pattern, "handler", totals[n])
+	})
+}
+
+func timedLayer(i int, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+
+		if totals, ok := r.Context().Value(timingContextKey{}).([]time.Duration); ok {
+			totals[i] = time.Since(start)
+		}
+	})
+}
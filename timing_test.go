@@ -0,0 +1,72 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func slowMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func fastMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+	})
+}
+
+func TestMiddlewareTiming(t *testing.T) {
+	timings := map[string]time.Duration{}
+	var routes []string
+
+	m := New()
+	m.MiddlewareTiming = func(route, middleware string, d time.Duration) {
+		routes = append(routes, route)
+		timings[middleware] = d
+	}
+	m.Use(slowMiddleware, fastMiddleware)
+	m.HandleFunc("/users/:id", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		if Param(r.Context(), "id") != "1" {
+			t.Errorf("expected parameters to be available to the handler")
+		}
+	}, "GET")
+
+	m.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/users/1", nil))
+
+	if len(timings) != 3 {
+		t.Fatalf("expected 3 timings but got %v", timings)
+	}
+
+	for _, route := range routes {
+		if route != "/users/:id" {
+			t.Errorf("unexpected route %q", route)
+		}
+	}
+
+	for name, d := range timings {
+		switch {
+		case strings.HasSuffix(name, "slowMiddleware"):
+			// The handler's 50ms shouldn't be included.
+			if d < 20*time.Millisecond || d >= 70*time.Millisecond {
+				t.Errorf("unexpected duration %v for slow middleware", d)
+			}
+		case strings.HasSuffix(name, "fastMiddleware"):
+			if d >= 20*time.Millisecond {
+				t.Errorf("unexpected duration %v for fast middleware", d)
+			}
+		case name == "handler":
+			if d < 50*time.Millisecond {
+				t.Errorf("unexpected duration %v for handler", d)
+			}
+		default:
+			t.Errorf("unexpected middleware name %q", name)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package flow
+
+import (
+	"net/http"
+	"strings"
+)
+
+// RequestTransform describes changes to make to requests before they reach the
+// handler, such as rewriting headers, injecting credentials for an upstream
+// service or normalizing the query string. Because it's plain data, it can be
+// loaded from configuration (the JSON form uses the field names in snake
+// case). The changes are applied in the order of the fields.
+type RequestTransform struct {
+	// DeleteHeaders lists headers to remove.
+	DeleteHeaders []string `json:"delete_headers,omitempty"`
+	// SetHeaders sets headers, replacing any existing values.
+	SetHeaders map[string]string `json:"set_headers,omitempty"`
+	// AddHeaders adds header values, keeping any existing values.
+	AddHeaders map[string]string `json:"add_headers,omitempty"`
+
+	// LowercaseQueryKeys converts the names of query string parameters to
+	// lower case, merging the values of parameters which differ only in case.
+	LowercaseQueryKeys bool `json:"lowercase_query_keys,omitempty"`
+	// DeleteQuery lists query string parameters to remove.
+	DeleteQuery []string `json:"delete_query,omitempty"`
+	// SetQuery sets query string parameters, replacing any existing values.
+	SetQuery map[string]string `json:"set_query,omitempty"`
+}
+
+// Transform registers a RequestTransform for the routes of the Mux (or group)
+// that are added afterwards. It runs after the route has been matched, at the
+// same point in the chain as middleware registered with Use at the same time.
+// Because the request is copied before it is changed, middleware which runs
+// earlier doesn't see the changes.
+func (m *Mux) Transform(t RequestTransform) {
+	m.Use(t.Middleware)
+}
+
+// Middleware returns middleware which applies the transform.
+func (t RequestTransform) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, t.apply(r))
+	})
+}
+
+func (t RequestTransform) apply(r *http.Request) *http.Request {
+	r = r.Clone(r.Context())
+
+	for _, name := range t.DeleteHeaders {
+		r.Header.Del(name)
+	}
+	for name, value := range t.SetHeaders {
+		r.Header.Set(name, value)
+	}
+	for name, value := range t.AddHeaders {
+		r.Header.Add(name, value)
+	}
+
+	if t.LowercaseQueryKeys || len(t.DeleteQuery) > 0 || len(t.SetQuery) > 0 {
+		query := r.URL.Query()
+
+		if t.LowercaseQueryKeys {
+			for key, values := range query {
+				if lower := strings.ToLower(key); lower != key {
+					delete(query, key)
+					query[lower] = append(query[lower], values...)
+				}
+			}
+		}
+		for _, key := range t.DeleteQuery {
+			query.Del(key)
+		}
+		for key, value := range t.SetQuery {
+			query.Set(key, value)
+		}
+
+		r.URL.RawQuery = query.Encode()
+	}
+
+	return r
+}
@@ -0,0 +1,72 @@
+package flow
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestTransform(t *testing.T) {
+	var received *http.Request
+	var outerAuth string
+
+	hf := func(w http.ResponseWriter, r *http.Request) {
+		received = r
+	}
+
+	var transform RequestTransform
+	err := json.NewDecoder(strings.NewReader(`{
+		"delete_headers": ["Cookie"],
+		"set_headers": {"Authorization": "Bearer upstream-token"},
+		"add_headers": {"X-Forwarded-Service": "gateway"},
+		"lowercase_query_keys": true,
+		"delete_query": ["debug"],
+		"set_query": {"version": "2"}
+	}`)).Decode(&transform)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New()
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+			outerAuth = r.Header.Get("Authorization")
+		})
+	})
+	m.Group(func(m *Mux) {
+		m.Transform(transform)
+		m.HandleFunc("/api/users", hf, "GET")
+	})
+	m.HandleFunc("/other", hf, "GET")
+
+	r := httptest.NewRequest("GET", "/api/users?Page=2&page=3&debug=1&version=1", nil)
+	r.Header.Set("Authorization", "Bearer client-token")
+	r.Header.Set("Cookie", "session=abc")
+	m.ServeHTTP(httptest.NewRecorder(), r)
+
+	if auth := received.Header.Get("Authorization"); auth != "Bearer upstream-token" {
+		t.Errorf("expected Authorization to be replaced but was %q", auth)
+	}
+	if cookie := received.Header.Get("Cookie"); cookie != "" {
+		t.Errorf("expected Cookie to be removed but was %q", cookie)
+	}
+	if svc := received.Header.Get("X-Forwarded-Service"); svc != "gateway" {
+		t.Errorf("expected X-Forwarded-Service to be added but was %q", svc)
+	}
+	if query := received.URL.RawQuery; query != "page=3&page=2&version=2" && query != "page=2&page=3&version=2" {
+		t.Errorf("unexpected query %q", query)
+	}
+	if outerAuth != "Bearer client-token" {
+		t.Errorf("expected the original request to be unchanged but Authorization was %q", outerAuth)
+	}
+
+	r = httptest.NewRequest("GET", "/other?debug=1", nil)
+	m.ServeHTTP(httptest.NewRecorder(), r)
+
+	if received.URL.RawQuery != "debug=1" {
+		t.Errorf("expected routes outside the group not to be transformed; query was %q", received.URL.RawQuery)
+	}
+}
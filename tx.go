@@ -0,0 +1,175 @@
+package flow
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"net"
+	"net/http"
+)
+
+// UnitOfWork begins, commits and rolls back transactions of type T for the
+// Transaction middleware. SQLUnitOfWork implements it for database/sql, and
+// other stores can be supported by implementing it for their own transaction
+// type.
+type UnitOfWork[T any] interface {
+	Begin(ctx context.Context) (T, error)
+	Commit(tx T) error
+	Rollback(tx T) error
+}
+
+type txContextKey struct{}
+
+// Transaction returns middleware which runs each request in a transaction. The
+// transaction is begun before the handler is called, and is available to it
+// through Tx. It is committed when the handler sends a 2xx or 3xx response
+// status, before the status is written, so that a failed commit can still be
+// reported to the client. Any other status, or a panic in the handler, rolls
+// the transaction back (and the panic continues, so it can be handled by
+// Recover). For example:
+//
+//	mux.Use(flow.Transaction[*sql.Tx](&flow.SQLUnitOfWork{DB: db}, nil))
+//	...
+//	tx, _ := flow.Tx[*sql.Tx](r.Context())
+//
+// Errors from beginning or committing the transaction are passed to
+// errorHandler, or DefaultErrorHandler if it is nil. A handler which doesn't
+// write a response commits the transaction, as it would send a 200 OK, but one
+// which hijacks the connection (and so sends no status through the
+// ResponseWriter) rolls it back; such handlers should manage their own
+// transactions.
+func Transaction[T any](uow UnitOfWork[T], errorHandler ErrorHandler) func(http.Handler) http.Handler {
+	if errorHandler == nil {
+		errorHandler = DefaultErrorHandler
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tx, err := uow.Begin(r.Context())
+			if err != nil {
+				errorHandler(w, r, err)
+				return
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), txContextKey{}, tx))
+			tw := &txWriter{ResponseWriter: w}
+			tw.finish = func(status int) error {
+				if status >= 200 && status < 400 {
+					err := uow.Commit(tx)
+					if err != nil {
+						errorHandler(w, r, err)
+					}
+					return err
+				}
+
+				uow.Rollback(tx)
+				return nil
+			}
+
+			defer func() {
+				if !tw.finished {
+					if v := recover(); v != nil {
+						tw.finished = true
+						uow.Rollback(tx)
+						panic(v)
+					}
+				}
+			}()
+
+			next.ServeHTTP(tw, r)
+
+			if !tw.finished {
+				tw.finished = true
+				if tw.hijacked {
+					uow.Rollback(tx)
+				} else {
+					tw.finish(http.StatusOK)
+				}
+			}
+		})
+	}
+}
+
+// Tx retrieves the transaction begun by the Transaction middleware. It returns
+// false if there isn't one, or if it isn't of type T.
+func Tx[T any](ctx context.Context) (T, bool) {
+	tx, ok := ctx.Value(txContextKey{}).(T)
+	return tx, ok
+}
+
+// SQLUnitOfWork is a UnitOfWork for database/sql transactions.
+type SQLUnitOfWork struct {
+	DB *sql.DB
+
+	// Options are used when beginning each transaction. If they are nil,
+	// the driver's defaults are used.
+	Options *sql.TxOptions
+}
+
+func (u *SQLUnitOfWork) Begin(ctx context.Context) (*sql.Tx, error) {
+	return u.DB.BeginTx(ctx, u.Options)
+}
+
+func (u *SQLUnitOfWork) Commit(tx *sql.Tx) error {
+	return tx.Commit()
+}
+
+func (u *SQLUnitOfWork) Rollback(tx *sql.Tx) error {
+	return tx.Rollback()
+}
+
+// txWriter commits or rolls back the transaction for a request when the
+// response status is written.
+type txWriter struct {
+	http.ResponseWriter
+	hijackTracker
+	finish   func(status int) error
+	finished bool
+
+	// err is the error from committing the transaction, after which the
+	// handler's response is discarded.
+	err error
+}
+
+func (tw *txWriter) WriteHeader(status int) {
+	// Informational responses don't end the request.
+	if status < 200 || tw.finished {
+		if tw.err == nil {
+			tw.ResponseWriter.WriteHeader(status)
+		}
+		return
+	}
+
+	tw.finished = true
+	if tw.err = tw.finish(status); tw.err != nil {
+		return
+	}
+	tw.ResponseWriter.WriteHeader(status)
+}
+
+func (tw *txWriter) Write(b []byte) (int, error) {
+	if !tw.finished {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.err != nil {
+		return 0, tw.err
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+func (tw *txWriter) Flush() {
+	if !tw.finished {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.err == nil {
+		http.NewResponseController(tw.ResponseWriter).Flush()
+	}
+}
+
+func (tw *txWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return tw.hijack(tw.ResponseWriter)
+}
+
+func (tw *txWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
@@ -0,0 +1,245 @@
+package flow
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testTx struct {
+	committed  bool
+	rolledBack bool
+}
+
+type testUnitOfWork struct {
+	beginErr  error
+	commitErr error
+	last      *testTx
+}
+
+func (u *testUnitOfWork) Begin(ctx context.Context) (*testTx, error) {
+	if u.beginErr != nil {
+		return nil, u.beginErr
+	}
+	u.last = &testTx{}
+	return u.last, nil
+}
+
+func (u *testUnitOfWork) Commit(tx *testTx) error {
+	if u.commitErr != nil {
+		return u.commitErr
+	}
+	tx.committed = true
+	return nil
+}
+
+func (u *testUnitOfWork) Rollback(tx *testTx) error {
+	tx.rolledBack = true
+	return nil
+}
+
+func TestTransaction(t *testing.T) {
+	var tests = []struct {
+		Name      string
+		BeginErr  error
+		CommitErr error
+		Handler   http.HandlerFunc
+
+		ExpectedStatus     int
+		ExpectedBody       string
+		ExpectedCommitted  bool
+		ExpectedRolledBack bool
+	}{
+		{
+			Name: "success",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("created"))
+			},
+			ExpectedStatus:    http.StatusCreated,
+			ExpectedBody:      "created",
+			ExpectedCommitted: true,
+		},
+		{
+			Name: "redirect",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/elsewhere", http.StatusSeeOther)
+			},
+			ExpectedStatus:    http.StatusSeeOther,
+			ExpectedBody:      "",
+			ExpectedCommitted: true,
+		},
+		{
+			Name: "implicit status",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			},
+			ExpectedStatus:    http.StatusOK,
+			ExpectedBody:      "ok",
+			ExpectedCommitted: true,
+		},
+		{
+			Name:              "no response",
+			Handler:           func(w http.ResponseWriter, r *http.Request) {},
+			ExpectedStatus:    http.StatusOK,
+			ExpectedBody:      "",
+			ExpectedCommitted: true,
+		},
+		{
+			Name: "error",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "invalid", http.StatusUnprocessableEntity)
+			},
+			ExpectedStatus:     http.StatusUnprocessableEntity,
+			ExpectedBody:       "invalid\n",
+			ExpectedRolledBack: true,
+		},
+		{
+			Name: "panic",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				panic("boom")
+			},
+			ExpectedStatus:     http.StatusInternalServerError,
+			ExpectedBody:       "Internal Server Error\n",
+			ExpectedRolledBack: true,
+		},
+		{
+			Name:      "failed commit",
+			CommitErr: errors.New("serialization failure"),
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("ok"))
+			},
+			ExpectedStatus: http.StatusInternalServerError,
+			ExpectedBody:   "Internal Server Error\n",
+		},
+		{
+			Name:     "failed begin",
+			BeginErr: errors.New("connection refused"),
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				t.Error("handler called after failed begin")
+			},
+			ExpectedStatus: http.StatusInternalServerError,
+			ExpectedBody:   "Internal Server Error\n",
+		},
+	}
+
+	for _, test := range tests {
+		uow := &testUnitOfWork{beginErr: test.BeginErr, commitErr: test.CommitErr}
+
+		m := New()
+		m.Use(Recover(nil))
+		m.Use(Transaction[*testTx](uow, nil))
+		m.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+			if tx, ok := Tx[*testTx](r.Context()); !ok || tx != uow.last {
+				t.Errorf("%s: expected Tx to return the transaction", test.Name)
+			}
+			test.Handler(w, r)
+		}, "POST")
+
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("POST", "/test", nil))
+
+		if rr.Code != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but was %d", test.Name, test.ExpectedStatus, rr.Code)
+		}
+		if rr.Body.String() != test.ExpectedBody {
+			t.Errorf("%s: expected body %q but was %q", test.Name, test.ExpectedBody, rr.Body.String())
+		}
+
+		var committed, rolledBack bool
+		if uow.last != nil {
+			committed, rolledBack = uow.last.committed, uow.last.rolledBack
+		}
+		if committed != test.ExpectedCommitted {
+			t.Errorf("%s: expected committed %t but was %t", test.Name, test.ExpectedCommitted, committed)
+		}
+		if rolledBack != test.ExpectedRolledBack {
+			t.Errorf("%s: expected rolled back %t but was %t", test.Name, test.ExpectedRolledBack, rolledBack)
+		}
+	}
+}
+
+func TestTransactionFlush(t *testing.T) {
+	uow := &testUnitOfWork{}
+
+	m := New()
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(unwrapWriter{w}, r)
+		})
+	}, Transaction[*testTx](uow, nil))
+	m.HandleFunc("/test", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+		w.(http.Flusher).Flush()
+	}, "POST")
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("POST", "/test", nil))
+
+	if !rr.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+	if !uow.last.committed {
+		t.Error("expected the transaction to be committed")
+	}
+}
+
+func TestTxWithoutTransaction(t *testing.T) {
+	if _, ok := Tx[*sql.Tx](context.Background()); ok {
+		t.Error("expected no transaction")
+	}
+}
+
+// testDriver is a database/sql driver which only records the outcome of
+// transactions.
+type testDriver struct {
+	outcomes chan string
+}
+
+func (d testDriver) Open(name string) (driver.Conn, error) { return testConn(d), nil }
+
+type testConn testDriver
+
+func (c testConn) Prepare(query string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c testConn) Close() error                              { return nil }
+func (c testConn) Begin() (driver.Tx, error)                 { return testDriverTx(c), nil }
+
+type testDriverTx testConn
+
+func (tx testDriverTx) Commit() error   { tx.outcomes <- "commit"; return nil }
+func (tx testDriverTx) Rollback() error { tx.outcomes <- "rollback"; return nil }
+
+func TestSQLUnitOfWork(t *testing.T) {
+	outcomes := make(chan string, 1)
+	sql.Register("flowtest", testDriver{outcomes: outcomes})
+
+	db, err := sql.Open("flowtest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := New()
+	m.Use(Transaction[*sql.Tx](&SQLUnitOfWork{DB: db}, nil))
+	m.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := Tx[*sql.Tx](r.Context()); !ok {
+			t.Error("expected Tx to return a *sql.Tx")
+		}
+	}, "POST")
+	m.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusConflict)
+	}, "POST")
+
+	for path, expected := range map[string]string{"/ok": "commit", "/fail": "rollback"} {
+		rr := httptest.NewRecorder()
+		m.ServeHTTP(rr, httptest.NewRequest("POST", path, nil))
+
+		if outcome := <-outcomes; outcome != expected {
+			t.Errorf("%s: expected %s but got %s", path, expected, outcome)
+		}
+	}
+}
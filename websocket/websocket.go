@@ -0,0 +1,519 @@
+// Package websocket is a small, dependency-free WebSocket (RFC 6455) server
+// for applications built with flow.
+//
+// An Upgrader performs the opening handshake, checking the request's origin,
+// negotiating a subprotocol and (optionally) authorizing the request, and
+// returns a Conn for exchanging messages. The Conn enforces a maximum message
+// size and can keep the connection alive with periodic pings:
+//
+//	u := &websocket.Upgrader{
+//		Origins:      []string{"https://example.com"},
+//		Subprotocols: []string{"chat.v2", "chat.v1"},
+//		PingInterval: 30 * time.Second,
+//	}
+//
+//	mux.Handle("/ws", u.Handler(func(c *websocket.Conn, r *http.Request) {
+//		for {
+//			typ, msg, err := c.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			c.WriteMessage(typ, msg)
+//		}
+//	}), "GET")
+//
+// Because an upgrade is an ordinary request until the handshake completes, the
+// handler can be protected with the same authentication middleware as any
+// other route. The Authorize hook is available for checks which depend on the
+// handshake itself, such as a token in the subprotocol list.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alexedwards/flow"
+)
+
+// MessageType is the type of a WebSocket message.
+type MessageType int
+
+// The message types, which are the same as the frame opcodes.
+const (
+	TextMessage   MessageType = 1
+	BinaryMessage MessageType = 2
+)
+
+const (
+	opContinuation = 0
+	opText         = 1
+	opBinary       = 2
+	opClose        = 8
+	opPing         = 9
+	opPong         = 10
+)
+
+// Close status codes from RFC 6455 section 7.4.1.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// closeNoStatus is the code reported when a close frame has no status code. It
+// must not be sent in a close frame.
+const closeNoStatus = 1005
+
+// DefaultMaxMessageSize is the maximum message size used when
+// Upgrader.MaxMessageSize is zero.
+const DefaultMaxMessageSize = 1 << 20
+
+// DefaultWriteTimeout is the write timeout used when Upgrader.WriteTimeout is
+// zero.
+const DefaultWriteTimeout = 10 * time.Second
+
+// ErrMessageTooBig is returned by ReadMessage when a message is larger than the
+// maximum message size. The connection is closed with CloseMessageTooBig.
+var ErrMessageTooBig = errors.New("websocket: message too big")
+
+// CloseError is returned by ReadMessage when the peer closes the connection.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: connection closed with code %d: %s", e.Code, e.Text)
+}
+
+// Upgrader upgrades HTTP requests to WebSocket connections.
+type Upgrader struct {
+	// Origins lists the origins (such as "https://example.com") which may
+	// open connections. If it is empty, only same-origin requests (where the
+	// host in the Origin header matches the request's Host) are allowed. The
+	// entry "*" allows any origin. Requests without an Origin header, which
+	// don't come from browsers, are always allowed.
+	Origins []string
+
+	// Subprotocols lists the supported subprotocols in order of preference.
+	// The first one which the client also offers is selected. If there is
+	// no overlap, the connection is made without a subprotocol.
+	Subprotocols []string
+
+	// MaxMessageSize is the maximum size of a received message in bytes. If
+	// it is zero, DefaultMaxMessageSize is used.
+	MaxMessageSize int64
+
+	// PingInterval enables keepalive when it is greater than zero: a ping is
+	// sent at this interval, and reads fail if nothing (including a pong) is
+	// received from the client for twice the interval.
+	PingInterval time.Duration
+
+	// WriteTimeout is the time allowed for writing each frame. A write which
+	// takes longer, because the client has stopped reading, fails and the
+	// connection can't be used any more. If it is zero, DefaultWriteTimeout
+	// is used, and if it is negative, writes never time out.
+	WriteTimeout time.Duration
+
+	// Authorize is an optional hook which is called after the handshake has
+	// been validated, but before the connection is upgraded. If it returns an
+	// error, the upgrade is refused with the status code from
+	// flow.StatusCode (403 Forbidden for a flow.HTTPError with that status,
+	// and so on).
+	Authorize func(r *http.Request) error
+}
+
+// Handler returns an http.Handler which upgrades requests and calls fn with
+// the connection. The connection is closed when fn returns.
+func (u *Upgrader) Handler(fn func(c *Conn, r *http.Request)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := u.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+
+		fn(c, r)
+	})
+}
+
+// Upgrade performs the WebSocket handshake. If the request isn't a valid
+// WebSocket handshake, or it is refused, Upgrade sends an error response and
+// returns an error.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	fail := func(status int, msg string) (*Conn, error) {
+		http.Error(w, msg, status)
+		return nil, errors.New("websocket: " + msg)
+	}
+
+	if r.Method != http.MethodGet {
+		return fail(http.StatusMethodNotAllowed, "handshake must use GET")
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") || !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return fail(http.StatusBadRequest, "not a websocket handshake")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return fail(http.StatusUpgradeRequired, "unsupported websocket version")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return fail(http.StatusBadRequest, "invalid Sec-WebSocket-Key")
+	}
+
+	if !u.checkOrigin(r) {
+		return fail(http.StatusForbidden, "origin not allowed")
+	}
+
+	if u.Authorize != nil {
+		if err := u.Authorize(r); err != nil {
+			status := flow.StatusCode(err)
+			return fail(status, http.StatusText(status))
+		}
+	}
+
+	subprotocol := u.selectSubprotocol(r)
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fail(http.StatusInternalServerError, "connection can't be hijacked")
+	}
+
+	// Clear any deadlines set by the server, such as its WriteTimeout. The
+	// Conn sets its own write deadline for each frame.
+	conn.SetDeadline(time.Time{})
+
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	brw.WriteString("Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n")
+	if subprotocol != "" {
+		brw.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	brw.WriteString("\r\n")
+
+	if err := brw.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c := &Conn{
+		conn:           conn,
+		br:             brw.Reader,
+		subprotocol:    subprotocol,
+		maxMessageSize: u.MaxMessageSize,
+		pingInterval:   u.PingInterval,
+		writeTimeout:   u.WriteTimeout,
+		done:           make(chan struct{}),
+	}
+	if c.maxMessageSize <= 0 {
+		c.maxMessageSize = DefaultMaxMessageSize
+	}
+	if c.writeTimeout == 0 {
+		c.writeTimeout = DefaultWriteTimeout
+	}
+
+	if c.pingInterval > 0 {
+		c.extendReadDeadline()
+		go c.keepalive()
+	}
+
+	return c, nil
+}
+
+func (u *Upgrader) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+
+	if len(u.Origins) == 0 {
+		parsed, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(parsed.Host, r.Host)
+	}
+
+	for _, allowed := range u.Origins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (u *Upgrader) selectSubprotocol(r *http.Request) string {
+	var offered []string
+	for _, v := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, p := range strings.Split(v, ",") {
+			offered = append(offered, strings.TrimSpace(p))
+		}
+	}
+
+	for _, supported := range u.Subprotocols {
+		for _, p := range offered {
+			if p == supported {
+				return supported
+			}
+		}
+	}
+
+	return ""
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// Conn is a WebSocket connection. ReadMessage must only be called from one
+// goroutine at a time, but WriteMessage and Close may be called concurrently
+// with each other and with ReadMessage.
+type Conn struct {
+	conn           net.Conn
+	br             *bufio.Reader
+	subprotocol    string
+	maxMessageSize int64
+	pingInterval   time.Duration
+	writeTimeout   time.Duration
+
+	writeMu   sync.Mutex
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// Subprotocol returns the negotiated subprotocol, or the empty string if none
+// was selected.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// ReadMessage reads the next text or binary message from the client. Pings
+// are answered automatically. If the client closes the connection, the
+// returned error is a *CloseError, and the close is acknowledged with the
+// client's status code.
+func (c *Conn) ReadMessage() (MessageType, []byte, error) {
+	var typ MessageType
+	var message []byte
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			c.writeFrame(opPong, payload)
+			continue
+		case opPong:
+			continue
+		case opClose:
+			closeErr := &CloseError{Code: closeNoStatus}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Text = string(payload[2:])
+			}
+			c.closeWith(closeErr.Code, "")
+			return 0, nil, closeErr
+		case opText, opBinary:
+			if message != nil {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			typ = MessageType(opcode)
+		case opContinuation:
+			if message == nil {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		if int64(len(message)+len(payload)) > c.maxMessageSize {
+			c.closeWith(CloseMessageTooBig, "")
+			return 0, nil, ErrMessageTooBig
+		}
+
+		if message == nil {
+			message = []byte{}
+		}
+		message = append(message, payload...)
+
+		if fin {
+			if typ == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidPayload, "invalid UTF-8")
+			}
+			return typ, message, nil
+		}
+	}
+}
+
+// WriteMessage sends a text or binary message to the client.
+func (c *Conn) WriteMessage(typ MessageType, data []byte) error {
+	if typ != TextMessage && typ != BinaryMessage {
+		return errors.New("websocket: invalid message type")
+	}
+
+	return c.writeFrame(byte(typ), data)
+}
+
+// Close sends a normal close frame and closes the underlying connection.
+func (c *Conn) Close() error {
+	return c.closeWith(CloseNormal, "")
+}
+
+func (c *Conn) closeWith(code int, text string) error {
+	var err error
+
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		var payload []byte
+		if code != closeNoStatus {
+			payload = binary.BigEndian.AppendUint16(nil, uint16(code))
+			payload = append(payload, text...)
+		}
+		c.writeFrame(opClose, payload)
+
+		err = c.conn.Close()
+	})
+
+	return err
+}
+
+func (c *Conn) fail(code int, text string) error {
+	c.closeWith(code, text)
+	return errors.New("websocket: " + text)
+}
+
+func (c *Conn) keepalive() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+			if err := c.writeFrame(opPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *Conn) extendReadDeadline() {
+	if c.pingInterval > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(2 * c.pingInterval))
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	c.extendReadDeadline()
+
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0f
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, c.fail(CloseProtocolError, "client frames must be masked")
+	}
+
+	length := uint64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= opClose && (length > 125 || !fin) {
+		return false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+	}
+
+	if length > uint64(c.maxMessageSize) {
+		c.closeWith(CloseMessageTooBig, "")
+		return false, 0, nil, ErrMessageTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	header := []byte{0x80 | opcode}
+
+	switch n := len(payload); {
+	case n <= 125:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if c.writeTimeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+
+	_, err := (&net.Buffers{header, payload}).WriteTo(c.conn)
+	return err
+}
@@ -0,0 +1,315 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alexedwards/flow"
+)
+
+// testClient is a minimal WebSocket client for exercising the server.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	br   *bufio.Reader
+	resp *http.Response
+}
+
+func dial(t *testing.T, ts *httptest.Server, header http.Header) *testClient {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", ts.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	req, _ := http.NewRequest("GET", ts.URL+"/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	if err := req.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testClient{t: t, conn: conn, br: br, resp: resp}
+}
+
+func (c *testClient) writeFrame(fin bool, opcode byte, payload []byte) {
+	b0 := opcode
+	if fin {
+		b0 |= 0x80
+	}
+
+	frame := []byte{b0}
+	switch n := len(payload); {
+	case n <= 125:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(n))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(n))
+	}
+
+	mask := [4]byte{1, 2, 3, 4}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	if _, err := c.conn.Write(frame); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *testClient) readFrame() (byte, []byte) {
+	c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var header [2]byte
+	if _, err := io.ReadFull(c.br, header[:]); err != nil {
+		c.t.Fatal(err)
+	}
+
+	length := int(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		io.ReadFull(c.br, ext[:])
+		length = int(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		c.t.Fatal(err)
+	}
+
+	return header[0] & 0x0f, payload
+}
+
+func echoServer(t *testing.T, u *Upgrader) *httptest.Server {
+	m := flow.New()
+	m.Handle("/ws", u.Handler(func(c *Conn, r *http.Request) {
+		for {
+			typ, msg, err := c.ReadMessage()
+			if err != nil {
+				return
+			}
+			c.WriteMessage(typ, msg)
+		}
+	}), "GET")
+
+	ts := httptest.NewServer(m)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestEcho(t *testing.T) {
+	ts := echoServer(t, &Upgrader{Subprotocols: []string{"chat.v2", "chat.v1"}})
+
+	c := dial(t, ts, http.Header{"Sec-Websocket-Protocol": {"chat.v1, chat.v2"}})
+
+	if c.resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected status 101 but was %d", c.resp.StatusCode)
+	}
+
+	if accept := c.resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("unexpected Sec-WebSocket-Accept %q", accept)
+	}
+
+	if p := c.resp.Header.Get("Sec-WebSocket-Protocol"); p != "chat.v2" {
+		t.Errorf("expected subprotocol chat.v2 but was %q", p)
+	}
+
+	c.writeFrame(true, opText, []byte("hello"))
+	if op, payload := c.readFrame(); op != opText || string(payload) != "hello" {
+		t.Errorf("unexpected echo %d %q", op, payload)
+	}
+
+	// A fragmented message with a ping in the middle.
+	c.writeFrame(false, opBinary, []byte("abc"))
+	c.writeFrame(true, opPing, []byte("p"))
+	c.writeFrame(true, opContinuation, []byte("def"))
+
+	if op, payload := c.readFrame(); op != opPong || string(payload) != "p" {
+		t.Errorf("expected pong but got %d %q", op, payload)
+	}
+	if op, payload := c.readFrame(); op != opBinary || string(payload) != "abcdef" {
+		t.Errorf("unexpected echo %d %q", op, payload)
+	}
+
+	c.writeFrame(true, opClose, binary.BigEndian.AppendUint16(nil, CloseNormal))
+	if op, payload := c.readFrame(); op != opClose || binary.BigEndian.Uint16(payload) != CloseNormal {
+		t.Errorf("expected close frame but got %d %v", op, payload)
+	}
+}
+
+func TestHandshakeChecks(t *testing.T) {
+	u := &Upgrader{
+		Origins: []string{"https://allowed.example"},
+		Authorize: func(r *http.Request) error {
+			if r.URL.Query().Get("token") == "" && r.Header.Get("X-Token") != "ok" {
+				return flow.Abort(http.StatusUnauthorized)
+			}
+			return nil
+		},
+	}
+	ts := echoServer(t, u)
+
+	var tests = []struct {
+		Name   string
+		Header http.Header
+
+		ExpectedStatus int
+	}{
+		{"allowed", http.Header{"Origin": {"https://allowed.example"}, "X-Token": {"ok"}}, http.StatusSwitchingProtocols},
+		{"no origin", http.Header{"X-Token": {"ok"}}, http.StatusSwitchingProtocols},
+		{"bad origin", http.Header{"Origin": {"https://evil.example"}, "X-Token": {"ok"}}, http.StatusForbidden},
+		{"unauthorized", http.Header{"Origin": {"https://allowed.example"}}, http.StatusUnauthorized},
+		{"bad version", http.Header{"Sec-Websocket-Version": {"8"}, "X-Token": {"ok"}}, http.StatusUpgradeRequired},
+		{"bad key", http.Header{"Sec-Websocket-Key": {"short"}, "X-Token": {"ok"}}, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		c := dial(t, ts, test.Header)
+		if c.resp.StatusCode != test.ExpectedStatus {
+			t.Errorf("%s: expected status %d but was %d", test.Name, test.ExpectedStatus, c.resp.StatusCode)
+		}
+	}
+}
+
+func TestSameOriginDefault(t *testing.T) {
+	ts := echoServer(t, &Upgrader{})
+
+	host := ts.Listener.Addr().String()
+
+	if c := dial(t, ts, http.Header{"Origin": {"http://" + host}}); c.resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("expected same-origin request to be allowed but got status %d", c.resp.StatusCode)
+	}
+
+	if c := dial(t, ts, http.Header{"Origin": {"http://other.example"}}); c.resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected cross-origin request to be refused but got status %d", c.resp.StatusCode)
+	}
+}
+
+func TestMaxMessageSize(t *testing.T) {
+	ts := echoServer(t, &Upgrader{MaxMessageSize: 8})
+
+	c := dial(t, ts, nil)
+	c.writeFrame(false, opText, []byte("12345"))
+	c.writeFrame(true, opContinuation, []byte("67890"))
+
+	if op, payload := c.readFrame(); op != opClose || binary.BigEndian.Uint16(payload) != CloseMessageTooBig {
+		t.Errorf("expected close frame with code 1009 but got %d %v", op, payload)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	ts := echoServer(t, &Upgrader{})
+
+	c := dial(t, ts, nil)
+	c.writeFrame(true, opText, []byte{0xff, 0xfe})
+
+	if op, payload := c.readFrame(); op != opClose || binary.BigEndian.Uint16(payload) != CloseInvalidPayload {
+		t.Errorf("expected close frame with code 1007 but got %d %v", op, payload)
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	ts := echoServer(t, &Upgrader{PingInterval: 20 * time.Millisecond})
+
+	c := dial(t, ts, nil)
+
+	if op, _ := c.readFrame(); op != opPing {
+		t.Fatalf("expected ping frame but got %d", op)
+	}
+
+	// Without any reply, the server gives up after twice the interval and
+	// closes the connection.
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		var b [1]byte
+		if _, err := c.br.Read(b[:]); err != nil {
+			if err != io.EOF {
+				t.Errorf("expected connection to be closed but got %v", err)
+			}
+			break
+		}
+	}
+}
+
+func TestCloseEchoesCode(t *testing.T) {
+	ts := echoServer(t, &Upgrader{})
+
+	var tests = []struct {
+		Payload []byte
+
+		ExpectedPayload []byte
+	}{
+		{binary.BigEndian.AppendUint16(nil, CloseGoingAway), binary.BigEndian.AppendUint16(nil, CloseGoingAway)},
+		{append(binary.BigEndian.AppendUint16(nil, 4000), "bye"...), binary.BigEndian.AppendUint16(nil, 4000)},
+		{nil, []byte{}},
+	}
+
+	for _, test := range tests {
+		c := dial(t, ts, nil)
+		c.writeFrame(true, opClose, test.Payload)
+
+		if op, payload := c.readFrame(); op != opClose || string(payload) != string(test.ExpectedPayload) {
+			t.Errorf("close with %v: expected close frame %v but got %d %v", test.Payload, test.ExpectedPayload, op, payload)
+		}
+	}
+}
+
+func TestWriteTimeout(t *testing.T) {
+	returned := make(chan error, 1)
+
+	u := &Upgrader{WriteTimeout: 50 * time.Millisecond}
+	m := flow.New()
+	m.Handle("/ws", u.Handler(func(c *Conn, r *http.Request) {
+		msg := make([]byte, 64<<10)
+		for {
+			if err := c.WriteMessage(BinaryMessage, msg); err != nil {
+				returned <- err
+				return
+			}
+		}
+	}), "GET")
+
+	ts := httptest.NewServer(m)
+	t.Cleanup(ts.Close)
+
+	// The client never reads, so the server's writes eventually block.
+	dial(t, ts, nil)
+
+	select {
+	case err := <-returned:
+		if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+			t.Errorf("expected a timeout error but got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WriteMessage blocked on a client which doesn't read")
+	}
+}
@@ -0,0 +1,29 @@
+package flow
+
+import "net/http"
+
+// When returns middleware which applies mw only to requests for which
+// predicate returns true. Other requests are passed directly to the next
+// handler. The predicate is evaluated for every request, so it can depend on
+// anything about the request, for example:
+//
+//	internal := func(r *http.Request) bool {
+//		return strings.HasPrefix(r.RemoteAddr, "10.")
+//	}
+//	mux.Use(flow.When(internal, debugMiddleware))
+//
+// If more than one middleware is given, they are applied in order (as with
+// Use) when the predicate is true.
+func When(predicate func(*http.Request) bool, mw ...func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := wrapMiddleware(next, mw)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if predicate(r) {
+				wrapped.ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
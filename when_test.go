@@ -0,0 +1,48 @@
+package flow
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWhen(t *testing.T) {
+	tag := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Middleware", name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	isJSON := func(r *http.Request) bool {
+		return r.Header.Get("Content-Type") == "application/json"
+	}
+
+	m := New()
+	m.Use(When(isJSON, tag("one"), tag("two")))
+	m.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {}, "POST")
+
+	var tests = []struct {
+		ContentType string
+
+		ExpectedMiddleware string
+	}{
+		{"application/json", "one,two"},
+		{"text/plain", ""},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest("POST", "/", nil)
+		r.Header.Set("Content-Type", test.ContentType)
+		rr := httptest.NewRecorder()
+
+		m.ServeHTTP(rr, r)
+
+		if mw := strings.Join(rr.Header().Values("X-Middleware"), ","); mw != test.ExpectedMiddleware {
+			t.Errorf("%s: expected middleware %q but was %q", test.ContentType, test.ExpectedMiddleware, mw)
+		}
+	}
+}
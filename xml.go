@@ -0,0 +1,129 @@
+package flow
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// XMLCodec is the Codec for application/xml and text/xml, which uses
+// encoding/xml.
+//
+// Decoding is safe to use with untrusted input: documents containing a DOCTYPE
+// declaration are rejected, so they can't define entities (encoding/xml never
+// fetches external entities, but rejecting DTDs entirely avoids any reliance on
+// that), and undefined entities are an error. Besides UTF-8, documents may use
+// the US-ASCII or ISO-8859-1 character sets, declared either in the charset
+// parameter of the request's Content-Type or in the XML declaration. The
+// charset parameter takes precedence, as required by RFC 7303.
+type XMLCodec struct{}
+
+func (XMLCodec) Marshal(v any) ([]byte, error) {
+	b, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), b...), nil
+}
+
+func (XMLCodec) Unmarshal(data []byte, v any) error {
+	return decodeXML(bytes.NewReader(data), "", v)
+}
+
+func (XMLCodec) unmarshalCharset(data []byte, charset string, v any) error {
+	return decodeXML(bytes.NewReader(data), charset, v)
+}
+
+// charsetUnmarshaler is implemented by codecs which handle a non-UTF-8 charset
+// parameter in the request's Content-Type themselves.
+type charsetUnmarshaler interface {
+	unmarshalCharset(data []byte, charset string, v any) error
+}
+
+var errUnsupportedCharset = errors.New("flow: unsupported charset")
+
+// decodeXML decodes the root element of an XML document from r into v. If
+// charset isn't empty, it overrides any encoding given in the XML declaration.
+func decodeXML(r io.Reader, charset string, v any) error {
+	if charset != "" {
+		cr, err := charsetReader(charset, r)
+		if err != nil {
+			return err
+		}
+		r = cr
+	}
+
+	d := xml.NewDecoder(r)
+	d.Strict = true
+	d.CharsetReader = func(label string, input io.Reader) (io.Reader, error) {
+		if charset != "" {
+			// The input has already been converted to UTF-8.
+			return input, nil
+		}
+		return charsetReader(label, input)
+	}
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return errors.New("flow: XML document has no root element")
+			}
+			return err
+		}
+
+		switch tok := tok.(type) {
+		case xml.Directive:
+			return errors.New("flow: XML documents containing a DOCTYPE declaration are not allowed")
+		case xml.StartElement:
+			return d.DecodeElement(v, &tok)
+		}
+	}
+}
+
+func isUTF8Charset(charset string) bool {
+	return charset == "" || strings.EqualFold(charset, "utf-8") || strings.EqualFold(charset, "utf8")
+}
+
+// charsetReader returns a reader which converts from the given charset to
+// UTF-8. Only UTF-8, US-ASCII and ISO-8859-1 (and its common aliases) are
+// supported, to avoid a dependency on golang.org/x/text.
+func charsetReader(charset string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "utf-8", "utf8", "us-ascii", "ascii":
+		return r, nil
+	case "iso-8859-1", "iso8859-1", "latin1", "l1":
+		return &latin1Reader{r: r}, nil
+	default:
+		return nil, fmt.Errorf("%w %q", errUnsupportedCharset, charset)
+	}
+}
+
+// latin1Reader converts ISO-8859-1 to UTF-8. Every byte maps directly to the
+// Unicode code point with the same value.
+type latin1Reader struct {
+	r       io.Reader
+	pending []byte
+}
+
+func (lr *latin1Reader) Read(p []byte) (int, error) {
+	if len(lr.pending) == 0 {
+		// Each input byte expands to at most two bytes of UTF-8.
+		buf := make([]byte, max(len(p)/2, 1))
+		n, err := lr.r.Read(buf)
+		for _, b := range buf[:n] {
+			lr.pending = utf8.AppendRune(lr.pending, rune(b))
+		}
+		if n == 0 {
+			return 0, err
+		}
+	}
+
+	n := copy(p, lr.pending)
+	lr.pending = lr.pending[n:]
+	return n, nil
+}
@@ -0,0 +1,53 @@
+package flow
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestXMLCodecUnmarshal(t *testing.T) {
+	type note struct {
+		To string `xml:"to"`
+	}
+
+	var tests = []struct {
+		Body string
+
+		ExpectedTo    string
+		ExpectedError bool
+	}{
+		{`<note><to>alice</to></note>`, "alice", false},
+		{"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><note><to>Ren\xe9e</to></note>", "Renée", false},
+		{`<?xml version="1.0" encoding="UTF-16"?><note><to>alice</to></note>`, "", true},
+		{`<!DOCTYPE note><note><to>alice</to></note>`, "", true},
+		{`<note><to>&undefined;</to></note>`, "", true},
+		{``, "", true},
+	}
+
+	for _, test := range tests {
+		var n note
+		err := XMLCodec{}.Unmarshal([]byte(test.Body), &n)
+
+		if (err != nil) != test.ExpectedError {
+			t.Errorf("%q: expected error %v but got %v", test.Body, test.ExpectedError, err)
+		}
+
+		if n.To != test.ExpectedTo {
+			t.Errorf("%q: expected to %q but was %q", test.Body, test.ExpectedTo, n.To)
+		}
+	}
+}
+
+func TestLatin1Reader(t *testing.T) {
+	input := strings.Repeat("\xe9\xff\x41", 1000)
+
+	b, err := io.ReadAll(&latin1Reader{r: strings.NewReader(input)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := strings.Repeat("éÿA", 1000); string(b) != expected {
+		t.Errorf("unexpected conversion result")
+	}
+}